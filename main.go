@@ -3,13 +3,18 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"splitzies/persistence"
 	"splitzies/storage"
@@ -19,6 +24,65 @@ import (
 //go:embed swagger/docs.html swagger.yaml
 var swaggerFS embed.FS
 
+// shutdownTimeout bounds how long in-flight requests may take to finish after SIGINT/SIGTERM
+const shutdownTimeout = 30 * time.Second
+
+// newLogger builds the JSON logger used across the server, at the level set by LOG_LEVEL
+// (debug, info, warn, or error; defaults to info)
+func newLogger() *slog.Logger {
+	level := slog.LevelInfo
+	value := os.Getenv("LOG_LEVEL")
+	var invalid bool
+	if value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			level, invalid = slog.LevelInfo, true
+		}
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	if invalid {
+		logger.Warn("Invalid LOG_LEVEL, using default", "value", value, "default", slog.LevelInfo.String())
+	}
+	return logger
+}
+
+// disableAIFromEnv reads DISABLE_AI, falling back to false when unset or invalid
+func disableAIFromEnv(logger *slog.Logger) bool {
+	value := os.Getenv("DISABLE_AI")
+	if value == "" {
+		return false
+	}
+	disabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warn("Invalid DISABLE_AI, using default", "value", value, "default", false)
+		return false
+	}
+	return disabled
+}
+
+// newServeMux mounts the Swagger UI and hands every other path to the API router,
+// so paths matching no route get its JSON 404
+func newServeMux(router http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/", router)
+
+	// Swagger UI - docs.html loads the OpenAPI spec from /swagger.yaml
+	mux.HandleFunc("/swagger/docs.html", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := fs.ReadFile(swaggerFS, "swagger/docs.html")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(data)
+	})
+	mux.HandleFunc("/swagger.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-yaml")
+		data, _ := fs.ReadFile(swaggerFS, "swagger.yaml")
+		w.Write(data)
+	})
+	mux.HandleFunc("/swagger", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/swagger/docs.html", http.StatusFound)
+	})
+	return mux
+}
+
 func main() {
 	ctx := context.Background()
 
@@ -31,9 +95,8 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer persistenceClient.Close(ctx)
 
-	if err := persistenceClient.RunMigrations(ctx, "migrations"); err != nil {
+	if err := persistenceClient.RunMigrations(ctx, persistence.MigrationsDir); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
@@ -45,82 +108,83 @@ func main() {
 	}
 	addr := ":" + port
 
+	logger := newLogger()
+
+	// DISABLE_AI=true runs without Google Cloud credentials for local development: uploads skip OCR and
+	// parsing so items are added by hand, and images are only stored when GCS is configured
+	disableAI := disableAIFromEnv(logger)
+
+	// Left nil, not set to a nil client, when a client isn't created
+	var imageStore tr.ImageStore
+	var ocrEngine tr.OCREngine
+
 	gcsClient, err := storage.NewGCSClient(ctx)
 	if err != nil {
-		log.Fatalf("Failed to create GCS client: %v", err)
+		if !disableAI {
+			log.Fatalf("Failed to create GCS client: %v", err)
+		}
+		logger.Warn("GCS is not configured; uploaded images will not be stored", "error", err)
+	} else {
+		logger.Info("Using GCS bucket", "bucket", gcsClient.BucketName())
+		imageStore = gcsClient
 	}
-	defer gcsClient.Close()
 
-	visionClient, err := storage.NewVisionClient(ctx)
-	if err != nil {
-		log.Fatalf("Failed to create Vision client: %v", err)
+	var visionClient *storage.VisionClient
+	if disableAI {
+		logger.Warn("DISABLE_AI is set; uploads skip OCR and parsing")
+	} else {
+		visionClient, err = storage.NewVisionClient(ctx)
+		if err != nil {
+			log.Fatalf("Failed to create Vision client: %v", err)
+		}
+		ocrEngine = visionClient
 	}
-	defer visionClient.Close()
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
-	httpTransport := tr.NewTransport(logger, persistenceClient, gcsClient, visionClient)
+	httpTransport := tr.NewTransport(logger, persistenceClient, imageStore, ocrEngine)
 
-	http.HandleFunc("/receipts/image", httpTransport.UploadReceiptImageHandler)
+	// Comma-separated list of browser origins allowed to call the API, e.g. "https://app.splitzies.com"
+	cors := tr.CORS(strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ","))
 
-	http.HandleFunc("/receipts/", func(w http.ResponseWriter, r *http.Request) {
-		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	server := &http.Server{Addr: addr, Handler: newServeMux(cors(httpTransport.Router()))}
 
-		// POST /receipts/{receipt_id}/users/{user_id}/items - assign items to user
-		if len(pathParts) == 5 && pathParts[0] == "receipts" && pathParts[2] == "users" && pathParts[4] == "items" && r.Method == http.MethodPost {
-			httpTransport.AssignItemsToUserHandler(w, r)
-			return
-		}
+	// Stop accepting requests on SIGINT/SIGTERM (e.g. during a rolling deploy)
+	signalCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		// /receipts/{receipt_id}/users - GET or POST
-		if len(pathParts) == 3 && pathParts[0] == "receipts" && pathParts[2] == "users" {
-			if r.Method == http.MethodPost {
-				httpTransport.AddUserToReceiptHandler(w, r)
-				return
-			}
-			if r.Method == http.MethodGet {
-				httpTransport.GetReceiptUsersHandler(w, r)
-				return
-			}
-			http.Error(w, tr.NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
-			return
+	go func() {
+		fmt.Printf("Server starting on %s\n", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
 		}
+	}()
+
+	<-signalCtx.Done()
+	stop()
+	logger.Info("Shutdown signal received, draining in-flight requests", "timeout", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("HTTP server did not shut down cleanly", "error", err)
+	} else {
+		logger.Info("HTTP server stopped")
+	}
 
-		// GET /receipts/{receipt_id}/items
-		if len(pathParts) == 3 && pathParts[0] == "receipts" && pathParts[2] == "items" && r.Method == http.MethodGet {
-			httpTransport.GetReceiptItemsHandler(w, r)
-			return
-		}
+	// Uploads return before OCR/parsing finishes; let that background work complete too
+	httpTransport.Wait()
+	logger.Info("Background receipt processing finished")
 
-		// GET /receipts/{receipt_id} - full receipt with users, items, assignments
-		if len(pathParts) == 2 && pathParts[0] == "receipts" && r.Method == http.MethodGet {
-			httpTransport.GetReceiptHandler(w, r)
-			return
+	// Clients are closed only after in-flight requests and processing finish so no upload or transaction is cut off
+	if visionClient != nil {
+		if err := visionClient.Close(); err != nil {
+			logger.Error("Failed to close Vision client", "error", err)
 		}
-
-		// PATCH /receipts/{receipt_id} - update tax/tip (when not parsed from receipt)
-		if len(pathParts) == 2 && pathParts[0] == "receipts" && r.Method == http.MethodPatch {
-			httpTransport.PatchReceiptHandler(w, r)
-			return
+	}
+	if gcsClient != nil {
+		if err := gcsClient.Close(); err != nil {
+			logger.Error("Failed to close GCS client", "error", err)
 		}
-
-		http.NotFound(w, r)
-	})
-
-	// Swagger UI - docs.html loads the OpenAPI spec from /swagger.yaml
-	http.HandleFunc("/swagger/docs.html", func(w http.ResponseWriter, r *http.Request) {
-		data, _ := fs.ReadFile(swaggerFS, "swagger/docs.html")
-		w.Header().Set("Content-Type", "text/html")
-		w.Write(data)
-	})
-	http.HandleFunc("/swagger.yaml", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/x-yaml")
-		data, _ := fs.ReadFile(swaggerFS, "swagger.yaml")
-		w.Write(data)
-	})
-	http.HandleFunc("/swagger", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, "/swagger/docs.html", http.StatusFound)
-	})
-
-	fmt.Printf("Server starting on %s\n", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	}
+	persistenceClient.Close()
+	logger.Info("Shutdown complete")
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tr "splitzies/transport"
+)
+
+func TestServeMuxServesMetrics(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "secret")
+	mux := newServeMux(tr.NewTransport(nil, nil, nil, nil).Router())
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("without the admin key: status = %d, want 403", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("X-Admin-Key", "secret")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("with the admin key: status = %d, want 200", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "splitzies_gemini_parses_total") {
+		t.Errorf("body = %q, want the Gemini metrics", w.Body.String())
+	}
+}
+
+func TestServeMuxNotFoundIsJSON(t *testing.T) {
+	mux := newServeMux(tr.NewTransport(nil, nil, nil, nil).Router())
+	for _, path := range []string{"/", "/foo", "/swagger/nope"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", path, w.Code)
+			continue
+		}
+		var body tr.NotFoundResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Path != path {
+			t.Errorf("%s: body = %+v (%v), want a JSON 404 for the path", path, body, err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger.yaml", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("/swagger.yaml: status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Incremented on every edit; PATCH requests can pass the version they read to detect concurrent edits
+ALTER TABLE receipts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE receipt_items ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE receipt_items DROP COLUMN version;
+ALTER TABLE receipts DROP COLUMN version;
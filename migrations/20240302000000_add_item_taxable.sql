@@ -0,0 +1,6 @@
+-- +goose Up
+-- Untaxed items (e.g. groceries) are excluded when allocating a receipt's tax across users
+ALTER TABLE receipt_items ADD COLUMN taxable BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose Down
+ALTER TABLE receipt_items DROP COLUMN taxable;
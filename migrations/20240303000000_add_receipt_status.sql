@@ -0,0 +1,6 @@
+-- +goose Up
+-- processing while OCR/parsing runs in the background after upload, then ready or failed
+ALTER TABLE receipts ADD COLUMN status TEXT NOT NULL DEFAULT 'ready';
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN status;
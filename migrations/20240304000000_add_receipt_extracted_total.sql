@@ -0,0 +1,6 @@
+-- +goose Up
+-- Total printed on the receipt as read by the parser, compared against the computed grand total
+ALTER TABLE receipts ADD COLUMN extracted_total REAL;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN extracted_total;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Discount lines (coupons, promotions) are stored as items with a negative total_price
+ALTER TABLE receipt_items ADD COLUMN is_discount BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE receipt_items DROP COLUMN is_discount;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Optional line-item category (food, drink, alcohol, service, other) extracted by the AI parser
+ALTER TABLE receipt_items ADD COLUMN category TEXT;
+
+-- +goose Down
+ALTER TABLE receipt_items DROP COLUMN category;
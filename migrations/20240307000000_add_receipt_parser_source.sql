@@ -0,0 +1,8 @@
+-- +goose Up
+-- Which parser (vision_gemini, documentai, regex) and model produced a receipt's items, for comparing parse quality
+ALTER TABLE receipts ADD COLUMN parser_source TEXT;
+ALTER TABLE receipts ADD COLUMN model_version TEXT;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN model_version;
+ALTER TABLE receipts DROP COLUMN parser_source;
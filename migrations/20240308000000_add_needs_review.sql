@@ -0,0 +1,8 @@
+-- +goose Up
+-- Set when the parser read an implausible price or quantity (e.g. a misread "$9,999,999")
+ALTER TABLE receipt_items ADD COLUMN needs_review BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE receipts ADD COLUMN needs_review BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN needs_review;
+ALTER TABLE receipt_items DROP COLUMN needs_review;
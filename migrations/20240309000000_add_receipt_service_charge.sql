@@ -0,0 +1,6 @@
+-- +goose Up
+-- Automatic service charge or included gratuity, kept apart from the voluntary tip
+ALTER TABLE receipts ADD COLUMN service_charge REAL;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN service_charge;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Whether tax and tip were read by the parser or entered by a user; NULL while the value is unset
+ALTER TABLE receipts ADD COLUMN tax_source TEXT;
+ALTER TABLE receipts ADD COLUMN tip_source TEXT;
+UPDATE receipts SET tax_source = 'parsed' WHERE tax IS NOT NULL;
+UPDATE receipts SET tip_source = 'parsed' WHERE tip IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN tip_source;
+ALTER TABLE receipts DROP COLUMN tax_source;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Uploaded image metadata for analytics and OCR/thumbnail tuning; width and height are NULL when the header couldn't be decoded
+ALTER TABLE receipts ADD COLUMN image_width INTEGER;
+ALTER TABLE receipts ADD COLUMN image_height INTEGER;
+ALTER TABLE receipts ADD COLUMN image_size_bytes BIGINT;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN image_size_bytes;
+ALTER TABLE receipts DROP COLUMN image_height;
+ALTER TABLE receipts DROP COLUMN image_width;
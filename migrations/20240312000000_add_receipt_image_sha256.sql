@@ -0,0 +1,8 @@
+-- +goose Up
+-- SHA-256 of the uploaded image bytes, so re-uploads of the same photo return the existing receipt
+ALTER TABLE receipts ADD COLUMN image_sha256 TEXT;
+CREATE INDEX idx_receipts_image_sha256 ON receipts(image_sha256);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_receipts_image_sha256;
+ALTER TABLE receipts DROP COLUMN image_sha256;
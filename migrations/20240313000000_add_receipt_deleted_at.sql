@@ -0,0 +1,6 @@
+-- +goose Up
+-- Soft delete: deleted receipts keep their rows for audit and recovery but are hidden from reads
+ALTER TABLE receipts ADD COLUMN deleted_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN deleted_at;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Shared items (delivery fees, unclaimed appetizers) are split evenly among every user on the receipt
+ALTER TABLE receipt_items ADD COLUMN shared BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE receipt_items DROP COLUMN shared;
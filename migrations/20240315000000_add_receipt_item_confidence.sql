@@ -0,0 +1,6 @@
+-- +goose Up
+-- How sure the parser was of each line item (0-1); only Document AI reports one
+ALTER TABLE receipt_items ADD COLUMN confidence REAL;
+
+-- +goose Down
+ALTER TABLE receipt_items DROP COLUMN confidence;
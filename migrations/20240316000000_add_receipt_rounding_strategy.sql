@@ -0,0 +1,6 @@
+-- +goose Up
+-- Who absorbs the leftover cents when an item is split equally: first (earliest assigned), payer, or largest-share
+ALTER TABLE receipts ADD COLUMN rounding_strategy TEXT NOT NULL DEFAULT 'first';
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN rounding_strategy;
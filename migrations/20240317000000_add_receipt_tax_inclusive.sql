@@ -0,0 +1,6 @@
+-- +goose Up
+-- Whether item prices already include tax (e.g. "VAT included"), so the tax line is not added on top
+ALTER TABLE receipts ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN tax_inclusive;
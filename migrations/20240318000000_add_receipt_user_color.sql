@@ -0,0 +1,18 @@
+-- +goose Up
+-- Hex color the UI shows a user in; users without one get the next palette color for their receipt.
+-- Existing users are colored from the same palette (persistence.userColors) in the order they were added.
+ALTER TABLE receipt_users ADD COLUMN color TEXT;
+
+UPDATE receipt_users ru
+SET color = (ARRAY['#E53935', '#1E88E5', '#43A047', '#FB8C00', '#8E24AA', '#00ACC1',
+                   '#FDD835', '#6D4C41', '#D81B60', '#3949AB', '#7CB342', '#546E7A'])[(ordered.position - 1) % 12 + 1]
+FROM (
+  SELECT id, ROW_NUMBER() OVER (PARTITION BY receipt_id ORDER BY created_at, id) AS position
+  FROM receipt_users
+) ordered
+WHERE ordered.id = ru.id;
+
+ALTER TABLE receipt_users ALTER COLUMN color SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE receipt_users DROP COLUMN color;
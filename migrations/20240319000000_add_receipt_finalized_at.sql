@@ -0,0 +1,6 @@
+-- +goose Up
+-- When the group froze the split; items, assignments, users, and tax/tip can't be edited while set
+ALTER TABLE receipts ADD COLUMN finalized_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN finalized_at;
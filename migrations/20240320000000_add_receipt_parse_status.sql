@@ -0,0 +1,6 @@
+-- +goose Up
+-- How reading an uploaded image went: ok, ocr_failed, parse_failed, or no_items; NULL until processed and for receipts without an upload
+ALTER TABLE receipts ADD COLUMN parse_status TEXT;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN parse_status;
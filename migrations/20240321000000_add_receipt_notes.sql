@@ -0,0 +1,6 @@
+-- +goose Up
+-- Free-form memo, e.g. "Birthday dinner, Alice covered Bob's share"
+ALTER TABLE receipts ADD COLUMN notes TEXT;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN notes;
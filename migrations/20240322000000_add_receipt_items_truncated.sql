@@ -0,0 +1,6 @@
+-- +goose Up
+-- Parsing found more items than MAX_RECEIPT_ITEMS and only the first were saved
+ALTER TABLE receipts ADD COLUMN items_truncated BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN items_truncated;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- How the receipt is split: itemized (by item assignments) or equal (the grand total divided evenly among all users)
+ALTER TABLE receipts ADD COLUMN split_mode TEXT NOT NULL DEFAULT 'itemized';
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN split_mode;
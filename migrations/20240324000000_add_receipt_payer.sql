@@ -0,0 +1,14 @@
+-- +goose Up
+-- The user who paid the bill; under the payer rounding strategy they absorb the leftover cents of equal splits.
+-- Cleared when the user is removed (e.g. by a reset).
+ALTER TABLE receipts ADD COLUMN payer_user_id VARCHAR(26) REFERENCES receipt_users(id) ON DELETE SET NULL;
+
+-- payer rounding used to mean the receipt's first user, so receipts already using it keep that user as the payer
+UPDATE receipts r
+SET payer_user_id = (
+  SELECT ru.id FROM receipt_users ru WHERE ru.receipt_id = r.id ORDER BY ru.created_at, ru.id LIMIT 1
+)
+WHERE r.rounding_strategy = 'payer';
+
+-- +goose Down
+ALTER TABLE receipts DROP COLUMN payer_user_id;
@@ -1,8 +1,10 @@
 package money
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"reflect"
 	"strings"
 
 	"github.com/Rhymond/go-money"
@@ -11,15 +13,79 @@ import (
 // Amount represents a monetary value with currency-aware decimal precision for JSON marshaling.
 // Uses go-money for ISO 4217 currency support (e.g. USD=2, KWD=3, JPY=0 decimal places).
 type Amount struct {
-	Value    float64
-	Currency *string
+	Value     float64
+	Currency  *string
+	Formatted bool // Marshal as {"value": 12.95, "formatted": "$12.95"} instead of a bare number
 }
 
 // MarshalJSON implements json.Marshaler to output clean decimal format (e.g. 12.95 not 12.950000762939453).
+// With Formatted set, the number is wrapped in an object alongside its Display string.
 func (a Amount) MarshalJSON() ([]byte, error) {
 	decimals := DecimalPlaces(a.Currency)
 	format := fmt.Sprintf("%%.%df", decimals)
-	return []byte(fmt.Sprintf(format, a.Value)), nil
+	value := fmt.Sprintf(format, a.Value)
+	if !a.Formatted {
+		return []byte(value), nil
+	}
+	display, err := json.Marshal(a.Display())
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf(`{"value":%s,"formatted":%s}`, value, display)), nil
+}
+
+// Display formats the amount with the currency's symbol, separators, and placement (e.g. "$1,021.95", "¥1,000").
+// Unknown currencies fall back to the amount followed by the code.
+func (a Amount) Display() string {
+	code := money.USD
+	if a.Currency != nil && strings.TrimSpace(*a.Currency) != "" {
+		code = strings.ToUpper(strings.TrimSpace(*a.Currency))
+	}
+	// go-money's NewFromFloat truncates (21.95 -> 21.94), so round to minor units here
+	minorUnits := int64(math.Round(a.Value * math.Pow10(DecimalPlaces(&code))))
+	return money.New(minorUnits, code).Display()
+}
+
+// SetFormatted sets Formatted on every Amount reachable from v (a pointer), through nested structs,
+// pointers, slices, and maps, so a whole response marshals with display strings
+func SetFormatted(v any) {
+	setFormatted(reflect.ValueOf(v))
+}
+
+var amountType = reflect.TypeOf(Amount{})
+
+func setFormatted(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			setFormatted(v.Elem())
+		}
+	case reflect.Struct:
+		if v.Type() == amountType {
+			if v.CanSet() {
+				v.FieldByName("Formatted").SetBool(true)
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				setFormatted(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			setFormatted(v.Index(i))
+		}
+	case reflect.Map:
+		// Map values aren't addressable, so update a copy and store it back
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			setFormatted(value)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
 }
 
 // DecimalPlaces returns the number of decimal places for the currency per ISO 4217.
@@ -44,6 +110,23 @@ func Round(value float64, currency *string) float64 {
 	return math.Round(value*scale) / scale
 }
 
+// RoundUpTo rounds value up to the next multiple of step (e.g. step 1 or 5 for whole dollars, 100 for yen),
+// working in the currency's minor units so floating-point drift can't push it a step too far.
+// A value already on a multiple is returned unchanged. Returns an error unless step is at least one minor unit.
+func RoundUpTo(value, step float64, currency *string) (float64, error) {
+	scale := math.Pow10(DecimalPlaces(currency))
+	stepUnits := int64(math.Round(step * scale))
+	if stepUnits <= 0 || math.IsNaN(step) || math.IsInf(step, 0) {
+		return 0, fmt.Errorf("step must be at least one minor currency unit")
+	}
+	units := int64(math.Round(value * scale))
+	rounded := units / stepUnits * stepUnits
+	if rounded < units {
+		rounded += stepUnits
+	}
+	return float64(rounded) / scale, nil
+}
+
 // NewAmount creates an Amount for JSON marshaling with currency-aware precision.
 func NewAmount(value float64, currency *string) Amount {
 	return Amount{
@@ -60,3 +143,28 @@ func Ptr(value *float64, currency *string) *Amount {
 	a := NewAmount(*value, currency)
 	return &a
 }
+
+// KnownCurrency reports whether code is an ISO 4217 currency code (case-insensitive)
+func KnownCurrency(code string) bool {
+	return money.GetCurrency(strings.ToUpper(strings.TrimSpace(code))) != nil
+}
+
+// Convert converts amount in currency from to currency to, where rate is units of to per unit of from.
+// The result is rounded to the target currency's decimal places (e.g. JPY has none).
+// Returns an error for a non-positive rate or an unknown currency code.
+func Convert(amount float64, from, to string, rate float64) (Amount, error) {
+	from, to = strings.ToUpper(strings.TrimSpace(from)), strings.ToUpper(strings.TrimSpace(to))
+	if !KnownCurrency(from) {
+		return Amount{}, fmt.Errorf("unknown currency %q", from)
+	}
+	if !KnownCurrency(to) {
+		return Amount{}, fmt.Errorf("unknown currency %q", to)
+	}
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return Amount{}, fmt.Errorf("exchange rate must be a positive number")
+	}
+	if from == to {
+		rate = 1
+	}
+	return NewAmount(amount*rate, &to), nil
+}
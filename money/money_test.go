@@ -60,3 +60,114 @@ func TestNewAmountPreservesDecimals(t *testing.T) {
 		t.Errorf("NewAmount(21.95) marshaled as %q, want \"21.95\"", string(b))
 	}
 }
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  float64
+		from    string
+		to      string
+		rate    float64
+		want    float64
+		wantErr bool
+	}{
+		{name: "EUR to USD", amount: 20.00, from: "EUR", to: "USD", rate: 1.0875, want: 21.75},
+		{name: "rounds to target decimals", amount: 12.34, from: "USD", to: "JPY", rate: 151.37, want: 1868},
+		{name: "three decimal target", amount: 10.00, from: "USD", to: "KWD", rate: 0.30712, want: 3.071},
+		{name: "codes are case-insensitive", amount: 10.00, from: "usd", to: " eur ", rate: 0.9, want: 9.00},
+		{name: "same currency ignores rate", amount: 10.00, from: "USD", to: "USD", rate: 2, want: 10.00},
+		{name: "zero rate", amount: 10.00, from: "USD", to: "EUR", rate: 0, wantErr: true},
+		{name: "unknown currency", amount: 10.00, from: "USD", to: "XYZ", rate: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.amount, tt.from, tt.to, tt.rate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Value != tt.want {
+				t.Errorf("Convert() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmountDisplay(t *testing.T) {
+	usd, jpy, eur, unknown := "USD", "JPY", "eur", "XYZ"
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{NewAmount(21.95, &usd), "$21.95"},
+		{NewAmount(1021.95, &usd), "$1,021.95"},
+		{NewAmount(-5, &usd), "-$5.00"},
+		{NewAmount(1000, &jpy), "¥1,000"},
+		{NewAmount(12.5, &eur), "€12.50"},
+		{NewAmount(3, nil), "$3.00"},
+		{NewAmount(3, &unknown), "3.00XYZ"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Display(); got != tt.want {
+			t.Errorf("Display(%v %v) = %q, want %q", tt.amount.Value, tt.amount.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestSetFormatted(t *testing.T) {
+	usd := "USD"
+	type line struct {
+		Amount Amount `json:"amount"`
+	}
+	response := struct {
+		Total  Amount            `json:"total"`
+		Tip    *Amount           `json:"tip"`
+		Lines  []line            `json:"lines"`
+		ByUser map[string]Amount `json:"by_user"`
+	}{
+		Total:  NewAmount(21.95, &usd),
+		Tip:    Ptr(new(float64), &usd),
+		Lines:  []line{{Amount: NewAmount(1.5, &usd)}},
+		ByUser: map[string]Amount{"alice": NewAmount(10, &usd)},
+	}
+
+	plain, _ := json.Marshal(response)
+	if want := `{"total":21.95,"tip":0.00,"lines":[{"amount":1.50}],"by_user":{"alice":10.00}}`; string(plain) != want {
+		t.Errorf("default marshal = %s, want %s", plain, want)
+	}
+
+	SetFormatted(&response)
+	formatted, _ := json.Marshal(response)
+	want := `{"total":{"value":21.95,"formatted":"$21.95"},"tip":{"value":0.00,"formatted":"$0.00"},` +
+		`"lines":[{"amount":{"value":1.50,"formatted":"$1.50"}}],"by_user":{"alice":{"value":10.00,"formatted":"$10.00"}}}`
+	if string(formatted) != want {
+		t.Errorf("formatted marshal = %s, want %s", formatted, want)
+	}
+}
+
+func TestRoundUpTo(t *testing.T) {
+	usd, jpy := "USD", "JPY"
+	tests := []struct {
+		value    float64
+		step     float64
+		currency *string
+		want     float64
+		wantErr  bool
+	}{
+		{value: 43.21, step: 1, currency: &usd, want: 44},
+		{value: 43.21, step: 5, currency: &usd, want: 45},
+		{value: 45.00, step: 5, currency: &usd, want: 45},
+		{value: 0.1 + 0.2, step: 0.05, currency: &usd, want: 0.30},
+		{value: 1234, step: 100, currency: &jpy, want: 1300},
+		{value: 10, step: 0, currency: &usd, wantErr: true},
+		{value: 10, step: 0.001, currency: &usd, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := RoundUpTo(tt.value, tt.step, tt.currency)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("RoundUpTo(%v, %v) error = %v, wantErr %v", tt.value, tt.step, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("RoundUpTo(%v, %v) = %v, want %v", tt.value, tt.step, got, tt.want)
+		}
+	}
+}
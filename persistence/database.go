@@ -2,20 +2,22 @@ package persistence
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 )
 
-var DB *pgx.Conn
-
-// Client wraps the database connection for use by handlers.
+// Client wraps a database connection pool for use by handlers and background receipt processing,
+// which run concurrently.
 type Client struct {
-	db *pgx.Conn
+	db *pgxpool.Pool
 }
 
 // NewClient creates a new persistence client and connects to the database.
@@ -24,53 +26,51 @@ func NewClient(ctx context.Context, databaseURL string) (*Client, error) {
 		return nil, fmt.Errorf("DATABASE_URL environment variable is required")
 	}
 
-	conn, err := pgx.Connect(ctx, databaseURL)
+	pool, err := pgxpool.New(ctx, databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
 
-	// Set global DB for receipt.go (SaveReceipt) which uses package-level DB
-	DB = conn
-
 	var version string
-	if err := conn.QueryRow(ctx, "SELECT version()").Scan(&version); err != nil {
-		conn.Close(ctx)
+	if err := pool.QueryRow(ctx, "SELECT version()").Scan(&version); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
 	log.Printf("Connected to: %s\n", version)
-	return &Client{db: conn}, nil
+	return &Client{db: pool}, nil
 }
 
-// Close closes the database connection.
-func (c *Client) Close(ctx context.Context) error {
+// Close closes every connection in the pool, waiting for connections in use to be released.
+func (c *Client) Close() {
 	if c.db != nil {
-		DB = nil
-		return c.db.Close(ctx)
+		c.db.Close()
 	}
-	return nil
 }
 
-// RunMigrations runs all pending database migrations using goose.
-func (c *Client) RunMigrations(ctx context.Context, migrationsDir string) error {
+// MigrationsDir is the directory of goose migration files, relative to the working directory
+const MigrationsDir = "migrations"
+
+// newMigrationProvider opens a *sql.DB for goose and creates a provider over the migrations in migrationsDir.
+// The caller must close the returned *sql.DB.
+func (c *Client) newMigrationProvider(migrationsDir string) (*goose.Provider, *sql.DB, error) {
 	if c.db == nil {
-		return fmt.Errorf("database connection not initialized")
+		return nil, nil, fmt.Errorf("database connection not initialized")
 	}
 
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
-		return fmt.Errorf("DATABASE_URL environment variable is required")
+		return nil, nil, fmt.Errorf("DATABASE_URL environment variable is required")
 	}
 
-	// Convert pgx connection to *sql.DB for goose
+	// Open a separate *sql.DB for goose
 	config, err := pgx.ParseConfig(databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to parse database URL: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse database URL: %w", err)
 	}
 
 	// Create a *sql.DB using pgx stdlib driver
 	sqlDB := stdlib.OpenDB(*config)
-	defer sqlDB.Close()
 
 	// Create filesystem from migrations directory
 	migrationsFS := os.DirFS(migrationsDir)
@@ -78,8 +78,19 @@ func (c *Client) RunMigrations(ctx context.Context, migrationsDir string) error
 	// Use the Provider API which properly handles .up.sql and .down.sql pairing
 	provider, err := goose.NewProvider(goose.DialectPostgres, sqlDB, migrationsFS)
 	if err != nil {
-		return fmt.Errorf("failed to create goose provider: %w", err)
+		sqlDB.Close()
+		return nil, nil, fmt.Errorf("failed to create goose provider: %w", err)
 	}
+	return provider, sqlDB, nil
+}
+
+// RunMigrations runs all pending database migrations using goose.
+func (c *Client) RunMigrations(ctx context.Context, migrationsDir string) error {
+	provider, sqlDB, err := c.newMigrationProvider(migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
 
 	// Run migrations up
 	results, err := provider.Up(ctx)
@@ -100,3 +111,54 @@ func (c *Client) RunMigrations(ctx context.Context, migrationsDir string) error
 
 	return nil
 }
+
+// MigrationInfo is one migration file and whether it has been applied
+type MigrationInfo struct {
+	Version   int64
+	Path      string
+	Applied   bool
+	AppliedAt *time.Time // nil when pending
+}
+
+// MigrationReport is the database schema version and the state of every known migration
+type MigrationReport struct {
+	CurrentVersion int64
+	Migrations     []MigrationInfo // In version order
+}
+
+// MigrationStatus reports the database's current schema version and which migrations in migrationsDir
+// have been applied. It only reads; nothing is migrated.
+func (c *Client) MigrationStatus(ctx context.Context, migrationsDir string) (*MigrationReport, error) {
+	provider, sqlDB, err := c.newMigrationProvider(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlDB.Close()
+
+	version, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database version: %w", err)
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	report := &MigrationReport{CurrentVersion: version, Migrations: make([]MigrationInfo, 0, len(statuses))}
+	for _, status := range statuses {
+		if status.Source == nil {
+			continue
+		}
+		info := MigrationInfo{
+			Version: status.Source.Version,
+			Path:    status.Source.Path,
+			Applied: status.State == goose.StateApplied,
+		}
+		if info.Applied {
+			appliedAt := status.AppliedAt
+			info.AppliedAt = &appliedAt
+		}
+		report.Migrations = append(report.Migrations, info)
+	}
+	return report, nil
+}
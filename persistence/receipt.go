@@ -5,11 +5,30 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/oklog/ulid/v2"
 )
 
+// Receipt statuses. Uploaded receipts are processing until background OCR/parsing finishes.
+const (
+	ReceiptStatusProcessing = "processing"
+	ReceiptStatusReady      = "ready"
+	ReceiptStatusFailed     = "failed"
+)
+
+// Parse statuses, recording how reading an uploaded image went so clients can tell a failed read from a
+// receipt that has no items
+const (
+	ParseStatusOK          = "ok"           // Items were parsed
+	ParseStatusOCRFailed   = "ocr_failed"   // No text could be read from the image
+	ParseStatusParseFailed = "parse_failed" // Every parser failed to turn the text into items
+	ParseStatusNoItems     = "no_items"     // Parsing succeeded but found no items, or was skipped (OCR only or AI disabled)
+	ParseStatusTimeout     = "timeout"      // OCR or parsing ran past PARSE_TIMEOUT
+)
+
 // Receipt represents a receipt in the database
 type Receipt struct {
 	ID          string
@@ -19,12 +38,63 @@ type Receipt struct {
 	Currency    *string
 	ReceiptDate *time.Time
 	Title       *string
+	Version     int     // Incremented on every edit, for optimistic concurrency
+	Status      string  // One of ReceiptStatusProcessing, ReceiptStatusReady, ReceiptStatusFailed
+	ParseStatus *string // One of the ParseStatus constants; nil until processed and for receipts without an upload
 	Items       []ReceiptItem
 }
 
 // OCRTextData represents the OCR text data stored as JSONB
 type OCRTextData struct {
-	Text string `json:"text"`
+	Text   string `json:"text"`
+	Engine string `json:"engine,omitempty"` // Vision feature that produced Text
+	Parser string `json:"parser,omitempty"` // What turned Text into items: gemini, documentai, regex, or none
+}
+
+// Parser sources recorded for each receipt, in fallback order
+const (
+	ParserSourceVisionGemini = "vision_gemini"
+	ParserSourceDocumentAI   = "documentai"
+	ParserSourceRegex        = "regex"
+)
+
+// ImageMetadata describes an uploaded receipt image
+type ImageMetadata struct {
+	Width     *int // nil when the image header couldn't be decoded
+	Height    *int
+	SizeBytes int64
+	SHA256    string // Hex digest of the uploaded bytes, for spotting re-uploads
+}
+
+// Where a receipt's tax or tip came from
+const (
+	ValueSourceParsed = "parsed" // Read from the receipt by a parser
+	ValueSourceManual = "manual" // Entered or confirmed by a user via PATCH
+)
+
+// Rounding strategies: who absorbs the leftover cents when an item is split equally
+const (
+	RoundingFirst        = "first"         // The users assigned earliest
+	RoundingPayer        = "payer"         // The receipt's payer user
+	RoundingLargestShare = "largest-share" // The users with the largest subtotals
+)
+
+// Rounding is a receipt's rounding strategy with the payer it refers to
+type Rounding struct {
+	Strategy    string  // RoundingFirst, RoundingPayer, or RoundingLargestShare; empty means RoundingFirst
+	PayerUserID *string // Who paid the bill; nil until set via PATCH
+}
+
+// Split modes: how a receipt's grand total is divided among its users
+const (
+	SplitModeItemized = "itemized" // By item assignments
+	SplitModeEqual    = "equal"    // Evenly among all users, whatever is assigned
+)
+
+// ParserInfo records which parser produced a receipt's items, for comparing parse quality in SQL
+type ParserInfo struct {
+	Source       string  // One of the ParserSource constants
+	ModelVersion *string // Gemini model or Document AI processor; nil for the regex parser
 }
 
 // Value implements driver.Valuer for JSONB storage
@@ -52,6 +122,29 @@ func (o *OCRTextData) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, o)
 }
 
+// GetReceiptOCRText gets the OCR text stored for a receipt at upload time.
+// Returns nil (and no error) if the receipt exists but no OCR text was saved, and a "receipt not found" error
+// when it is absent or deleted.
+func (c *Client) GetReceiptOCRText(ctx context.Context, receiptID string) (*OCRTextData, error) {
+	var ocrTextJSON []byte
+	err := c.db.QueryRow(ctx, "SELECT ocr_text FROM receipts WHERE id = $1 AND deleted_at IS NULL", receiptID).Scan(&ocrTextJSON)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("receipt not found")
+		}
+		return nil, fmt.Errorf("failed to get receipt OCR text: %w", err)
+	}
+	if len(ocrTextJSON) == 0 {
+		return nil, nil
+	}
+
+	ocrText := &OCRTextData{}
+	if err := json.Unmarshal(ocrTextJSON, ocrText); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal OCR text: %w", err)
+	}
+	return ocrText, nil
+}
+
 // ReceiptItem represents a receipt item in the database
 type ReceiptItem struct {
 	ID           string
@@ -60,55 +153,153 @@ type ReceiptItem struct {
 	Quantity     int
 	TotalPrice   float64
 	PricePerItem float64
+	Version      int      // Incremented on every edit, for optimistic concurrency
+	Taxable      bool     // Only taxable items count toward a user's share of tax
+	IsDiscount   bool     // Coupon or promotion; TotalPrice is negative
+	Category     *string  // food, drink, alcohol, service, or other; nil when the parser was unsure
+	NeedsReview  bool     // The parser read an implausible price or quantity
+	Shared       bool     // Split evenly among every user on the receipt, without explicit assignments
+	Confidence   *float64 // How sure the parser was of the line (0-1); nil unless Document AI parsed it
 }
 
-// SaveReceipt saves a receipt with its items to the database
-// imageURL is optional - pass nil if no image is provided
-// ocrText is optional - pass nil if no OCR text is provided
-// tax and tip are optional - parsed from receipt or can be set via PATCH later
-func SaveReceipt(items []ReceiptItemDB, imageURL *string, ocrText *OCRTextData, currency *string, receiptDate *time.Time, title *string, tax *float64, tip *float64) (*Receipt, error) {
-	ctx := context.Background()
-	if DB == nil {
-		return nil, fmt.Errorf("database not initialized")
+// CreateProcessingReceipt saves a receipt with just its image, in processing status.
+// Items and parsed metadata are added later by CompleteReceiptProcessing.
+func (c *Client) CreateProcessingReceipt(ctx context.Context, receiptID string, imageURL *string, image *ImageMetadata) (*Receipt, error) {
+	receipt := &Receipt{
+		ID:       receiptID,
+		ImageURL: imageURL,
+		Version:  1,
+		Status:   ReceiptStatusProcessing,
+		Items:    []ReceiptItem{},
 	}
+	var width, height *int
+	var sizeBytes *int64
+	var sha256 *string
+	if image != nil {
+		width, height, sizeBytes = image.Width, image.Height, &image.SizeBytes
+		if image.SHA256 != "" {
+			sha256 = &image.SHA256
+		}
+	}
+	err := c.db.QueryRow(ctx, `
+		INSERT INTO receipts (id, created_at, image_url, status, image_width, image_height, image_size_bytes, image_sha256)
+		VALUES ($1, CURRENT_TIMESTAMP, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`, receiptID, imageURL, ReceiptStatusProcessing, width, height, sizeBytes, sha256).Scan(&receipt.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert receipt: %w", err)
+	}
+	return receipt, nil
+}
 
-	// Generate ULID for receipt
-	receiptID := ulid.Make().String()
-
-	// Start a transaction
-	tx, err := DB.Begin(ctx)
+// GetReceiptByImageHash finds the earliest receipt uploaded with the given image SHA-256 that hasn't failed
+// or been deleted, so a failed or deleted upload can be retried with the same photo. Returns nil (and no error) if there is none.
+// Items are not loaded.
+func (c *Client) GetReceiptByImageHash(ctx context.Context, sha256 string) (*Receipt, error) {
+	receipt := &Receipt{Items: []ReceiptItem{}}
+	err := c.db.QueryRow(ctx, `
+		SELECT id, created_at, image_url, currency, receipt_date, title, version, status, parse_status
+		FROM receipts
+		WHERE image_sha256 = $1 AND status <> $2 AND deleted_at IS NULL
+		ORDER BY created_at ASC, id ASC
+		LIMIT 1
+	`, sha256, ReceiptStatusFailed).Scan(&receipt.ID, &receipt.CreatedAt, &receipt.ImageURL, &receipt.Currency, &receipt.ReceiptDate, &receipt.Title, &receipt.Version, &receipt.Status, &receipt.ParseStatus)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get receipt by image hash: %w", err)
 	}
-	defer tx.Rollback(ctx)
+	return receipt, nil
+}
 
-	// Convert OCRTextData to JSONB
+// CompleteReceiptProcessing stores the parsed items and metadata for a processing receipt and marks it ready.
+// Tax, tip, and service charge already set via PATCH while processing are kept rather than overwritten by parsed values;
+// tax and tip that are filled in here are marked as parsed.
+// extractedTotal is the total printed on the receipt, when the parser read one.
+// taxInclusive marks item prices as already including tax; it never clears a flag set via PATCH.
+// parser is nil when items were not parsed (OCR only). parseStatus is one of the ParseStatus constants.
+// itemsTruncated records that parsing found more items than the cap and only these were kept.
+// Returns the inserted items.
+func (c *Client) CompleteReceiptProcessing(ctx context.Context, receiptID string, items []ReceiptItemDB, ocrText *OCRTextData, currency *string, receiptDate *time.Time, title *string, tax, tip, serviceCharge, extractedTotal *float64, taxInclusive bool, parser *ParserInfo, parseStatus string, itemsTruncated bool) ([]ReceiptItem, error) {
 	var ocrTextJSON []byte
 	if ocrText != nil {
+		var err error
 		ocrTextJSON, err = json.Marshal(ocrText)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal OCR text: %w", err)
 		}
 	}
 
-	// Insert receipt with generated ULID, optional image URL, optional OCR text, Gemini metadata, and tax/tip if parsed
-	_, err = tx.Exec(ctx, "INSERT INTO receipts (id, created_at, image_url, ocr_text, currency, receipt_date, title, tax, tip) VALUES ($1, CURRENT_TIMESTAMP, $2, $3, $4, $5, $6, $7, $8)", receiptID, imageURL, ocrTextJSON, currency, receiptDate, title, tax, tip)
+	tx, err := c.db.Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert receipt: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
+
+	parserSource, modelVersion := parser.columns()
+	tag, err := tx.Exec(ctx, `
+		UPDATE receipts
+		SET ocr_text = $2, currency = $3, receipt_date = $4, title = $5,
+			tax = COALESCE(tax, $6), tip = COALESCE(tip, $7), extracted_total = $8,
+			tax_source = CASE WHEN tax IS NULL AND $6 IS NOT NULL THEN $15 ELSE tax_source END,
+			tip_source = CASE WHEN tip IS NULL AND $7 IS NOT NULL THEN $15 ELSE tip_source END,
+			parser_source = $11, model_version = $12, needs_review = $13, service_charge = COALESCE(service_charge, $14),
+			tax_inclusive = tax_inclusive OR $16, status = $9, parse_status = $17, items_truncated = $18, version = version + 1
+		WHERE id = $1 AND status = $10
+	`, receiptID, ocrTextJSON, currency, receiptDate, title, tax, tip, extractedTotal, ReceiptStatusReady, ReceiptStatusProcessing, parserSource, modelVersion, anyNeedsReview(items), serviceCharge, ValueSourceParsed, taxInclusive, parseStatus, itemsTruncated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update receipt: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("receipt not found or not processing")
+	}
+
+	dbItems, err := insertReceiptItems(ctx, tx, receiptID, items)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return dbItems, nil
+}
+
+// columns returns the parser_source and model_version column values; both nil for a nil ParserInfo
+func (p *ParserInfo) columns() (*string, *string) {
+	if p == nil {
+		return nil, nil
+	}
+	return &p.Source, p.ModelVersion
+}
+
+// FailReceiptProcessing marks a receipt as failed, recording why reading its image failed.
+// A nil parseStatus leaves parse_status as it was, for failures after parsing (e.g. saving the items).
+func (c *Client) FailReceiptProcessing(ctx context.Context, receiptID string, parseStatus *string) error {
+	tag, err := c.db.Exec(ctx, "UPDATE receipts SET status = $2, parse_status = COALESCE($3, parse_status) WHERE id = $1", receiptID, ReceiptStatusFailed, parseStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update receipt status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("receipt not found")
+	}
+	return nil
+}
 
+// insertReceiptItems inserts items for a receipt within tx and returns them with their generated IDs.
+// The inserts are sent as one batch, so a parsed receipt's items cost a single round trip.
+func insertReceiptItems(ctx context.Context, tx pgx.Tx, receiptID string, items []ReceiptItemDB) ([]ReceiptItem, error) {
 	dbItems := make([]ReceiptItem, 0, len(items))
+	batch := &pgx.Batch{}
 	for _, item := range items {
 		// Generate ULID for each item
 		itemID := ulid.Make().String()
 
-		_, err := tx.Exec(ctx, `
-			INSERT INTO receipt_items (id, receipt_id, name, quantity, total_price, price_per_item)
-			VALUES ($1, $2, $3, $4, $5, $6)
-		`, itemID, receiptID, item.Name, item.Quantity, item.TotalPrice, item.PricePerItem)
-		if err != nil {
-			return nil, fmt.Errorf("failed to insert receipt item: %w", err)
-		}
+		batch.Queue(`
+			INSERT INTO receipt_items (id, receipt_id, name, quantity, total_price, price_per_item, is_discount, category, needs_review, confidence)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, itemID, receiptID, item.Name, item.Quantity, item.TotalPrice, item.PricePerItem, item.IsDiscount, item.Category, item.NeedsReview, item.Confidence)
 
 		dbItems = append(dbItems, ReceiptItem{
 			ID:           itemID,
@@ -117,57 +308,306 @@ func SaveReceipt(items []ReceiptItemDB, imageURL *string, ocrText *OCRTextData,
 			Quantity:     item.Quantity,
 			TotalPrice:   item.TotalPrice,
 			PricePerItem: item.PricePerItem,
+			Version:      1,
+			Taxable:      true,
+			IsDiscount:   item.IsDiscount,
+			Category:     item.Category,
+			NeedsReview:  item.NeedsReview,
+			Confidence:   item.Confidence,
 		})
 	}
+	if batch.Len() == 0 {
+		return dbItems, nil
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, fmt.Errorf("failed to insert receipt item: %w", err)
+	}
+	return dbItems, nil
+}
 
-	// Commit transaction
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+// ReceiptItemDB is used for saving items to the database (with non-nullable float64)
+type ReceiptItemDB struct {
+	Name         string
+	Quantity     int
+	TotalPrice   float64
+	PricePerItem float64
+	IsDiscount   bool
+	Category     *string
+	NeedsReview  bool
+	Confidence   *float64
+}
+
+// anyNeedsReview reports whether any item was flagged for review; such receipts are flagged too
+func anyNeedsReview(items []ReceiptItemDB) bool {
+	for _, item := range items {
+		if item.NeedsReview {
+			return true
+		}
 	}
+	return false
+}
 
-	// Get receipt with created_at timestamp, image_url, ocr_text, and metadata
-	var createdAt time.Time
-	var dbImageURL *string
-	var dbOCRTextJSON []byte
-	var dbCurrency *string
-	var dbReceiptDate *time.Time
-	var dbTitle *string
-	err = DB.QueryRow(ctx, "SELECT created_at, image_url, ocr_text, currency, receipt_date, title FROM receipts WHERE id = $1", receiptID).Scan(&createdAt, &dbImageURL, &dbOCRTextJSON, &dbCurrency, &dbReceiptDate, &dbTitle)
+// DeleteReceipt soft-deletes a receipt: its rows are kept but it is hidden from reads until UndeleteReceipt.
+// Returns a "receipt not found" error when the receipt is absent or already deleted.
+func (c *Client) DeleteReceipt(ctx context.Context, receiptID string) error {
+	tag, err := c.db.Exec(ctx, `
+		UPDATE receipts SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL
+	`, receiptID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get receipt data: %w", err)
+		return fmt.Errorf("failed to delete receipt: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("receipt not found")
+	}
+	return nil
+}
 
-	var dbOCRText *OCRTextData
-	if len(dbOCRTextJSON) > 0 {
-		dbOCRText = &OCRTextData{}
-		if err := json.Unmarshal(dbOCRTextJSON, dbOCRText); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal OCR text: %w", err)
+// UndeleteReceipt restores a soft-deleted receipt. Restoring a receipt that isn't deleted is a no-op.
+// Returns a "receipt not found" error when the receipt is absent.
+func (c *Client) UndeleteReceipt(ctx context.Context, receiptID string) error {
+	tag, err := c.db.Exec(ctx, "UPDATE receipts SET deleted_at = NULL WHERE id = $1", receiptID)
+	if err != nil {
+		return fmt.Errorf("failed to restore receipt: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("receipt not found")
+	}
+	return nil
+}
+
+// DuplicateReceipt copies a receipt's title, currency, users, and items to a new receipt, as a template for
+// splitting a recurring meal. Users and items get fresh IDs and keep their order; assignments, the image,
+// and tax/tip are not copied. Returns the new receipt with its items, and its users.
+// Returns a "receipt not found" error when the receipt is absent or deleted.
+func (c *Client) DuplicateReceipt(ctx context.Context, receiptID string) (*Receipt, []ReceiptUser, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	receipt := &Receipt{ID: GenerateReceiptID(), Version: 1, Status: ReceiptStatusReady}
+	err = tx.QueryRow(ctx, "SELECT title, currency FROM receipts WHERE id = $1 AND deleted_at IS NULL", receiptID).Scan(&receipt.Title, &receipt.Currency)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, nil, fmt.Errorf("receipt not found")
 		}
+		return nil, nil, fmt.Errorf("failed to get receipt: %w", err)
 	}
 
-	receipt := &Receipt{
-		ID:          receiptID,
-		CreatedAt:   createdAt,
-		ImageURL:    dbImageURL,
-		OCRText:     dbOCRText,
-		Currency:    dbCurrency,
-		ReceiptDate: dbReceiptDate,
-		Title:       dbTitle,
-		Items:       dbItems,
+	rows, err := tx.Query(ctx, receiptUsersQuery, receiptID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query receipt users: %w", err)
+	}
+	sourceUsers, err := scanReceiptUsers(rows)
+	rows.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err = tx.Query(ctx, receiptItemsQuery, receiptID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query receipt items: %w", err)
+	}
+	sourceItems, err := scanReceiptItems(rows)
+	rows.Close()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return receipt, nil
+	err = tx.QueryRow(ctx, `
+		INSERT INTO receipts (id, created_at, currency, title, status)
+		VALUES ($1, CURRENT_TIMESTAMP, $2, $3, $4)
+		RETURNING created_at
+	`, receipt.ID, receipt.Currency, receipt.Title, receipt.Status).Scan(&receipt.CreatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to insert receipt: %w", err)
+	}
+
+	users := make([]ReceiptUser, 0, len(sourceUsers))
+	for _, source := range sourceUsers {
+		user, err := insertReceiptUser(ctx, tx, receipt.ID, source.Name, &source.Color)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to insert receipt user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	// Items are listed by ID, so ULIDs made in order keep the source's order
+	receipt.Items = make([]ReceiptItem, 0, len(sourceItems))
+	for _, item := range sourceItems {
+		item.ID, item.ReceiptID, item.Version = ulid.Make().String(), receipt.ID, 1
+		_, err := tx.Exec(ctx, `
+			INSERT INTO receipt_items (id, receipt_id, name, quantity, total_price, price_per_item, taxable, is_discount, category, needs_review, shared, confidence)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, item.ID, item.ReceiptID, item.Name, item.Quantity, item.TotalPrice, item.PricePerItem, item.Taxable, item.IsDiscount, item.Category, item.NeedsReview, item.Shared, item.Confidence)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to insert receipt item: %w", err)
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return receipt, users, nil
 }
 
-// ReceiptItemDB is used for saving items to the database (with non-nullable float64)
-type ReceiptItemDB struct {
-	Name         string
-	Quantity     int
-	TotalPrice   float64
-	PricePerItem float64
+// SetReceiptFinalized finalizes a receipt, freezing its split, or unfinalizes it so it can be edited again.
+// Finalizing an already finalized receipt keeps its original finalized_at, and the version only changes
+// when the state does. If expectedVersion is set, the change only applies when the stored version matches.
+// Returns finalized_at (nil once unfinalized) and the receipt's version.
+func (c *Client) SetReceiptFinalized(ctx context.Context, receiptID string, finalized bool, expectedVersion *int) (*time.Time, int, error) {
+	var finalizedAt *time.Time
+	var version int
+	err := c.db.QueryRow(ctx, `
+		UPDATE receipts
+		SET finalized_at = CASE WHEN $2 THEN COALESCE(finalized_at, CURRENT_TIMESTAMP) END,
+			version = CASE WHEN (finalized_at IS NOT NULL) = $2 THEN version ELSE version + 1 END
+		WHERE id = $1 AND deleted_at IS NULL AND ($3::int IS NULL OR version = $3)
+		RETURNING finalized_at, version
+	`, receiptID, finalized, expectedVersion).Scan(&finalizedAt, &version)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, 0, c.receiptUpdateMissError(ctx, receiptID)
+		}
+		return nil, 0, fmt.Errorf("failed to update receipt finalization: %w", err)
+	}
+	return finalizedAt, version, nil
+}
+
+// FinalizedError is returned by an edit to a receipt whose split is finalized
+type FinalizedError struct {
+	FinalizedAt time.Time
+}
+
+func (e *FinalizedError) Error() string {
+	return fmt.Sprintf("receipt was finalized at %s", e.FinalizedAt.Format(time.RFC3339))
+}
+
+// lockUnfinalizedReceipt locks a receipt's row until tx ends, so it can't be finalized in the middle of an edit,
+// and returns a *FinalizedError when it already is. Edits check here rather than before they start, or a finalize
+// could land between the check and the edit. Returns a "receipt not found" error when the receipt is absent or deleted.
+func lockUnfinalizedReceipt(ctx context.Context, tx pgx.Tx, receiptID string) error {
+	var finalizedAt *time.Time
+	err := tx.QueryRow(ctx, "SELECT finalized_at FROM receipts WHERE id = $1 AND deleted_at IS NULL FOR SHARE", receiptID).Scan(&finalizedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("receipt not found")
+		}
+		return fmt.Errorf("failed to lock receipt: %w", err)
+	}
+	if finalizedAt != nil {
+		return &FinalizedError{FinalizedAt: *finalizedAt}
+	}
+	return nil
+}
+
+// GetReceiptFinalizedAt gets when a receipt was finalized; nil when it isn't.
+// Returns a "receipt not found" error when the receipt is absent or deleted.
+func (c *Client) GetReceiptFinalizedAt(ctx context.Context, receiptID string) (*time.Time, error) {
+	var finalizedAt *time.Time
+	err := c.db.QueryRow(ctx, "SELECT finalized_at FROM receipts WHERE id = $1 AND deleted_at IS NULL", receiptID).Scan(&finalizedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("receipt not found")
+		}
+		return nil, fmt.Errorf("failed to get receipt finalization: %w", err)
+	}
+	return finalizedAt, nil
 }
 
 // GenerateReceiptID generates a new ULID for a receipt
 func GenerateReceiptID() string {
 	return ulid.Make().String()
 }
+
+// ReceiptFilter narrows ListReceipts; zero values don't filter
+type ReceiptFilter struct {
+	Title          string     // Case-insensitive substring of the title
+	From           *time.Time // receipt_date on or after
+	To             *time.Time // receipt_date before (exclusive)
+	IncludeDeleted bool       // Also list soft-deleted receipts
+}
+
+// ReceiptSummary is one row of ListReceipts
+type ReceiptSummary struct {
+	ID          string
+	Title       *string
+	ReceiptDate *time.Time
+	CreatedAt   time.Time
+	Currency    *string
+	Status      string
+	DeletedAt   *time.Time // Only set when listing with IncludeDeleted
+}
+
+// likePattern escapes LIKE wildcards in s and wraps it for a substring match
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// ListReceipts gets up to limit receipts matching filter, newest receipt date first (upload time when the
+// date wasn't parsed), with ID breaking ties. after is the ID of the last receipt from the previous page
+// (empty for the first page). nextCursor is empty when there are no more receipts.
+func (c *Client) ListReceipts(ctx context.Context, filter ReceiptFilter, limit int, after string) ([]ReceiptSummary, string, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+	if filter.Title != "" {
+		addCondition("title ILIKE $%d", likePattern(filter.Title))
+	}
+	if filter.From != nil {
+		addCondition("receipt_date >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("receipt_date < $%d", *filter.To)
+	}
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if after != "" {
+		addCondition("(COALESCE(receipt_date, created_at), id) < (SELECT COALESCE(receipt_date, created_at), id FROM receipts WHERE id = $%d)", after)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Fetch one extra row to know whether another page exists
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`
+		SELECT id, title, receipt_date, created_at, currency, status, deleted_at
+		FROM receipts
+		%s
+		ORDER BY COALESCE(receipt_date, created_at) DESC, id DESC
+		LIMIT $%d
+	`, where, len(args))
+
+	rows, err := c.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list receipts: %w", err)
+	}
+	defer rows.Close()
+
+	receipts := make([]ReceiptSummary, 0, limit)
+	for rows.Next() {
+		var r ReceiptSummary
+		if err := rows.Scan(&r.ID, &r.Title, &r.ReceiptDate, &r.CreatedAt, &r.Currency, &r.Status, &r.DeletedAt); err != nil {
+			return nil, "", fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		receipts = append(receipts, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating receipts: %w", err)
+	}
+
+	var nextCursor string
+	if len(receipts) > limit {
+		receipts = receipts[:limit]
+		nextCursor = receipts[limit-1].ID
+	}
+	return receipts, nextCursor, nil
+}
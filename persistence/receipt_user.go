@@ -3,9 +3,11 @@ package persistence
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/oklog/ulid/v2"
 )
 
@@ -14,6 +16,7 @@ type ReceiptUser struct {
 	ID        string
 	ReceiptID string
 	Name      string
+	Color     string // Hex color, e.g. "#1E88E5"
 	CreatedAt time.Time
 }
 
@@ -26,132 +29,830 @@ type ReceiptUserItem struct {
 	CreatedAt     time.Time
 }
 
-// AddUserToReceipt adds a user to a receipt
-func (c *Client) AddUserToReceipt(ctx context.Context, receiptID, name string) (*ReceiptUser, error) {
-	// Generate ULID for user
-	userID := ulid.Make().String()
+// userColors is the palette users are colored from when they don't pick a color, in the order they are added
+// to a receipt. Migration 20240318000000 colors existing users from the same palette.
+var userColors = []string{
+	"#E53935", "#1E88E5", "#43A047", "#FB8C00", "#8E24AA", "#00ACC1",
+	"#FDD835", "#6D4C41", "#D81B60", "#3949AB", "#7CB342", "#546E7A",
+}
+
+// rowQuerier is satisfied by both *pgxpool.Pool and pgx.Tx
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertReceiptUser inserts a user on a receipt and returns it. A nil color takes the palette color after
+// the receipt's existing users, so colors stay the same across sessions and clients.
+func insertReceiptUser(ctx context.Context, q rowQuerier, receiptID, name string, color *string) (ReceiptUser, error) {
+	// ULIDs from one process increase, so they keep insertion order among users sharing a created_at
+	user := ReceiptUser{ID: ulid.Make().String(), ReceiptID: receiptID, Name: name}
+	err := q.QueryRow(ctx, `
+		INSERT INTO receipt_users (id, receipt_id, name, color, created_at)
+		VALUES ($1, $2, $3,
+			COALESCE($4, ($5::text[])[(SELECT COUNT(*) FROM receipt_users WHERE receipt_id = $2) % cardinality($5::text[]) + 1]),
+			CURRENT_TIMESTAMP)
+		RETURNING color
+	`, user.ID, receiptID, name, color, userColors).Scan(&user.Color)
+	return user, err
+}
+
+// requireNewUserNames returns an "already has a user named" error when one of names matches a user on the
+// receipt. Names match case-insensitively, as when resolving a user_name, which two such users would make ambiguous.
+func requireNewUserNames(ctx context.Context, tx pgx.Tx, receiptID string, names []string) error {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = strings.ToLower(name)
+	}
+	var existing string
+	err := tx.QueryRow(ctx, "SELECT name FROM receipt_users WHERE receipt_id = $1 AND LOWER(name) = ANY($2) ORDER BY created_at ASC, id ASC LIMIT 1", receiptID, keys).Scan(&existing)
+	if err == nil {
+		return fmt.Errorf("receipt already has a user named %q", existing)
+	}
+	if !strings.Contains(err.Error(), "no rows") {
+		return fmt.Errorf("failed to check receipt user names: %w", err)
+	}
+	return nil
+}
 
-	// Insert user (foreign key constraint will fail if receipt doesn't exist)
-	_, err := c.db.Exec(ctx, `
-		INSERT INTO receipt_users (id, receipt_id, name, created_at)
-		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
-	`, userID, receiptID, name)
+// AddUserToReceipt adds a user to a receipt. color is a hex color such as "#1E88E5"; nil assigns the next
+// color from the palette. Returns a "receipt not found" error when the receipt is absent or deleted, and an
+// "already has a user named" error when a user on the receipt has the same name, ignoring case.
+func (c *Client) AddUserToReceipt(ctx context.Context, receiptID, name string, color *string) (*ReceiptUser, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, err
+	}
+	if err := requireNewUserNames(ctx, tx, receiptID, []string{name}); err != nil {
+		return nil, err
+	}
+
+	user, err := insertReceiptUser(ctx, tx, receiptID, name, color)
 	if err != nil {
-		// Check if it's a foreign key violation (receipt doesn't exist)
-		if strings.Contains(err.Error(), "foreign key") || strings.Contains(err.Error(), "violates foreign key") {
-			return nil, fmt.Errorf("receipt not found")
-		}
 		return nil, fmt.Errorf("failed to insert receipt user: %w", err)
 	}
 
-	user := &ReceiptUser{
-		ID:        userID,
-		ReceiptID: receiptID,
-		Name:      name,
-		// CreatedAt is kept in DB but not surfaced in responses
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	// CreatedAt is kept in DB but not surfaced in responses
+	return &user, nil
+}
+
+// AddUsersToReceipt adds several users to a receipt in one transaction, returning them in the order of names.
+// Each user gets the next color from the palette.
+// Returns a "receipt not found" error when the receipt is absent or deleted, and an "already has a user named"
+// error, adding none of them, when any name matches a user on the receipt.
+func (c *Client) AddUsersToReceipt(ctx context.Context, receiptID string, names []string) ([]ReceiptUser, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, err
+	}
+	if err := requireNewUserNames(ctx, tx, receiptID, names); err != nil {
+		return nil, err
+	}
+
+	users := make([]ReceiptUser, 0, len(names))
+	for _, name := range names {
+		user, err := insertReceiptUser(ctx, tx, receiptID, name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert receipt user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return users, nil
+}
+
+// AssignedItem is one item assigned by AssignItemsToUser
+type AssignedItem struct {
+	ReceiptUserItem
+	Created bool // false when the user already had the item
+}
+
+// AssignItemsToUser assigns several items to a user with an equal split, in one transaction: if any item fails,
+// none are assigned. Returns the assignments in the order of receiptItemIDs.
+func (c *Client) AssignItemsToUser(ctx context.Context, receiptUserID string, receiptItemIDs []string, updateExisting bool) ([]AssignedItem, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var receiptID string
+	if err := tx.QueryRow(ctx, "SELECT receipt_id FROM receipt_users WHERE id = $1", receiptUserID).Scan(&receiptID); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("receipt user or item not found")
+		}
+		return nil, fmt.Errorf("failed to verify user: %w", err)
+	}
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, err
+	}
+
+	assigned := make([]AssignedItem, 0, len(receiptItemIDs))
+	for _, itemID := range receiptItemIDs {
+		assignment, created, err := assignItemToUser(ctx, tx, receiptUserID, itemID, nil, updateExisting)
+		if err != nil {
+			return nil, err
+		}
+		assigned = append(assigned, AssignedItem{ReceiptUserItem: *assignment, Created: created})
 	}
 
-	return user, nil
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return assigned, nil
 }
 
-// AssignItemToUser assigns an item to a user
+// assignItemToUser assigns an item to a user within tx, whose caller has locked the receipt with lockUnfinalizedReceipt
 // If amountPaid is nil, it means equal split (will be calculated when needed)
 // If amountPaid is set, it's a custom amount
-func (c *Client) AssignItemToUser(ctx context.Context, receiptUserID, receiptItemID string, amountPaid *float64) (*ReceiptUserItem, error) {
+// Assigning a pair that is already assigned with the same amount is a no-op. With a different amount the existing
+// assignment is updated when updateExisting is set, and otherwise left alone with an "already assigned" error.
+// Returns the assignment and whether it was newly created.
+func assignItemToUser(ctx context.Context, tx pgx.Tx, receiptUserID, receiptItemID string, amountPaid *float64, updateExisting bool) (*ReceiptUserItem, bool, error) {
 	// Verify user and item belong to the same receipt (this also verifies they exist)
 	var userReceiptID, itemReceiptID string
-	err := c.db.QueryRow(ctx, `
+	err := tx.QueryRow(ctx, `
 		SELECT 
 			(SELECT receipt_id FROM receipt_users WHERE id = $1),
 			(SELECT receipt_id FROM receipt_items WHERE id = $2)
 	`, receiptUserID, receiptItemID).Scan(&userReceiptID, &itemReceiptID)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows") {
-			return nil, fmt.Errorf("receipt user or item not found")
+			return nil, false, fmt.Errorf("receipt user or item not found")
 		}
-		return nil, fmt.Errorf("failed to verify user and item: %w", err)
+		return nil, false, fmt.Errorf("failed to verify user and item: %w", err)
 	}
 	if userReceiptID != itemReceiptID {
-		return nil, fmt.Errorf("user and item must belong to the same receipt")
+		return nil, false, fmt.Errorf("user and item must belong to the same receipt")
 	}
 
 	// Generate ULID for assignment
 	assignmentID := ulid.Make().String()
 
-	// Insert assignment (or update if exists due to unique constraint)
+	// Insert assignment; an existing one for the pair is left for the checks below
 	// Foreign key constraints will fail if user or item doesn't exist
-	_, err = c.db.Exec(ctx, `
+	assignment := &ReceiptUserItem{
+		ReceiptUserID: receiptUserID,
+		ReceiptItemID: receiptItemID,
+	}
+	err = tx.QueryRow(ctx, `
 		INSERT INTO receipt_user_items (id, receipt_user_id, receipt_item_id, amount_owed, created_at)
 		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
-		ON CONFLICT (receipt_user_id, receipt_item_id) 
-		DO UPDATE SET amount_owed = EXCLUDED.amount_owed
-	`, assignmentID, receiptUserID, receiptItemID, amountPaid)
-	if err != nil {
+		ON CONFLICT (receipt_user_id, receipt_item_id) DO NOTHING
+		RETURNING id, amount_owed, created_at
+	`, assignmentID, receiptUserID, receiptItemID, amountPaid).Scan(&assignment.ID, &assignment.AmountOwed, &assignment.CreatedAt)
+	if err == nil {
+		return assignment, true, nil
+	}
+	if !strings.Contains(err.Error(), "no rows") {
 		// Check if it's a foreign key violation
 		if strings.Contains(err.Error(), "foreign key") || strings.Contains(err.Error(), "violates foreign key") {
-			return nil, fmt.Errorf("receipt user or item not found")
+			return nil, false, fmt.Errorf("receipt user or item not found")
 		}
-		return nil, fmt.Errorf("failed to assign item to user: %w", err)
+		return nil, false, fmt.Errorf("failed to assign item to user: %w", err)
 	}
 
-	// Get amount_owed (for conflict case where it might have been updated)
-	var dbAmountOwed *float64
-	err = c.db.QueryRow(ctx, "SELECT amount_owed FROM receipt_user_items WHERE id = $1", assignmentID).Scan(&dbAmountOwed)
+	// Already assigned: an unchanged amount is a no-op, and a changed one needs updateExisting
+	query := "SELECT id, amount_owed, created_at FROM receipt_user_items WHERE receipt_user_id = $1 AND receipt_item_id = $2"
+	args := []interface{}{receiptUserID, receiptItemID}
+	if updateExisting {
+		query = "UPDATE receipt_user_items SET amount_owed = $3 WHERE receipt_user_id = $1 AND receipt_item_id = $2 RETURNING id, amount_owed, created_at"
+		args = append(args, amountPaid)
+	}
+	err = tx.QueryRow(ctx, query, args...).Scan(&assignment.ID, &assignment.AmountOwed, &assignment.CreatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get receipt user item data: %w", err)
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, false, fmt.Errorf("receipt user or item not found")
+		}
+		return nil, false, fmt.Errorf("failed to assign item to user: %w", err)
+	}
+	if !updateExisting && !sameAmount(assignment.AmountOwed, amountPaid) {
+		return nil, false, fmt.Errorf("user is already assigned to item %s with a different amount", receiptItemID)
 	}
+	return assignment, false, nil
+}
 
-	assignment := &ReceiptUserItem{
-		ID:            assignmentID,
-		ReceiptUserID: receiptUserID,
-		ReceiptItemID: receiptItemID,
-		AmountOwed:    dbAmountOwed,
-		// CreatedAt is kept in DB but not surfaced in responses
+// sameAmount reports whether two optional amounts are both unset or equal to the cent
+func sameAmount(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return math.Round(*a*100) == math.Round(*b*100)
+}
+
+// ReceiptUserItemDB is used for creating assignments in bulk
+type ReceiptUserItemDB struct {
+	ReceiptUserID string
+	UserName      string // Resolved to ReceiptUserID within the transaction when ReceiptUserID is empty
+	ReceiptItemID string
+	AmountOwed    *float64 // nil means equal split
+}
+
+// BulkAssign assigns many items to many users on a receipt in a single transaction.
+// Every referenced user and item must belong to the receipt; nothing is inserted otherwise.
+// Existing assignments for the same user/item pair have their amount_owed updated.
+func (c *Client) BulkAssign(ctx context.Context, receiptID string, assignments []ReceiptUserItemDB) ([]ReceiptUserItem, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, err
+	}
+	if err := resolveAssignmentUserNames(ctx, tx, receiptID, assignments); err != nil {
+		return nil, err
+	}
+	if err := validateAssignmentRefs(ctx, tx, receiptID, assignments); err != nil {
+		return nil, err
+	}
+
+	created, err := upsertAssignments(ctx, tx, assignments)
+	if err != nil {
+		return nil, err
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return created, nil
+}
+
+// ReplaceAssignments makes assignments the complete set of assignments for a receipt in one transaction.
+// Existing assignments not in the set are deleted; the rest are inserted or have their amount updated.
+// All referenced users and items must belong to the receipt. Returns the resulting assignments and the number removed.
+func (c *Client) ReplaceAssignments(ctx context.Context, receiptID string, assignments []ReceiptUserItemDB) ([]ReceiptUserItem, int64, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, 0, err
+	}
+	if err := resolveAssignmentUserNames(ctx, tx, receiptID, assignments); err != nil {
+		return nil, 0, err
+	}
+	if err := validateAssignmentRefs(ctx, tx, receiptID, assignments); err != nil {
+		return nil, 0, err
+	}
+
+	removed, err := deleteAssignmentsNotIn(ctx, tx, receiptID, assignments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	assigned, err := upsertAssignments(ctx, tx, assignments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return assigned, removed, nil
+}
+
+// SplitEvenly assigns every item on a receipt to every user on it with an equal split, in one transaction,
+// replacing any existing assignments and custom amounts. Returns the resulting assignments and the number removed.
+func (c *Client) SplitEvenly(ctx context.Context, receiptID string) ([]ReceiptUserItem, int64, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, 0, err
+	}
+
+	userIDs, err := queryIDs(ctx, tx, "SELECT id FROM receipt_users WHERE receipt_id = $1 ORDER BY created_at ASC, id ASC", receiptID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query receipt users: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return nil, 0, fmt.Errorf("receipt has no users")
+	}
+	itemIDs, err := queryIDs(ctx, tx, "SELECT id FROM receipt_items WHERE receipt_id = $1 ORDER BY id ASC", receiptID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query receipt items: %w", err)
+	}
+
+	assignments := make([]ReceiptUserItemDB, 0, len(userIDs)*len(itemIDs))
+	for _, itemID := range itemIDs {
+		for _, userID := range userIDs {
+			assignments = append(assignments, ReceiptUserItemDB{ReceiptUserID: userID, ReceiptItemID: itemID})
+		}
+	}
+
+	removed, err := deleteAssignmentsNotIn(ctx, tx, receiptID, assignments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// AmountOwed is nil, so upserting also clears custom amounts on existing assignments
+	assigned, err := upsertAssignments(ctx, tx, assignments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return assigned, removed, nil
+}
+
+// AssignItemToAllExcept assigns an item to every user on the receipt except excludeUserIDs with an equal split,
+// in one transaction, replacing the item's existing assignments and custom amounts. The item and every excluded
+// user must belong to the receipt. Returns the item's resulting assignments and the number removed.
+func (c *Client) AssignItemToAllExcept(ctx context.Context, receiptID, receiptItemID string, excludeUserIDs []string) ([]ReceiptUserItem, int64, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, 0, err
+	}
+	refs := make([]ReceiptUserItemDB, len(excludeUserIDs))
+	for i, userID := range excludeUserIDs {
+		refs[i] = ReceiptUserItemDB{ReceiptUserID: userID, ReceiptItemID: receiptItemID}
+	}
+	if err := validateAssignmentRefs(ctx, tx, receiptID, refs); err != nil {
+		return nil, 0, err
+	}
+	if missing, err := missingIDs(ctx, tx, "receipt_items", receiptID, []string{receiptItemID}); err != nil {
+		return nil, 0, err
+	} else if len(missing) > 0 {
+		return nil, 0, fmt.Errorf("receipt item(s) not found on receipt: %s", receiptItemID)
+	}
+
+	userIDs, err := queryIDs(ctx, tx, "SELECT id FROM receipt_users WHERE receipt_id = $1 AND NOT (id = ANY($2)) ORDER BY created_at ASC, id ASC", receiptID, excludeUserIDs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query receipt users: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return nil, 0, fmt.Errorf("receipt has no users left to assign the item to")
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM receipt_user_items WHERE receipt_item_id = $1 AND NOT (receipt_user_id = ANY($2))", receiptItemID, userIDs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to remove stale assignments: %w", err)
+	}
+
+	assignments := make([]ReceiptUserItemDB, len(userIDs))
+	for i, userID := range userIDs {
+		assignments[i] = ReceiptUserItemDB{ReceiptUserID: userID, ReceiptItemID: receiptItemID}
+	}
+	// AmountOwed is nil, so upserting also clears custom amounts on existing assignments
+	assigned, err := upsertAssignments(ctx, tx, assignments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return assigned, tag.RowsAffected(), nil
+}
+
+// queryIDs runs a query selecting a single id column within tx and returns the IDs in order
+func queryIDs(ctx context.Context, tx pgx.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteAssignmentsNotIn deletes the receipt's assignments whose user-item pair is not in keep
+// and returns how many were deleted
+func deleteAssignmentsNotIn(ctx context.Context, tx pgx.Tx, receiptID string, keep []ReceiptUserItemDB) (int64, error) {
+	userIDs := make([]string, len(keep))
+	itemIDs := make([]string, len(keep))
+	for i, a := range keep {
+		userIDs[i] = a.ReceiptUserID
+		itemIDs[i] = a.ReceiptItemID
+	}
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM receipt_user_items rui
+		USING receipt_users ru
+		WHERE ru.id = rui.receipt_user_id
+		  AND ru.receipt_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM unnest($2::text[], $3::text[]) AS keep(user_id, item_id)
+			WHERE keep.user_id = rui.receipt_user_id AND keep.item_id = rui.receipt_item_id
+		  )
+	`, receiptID, userIDs, itemIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove stale assignments: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// resolveAssignmentUserNames fills in ReceiptUserID for assignments that name a user instead.
+// Names match case-insensitively within the receipt; a name with no match creates the user,
+// and a name matching more than one user is an ambiguity error.
+func resolveAssignmentUserNames(ctx context.Context, tx pgx.Tx, receiptID string, assignments []ReceiptUserItemDB) error {
+	resolved := make(map[string]string)
+	for i := range assignments {
+		name := strings.TrimSpace(assignments[i].UserName)
+		if assignments[i].ReceiptUserID != "" || name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if id, ok := resolved[key]; ok {
+			assignments[i].ReceiptUserID = id
+			continue
+		}
+
+		rows, err := tx.Query(ctx, "SELECT id FROM receipt_users WHERE receipt_id = $1 AND LOWER(name) = $2", receiptID, key)
+		if err != nil {
+			return fmt.Errorf("failed to look up receipt user by name: %w", err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("failed to look up receipt user by name: %w", err)
+		}
+
+		var id string
+		switch len(ids) {
+		case 0:
+			user, err := insertReceiptUser(ctx, tx, receiptID, name, nil)
+			if err != nil {
+				if strings.Contains(err.Error(), "foreign key") {
+					return fmt.Errorf("receipt not found")
+				}
+				return fmt.Errorf("failed to insert receipt user: %w", err)
+			}
+			id = user.ID
+		case 1:
+			id = ids[0]
+		default:
+			return fmt.Errorf("ambiguous user name %q: %d users on the receipt have that name", name, len(ids))
+		}
+		resolved[key] = id
+		assignments[i].ReceiptUserID = id
+	}
+	return nil
+}
+
+// validateAssignmentRefs checks that the receipt exists and every referenced user and item belongs to it
+func validateAssignmentRefs(ctx context.Context, tx pgx.Tx, receiptID string, assignments []ReceiptUserItemDB) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM receipts WHERE id = $1 AND deleted_at IS NULL)", receiptID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check receipt existence: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("receipt not found")
+	}
+
+	userIDs := make([]string, 0, len(assignments))
+	itemIDs := make([]string, 0, len(assignments))
+	for _, a := range assignments {
+		userIDs = append(userIDs, a.ReceiptUserID)
+		itemIDs = append(itemIDs, a.ReceiptItemID)
+	}
+	if missing, err := missingIDs(ctx, tx, "receipt_users", receiptID, userIDs); err != nil {
+		return err
+	} else if len(missing) > 0 {
+		return fmt.Errorf("receipt user(s) not found on receipt: %s", strings.Join(missing, ", "))
+	}
+	if missing, err := missingIDs(ctx, tx, "receipt_items", receiptID, itemIDs); err != nil {
+		return err
+	} else if len(missing) > 0 {
+		return fmt.Errorf("receipt item(s) not found on receipt: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// upsertAssignments inserts each assignment, updating amount_owed when the user-item pair already exists
+func upsertAssignments(ctx context.Context, tx pgx.Tx, assignments []ReceiptUserItemDB) ([]ReceiptUserItem, error) {
+	created := make([]ReceiptUserItem, 0, len(assignments))
+	for _, a := range assignments {
+		// On conflict the existing row is kept, so RETURNING yields its original ID
+		assignment := ReceiptUserItem{
+			ReceiptUserID: a.ReceiptUserID,
+			ReceiptItemID: a.ReceiptItemID,
+		}
+		err := tx.QueryRow(ctx, `
+			INSERT INTO receipt_user_items (id, receipt_user_id, receipt_item_id, amount_owed, created_at)
+			VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+			ON CONFLICT (receipt_user_id, receipt_item_id)
+			DO UPDATE SET amount_owed = EXCLUDED.amount_owed
+			RETURNING id, amount_owed, created_at
+		`, ulid.Make().String(), a.ReceiptUserID, a.ReceiptItemID, a.AmountOwed).Scan(&assignment.ID, &assignment.AmountOwed, &assignment.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign item to user: %w", err)
+		}
+		created = append(created, assignment)
+	}
+	return created, nil
+}
+
+// missingIDs returns the IDs from ids that are not rows of table belonging to receiptID.
+// table must be a trusted table name with id and receipt_id columns.
+func missingIDs(ctx context.Context, tx pgx.Tx, table, receiptID string, ids []string) ([]string, error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT id FROM %s WHERE receipt_id = $1 AND id = ANY($2)", table), receiptID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan %s id: %w", table, err)
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s: %w", table, err)
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+			found[id] = true // report each missing ID once
+		}
+	}
+	return missing, nil
+}
+
+// SetAssignmentAmount sets the custom amount a user owes for an item on a receipt; nil returns the
+// assignment to an equal split. Returns the updated assignment, or a not found error if the user is not
+// assigned to the item on the receipt.
+func (c *Client) SetAssignmentAmount(ctx context.Context, receiptID, receiptUserID, receiptItemID string, amount *float64) (*ReceiptUserItem, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, err
+	}
+
+	assignment := &ReceiptUserItem{ReceiptUserID: receiptUserID, ReceiptItemID: receiptItemID}
+	err = tx.QueryRow(ctx, `
+		UPDATE receipt_user_items rui
+		SET amount_owed = $4
+		FROM receipt_users ru
+		WHERE ru.id = rui.receipt_user_id
+		  AND ru.receipt_id = $1
+		  AND rui.receipt_user_id = $2
+		  AND rui.receipt_item_id = $3
+		RETURNING rui.id, rui.amount_owed, rui.created_at
+	`, receiptID, receiptUserID, receiptItemID, amount).Scan(&assignment.ID, &assignment.AmountOwed, &assignment.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("assignment not found: user is not assigned to this item on the receipt")
+		}
+		return nil, fmt.Errorf("failed to update assignment amount: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return assignment, nil
 }
 
-// GetReceiptUsers gets all users for a receipt
-func (c *Client) GetReceiptUsers(ctx context.Context, receiptID string) ([]ReceiptUser, error) {
-	rows, err := c.db.Query(ctx, `
-		SELECT id, receipt_id, name, created_at
+// ReassignItem moves fromUserID's assignment of an item to toUserID in one statement, keeping the assignment's
+// ID, custom amount, and position. Both users and the item must belong to the receipt, fromUserID must be
+// assigned to the item, and toUserID must not be already.
+func (c *Client) ReassignItem(ctx context.Context, receiptID, receiptItemID, fromUserID, toUserID string) (*ReceiptUserItem, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, err
+	}
+	refs := []ReceiptUserItemDB{
+		{ReceiptUserID: fromUserID, ReceiptItemID: receiptItemID},
+		{ReceiptUserID: toUserID, ReceiptItemID: receiptItemID},
+	}
+	if err := validateAssignmentRefs(ctx, tx, receiptID, refs); err != nil {
+		return nil, err
+	}
+
+	var alreadyAssigned bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM receipt_user_items WHERE receipt_user_id = $1 AND receipt_item_id = $2)", toUserID, receiptItemID).Scan(&alreadyAssigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing assignment: %w", err)
+	}
+	if alreadyAssigned {
+		return nil, fmt.Errorf("user %s is already assigned to this item", toUserID)
+	}
+
+	assignment := &ReceiptUserItem{ReceiptUserID: toUserID, ReceiptItemID: receiptItemID}
+	err = tx.QueryRow(ctx, `
+		UPDATE receipt_user_items
+		SET receipt_user_id = $3
+		WHERE receipt_user_id = $1 AND receipt_item_id = $2
+		RETURNING id, amount_owed, created_at
+	`, fromUserID, receiptItemID, toUserID).Scan(&assignment.ID, &assignment.AmountOwed, &assignment.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("assignment not found: user %s is not assigned to this item", fromUserID)
+		}
+		return nil, fmt.Errorf("failed to reassign item: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return assignment, nil
+}
+
+// ClearUserAssignments removes every item assignment for a user on a receipt.
+// Returns the number of assignments deleted, or a not found error if the user is not on the receipt.
+func (c *Client) ClearUserAssignments(ctx context.Context, receiptID, receiptUserID string) (int64, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return 0, err
+	}
+	var exists bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM receipt_users WHERE id = $1 AND receipt_id = $2)", receiptUserID, receiptID).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check receipt user existence: %w", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("receipt user not found")
+	}
+
+	result, err := tx.Exec(ctx, "DELETE FROM receipt_user_items WHERE receipt_user_id = $1", receiptUserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear user assignments: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// ReceiptReset counts what ResetReceipt removed
+type ReceiptReset struct {
+	Assignments int64
+	Users       int64
+	Items       int64
+}
+
+// ResetReceipt removes every assignment and user on a receipt, and its items when includeItems is set, in one
+// transaction. The receipt row and its image are kept. Returns a "receipt not found" error when the receipt is
+// absent or deleted.
+func (c *Client) ResetReceipt(ctx context.Context, receiptID string, includeItems bool) (*ReceiptReset, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return nil, err
+	}
+
+	reset := &ReceiptReset{}
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM receipt_user_items rui
+		USING receipt_users ru
+		WHERE ru.id = rui.receipt_user_id AND ru.receipt_id = $1
+	`, receiptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete assignments: %w", err)
+	}
+	reset.Assignments = tag.RowsAffected()
+
+	tag, err = tx.Exec(ctx, "DELETE FROM receipt_users WHERE receipt_id = $1", receiptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete receipt users: %w", err)
+	}
+	reset.Users = tag.RowsAffected()
+
+	if includeItems {
+		tag, err = tx.Exec(ctx, "DELETE FROM receipt_items WHERE receipt_id = $1", receiptID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete receipt items: %w", err)
+		}
+		reset.Items = tag.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return reset, nil
+}
+
+// Queries shared by the single-table getters and GetReceiptSnapshot
+const (
+	receiptUsersQuery = `
+		SELECT id, receipt_id, name, color, created_at
 		FROM receipt_users
 		WHERE receipt_id = $1
-		ORDER BY created_at ASC
-	`, receiptID)
+		ORDER BY created_at ASC, id ASC
+	`
+	receiptItemsQuery = `
+		SELECT ri.id, ri.receipt_id, ri.name, ri.quantity, ri.total_price, ri.price_per_item, ri.version, ri.taxable, ri.is_discount, ri.category, ri.needs_review, ri.shared, ri.confidence
+		FROM receipt_items ri
+		JOIN receipts r ON r.id = ri.receipt_id
+		WHERE ri.receipt_id = $1 AND r.deleted_at IS NULL
+		ORDER BY ri.id ASC
+	`
+	receiptAssignmentsQuery = `
+		SELECT rui.id, rui.receipt_user_id, rui.receipt_item_id, rui.amount_owed, rui.created_at
+		FROM receipt_user_items rui
+		JOIN receipt_users ru ON ru.id = rui.receipt_user_id
+		WHERE ru.receipt_id = $1
+		ORDER BY rui.created_at ASC, rui.id ASC
+	`
+)
+
+// GetReceiptUsers gets all users for a receipt
+func (c *Client) GetReceiptUsers(ctx context.Context, receiptID string) ([]ReceiptUser, error) {
+	rows, err := c.db.Query(ctx, receiptUsersQuery, receiptID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query receipt users: %w", err)
 	}
 	defer rows.Close()
+	return scanReceiptUsers(rows)
+}
 
+// scanReceiptUsers reads rows selected by receiptUsersQuery
+func scanReceiptUsers(rows pgx.Rows) ([]ReceiptUser, error) {
 	users := make([]ReceiptUser, 0)
 	for rows.Next() {
 		var user ReceiptUser
-		err := rows.Scan(&user.ID, &user.ReceiptID, &user.Name, &user.CreatedAt)
+		err := rows.Scan(&user.ID, &user.ReceiptID, &user.Name, &user.Color, &user.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan receipt user: %w", err)
 		}
 		users = append(users, user)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating receipt users: %w", err)
 	}
 
 	return users, nil
 }
 
-// ReceiptTaxTip holds tax and tip for a receipt
+// ReceiptTaxTip holds tax, tip, and service charge for a receipt
 type ReceiptTaxTip struct {
-	Tax *float64
-	Tip *float64
+	Tax           *float64
+	Tip           *float64 // Voluntary tip
+	ServiceCharge *float64 // Automatic service charge or included gratuity
+	TaxSource     *string  // ValueSourceParsed or ValueSourceManual; nil when Tax is unset
+	TipSource     *string  // ValueSourceParsed or ValueSourceManual; nil when Tip is unset
+	TaxInclusive  bool     // Item prices already include Tax, so it is not added on top
 }
 
 // GetReceiptCurrency gets the currency code for a receipt (nil if not set).
+// Returns a "receipt not found" error when the receipt is absent or deleted.
 func (c *Client) GetReceiptCurrency(ctx context.Context, receiptID string) (*string, error) {
 	var currency *string
-	err := c.db.QueryRow(ctx, "SELECT currency FROM receipts WHERE id = $1", receiptID).Scan(&currency)
+	err := c.db.QueryRow(ctx, "SELECT currency FROM receipts WHERE id = $1 AND deleted_at IS NULL", receiptID).Scan(&currency)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows") {
 			return nil, fmt.Errorf("receipt not found")
@@ -161,103 +862,438 @@ func (c *Client) GetReceiptCurrency(ctx context.Context, receiptID string) (*str
 	return currency, nil
 }
 
-// GetReceiptTaxTip gets tax and tip for a receipt
+// GetReceiptTaxTip gets tax, tip, and service charge for a receipt.
+// Returns a "receipt not found" error when the receipt is absent or deleted.
 func (c *Client) GetReceiptTaxTip(ctx context.Context, receiptID string) (*ReceiptTaxTip, error) {
-	var tax, tip *float64
-	err := c.db.QueryRow(ctx, "SELECT tax, tip FROM receipts WHERE id = $1", receiptID).Scan(&tax, &tip)
+	var taxTip ReceiptTaxTip
+	err := c.db.QueryRow(ctx, "SELECT tax, tip, service_charge, tax_source, tip_source, tax_inclusive FROM receipts WHERE id = $1 AND deleted_at IS NULL", receiptID).
+		Scan(&taxTip.Tax, &taxTip.Tip, &taxTip.ServiceCharge, &taxTip.TaxSource, &taxTip.TipSource, &taxTip.TaxInclusive)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows") {
 			return nil, fmt.Errorf("receipt not found")
 		}
 		return nil, fmt.Errorf("failed to get receipt tax/tip: %w", err)
 	}
-	return &ReceiptTaxTip{Tax: tax, Tip: tip}, nil
+	return &taxTip, nil
+}
+
+// ReceiptUpdate holds the receipt fields to change; nil fields are left as they are
+type ReceiptUpdate struct {
+	Tax              *float64 // Marked as manual
+	Tip              *float64 // Marked as manual
+	ServiceCharge    *float64
+	RoundingStrategy *string // RoundingFirst, RoundingPayer, or RoundingLargestShare
+	PayerUserID      *string // Must be a user on the receipt
+	SplitMode        *string // SplitModeItemized or SplitModeEqual
+	TaxInclusive     *bool
+	Notes            *string // An empty string clears the notes
 }
 
-// UpdateReceiptTaxTip sets tax and/or tip for a receipt. Pass nil for fields to leave unchanged.
-func (c *Client) UpdateReceiptTaxTip(ctx context.Context, receiptID string, tax, tip *float64) error {
+// UpdateReceipt sets tax, tip, service charge, rounding strategy, payer, split mode, tax inclusivity, and/or notes for a receipt.
+// If expectedVersion is set, the update only applies when the stored version matches; otherwise a
+// version conflict error is returned. A payer who is not a user on the receipt returns a "not a user on this receipt" error. Notes can be edited on a finalized receipt; anything else returns a
+// *FinalizedError. Returns the receipt's new version, or a "receipt not found" error when the receipt is absent or deleted.
+func (c *Client) UpdateReceipt(ctx context.Context, receiptID string, update ReceiptUpdate, expectedVersion *int) (int, error) {
 	var setClauses []string
 	var args []interface{}
 	argNum := 1
-	if tax != nil {
-		setClauses = append(setClauses, fmt.Sprintf("tax = $%d", argNum))
-		args = append(args, *tax)
+	if update.Tax != nil {
+		setClauses = append(setClauses, fmt.Sprintf("tax = $%d", argNum), fmt.Sprintf("tax_source = '%s'", ValueSourceManual))
+		args = append(args, *update.Tax)
 		argNum++
 	}
-	if tip != nil {
-		setClauses = append(setClauses, fmt.Sprintf("tip = $%d", argNum))
-		args = append(args, *tip)
+	if update.Tip != nil {
+		setClauses = append(setClauses, fmt.Sprintf("tip = $%d", argNum), fmt.Sprintf("tip_source = '%s'", ValueSourceManual))
+		args = append(args, *update.Tip)
+		argNum++
+	}
+	if update.ServiceCharge != nil {
+		setClauses = append(setClauses, fmt.Sprintf("service_charge = $%d", argNum))
+		args = append(args, *update.ServiceCharge)
+		argNum++
+	}
+	if update.RoundingStrategy != nil {
+		setClauses = append(setClauses, fmt.Sprintf("rounding_strategy = $%d", argNum))
+		args = append(args, *update.RoundingStrategy)
+		argNum++
+	}
+	payerArg := 0
+	if update.PayerUserID != nil {
+		setClauses = append(setClauses, fmt.Sprintf("payer_user_id = $%d", argNum))
+		args = append(args, *update.PayerUserID)
+		payerArg = argNum
+		argNum++
+	}
+	if update.SplitMode != nil {
+		setClauses = append(setClauses, fmt.Sprintf("split_mode = $%d", argNum))
+		args = append(args, *update.SplitMode)
+		argNum++
+	}
+	if update.TaxInclusive != nil {
+		setClauses = append(setClauses, fmt.Sprintf("tax_inclusive = $%d", argNum))
+		args = append(args, *update.TaxInclusive)
+		argNum++
+	}
+	if update.Notes != nil {
+		setClauses = append(setClauses, fmt.Sprintf("notes = NULLIF($%d, '')", argNum))
+		args = append(args, *update.Notes)
 		argNum++
 	}
 	if len(setClauses) == 0 {
-		return fmt.Errorf("at least one of tax or tip must be provided")
+		return 0, fmt.Errorf("at least one of tax, tip, service charge, rounding strategy, payer, split mode, tax inclusive, or notes must be provided")
 	}
+	setClauses = append(setClauses, "version = version + 1")
 	args = append(args, receiptID)
-	query := fmt.Sprintf("UPDATE receipts SET %s WHERE id = $%d", strings.Join(setClauses, ", "), argNum)
-	result, err := c.db.Exec(ctx, query, args...)
+	where := fmt.Sprintf("id = $%d AND deleted_at IS NULL", argNum)
+	notesOnly := update.Tax == nil && update.Tip == nil && update.ServiceCharge == nil && update.RoundingStrategy == nil && update.PayerUserID == nil && update.SplitMode == nil && update.TaxInclusive == nil
+	if !notesOnly {
+		where += " AND finalized_at IS NULL"
+	}
+	if payerArg != 0 {
+		where += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM receipt_users WHERE id = $%d AND receipt_id = receipts.id)", payerArg)
+	}
+	if expectedVersion != nil {
+		argNum++
+		args = append(args, *expectedVersion)
+		where += fmt.Sprintf(" AND version = $%d", argNum)
+	}
+	query := fmt.Sprintf("UPDATE receipts SET %s WHERE %s RETURNING version", strings.Join(setClauses, ", "), where)
+	var version int
+	err := c.db.QueryRow(ctx, query, args...).Scan(&version)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			if !notesOnly {
+				if finalizedAt, err := c.GetReceiptFinalizedAt(ctx, receiptID); err == nil && finalizedAt != nil {
+					return 0, &FinalizedError{FinalizedAt: *finalizedAt}
+				}
+			}
+			if update.PayerUserID != nil {
+				var onReceipt bool
+				if err := c.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM receipt_users WHERE id = $1 AND receipt_id = $2)", *update.PayerUserID, receiptID).Scan(&onReceipt); err != nil {
+					return 0, fmt.Errorf("failed to check payer: %w", err)
+				}
+				if !onReceipt {
+					return 0, fmt.Errorf("payer %s is not a user on this receipt", *update.PayerUserID)
+				}
+			}
+			return 0, c.receiptUpdateMissError(ctx, receiptID)
+		}
+		return 0, fmt.Errorf("failed to update receipt: %w", err)
+	}
+	return version, nil
+}
+
+// receiptUpdateMissError explains why a versioned receipt UPDATE matched no rows:
+// either the receipt does not exist or its version has moved on.
+func (c *Client) receiptUpdateMissError(ctx context.Context, receiptID string) error {
+	exists, err := c.ReceiptExists(ctx, receiptID)
 	if err != nil {
-		return fmt.Errorf("failed to update receipt tax/tip: %w", err)
+		return err
 	}
-	if result.RowsAffected() == 0 {
+	if !exists {
 		return fmt.Errorf("receipt not found")
 	}
-	return nil
+	return fmt.Errorf("receipt was modified by another request (version conflict)")
+}
+
+// ReceiptItemUpdate holds the item flags to change; nil fields are left as they are
+type ReceiptItemUpdate struct {
+	Taxable *bool
+	Shared  *bool
+}
+
+// UpdateReceiptItem sets an item's taxable and/or shared flags and bumps its version.
+// If expectedVersion is non-nil the update only applies when the item is still at that version.
+// Returns the item's new version.
+func (c *Client) UpdateReceiptItem(ctx context.Context, receiptID, itemID string, update ReceiptItemUpdate, expectedVersion *int) (int, error) {
+	var sets []string
+	args := []interface{}{receiptID, itemID}
+	if update.Taxable != nil {
+		args = append(args, *update.Taxable)
+		sets = append(sets, fmt.Sprintf("taxable = $%d", len(args)))
+	}
+	if update.Shared != nil {
+		args = append(args, *update.Shared)
+		sets = append(sets, fmt.Sprintf("shared = $%d", len(args)))
+	}
+	if len(sets) == 0 {
+		return 0, fmt.Errorf("no receipt item fields to update")
+	}
+	sets = append(sets, "version = version + 1")
+	query := fmt.Sprintf("UPDATE receipt_items SET %s WHERE receipt_id = $1 AND id = $2", strings.Join(sets, ", "))
+	if expectedVersion != nil {
+		args = append(args, *expectedVersion)
+		query += fmt.Sprintf(" AND version = $%d", len(args))
+	}
+	query += " RETURNING version"
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return 0, err
+	}
+	var version int
+	err = tx.QueryRow(ctx, query, args...).Scan(&version)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return 0, itemUpdateMissError(ctx, tx, receiptID, itemID)
+		}
+		return 0, fmt.Errorf("failed to update receipt item: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return version, nil
 }
 
-// ReceiptExists checks if a receipt exists
+// itemUpdateMissError explains why a versioned receipt item UPDATE matched no rows:
+// either the item does not exist on the receipt or its version has moved on.
+func itemUpdateMissError(ctx context.Context, q rowQuerier, receiptID, itemID string) error {
+	var exists bool
+	err := q.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM receipt_items WHERE receipt_id = $1 AND id = $2)", receiptID, itemID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check receipt item existence: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("receipt item not found")
+	}
+	return fmt.Errorf("receipt item was modified by another request (version conflict)")
+}
+
+// ReceiptExists checks if a receipt exists and has not been deleted
 func (c *Client) ReceiptExists(ctx context.Context, receiptID string) (bool, error) {
 	var exists bool
-	err := c.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM receipts WHERE id = $1)", receiptID).Scan(&exists)
+	err := c.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM receipts WHERE id = $1 AND deleted_at IS NULL)", receiptID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check receipt existence: %w", err)
 	}
 	return exists, nil
 }
 
+// ReceiptSnapshot is everything GET /receipts/{receipt_id} reads about a receipt
+type ReceiptSnapshot struct {
+	ReceiptID      string
+	Title          *string
+	ReceiptDate    *time.Time
+	Currency       *string
+	TaxTip         ReceiptTaxTip
+	Rounding       Rounding
+	SplitMode      string // SplitModeItemized or SplitModeEqual
+	ExtractedTotal *float64
+	Notes          *string
+	Version        int
+	Status         string
+	ParseStatus    *string // One of the ParseStatus constants; nil until processed and for receipts without an upload
+	ItemsTruncated bool    // Parsing found more items than the cap and only the first were saved
+	NeedsReview    bool
+	FinalizedAt    *time.Time     // nil unless the split is frozen
+	Parser         *ParserInfo    // nil when items were not parsed
+	Image          *ImageMetadata // nil for receipts without an uploaded image or created before this was recorded
+	Users          []ReceiptUser
+	Items          []ReceiptItem
+	Assignments    []ReceiptUserItem
+}
+
+// GetReceiptSnapshot reads a receipt row with its users, items, and assignments in a single round trip.
+// Returns a "receipt not found" error when the receipt row is absent or deleted.
+func (c *Client) GetReceiptSnapshot(ctx context.Context, receiptID string) (*ReceiptSnapshot, error) {
+	snapshot := &ReceiptSnapshot{ReceiptID: receiptID}
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		SELECT title, receipt_date, currency, tax, tip, service_charge, tax_source, tip_source, tax_inclusive, rounding_strategy, payer_user_id, split_mode, extracted_total, notes, version, status, parse_status, items_truncated, needs_review, finalized_at, parser_source, model_version,
+			image_width, image_height, image_size_bytes
+		FROM receipts
+		WHERE id = $1 AND deleted_at IS NULL
+	`, receiptID).QueryRow(func(row pgx.Row) error {
+		var parserSource, modelVersion *string
+		var imageWidth, imageHeight *int
+		var imageSizeBytes *int64
+		err := row.Scan(&snapshot.Title, &snapshot.ReceiptDate, &snapshot.Currency, &snapshot.TaxTip.Tax, &snapshot.TaxTip.Tip, &snapshot.TaxTip.ServiceCharge,
+			&snapshot.TaxTip.TaxSource, &snapshot.TaxTip.TipSource, &snapshot.TaxTip.TaxInclusive, &snapshot.Rounding.Strategy, &snapshot.Rounding.PayerUserID, &snapshot.SplitMode, &snapshot.ExtractedTotal, &snapshot.Notes,
+			&snapshot.Version, &snapshot.Status, &snapshot.ParseStatus, &snapshot.ItemsTruncated, &snapshot.NeedsReview, &snapshot.FinalizedAt, &parserSource, &modelVersion,
+			&imageWidth, &imageHeight, &imageSizeBytes)
+		if err != nil {
+			if strings.Contains(err.Error(), "no rows") {
+				return fmt.Errorf("receipt not found")
+			}
+			return fmt.Errorf("failed to get receipt: %w", err)
+		}
+		if parserSource != nil {
+			snapshot.Parser = &ParserInfo{Source: *parserSource, ModelVersion: modelVersion}
+		}
+		if imageSizeBytes != nil {
+			snapshot.Image = &ImageMetadata{Width: imageWidth, Height: imageHeight, SizeBytes: *imageSizeBytes}
+		}
+		return nil
+	})
+	batch.Queue(receiptUsersQuery, receiptID).Query(func(rows pgx.Rows) (err error) {
+		snapshot.Users, err = scanReceiptUsers(rows)
+		return err
+	})
+	batch.Queue(receiptItemsQuery, receiptID).Query(func(rows pgx.Rows) (err error) {
+		snapshot.Items, err = scanReceiptItems(rows)
+		return err
+	})
+	batch.Queue(receiptAssignmentsQuery, receiptID).Query(func(rows pgx.Rows) (err error) {
+		snapshot.Assignments, err = scanReceiptAssignments(rows)
+		return err
+	})
+
+	if err := c.db.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
 // GetReceiptItems gets all items for a receipt
 func (c *Client) GetReceiptItems(ctx context.Context, receiptID string) ([]ReceiptItem, error) {
-	rows, err := c.db.Query(ctx, `
-		SELECT id, receipt_id, name, quantity, total_price, price_per_item
-		FROM receipt_items
-		WHERE receipt_id = $1
-		ORDER BY id ASC
-	`, receiptID)
+	rows, err := c.db.Query(ctx, receiptItemsQuery, receiptID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query receipt items: %w", err)
 	}
 	defer rows.Close()
+	return scanReceiptItems(rows)
+}
+
+// GetReceiptItem gets one item on a receipt. Returns a "receipt item not found" error when the item is absent,
+// belongs to another receipt, or its receipt is deleted.
+func (c *Client) GetReceiptItem(ctx context.Context, receiptID, itemID string) (*ReceiptItem, error) {
+	var item ReceiptItem
+	err := c.db.QueryRow(ctx, `
+		SELECT ri.id, ri.receipt_id, ri.name, ri.quantity, ri.total_price, ri.price_per_item, ri.version, ri.taxable, ri.is_discount, ri.category, ri.needs_review, ri.shared, ri.confidence
+		FROM receipt_items ri
+		JOIN receipts r ON r.id = ri.receipt_id
+		WHERE ri.receipt_id = $1 AND ri.id = $2 AND r.deleted_at IS NULL
+	`, receiptID, itemID).Scan(&item.ID, &item.ReceiptID, &item.Name, &item.Quantity, &item.TotalPrice, &item.PricePerItem, &item.Version, &item.Taxable, &item.IsDiscount, &item.Category, &item.NeedsReview, &item.Shared, &item.Confidence)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("receipt item not found")
+		}
+		return nil, fmt.Errorf("failed to get receipt item: %w", err)
+	}
+	return &item, nil
+}
+
+// AddReceiptItem adds an item entered by hand to a receipt and returns it with its generated ID.
+// Returns a "receipt not found" error when the receipt is absent or deleted, and an "already has" error when
+// it already has maxItems items.
+func (c *Client) AddReceiptItem(ctx context.Context, receiptID string, item ReceiptItemDB, maxItems int) (*ReceiptItem, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the receipt row keeps concurrent adds from both passing the count check, and keeps it from
+	// being finalized until the add commits
+	var finalizedAt *time.Time
+	if err := tx.QueryRow(ctx, "SELECT finalized_at FROM receipts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", receiptID).Scan(&finalizedAt); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("receipt not found")
+		}
+		return nil, fmt.Errorf("failed to check receipt existence: %w", err)
+	}
+	if finalizedAt != nil {
+		return nil, &FinalizedError{FinalizedAt: *finalizedAt}
+	}
+	var count int
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM receipt_items WHERE receipt_id = $1", receiptID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count receipt items: %w", err)
+	}
+	if count >= maxItems {
+		return nil, fmt.Errorf("receipt already has the maximum of %d items", maxItems)
+	}
+
+	items, err := insertReceiptItems(ctx, tx, receiptID, []ReceiptItemDB{item})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &items[0], nil
+}
+
+// DeleteReceiptItem removes an item and every assignment of it, in one transaction, and returns how many
+// assignments were removed. Returns a "receipt item not found" error when the item is absent or belongs to
+// another receipt, and a "receipt not found" error when the receipt is absent or deleted.
+func (c *Client) DeleteReceiptItem(ctx context.Context, receiptID, itemID string) (int64, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUnfinalizedReceipt(ctx, tx, receiptID); err != nil {
+		return 0, err
+	}
+	var exists bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM receipt_items ri
+			JOIN receipts r ON r.id = ri.receipt_id
+			WHERE ri.receipt_id = $1 AND ri.id = $2 AND r.deleted_at IS NULL
+		)
+	`, receiptID, itemID).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check receipt item existence: %w", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("receipt item not found")
+	}
 
+	tag, err := tx.Exec(ctx, "DELETE FROM receipt_user_items WHERE receipt_item_id = $1", itemID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete item assignments: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM receipt_items WHERE id = $1 AND receipt_id = $2", itemID, receiptID); err != nil {
+		return 0, fmt.Errorf("failed to delete receipt item: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// scanReceiptItems reads rows selected by receiptItemsQuery
+func scanReceiptItems(rows pgx.Rows) ([]ReceiptItem, error) {
 	items := make([]ReceiptItem, 0)
 	for rows.Next() {
 		var item ReceiptItem
-		err := rows.Scan(&item.ID, &item.ReceiptID, &item.Name, &item.Quantity, &item.TotalPrice, &item.PricePerItem)
+		err := rows.Scan(&item.ID, &item.ReceiptID, &item.Name, &item.Quantity, &item.TotalPrice, &item.PricePerItem, &item.Version, &item.Taxable, &item.IsDiscount, &item.Category, &item.NeedsReview, &item.Shared, &item.Confidence)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan receipt item: %w", err)
 		}
 		items = append(items, item)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating receipt items: %w", err)
 	}
 
 	return items, nil
 }
 
-// GetReceiptAssignments gets all user-item assignments for a receipt
+// GetReceiptAssignments gets all user-item assignments for a receipt, in the order they were made.
+// Assignments made in one transaction share created_at, so ID (a ULID) breaks ties.
 func (c *Client) GetReceiptAssignments(ctx context.Context, receiptID string) ([]ReceiptUserItem, error) {
-	rows, err := c.db.Query(ctx, `
-		SELECT rui.id, rui.receipt_user_id, rui.receipt_item_id, rui.amount_owed, rui.created_at
-		FROM receipt_user_items rui
-		JOIN receipt_users ru ON ru.id = rui.receipt_user_id
-		WHERE ru.receipt_id = $1
-		ORDER BY rui.created_at ASC
-	`, receiptID)
+	rows, err := c.db.Query(ctx, receiptAssignmentsQuery, receiptID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query receipt assignments: %w", err)
 	}
 	defer rows.Close()
+	return scanReceiptAssignments(rows)
+}
 
+// scanReceiptAssignments reads rows selected by receiptAssignmentsQuery
+func scanReceiptAssignments(rows pgx.Rows) ([]ReceiptUserItem, error) {
 	assignments := make([]ReceiptUserItem, 0)
 	for rows.Next() {
 		var a ReceiptUserItem
@@ -268,13 +1304,102 @@ func (c *Client) GetReceiptAssignments(ctx context.Context, receiptID string) ([
 		assignments = append(assignments, a)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating receipt assignments: %w", err)
 	}
 
 	return assignments, nil
 }
 
+// GetReceiptAssignmentsPage gets up to limit user-item assignments for a receipt, ordered by ID.
+// after is the ID of the last assignment from the previous page (empty for the first page).
+// nextCursor is empty when there are no more assignments.
+func (c *Client) GetReceiptAssignmentsPage(ctx context.Context, receiptID string, limit int, after string) ([]ReceiptUserItem, string, error) {
+	// Fetch one extra row to know whether another page exists
+	rows, err := c.db.Query(ctx, `
+		SELECT rui.id, rui.receipt_user_id, rui.receipt_item_id, rui.amount_owed, rui.created_at
+		FROM receipt_user_items rui
+		JOIN receipt_users ru ON ru.id = rui.receipt_user_id
+		WHERE ru.receipt_id = $1 AND rui.id > $2
+		ORDER BY rui.id ASC
+		LIMIT $3
+	`, receiptID, after, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query receipt assignments: %w", err)
+	}
+	defer rows.Close()
+
+	assignments := make([]ReceiptUserItem, 0, limit)
+	for rows.Next() {
+		var a ReceiptUserItem
+		err := rows.Scan(&a.ID, &a.ReceiptUserID, &a.ReceiptItemID, &a.AmountOwed, &a.CreatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan receipt assignment: %w", err)
+		}
+		assignments = append(assignments, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating receipt assignments: %w", err)
+	}
+
+	var nextCursor string
+	if len(assignments) > limit {
+		assignments = assignments[:limit]
+		nextCursor = assignments[limit-1].ID
+	}
+
+	return assignments, nextCursor, nil
+}
+
+// ReceiptUserMatch is a receipt user found by name, with the receipt it belongs to
+type ReceiptUserMatch struct {
+	User             ReceiptUser
+	ReceiptTitle     *string
+	ReceiptCreatedAt time.Time
+}
+
+// SearchReceiptUsersByName finds receipt users across all receipts whose name matches name, ignoring case.
+// Results are ordered by receipt user ID; pass the returned cursor as after to fetch the next page.
+// The cursor is empty on the last page.
+func (c *Client) SearchReceiptUsersByName(ctx context.Context, name string, limit int, after string) ([]ReceiptUserMatch, string, error) {
+	// Fetch one extra row to know whether another page exists
+	rows, err := c.db.Query(ctx, `
+		SELECT ru.id, ru.receipt_id, ru.name, ru.color, ru.created_at, r.title, r.created_at
+		FROM receipt_users ru
+		JOIN receipts r ON r.id = ru.receipt_id
+		WHERE LOWER(ru.name) = LOWER($1) AND ru.id > $2 AND r.deleted_at IS NULL
+		ORDER BY ru.id ASC
+		LIMIT $3
+	`, name, after, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to search receipt users: %w", err)
+	}
+	defer rows.Close()
+
+	matches := make([]ReceiptUserMatch, 0, limit)
+	for rows.Next() {
+		var m ReceiptUserMatch
+		err := rows.Scan(&m.User.ID, &m.User.ReceiptID, &m.User.Name, &m.User.Color, &m.User.CreatedAt, &m.ReceiptTitle, &m.ReceiptCreatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan receipt user: %w", err)
+		}
+		matches = append(matches, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating receipt users: %w", err)
+	}
+
+	var nextCursor string
+	if len(matches) > limit {
+		matches = matches[:limit]
+		nextCursor = matches[limit-1].User.ID
+	}
+
+	return matches, nextCursor, nil
+}
+
 // GetUserItems gets all items assigned to a user
 func (c *Client) GetUserItems(ctx context.Context, receiptUserID string) ([]ReceiptUserItem, error) {
 	rows, err := c.db.Query(ctx, `
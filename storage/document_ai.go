@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math"
 	"os"
 	"regexp"
@@ -17,16 +18,76 @@ import (
 
 // DocumentAIReceipt captures the structured result from Document AI.
 type DocumentAIReceipt struct {
-	Text         string
-	MerchantName string
-	TotalAmount  *float64
-	TaxAmount    *float64
-	Items        []ReceiptItemParsed
+	Processor     string // Processor resource name that produced the result
+	Text          string
+	MerchantName  string
+	TotalAmount   *float64
+	TaxAmount     *float64
+	ServiceCharge *float64 // Only from processors that extract a service_charge entity
+	Items         []ReceiptItemParsed
+}
+
+// Defaults for ConfidenceThresholds when DOCUMENT_AI_REVIEW_CONFIDENCE / DOCUMENT_AI_DROP_CONFIDENCE are not set
+const (
+	defaultReviewConfidence = 0.5
+	defaultDropConfidence   = 0.0 // keep every item
+)
+
+// ConfidenceThresholds decide what happens to Document AI line items read with low confidence: items below
+// Drop are discarded, and items below Review are kept but flagged for review
+type ConfidenceThresholds struct {
+	Review float64
+	Drop   float64
+}
+
+// ConfidenceThresholdsFromEnv reads DOCUMENT_AI_REVIEW_CONFIDENCE and DOCUMENT_AI_DROP_CONFIDENCE, falling back
+// to the defaults when unset, or with a warning when not a number from 0 to 1
+func ConfidenceThresholdsFromEnv(log *slog.Logger) ConfidenceThresholds {
+	return ConfidenceThresholds{
+		Review: confidenceFromEnv(log, "DOCUMENT_AI_REVIEW_CONFIDENCE", defaultReviewConfidence),
+		Drop:   confidenceFromEnv(log, "DOCUMENT_AI_DROP_CONFIDENCE", defaultDropConfidence),
+	}
+}
+
+func confidenceFromEnv(log *slog.Logger, name string, fallback float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	confidence, err := strconv.ParseFloat(value, 64)
+	if err != nil || !(confidence >= 0 && confidence <= 1) {
+		log.Warn("Invalid "+name+", using default", "value", value, "default", fallback)
+		return fallback
+	}
+	return confidence
+}
+
+// FilterLowConfidenceItems drops items below thresholds.Drop and flags the rest below thresholds.Review as
+// needing review. Items without a confidence are kept as they are.
+func FilterLowConfidenceItems(items []ReceiptItemParsed, thresholds ConfidenceThresholds) []ReceiptItemParsed {
+	kept := make([]ReceiptItemParsed, 0, len(items))
+	for _, item := range items {
+		if item.Confidence != nil {
+			if *item.Confidence < thresholds.Drop {
+				continue
+			}
+			if *item.Confidence < thresholds.Review {
+				item.NeedsReview = true
+			}
+		}
+		kept = append(kept, item)
+	}
+	return kept
 }
 
 var moneyPattern = regexp.MustCompile(`[-+]?\d[\d,]*\.?\d{0,2}`)
 var quantityPattern = regexp.MustCompile(`\d+(\.\d+)?`)
 
+// DocumentAIConfigured reports whether a Document AI receipt processor has been configured
+func DocumentAIConfigured() bool {
+	return os.Getenv("DOCUMENT_AI_PROCESSOR_ID") != ""
+}
+
 // ProcessReceiptWithDocumentAI sends the document bytes to the Document AI receipt processor.
 func ProcessReceiptWithDocumentAI(ctx context.Context, documentData []byte, mimeType string) (*DocumentAIReceipt, error) {
 	credsJSON := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS_JSON")
@@ -87,7 +148,8 @@ func ProcessReceiptWithDocumentAI(ctx context.Context, documentData []byte, mime
 	}
 
 	result := &DocumentAIReceipt{
-		Text: doc.GetText(),
+		Processor: processorName,
+		Text:      doc.GetText(),
 	}
 
 	for _, entity := range doc.GetEntities() {
@@ -104,9 +166,13 @@ func ProcessReceiptWithDocumentAI(ctx context.Context, documentData []byte, mime
 			if amount, ok := moneyFromEntity(entity); ok {
 				result.TaxAmount = &amount
 			}
+		case "service_charge", "gratuity_amount":
+			if amount, ok := moneyFromEntity(entity); ok {
+				result.ServiceCharge = &amount
+			}
 		case "line_item":
 			item := parseLineItemEntity(entity)
-			if item.Name != "" && item.TotalPrice > 0 {
+			if item.Name != "" && item.TotalPrice != 0 {
 				result.Items = append(result.Items, item)
 			}
 		}
@@ -116,7 +182,8 @@ func ProcessReceiptWithDocumentAI(ctx context.Context, documentData []byte, mime
 }
 
 func parseLineItemEntity(entity *documentaipb.Document_Entity) ReceiptItemParsed {
-	item := ReceiptItemParsed{Quantity: 1}
+	confidence := float64(entity.GetConfidence())
+	item := ReceiptItemParsed{Quantity: 1, Confidence: &confidence}
 
 	for _, prop := range entity.GetProperties() {
 		switch prop.GetType() {
@@ -135,12 +202,13 @@ func parseLineItemEntity(entity *documentaipb.Document_Entity) ReceiptItemParsed
 		}
 	}
 
-	if item.TotalPrice == 0 && item.PricePerItem > 0 {
+	if item.TotalPrice == 0 && item.PricePerItem != 0 {
 		item.TotalPrice = item.PricePerItem * float64(item.Quantity)
 	}
-	if item.PricePerItem == 0 && item.TotalPrice > 0 {
+	if item.PricePerItem == 0 && item.TotalPrice != 0 {
 		item.PricePerItem = item.TotalPrice / float64(item.Quantity)
 	}
+	item.IsDiscount = item.TotalPrice < 0
 
 	return item
 }
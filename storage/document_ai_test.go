@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestFilterLowConfidenceItems(t *testing.T) {
+	confidence := func(c float64) *float64 { return &c }
+	items := []ReceiptItemParsed{
+		{Name: "Burger", TotalPrice: 12, Confidence: confidence(0.95)},
+		{Name: "Fries", TotalPrice: 4, Confidence: confidence(0.4)},
+		{Name: "Smudge", TotalPrice: 99, Confidence: confidence(0.1)},
+		{Name: "Soda", TotalPrice: 2}, // no confidence from the parser
+	}
+
+	got := FilterLowConfidenceItems(items, ConfidenceThresholds{Review: 0.5, Drop: 0.2})
+
+	if len(got) != 3 {
+		t.Fatalf("kept %d items, want 3: %+v", len(got), got)
+	}
+	want := map[string]bool{"Burger": false, "Fries": true, "Soda": false}
+	for _, item := range got {
+		needsReview, ok := want[item.Name]
+		if !ok {
+			t.Errorf("kept %q, want it dropped", item.Name)
+			continue
+		}
+		if item.NeedsReview != needsReview {
+			t.Errorf("%s: NeedsReview = %v, want %v", item.Name, item.NeedsReview, needsReview)
+		}
+	}
+}
+
+func TestConfidenceThresholdsFromEnv(t *testing.T) {
+	for value, want := range map[string]float64{"": defaultReviewConfidence, "0.7": 0.7, "1": 1, "high": defaultReviewConfidence, "1.5": defaultReviewConfidence, "NaN": defaultReviewConfidence} {
+		t.Setenv("DOCUMENT_AI_REVIEW_CONFIDENCE", value)
+		var logs strings.Builder
+		if got := ConfidenceThresholdsFromEnv(slog.New(slog.NewTextHandler(&logs, nil))).Review; got != want {
+			t.Errorf("DOCUMENT_AI_REVIEW_CONFIDENCE=%q: Review = %v, want %v", value, got, want)
+		}
+		if warned := strings.Contains(logs.String(), "Invalid DOCUMENT_AI_REVIEW_CONFIDENCE"); warned != (value != "" && want == defaultReviewConfidence) {
+			t.Errorf("DOCUMENT_AI_REVIEW_CONFIDENCE=%q: warned = %v", value, warned)
+		}
+	}
+}
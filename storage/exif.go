@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+// JPEG markers used when walking segments
+const (
+	jpegMarkerSOI  = 0xD8
+	jpegMarkerEOI  = 0xD9
+	jpegMarkerSOS  = 0xDA
+	jpegMarkerAPP1 = 0xE1 // EXIF and XMP
+	jpegMarkerAPPD = 0xED // Photoshop/IPTC
+)
+
+// exifOrientationTag is the TIFF tag holding how the camera was held
+const exifOrientationTag = 0x0112
+
+// jpegSegment is one marker segment before the image data; data includes the length bytes
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// splitJPEG splits a JPEG into its header segments and the image data from the first SOS marker up to and
+// including EOI. Anything after EOI (such as the video a phone appends for a motion photo) is dropped.
+func splitJPEG(data []byte) (segments []jpegSegment, scan []byte, err error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return nil, nil, fmt.Errorf("not a JPEG")
+	}
+	pos := 2
+	for {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return nil, nil, fmt.Errorf("corrupt JPEG segment at offset %d", pos)
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // fill byte
+			pos++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, nil, fmt.Errorf("corrupt JPEG segment at offset %d", pos)
+		}
+		if marker == jpegMarkerSOS {
+			// In entropy-coded data 0xFF is always followed by 0x00 or a restart marker, so the first FFD9 is EOI
+			end := bytes.Index(data[pos:], []byte{0xFF, jpegMarkerEOI})
+			if end < 0 {
+				return nil, nil, fmt.Errorf("JPEG has no end-of-image marker")
+			}
+			return segments, data[pos : pos+end+2], nil
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: data[pos+2 : pos+2+length]})
+		pos += 2 + length
+	}
+}
+
+// isMetadataSegment reports whether a segment carries EXIF, XMP, or IPTC metadata rather than anything
+// needed to render the image (JFIF, ICC profiles, and Adobe color transforms are kept)
+func isMetadataSegment(segment jpegSegment) bool {
+	return segment.marker == jpegMarkerAPP1 || segment.marker == jpegMarkerAPPD
+}
+
+// exifOrientation reads the orientation tag from an APP1 EXIF segment; 0 when absent or unreadable
+func exifOrientation(segment jpegSegment) int {
+	payload := segment.data[2:]
+	if !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+		return 0
+	}
+	tiff := payload[6:]
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 0
+			}
+			return orientation
+		}
+	}
+	return 0
+}
+
+// normalizeJPEG turns a JPEG upright per its EXIF orientation and removes its metadata, which can include
+// the location a photo was taken. A JPEG that is already upright is rewritten losslessly without its
+// metadata segments; a rotated or mirrored one is re-encoded. JPEGs without metadata are returned unchanged.
+func normalizeJPEG(imageData []byte) ([]byte, error) {
+	segments, scan, err := splitJPEG(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 0
+	hasMetadata := false
+	for _, segment := range segments {
+		if isMetadataSegment(segment) {
+			hasMetadata = true
+			if o := exifOrientation(segment); o != 0 {
+				orientation = o
+			}
+		}
+	}
+	if !hasMetadata {
+		return imageData, nil
+	}
+
+	if orientation > 1 {
+		img, err := jpeg.Decode(bytes.NewReader(imageData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode JPEG: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, orientUpright(img, orientation), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode image as JPEG: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	out := make([]byte, 0, len(imageData))
+	out = append(out, 0xFF, jpegMarkerSOI)
+	for _, segment := range segments {
+		if isMetadataSegment(segment) {
+			continue
+		}
+		out = append(out, 0xFF, segment.marker)
+		out = append(out, segment.data...)
+	}
+	return append(out, scan...), nil
+}
+
+// orientUpright applies the transform EXIF orientation o (2-8) asks a viewer to make
+func orientUpright(img image.Image, o int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				sx, sy = x, h-1-y
+			case 5: // mirrored, rotated 90° counter-clockwise
+				sx, sy = y, x
+			case 6: // rotated 90° counter-clockwise; turn clockwise
+				sx, sy = y, h-1-x
+			case 7: // mirrored, rotated 90° clockwise
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 90° clockwise; turn counter-clockwise
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
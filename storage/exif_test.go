@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifSegment builds an APP1 EXIF segment whose IFD0 holds only the orientation tag
+func exifSegment(orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8)) // IFD0 offset
+	binary.Write(&tiff, binary.BigEndian, uint16(1)) // one entry
+	binary.Write(&tiff, binary.BigEndian, uint16(exifOrientationTag))
+	binary.Write(&tiff, binary.BigEndian, uint16(3)) // SHORT
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, orientation)
+	binary.Write(&tiff, binary.BigEndian, uint16(0))
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // no next IFD
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, jpegMarkerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// rotatedJPEGFixture is a 64x32 JPEG whose left half is black and right half white, tagged with orientation
+// and followed by trailing bytes like a motion photo's video
+func rotatedJPEGFixture(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 32; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("encode fixture: %v", err)
+	}
+	plain := buf.Bytes()
+	fixture := append([]byte{0xFF, jpegMarkerSOI}, exifSegment(orientation)...)
+	fixture = append(fixture, plain[2:]...)
+	return append(fixture, []byte("ftypmp42 trailing video")...)
+}
+
+func TestNormalizeReceiptImageRotatesPerEXIF(t *testing.T) {
+	fixture := rotatedJPEGFixture(t, 6)
+
+	out, contentType, err := NormalizeReceiptImage(fixture, "image/jpeg")
+	if err != nil {
+		t.Fatalf("NormalizeReceiptImage: %v", err)
+	}
+	if contentType != "image/jpeg" {
+		t.Errorf("content type = %q, want image/jpeg", contentType)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	// Orientation 6 is turned 90° clockwise: 32x64, with the black left half now on top
+	if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 64 {
+		t.Fatalf("size = %dx%d, want 32x64", b.Dx(), b.Dy())
+	}
+	if top, bottom := gray(img.At(16, 16)), gray(img.At(16, 48)); top > 64 || bottom < 192 {
+		t.Errorf("top = %d, bottom = %d; want dark top and light bottom", top, bottom)
+	}
+	if bytes.Contains(out, []byte("Exif")) || bytes.Contains(out, []byte("ftypmp42")) {
+		t.Error("output still contains EXIF or trailing data")
+	}
+}
+
+func TestNormalizeReceiptImageStripsEXIFFromUprightJPEG(t *testing.T) {
+	fixture := rotatedJPEGFixture(t, 1)
+
+	out, _, err := NormalizeReceiptImage(fixture, "image/jpeg")
+	if err != nil {
+		t.Fatalf("NormalizeReceiptImage: %v", err)
+	}
+	if bytes.Contains(out, []byte("Exif")) || bytes.Contains(out, []byte("ftypmp42")) {
+		t.Error("output still contains EXIF or trailing data")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+		t.Errorf("size = %dx%d, want 64x32 unchanged", b.Dx(), b.Dy())
+	}
+}
+
+func TestNormalizeReceiptImageLeavesJPEGWithoutEXIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out, _, err := NormalizeReceiptImage(buf.Bytes(), "image/jpeg")
+	if err != nil {
+		t.Fatalf("NormalizeReceiptImage: %v", err)
+	}
+	if !bytes.Equal(out, buf.Bytes()) {
+		t.Error("JPEG without EXIF was modified")
+	}
+}
+
+func gray(c color.Color) uint8 {
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -94,28 +93,8 @@ func UploadReceiptImageFromReader(ctx context.Context, reader io.Reader, receipt
 	}
 	defer client.Close()
 
-	// Determine file extension from content type
-	ext := ".jpg"
-	if contentType != "" {
-		switch contentType {
-		case "image/png":
-			ext = ".png"
-		case "image/jpeg", "image/jpg":
-			ext = ".jpg"
-		case "image/gif":
-			ext = ".gif"
-		case "image/webp":
-			ext = ".webp"
-		default:
-			ext = filepath.Ext(contentType)
-			if ext == "" {
-				ext = ".jpg"
-			}
-		}
-	}
-
 	// Generate object name with receipt ID
-	objectName := fmt.Sprintf("receipts/%s%s", receiptID, ext)
+	objectName := getObjectName(receiptID, contentType)
 
 	// Create writer for the object
 	wc := client.Bucket(bucketName).Object(objectName).NewWriter(ctx)
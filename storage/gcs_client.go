@@ -2,21 +2,57 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
+// Upload retry settings; the GCS client library does not retry a failed streaming write
+const (
+	uploadAttempts       = 3
+	defaultUploadBackoff = 500 * time.Millisecond
+)
+
 type GCSClient struct {
-	client     *storage.Client
-	bucketName string
+	client        *storage.Client
+	bucketName    string
+	uploadBackoff time.Duration // delay before the first retry; doubles after each attempt
+}
+
+// UploadError is returned when a receipt image upload fails.
+// Transient is true when the failure was a GCS server error or network problem that persisted
+// through every retry, as opposed to a request GCS rejected outright.
+type UploadError struct {
+	Transient bool
+	Attempts  int
+	Err       error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("failed to upload receipt image after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
 }
 
+// bucketCheckTimeout bounds the startup check that the configured bucket exists
+const bucketCheckTimeout = 10 * time.Second
+
+// NewGCSClient creates a client for the bucket named by GCS_BUCKET_NAME (default "splitzies"),
+// returning an error if the bucket doesn't exist or the credentials can't read it.
 func NewGCSClient(ctx context.Context) (*GCSClient, error) {
 	credsJSON := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS_JSON")
 	if credsJSON == "" {
@@ -34,17 +70,95 @@ func NewGCSClient(ctx context.Context) (*GCSClient, error) {
 		return nil, fmt.Errorf("failed to create GCS client: %w", err)
 	}
 
+	// Fail at startup rather than on the first upload when the bucket is missing or unreachable
+	attrsCtx, cancel := context.WithTimeout(ctx, bucketCheckTimeout)
+	defer cancel()
+	if _, err := client.Bucket(bucketName).Attrs(attrsCtx); err != nil {
+		client.Close()
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			return nil, fmt.Errorf("GCS bucket %q does not exist; check GCS_BUCKET_NAME", bucketName)
+		}
+		return nil, fmt.Errorf("GCS bucket %q is not reachable (check GCS_BUCKET_NAME and the credentials' permissions): %w", bucketName, err)
+	}
+
 	return &GCSClient{
-		client:     client,
-		bucketName: bucketName,
+		client:        client,
+		bucketName:    bucketName,
+		uploadBackoff: defaultUploadBackoff,
 	}, nil
 }
 
+// BucketName returns the bucket receipt images are stored in
+func (c *GCSClient) BucketName() string {
+	return c.bucketName
+}
+
+// UploadReceiptImageFromReader uploads an image and returns its media link.
+// Transient failures are retried with backoff until ctx is done, provided reader is an io.Seeker
+// so it can be rewound; errors are returned as *UploadError.
 func (c *GCSClient) UploadReceiptImageFromReader(ctx context.Context, reader io.Reader, receiptID string, contentType string) (string, error) {
+	var mediaLink string
+	err := retryUpload(ctx, reader, uploadAttempts, c.uploadBackoff, func() error {
+		var err error
+		mediaLink, err = c.uploadOnce(ctx, reader, receiptID, contentType)
+		return err
+	})
+	return mediaLink, err
+}
+
+// retryUpload calls upload until it succeeds, fails with a non-transient error, runs out of attempts,
+// or ctx is done. reader is rewound before each retry; readers that can't seek are tried once.
+func retryUpload(ctx context.Context, reader io.Reader, attempts int, backoff time.Duration, upload func() error) error {
+	seeker, canRewind := reader.(io.Seeker)
+	for attempt := 1; ; attempt++ {
+		err := upload()
+		if err == nil {
+			return nil
+		}
+		transient := isTransientGCSError(err)
+		if !transient || !canRewind || attempt == attempts {
+			return &UploadError{Transient: transient, Attempts: attempt, Err: err}
+		}
+
+		select {
+		case <-ctx.Done():
+			return &UploadError{Transient: transient, Attempts: attempt, Err: err}
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		if _, seekErr := seeker.Seek(0, io.SeekStart); seekErr != nil {
+			return &UploadError{Transient: transient, Attempts: attempt, Err: err}
+		}
+	}
+}
+
+// isTransientGCSError reports whether err is worth retrying: a GCS 5xx or 429, or a network failure.
+// Context cancellation is not, since retrying can't outlive the caller.
+func isTransientGCSError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// uploadOnce writes reader to the receipt's object and returns the object's media link
+func (c *GCSClient) uploadOnce(ctx context.Context, reader io.Reader, receiptID string, contentType string) (string, error) {
 	bucket := c.client.Bucket(c.bucketName)
 	object := bucket.Object(getObjectName(receiptID, contentType))
 
 	writer := object.NewWriter(ctx)
+	// Stream the image in a single request instead of copying it into a 16MiB chunk buffer first; receipt
+	// images are bounded by MAX_UPLOAD_BYTES, and retryUpload rewinds reader to retry
+	writer.ChunkSize = 0
 	writer.ContentType = contentType
 	writer.Metadata = map[string]string{
 		"receipt_id":  receiptID,
@@ -66,32 +180,103 @@ func (c *GCSClient) UploadReceiptImageFromReader(ctx context.Context, reader io.
 	return attrs.MediaLink, nil
 }
 
+// DeleteReceiptImage deletes the image stored for receiptID with the given content type.
+// Deleting an image that doesn't exist is not an error.
+func (c *GCSClient) DeleteReceiptImage(ctx context.Context, receiptID string, contentType string) error {
+	return c.deleteObject(ctx, getObjectName(receiptID, contentType))
+}
+
+// DeleteReceiptImageByURL deletes the image at a URL previously returned by an upload
+// (a GCS media link, a storage.googleapis.com public URL, or a gs:// URL).
+// Deleting an image that doesn't exist is not an error.
+func (c *GCSClient) DeleteReceiptImageByURL(ctx context.Context, imageURL string) error {
+	objectName, err := objectNameFromURL(c.bucketName, imageURL)
+	if err != nil {
+		return err
+	}
+	return c.deleteObject(ctx, objectName)
+}
+
+func (c *GCSClient) deleteObject(ctx context.Context, objectName string) error {
+	err := c.client.Bucket(c.bucketName).Object(objectName).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete receipt image %s: %w", objectName, err)
+	}
+	return nil
+}
+
 func (c *GCSClient) Close() error {
 	return c.client.Close()
 }
 
+// imageExtensions maps supported image content types to object name extensions
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/jpg":  ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/heic": ".heic",
+	"image/heif": ".heif",
+}
+
 func getObjectName(receiptID string, contentType string) string {
-	// Determine file extension from content type
+	// Determine file extension from content type; a missing content type is treated as JPEG
+	// and anything unrecognized gets a generic .bin rather than a guess from the MIME string
 	ext := ".jpg"
 	if contentType != "" {
-		switch contentType {
-		case "image/png":
-			ext = ".png"
-		case "image/jpeg", "image/jpg":
-			ext = ".jpg"
-		case "image/gif":
-			ext = ".gif"
-		case "image/webp":
-			ext = ".webp"
-		default:
-			ext = filepath.Ext(contentType)
-			if ext == "" {
-				ext = ".jpg"
-			}
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			mediaType = contentType
+		}
+		var ok bool
+		if ext, ok = imageExtensions[strings.ToLower(mediaType)]; !ok {
+			ext = ".bin"
 		}
 	}
 
-	// Generate object name with receipt ID
+	// Generate object name with receipt ID, under GCS_OBJECT_PREFIX when set (e.g. "staging")
 	objectName := fmt.Sprintf("receipts/%s%s", receiptID, ext)
+	if prefix := strings.Trim(os.Getenv("GCS_OBJECT_PREFIX"), "/"); prefix != "" {
+		objectName = prefix + "/" + objectName
+	}
 	return objectName
 }
+
+// objectNameFromURL extracts the object name from an image URL in bucketName.
+// Supports media links (.../download/storage/v1/b/{bucket}/o/{escaped object}?alt=media),
+// public URLs (https://storage.googleapis.com/{bucket}/{object}) and gs://{bucket}/{object}.
+func objectNameFromURL(bucketName, imageURL string) (string, error) {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid image URL: %w", err)
+	}
+
+	var bucket, objectName string
+	switch {
+	case u.Scheme == "gs":
+		bucket, objectName = u.Host, strings.TrimPrefix(u.Path, "/")
+	case u.Host == "storage.googleapis.com" && strings.HasPrefix(u.EscapedPath(), "/download/storage/v1/b/"):
+		// The object segment is path-escaped ("receipts%2Fabc.jpg"), so split the escaped path
+		parts := strings.SplitN(strings.TrimPrefix(u.EscapedPath(), "/download/storage/v1/b/"), "/o/", 2)
+		if len(parts) != 2 {
+			return "", fmt.Errorf("unrecognized GCS media link: %s", imageURL)
+		}
+		bucket = parts[0]
+		if objectName, err = url.PathUnescape(parts[1]); err != nil {
+			return "", fmt.Errorf("invalid object name in image URL: %w", err)
+		}
+	case u.Host == "storage.googleapis.com":
+		bucket, objectName, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	default:
+		return "", fmt.Errorf("not a GCS image URL: %s", imageURL)
+	}
+
+	if bucket != bucketName {
+		return "", fmt.Errorf("image URL is in bucket %q, not %q", bucket, bucketName)
+	}
+	if objectName == "" {
+		return "", fmt.Errorf("image URL has no object name: %s", imageURL)
+	}
+	return objectName, nil
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestGetObjectName(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"image/png", "receipts/abc.png"},
+		{"image/jpeg", "receipts/abc.jpg"},
+		{"image/jpg", "receipts/abc.jpg"},
+		{"image/webp", "receipts/abc.webp"},
+		{"IMAGE/PNG", "receipts/abc.png"},
+		{"image/jpeg; charset=binary", "receipts/abc.jpg"},
+		{"", "receipts/abc.jpg"},
+		{"application/octet-stream", "receipts/abc.bin"},
+		{"not a mime type", "receipts/abc.bin"},
+	}
+	for _, tt := range tests {
+		if got := getObjectName("abc", tt.contentType); got != tt.want {
+			t.Errorf("getObjectName(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestGetObjectNamePrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"", "receipts/abc.png"},
+		{"staging", "staging/receipts/abc.png"},
+		{"/prod/2024/", "prod/2024/receipts/abc.png"},
+		{"/", "receipts/abc.png"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GCS_OBJECT_PREFIX", tt.prefix)
+		if got := getObjectName("abc", "image/png"); got != tt.want {
+			t.Errorf("with prefix %q getObjectName() = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestObjectNameFromURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{url: "https://storage.googleapis.com/download/storage/v1/b/splitzies/o/receipts%2Fabc.jpg?generation=1700000000000000&alt=media", want: "receipts/abc.jpg"},
+		{url: "https://storage.googleapis.com/download/storage/v1/b/splitzies/o/staging%2Freceipts%2Fabc.png?alt=media", want: "staging/receipts/abc.png"},
+		{url: "https://storage.googleapis.com/splitzies/receipts/abc.jpg", want: "receipts/abc.jpg"},
+		{url: "gs://splitzies/receipts/abc.jpg", want: "receipts/abc.jpg"},
+		{url: "https://storage.googleapis.com/other-bucket/receipts/abc.jpg", wantErr: true},
+		{url: "https://storage.googleapis.com/splitzies", wantErr: true},
+		{url: "https://example.com/receipts/abc.jpg", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := objectNameFromURL("splitzies", tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("objectNameFromURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("objectNameFromURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransientGCSError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &googleapi.Error{Code: http.StatusServiceUnavailable}, true},
+		{"rate limited", fmt.Errorf("wrapped: %w", &googleapi.Error{Code: http.StatusTooManyRequests}), true},
+		{"forbidden", &googleapi.Error{Code: http.StatusForbidden}, false},
+		{"connection reset", fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{"truncated response", io.ErrUnexpectedEOF, true},
+		{"deadline", context.DeadlineExceeded, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientGCSError(tt.err); got != tt.want {
+				t.Errorf("isTransientGCSError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryUpload(t *testing.T) {
+	transient := &googleapi.Error{Code: http.StatusBadGateway}
+
+	t.Run("retries transient errors and rewinds the reader", func(t *testing.T) {
+		reader := bytes.NewReader([]byte("image"))
+		calls := 0
+		err := retryUpload(context.Background(), reader, 3, 0, func() error {
+			calls++
+			data, _ := io.ReadAll(reader)
+			if string(data) != "image" {
+				t.Errorf("attempt %d read %q, want the full image", calls, data)
+			}
+			if calls < 3 {
+				return transient
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Errorf("err = %v after %d calls, want success on the third", err, calls)
+		}
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		calls := 0
+		err := retryUpload(context.Background(), bytes.NewReader(nil), 3, 0, func() error {
+			calls++
+			return transient
+		})
+		var uploadErr *UploadError
+		if !errors.As(err, &uploadErr) || !uploadErr.Transient || uploadErr.Attempts != 3 || calls != 3 {
+			t.Errorf("err = %#v after %d calls, want transient UploadError after 3 attempts", err, calls)
+		}
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		calls := 0
+		err := retryUpload(context.Background(), bytes.NewReader(nil), 3, 0, func() error {
+			calls++
+			return &googleapi.Error{Code: http.StatusForbidden}
+		})
+		var uploadErr *UploadError
+		if !errors.As(err, &uploadErr) || uploadErr.Transient || calls != 1 {
+			t.Errorf("err = %#v after %d calls, want one non-transient attempt", err, calls)
+		}
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		calls := 0
+		err := retryUpload(ctx, bytes.NewReader(nil), 3, time.Hour, func() error {
+			calls++
+			return transient
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("err = %v after %d calls, want failure after one attempt", err, calls)
+		}
+	})
+}
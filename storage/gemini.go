@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,28 +20,177 @@ type geminiReceiptItem struct {
 	Quantity     int      `json:"quantity"`
 	TotalPrice   *float64 `json:"total_price,omitempty"`
 	PricePerItem *float64 `json:"price_per_item,omitempty"`
+	IsDiscount   bool     `json:"is_discount,omitempty"`
+	Category     *string  `json:"category,omitempty"`
+}
+
+// ItemCategories is the controlled vocabulary Gemini picks line-item categories from
+var ItemCategories = []string{"food", "drink", "alcohol", "service", "other"}
+
+// normalizeItemCategory lowercases value and returns nil unless it is one of ItemCategories
+func normalizeItemCategory(value *string) *string {
+	normalized := normalizeOptionalString(value)
+	if normalized == nil {
+		return nil
+	}
+	category := strings.ToLower(*normalized)
+	for _, known := range ItemCategories {
+		if category == known {
+			return &category
+		}
+	}
+	return nil
 }
 
 type geminiReceiptData struct {
-	Items       []geminiReceiptItem `json:"items"`
-	Currency    *string            `json:"currency"`
-	Date        *string            `json:"date"`
-	ReceiptDate *string            `json:"receipt_date"`
-	Title       *string            `json:"title"`
-	Tax         *float64           `json:"tax"`
-	Tip         *float64           `json:"tip"`
+	Items         []geminiReceiptItem `json:"items"`
+	Currency      *string             `json:"currency"`
+	Date          *string             `json:"date"`
+	ReceiptDate   *string             `json:"receipt_date"`
+	Title         *string             `json:"title"`
+	Tax           *float64            `json:"tax"`
+	Tip           *float64            `json:"tip"`
+	ServiceCharge *float64            `json:"service_charge"`
+	TaxInclusive  *bool               `json:"tax_inclusive"`
+}
+
+// geminiModel is the Gemini model receipts are parsed with
+const geminiModel = "gemini-2.0-flash-001"
+
+// Defaults for ItemLimits when GEMINI_MAX_UNIT_PRICE / GEMINI_MAX_QUANTITY are not set
+const (
+	defaultMaxUnitPrice = 1000.0
+	defaultMaxQuantity  = 100
+)
+
+// ItemLimits bounds what counts as a plausible parsed line item; anything beyond is flagged for review
+type ItemLimits struct {
+	MaxUnitPrice float64
+	MaxQuantity  int
+}
+
+// itemLimitsFromEnv reads GEMINI_MAX_UNIT_PRICE and GEMINI_MAX_QUANTITY, falling back to the defaults
+// when unset or not positive
+func itemLimitsFromEnv() ItemLimits {
+	limits := ItemLimits{MaxUnitPrice: defaultMaxUnitPrice, MaxQuantity: defaultMaxQuantity}
+	if v, err := strconv.ParseFloat(os.Getenv("GEMINI_MAX_UNIT_PRICE"), 64); err == nil && v > 0 {
+		limits.MaxUnitPrice = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("GEMINI_MAX_QUANTITY")); err == nil && v > 0 {
+		limits.MaxQuantity = v
+	}
+	return limits
+}
+
+// flagImplausibleItems marks items whose unit price or quantity exceeds limits as needing review
+// (e.g. a misread "$9,999,999") and reports whether any were flagged
+func flagImplausibleItems(items []ReceiptItemParsed, limits ItemLimits) bool {
+	flagged := false
+	for i := range items {
+		if math.Abs(items[i].PricePerItem) > limits.MaxUnitPrice || items[i].Quantity > limits.MaxQuantity {
+			items[i].NeedsReview = true
+			flagged = true
+		}
+	}
+	return flagged
+}
+
+// Defaults for GenerationSettings when the GEMINI_* generation variables are not set
+const (
+	defaultGeminiTemperature = 0.1
+	defaultGeminiTopP        = 0.95
+	defaultGeminiTopK        = 40
+	// minGeminiOutputTokens is the output budget for short receipts; longer OCR text gets proportionally more
+	minGeminiOutputTokens = 1024
+	// maxGeminiOutputTokens is the most the model can return; truncated responses are retried up to this
+	maxGeminiOutputTokens = 8192
+)
+
+// GenerationSettings are the sampling parameters and output budget receipts are parsed with
+type GenerationSettings struct {
+	Temperature     float32
+	TopP            float32
+	TopK            float32
+	MaxOutputTokens int32 // 0 sizes the budget from the OCR text; see outputTokenBudget
+}
+
+// generationSettingsFromEnv reads GEMINI_TEMPERATURE, GEMINI_TOP_P, GEMINI_TOP_K, and GEMINI_MAX_OUTPUT_TOKENS,
+// falling back to the defaults when unset or out of range
+func generationSettingsFromEnv() GenerationSettings {
+	settings := GenerationSettings{Temperature: defaultGeminiTemperature, TopP: defaultGeminiTopP, TopK: defaultGeminiTopK}
+	if v, err := strconv.ParseFloat(os.Getenv("GEMINI_TEMPERATURE"), 32); err == nil && v >= 0 && v <= 2 {
+		settings.Temperature = float32(v)
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("GEMINI_TOP_P"), 32); err == nil && v > 0 && v <= 1 {
+		settings.TopP = float32(v)
+	}
+	if v, err := strconv.Atoi(os.Getenv("GEMINI_TOP_K")); err == nil && v > 0 {
+		settings.TopK = float32(v)
+	}
+	if v, err := strconv.Atoi(os.Getenv("GEMINI_MAX_OUTPUT_TOKENS")); err == nil && v > 0 {
+		settings.MaxOutputTokens = int32(min(v, maxGeminiOutputTokens))
+	}
+	return settings
+}
+
+// outputTokenBudget is the first MaxOutputTokens to request for ocrText. Unless configured, it is one token per
+// OCR character (the JSON for a line is several times longer than the line itself), between
+// minGeminiOutputTokens and maxGeminiOutputTokens.
+func outputTokenBudget(settings GenerationSettings, ocrText string) int32 {
+	if settings.MaxOutputTokens > 0 {
+		return settings.MaxOutputTokens
+	}
+	return int32(min(max(len(ocrText), minGeminiOutputTokens), maxGeminiOutputTokens))
+}
+
+// geminiTruncated reports whether Gemini stopped because it ran out of output tokens, leaving incomplete JSON
+func geminiTruncated(resp *genai.GenerateContentResponse) bool {
+	return resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens
 }
 
 type GeminiReceiptParseResult struct {
-	Items       []ReceiptItemParsed
-	Currency    *string
-	ReceiptDate *time.Time
-	Title       *string
-	Tax         *float64
-	Tip         *float64
+	Items         []ReceiptItemParsed
+	Currency      *string
+	ReceiptDate   *time.Time
+	Title         *string
+	Tax           *float64
+	Tip           *float64 // Voluntary tip only
+	ServiceCharge *float64 // Automatic service charge or included gratuity
+	ModelVersion  string   // Model that produced the result, as reported by Gemini
+	NeedsReview   bool     // Some item had an implausible price or quantity
+	TaxInclusive  bool     // Item prices already include Tax (e.g. "VAT included")
+	Usage         GeminiUsage
+}
+
+// GeminiUsage is what parsing one receipt cost: tokens as reported by Gemini, summed over every call
+// (a truncated response is retried with a larger budget), and the time spent waiting on them
+type GeminiUsage struct {
+	Calls           int
+	PromptTokens    int
+	CandidateTokens int // Output tokens
+	TotalTokens     int
+	Latency         time.Duration
+	// TruncatedCalls are the calls whose response ran out of output tokens; all but the last are retried with
+	// twice the budget, up to MaxOutputTokens
+	TruncatedCalls  int
+	MaxOutputTokens int32 // Output token budget of the last call
+}
+
+// add records one GenerateContent call that took latency; resp is nil when the call failed
+func (u *GeminiUsage) add(resp *genai.GenerateContentResponse, latency time.Duration) {
+	u.Calls++
+	u.Latency += latency
+	if resp == nil || resp.UsageMetadata == nil {
+		return
+	}
+	u.PromptTokens += int(resp.UsageMetadata.PromptTokenCount)
+	u.CandidateTokens += int(resp.UsageMetadata.CandidatesTokenCount)
+	u.TotalTokens += int(resp.UsageMetadata.TotalTokenCount)
 }
 
 // ParseReceiptItemsWithGemini parses OCR text into receipt items using Gemini.
+// On error the result is empty except for Usage, which is set whenever Gemini was called, since a response
+// that can't be parsed still costs tokens.
 func ParseReceiptItemsWithGemini(ctx context.Context, ocrText string) (GeminiReceiptParseResult, error) {
 	var empty GeminiReceiptParseResult
 	if strings.TrimSpace(ocrText) == "" {
@@ -85,53 +237,76 @@ func ParseReceiptItemsWithGemini(ctx context.Context, ocrText string) (GeminiRec
 Return ONLY valid JSON with this schema:
 {
   "items": [
-    {"name": "string", "quantity": 1, "total_price": 1.23, "price_per_item": 1.23}
+    {"name": "string", "quantity": 1, "total_price": 1.23, "price_per_item": 1.23, "is_discount": false, "category": "food"}
   ],
   "currency": "string",
   "receipt_date": "string (ISO 8601 date: YYYY-MM-DD preferred)",
   "title": "string",
   "tax": 1.23,
-  "tip": 2.50
+  "tip": 2.50,
+  "service_charge": 9.00,
+  "tax_inclusive": false
 }
 Rules:
-- Include only line items in items (exclude tax, totals, payment, change, headers, footers).
+- Include only line items in items (exclude tax, tip, service charge, totals, payment, change, headers, footers).
+- Include coupons, discounts, and promotions as items with a negative total_price and "is_discount": true.
 - If quantity is missing, use 1.
+- category: one of "food", "drink", "alcohol", "service", or "other". Use null if unsure.
 - If total_price or price_per_item is missing, set it to null.
 - Try to convert the name into a human-readable format (e.g., "Coca-Cola" instead of "COLA").
 - Title should be the restaurant name or where the receipt is from.
 - If currency is not explicit, try to infer it from the context (e.g., "USD" for US-based receipts). If no currency is found, leave it null.
 - tax: Parse the sales tax amount if present (e.g., "Tax: $1.50"). Null if not found.
-- tip: Parse the tip/gratuity amount if present (e.g., "Tip: $5.00"). Null if not found.
+- tip: Parse the voluntary tip amount if present (e.g., "Tip: $5.00"). Null if not found.
+- service_charge: Parse an automatic service charge or included gratuity if present (e.g., "Service Charge 18%%: $9.00", "Gratuity included: $9.00", "Auto Grat"). Null if not found.
+- Never count the same amount as both tip and service_charge. A gratuity the receipt says is included or automatic is a service_charge, not a tip.
+- tax_inclusive: true if the item prices already include the tax, e.g. the receipt says "VAT included", "incl. VAT", "Prices include GST", "MwSt. enthalten", "TVA incluse", or "税込", or the total equals the sum of the items with no tax added. Still parse the included tax amount into tax. Otherwise false.
 
 Receipt OCR text:
 ---
 %s
 ---`, ocrText)
 
+	settings := generationSettingsFromEnv()
 	config := &genai.GenerateContentConfig{
-		Temperature:     genai.Ptr(float32(0.1)),
-		TopP:            genai.Ptr(float32(0.95)),
-		TopK:            genai.Ptr(float32(40)),
-		MaxOutputTokens: 1024,
+		Temperature:     genai.Ptr(settings.Temperature),
+		TopP:            genai.Ptr(settings.TopP),
+		TopK:            genai.Ptr(settings.TopK),
+		MaxOutputTokens: outputTokenBudget(settings, ocrText),
 	}
-	resp, err := client.Models.GenerateContent(ctx, "gemini-2.0-flash-001", genai.Text(prompt), config)
-	if err != nil {
-		return empty, fmt.Errorf("failed to generate content: %w", err)
+	var resp *genai.GenerateContentResponse
+	var usage GeminiUsage
+	for {
+		start := time.Now()
+		resp, err = client.Models.GenerateContent(ctx, geminiModel, genai.Text(prompt), config)
+		usage.add(resp, time.Since(start))
+		usage.MaxOutputTokens = config.MaxOutputTokens
+		if err != nil {
+			return GeminiReceiptParseResult{Usage: usage}, fmt.Errorf("failed to generate content: %w", err)
+		}
+		if !geminiTruncated(resp) {
+			break
+		}
+		usage.TruncatedCalls++
+		if config.MaxOutputTokens >= maxGeminiOutputTokens {
+			return GeminiReceiptParseResult{Usage: usage}, fmt.Errorf("Gemini response truncated at %d output tokens", config.MaxOutputTokens)
+		}
+		config.MaxOutputTokens = min(config.MaxOutputTokens*2, maxGeminiOutputTokens)
 	}
 
 	fmt.Println("Gemini response:", resp)
 
 	responseText := extractGeminiText(resp)
 	if responseText == "" {
-		return empty, fmt.Errorf("empty response from Gemini")
+		return GeminiReceiptParseResult{Usage: usage}, fmt.Errorf("empty response from Gemini")
 	}
 
 	fmt.Println("Gemini response text:", responseText)
 	cleaned := cleanGeminiJSON(responseText)
 	fmt.Println("Cleaned Gemini JSON:", cleaned)
-	var parsed geminiReceiptData
-	if err := json.Unmarshal([]byte(cleaned), &parsed); err != nil {
-		return empty, fmt.Errorf("failed to parse Gemini JSON: %w", err)
+	parsed, err := decodeGeminiReceipt(cleaned)
+	if err != nil {
+		return GeminiReceiptParseResult{Usage: usage}, fmt.Errorf("failed to parse Gemini JSON: %w", err)
 	}
 
 	items := make([]ReceiptItemParsed, 0, len(parsed.Items))
@@ -162,7 +337,13 @@ Receipt OCR text:
 			pricePerItem = *item.PricePerItem
 		}
 
-		if totalPrice <= 0 || pricePerItem <= 0 {
+		if item.IsDiscount {
+			// Discounts are sometimes returned as positive amounts; store them as negative
+			totalPrice, pricePerItem = -math.Abs(totalPrice), -math.Abs(pricePerItem)
+			if totalPrice == 0 {
+				continue
+			}
+		} else if totalPrice <= 0 || pricePerItem <= 0 {
 			continue
 		}
 
@@ -171,6 +352,8 @@ Receipt OCR text:
 			Quantity:     qty,
 			TotalPrice:   totalPrice,
 			PricePerItem: pricePerItem,
+			IsDiscount:   item.IsDiscount,
+			Category:     normalizeItemCategory(item.Category),
 		})
 	}
 
@@ -179,13 +362,25 @@ Receipt OCR text:
 		receiptDate = parseReceiptDate(parsed.Date)
 	}
 
+	needsReview := flagImplausibleItems(items, itemLimitsFromEnv())
+
+	modelVersion := resp.ModelVersion
+	if modelVersion == "" {
+		modelVersion = geminiModel
+	}
+
 	return GeminiReceiptParseResult{
-		Items:       items,
-		Currency:    normalizeOptionalString(parsed.Currency),
-		ReceiptDate: receiptDate,
-		Title:       normalizeOptionalString(parsed.Title),
-		Tax:         parsed.Tax,
-		Tip:         parsed.Tip,
+		Items:         items,
+		Currency:      normalizeOptionalString(parsed.Currency),
+		ReceiptDate:   receiptDate,
+		Title:         normalizeOptionalString(parsed.Title),
+		Tax:           parsed.Tax,
+		Tip:           parsed.Tip,
+		ServiceCharge: parsed.ServiceCharge,
+		ModelVersion:  modelVersion,
+		NeedsReview:   needsReview,
+		TaxInclusive:  parsed.TaxInclusive != nil && *parsed.TaxInclusive,
+		Usage:         usage,
 	}, nil
 }
 
@@ -219,10 +414,10 @@ func parseReceiptDate(value *string) *time.Time {
 		return nil
 	}
 	layouts := []string{
-		"2006-01-02",           // ISO 8601
-		"2006-01-02T15:04:05",  // ISO 8601 with time
-		"01/02/2006",           // US
-		"02/01/2006",           // EU
+		"2006-01-02",          // ISO 8601
+		"2006-01-02T15:04:05", // ISO 8601 with time
+		"01/02/2006",          // US
+		"02/01/2006",          // EU
 		"2006/01/02",
 		"Jan 2, 2006",
 		"January 2, 2006",
@@ -238,18 +433,90 @@ func parseReceiptDate(value *string) *time.Time {
 	return nil
 }
 
+// geminiFencePattern matches a markdown code fence (with or without a language tag) anywhere in the response
+var geminiFencePattern = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*(.*?)```")
+
+// cleanGeminiJSON extracts the JSON value from a Gemini response that may be wrapped in markdown fences or prose.
+// It returns the first balanced object or array that is valid JSON, so stray braces in surrounding text
+// don't break parsing. If none is found the trimmed input is returned for json.Unmarshal to report on.
 func cleanGeminiJSON(input string) string {
 	cleaned := strings.TrimSpace(input)
-	cleaned = strings.TrimPrefix(cleaned, "```json")
-	cleaned = strings.TrimPrefix(cleaned, "```")
-	cleaned = strings.TrimSuffix(cleaned, "```")
-	cleaned = strings.TrimSpace(cleaned)
+	if match := geminiFencePattern.FindStringSubmatch(cleaned); match != nil {
+		cleaned = strings.TrimSpace(match[1])
+	}
+	if json.Valid([]byte(cleaned)) {
+		return cleaned
+	}
 
-	start := strings.Index(cleaned, "{")
-	end := strings.LastIndex(cleaned, "}")
-	if start >= 0 && end >= start {
-		return cleaned[start : end+1]
+	for start := 0; start < len(cleaned); start++ {
+		if cleaned[start] != '{' && cleaned[start] != '[' {
+			continue
+		}
+		if end := matchingBracket(cleaned, start); end > start && json.Valid([]byte(cleaned[start:end+1])) {
+			return cleaned[start : end+1]
+		}
 	}
 
 	return cleaned
 }
+
+// matchingBracket returns the index of the bracket closing the one at s[start], skipping brackets inside
+// JSON strings, or -1 if it is never closed
+func matchingBracket(s string, start int) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// decodeGeminiReceipt unmarshals cleaned Gemini JSON. Besides the requested object it accepts a top-level array,
+// either of receipt objects (the first is used) or of bare line items.
+func decodeGeminiReceipt(cleaned string) (geminiReceiptData, error) {
+	var parsed geminiReceiptData
+	if !strings.HasPrefix(cleaned, "[") {
+		err := json.Unmarshal([]byte(cleaned), &parsed)
+		return parsed, err
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(cleaned), &elements); err != nil {
+		return parsed, err
+	}
+	if len(elements) == 0 {
+		return parsed, nil
+	}
+	var first map[string]json.RawMessage
+	if err := json.Unmarshal(elements[0], &first); err != nil {
+		return parsed, fmt.Errorf("unexpected array element: %w", err)
+	}
+	if _, ok := first["items"]; ok {
+		err := json.Unmarshal(elements[0], &parsed)
+		return parsed, err
+	}
+	err := json.Unmarshal([]byte(cleaned), &parsed.Items)
+	return parsed, err
+}
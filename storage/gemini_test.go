@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestNormalizeItemCategory(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name  string
+		value *string
+		want  *string
+	}{
+		{name: "known category", value: str("drink"), want: str("drink")},
+		{name: "case and whitespace", value: str(" Alcohol "), want: str("alcohol")},
+		{name: "outside the vocabulary", value: str("dessert")},
+		{name: "empty", value: str("")},
+		{name: "null", value: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeItemCategory(tt.value)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("normalizeItemCategory = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagImplausibleItems(t *testing.T) {
+	items := []ReceiptItemParsed{
+		{Name: "Burger", Quantity: 1, TotalPrice: 12.99, PricePerItem: 12.99},
+		{Name: "Steak", Quantity: 1, TotalPrice: 9999999.00, PricePerItem: 9999999.00}, // misread price
+		{Name: "Napkins", Quantity: 5000, TotalPrice: 5.00, PricePerItem: 0.001},
+		{Name: "COUPON", Quantity: 1, TotalPrice: -5.00, PricePerItem: -5.00, IsDiscount: true},
+	}
+
+	flagged := flagImplausibleItems(items, ItemLimits{MaxUnitPrice: 1000, MaxQuantity: 100})
+
+	if !flagged {
+		t.Error("flagImplausibleItems = false, want true")
+	}
+	want := []bool{false, true, true, false}
+	for i, item := range items {
+		if item.NeedsReview != want[i] {
+			t.Errorf("%s NeedsReview = %v, want %v", item.Name, item.NeedsReview, want[i])
+		}
+	}
+
+	if flagImplausibleItems(items[:1], ItemLimits{MaxUnitPrice: 1000, MaxQuantity: 100}) {
+		t.Error("plausible items were flagged")
+	}
+}
+
+func TestItemLimitsFromEnv(t *testing.T) {
+	t.Setenv("GEMINI_MAX_UNIT_PRICE", "250.50")
+	t.Setenv("GEMINI_MAX_QUANTITY", "not a number")
+
+	limits := itemLimitsFromEnv()
+
+	if limits.MaxUnitPrice != 250.50 {
+		t.Errorf("MaxUnitPrice = %v, want 250.50", limits.MaxUnitPrice)
+	}
+	if limits.MaxQuantity != defaultMaxQuantity {
+		t.Errorf("MaxQuantity = %v, want default %v", limits.MaxQuantity, defaultMaxQuantity)
+	}
+}
+
+func TestCleanGeminiJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "bare object", input: `{"items": []}`, want: `{"items": []}`},
+		{name: "fenced json", input: "```json\n{\"items\": []}\n```", want: `{"items": []}`},
+		{name: "fence without language tag", input: "```\n{\"items\": []}\n```", want: `{"items": []}`},
+		{name: "fence after prose", input: "Here you go:\n```JSON\n{\"items\": []}\n```\nLet me know!", want: `{"items": []}`},
+		{name: "top-level array", input: `[{"name": "Soda", "quantity": 1}]`, want: `[{"name": "Soda", "quantity": 1}]`},
+		{
+			name:  "prose with stray braces",
+			input: `Parsed {as requested}: {"title": "Joe's {Diner}", "items": []} Hope that helps :}`,
+			want:  `{"title": "Joe's {Diner}", "items": []}`,
+		},
+		{name: "no json", input: "sorry, I can't read this", want: "sorry, I can't read this"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanGeminiJSON(tt.input); got != tt.want {
+				t.Errorf("cleanGeminiJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeGeminiReceipt(t *testing.T) {
+	t.Run("array of items", func(t *testing.T) {
+		parsed, err := decodeGeminiReceipt(cleanGeminiJSON("```json\n[{\"name\": \"Soda\", \"quantity\": 2, \"total_price\": 4.00}]\n```"))
+		if err != nil {
+			t.Fatalf("decodeGeminiReceipt: %v", err)
+		}
+		if len(parsed.Items) != 1 || parsed.Items[0].Name != "Soda" || parsed.Items[0].Quantity != 2 {
+			t.Errorf("items = %+v, want one Soda x2", parsed.Items)
+		}
+	})
+
+	t.Run("array wrapping the receipt", func(t *testing.T) {
+		parsed, err := decodeGeminiReceipt(`[{"title": "Cafe", "tax": 1.25, "items": [{"name": "Latte", "quantity": 1}]}]`)
+		if err != nil {
+			t.Fatalf("decodeGeminiReceipt: %v", err)
+		}
+		if parsed.Title == nil || *parsed.Title != "Cafe" || parsed.Tax == nil || *parsed.Tax != 1.25 || len(parsed.Items) != 1 {
+			t.Errorf("parsed = %+v, want the Cafe receipt with one item", parsed)
+		}
+	})
+
+	t.Run("prose-wrapped object", func(t *testing.T) {
+		parsed, err := decodeGeminiReceipt(cleanGeminiJSON(`Sure! {"items": [{"name": "Fries", "quantity": 1}], "tip": 2} (amounts in {USD})`))
+		if err != nil {
+			t.Fatalf("decodeGeminiReceipt: %v", err)
+		}
+		if len(parsed.Items) != 1 || parsed.Tip == nil || *parsed.Tip != 2 {
+			t.Errorf("parsed = %+v, want Fries and a 2.00 tip", parsed)
+		}
+	})
+}
+
+func TestGenerationSettingsFromEnv(t *testing.T) {
+	t.Setenv("GEMINI_TEMPERATURE", "0.3")
+	t.Setenv("GEMINI_TOP_P", "not a number")
+	t.Setenv("GEMINI_TOP_K", "20")
+	t.Setenv("GEMINI_MAX_OUTPUT_TOKENS", "100000")
+
+	got := generationSettingsFromEnv()
+	want := GenerationSettings{Temperature: 0.3, TopP: defaultGeminiTopP, TopK: 20, MaxOutputTokens: maxGeminiOutputTokens}
+	if got != want {
+		t.Errorf("generationSettingsFromEnv = %+v, want %+v", got, want)
+	}
+}
+
+func TestOutputTokenBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings GenerationSettings
+		textLen  int
+		want     int32
+	}{
+		{name: "short receipt", textLen: 300, want: minGeminiOutputTokens},
+		{name: "long receipt", textLen: 3000, want: 3000},
+		{name: "very long receipt", textLen: 20000, want: maxGeminiOutputTokens},
+		{name: "configured", settings: GenerationSettings{MaxOutputTokens: 2048}, textLen: 20000, want: 2048},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outputTokenBudget(tt.settings, strings.Repeat("x", tt.textLen)); got != tt.want {
+				t.Errorf("outputTokenBudget = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiTruncated(t *testing.T) {
+	truncated := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonMaxTokens}}}
+	finished := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonStop}}}
+	if !geminiTruncated(truncated) {
+		t.Error("MAX_TOKENS response not detected as truncated")
+	}
+	if geminiTruncated(finished) || geminiTruncated(nil) {
+		t.Error("complete response detected as truncated")
+	}
+}
+
+func TestGeminiUsageAddsEveryCall(t *testing.T) {
+	var usage GeminiUsage
+	// A truncated first response, its retry, and a call that failed outright
+	usage.add(&genai.GenerateContentResponse{UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 800, CandidatesTokenCount: 1024, TotalTokenCount: 1824}}, 2*time.Second)
+	usage.add(&genai.GenerateContentResponse{UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 800, CandidatesTokenCount: 1500, TotalTokenCount: 2300}}, 3*time.Second)
+	usage.add(nil, time.Second)
+
+	want := GeminiUsage{Calls: 3, PromptTokens: 1600, CandidateTokens: 2524, TotalTokens: 4124, Latency: 6 * time.Second}
+	if usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // Registered for ImageDimensions
+	"image/jpeg"
+	_ "image/png" // Registered for ImageDimensions
+
+	"github.com/gen2brain/heic"
+	"golang.org/x/image/webp"
+)
+
+// jpegQuality is used when re-encoding transcoded images; high enough to keep small receipt text legible
+const jpegQuality = 90
+
+// NormalizeReceiptImage transcodes formats that Vision handles poorly (HEIC/HEIF from iPhones, WebP)
+// to JPEG so the same bytes can be sent to OCR and stored in GCS. JPEGs are turned upright per their EXIF
+// orientation, so Vision doesn't read sideways text, and stripped of EXIF for privacy.
+// Returns the original data and content type unchanged for every other format.
+func NormalizeReceiptImage(imageData []byte, contentType string) ([]byte, string, error) {
+	var decode func([]byte) (image.Image, error)
+	switch contentType {
+	case "image/jpeg", "image/jpg":
+		normalized, err := normalizeJPEG(imageData)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s image: %w", contentType, err)
+		}
+		return normalized, contentType, nil
+	case "image/heic", "image/heif":
+		decode = func(data []byte) (image.Image, error) { return heic.Decode(bytes.NewReader(data)) }
+	case "image/webp":
+		decode = func(data []byte) (image.Image, error) { return webp.Decode(bytes.NewReader(data)) }
+	default:
+		return imageData, contentType, nil
+	}
+
+	img, err := decode(imageData)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode %s image: %w", contentType, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, "", fmt.Errorf("failed to encode image as JPEG: %w", err)
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+// ImageDimensions reads an image's width and height from its header without decoding the pixels.
+// ok is false for unrecognized formats or a corrupt header.
+func ImageDimensions(imageData []byte) (width, height int, ok bool) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestImageDimensions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 40))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	width, height, ok := ImageDimensions(buf.Bytes())
+	if !ok || width != 30 || height != 40 {
+		t.Errorf("ImageDimensions(png) = %d, %d, %v; want 30, 40, true", width, height, ok)
+	}
+
+	if _, _, ok := ImageDimensions([]byte("not an image")); ok {
+		t.Error("ImageDimensions(garbage) ok = true, want false")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"cloud.google.com/go/storage"
 	vision "cloud.google.com/go/vision/apiv1"
@@ -16,6 +17,16 @@ import (
 	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
 )
 
+// taxInclusivePattern matches receipt wording saying prices already include tax, e.g. "VAT included",
+// "Incl. 19% MwSt", "Prices include GST", "TVA incluse", or "税込"
+var taxInclusivePattern = regexp.MustCompile(`(?i)\b(?:(?:tax|taxes|vat|gst|mwst|tva|iva)\.?\s+(?:is\s+|are\s+)?(?:incl\b|included|inclusive|enthalten|incluse|incluid[oa])|incl(?:\.|uded|uding|usive)?\s+(?:of\s+)?(?:all\s+)?(?:\d+(?:[.,]\d+)?\s*%\s*)?(?:tax|taxes|vat|gst|mwst|tva|iva)\b|prices?\s+include\s+(?:all\s+)?(?:tax|taxes|vat|gst)\b|enthaltene\s+mwst)|税込|内税`)
+
+// TaxInclusiveFromText reports whether OCR text says item prices already include tax.
+// Used when the parser (regex or Document AI) can't tell on its own.
+func TaxInclusiveFromText(ocrText string) bool {
+	return taxInclusivePattern.MatchString(ocrText)
+}
+
 // ExtractReceiptItemsFromText parses OCR text to extract receipt items
 // This is a basic parser - receipt formats vary widely, so this may need refinement
 func ExtractReceiptItemsFromText(ocrText string) []ReceiptItemParsed {
@@ -36,6 +47,10 @@ func ExtractReceiptItemsFromText(ocrText string) []ReceiptItemParsed {
 	// Pattern to match just a price at the end of a line
 	endPricePattern := regexp.MustCompile(`(.+?)\s+\$?([\d,]+\.?\d{0,2})\s*$`)
 
+	// Pattern to match a discount/coupon line: a name followed by a negative price,
+	// written as "-$5.00", "$5.00-" or "($5.00)"
+	discountPattern := regexp.MustCompile(`^(.+?)\s+(?:-\s*\$?([\d,]+\.\d{2})|\$?([\d,]+\.\d{2})-|\(\$?([\d,]+\.\d{2})\))\s*$`)
+
 	// Skip header/footer lines (common receipt patterns)
 	skipPatterns := []*regexp.Regexp{
 		regexp.MustCompile(`(?i)^(subtotal|tax|total|amount|change|cash|card|receipt|thank|visit|date|time)`),
@@ -64,6 +79,21 @@ func ExtractReceiptItemsFromText(ocrText string) []ReceiptItemParsed {
 		var item ReceiptItemParsed
 		var found bool
 
+		// Discounts are checked first; the other patterns would read "-$5.00" as a positive price
+		if matches := discountPattern.FindStringSubmatch(line); matches != nil {
+			priceStr := strings.ReplaceAll(matches[2]+matches[3]+matches[4], ",", "")
+			if price, err := strconv.ParseFloat(priceStr, 64); err == nil && price > 0 {
+				items = append(items, ReceiptItemParsed{
+					Name:         strings.TrimSpace(matches[1]),
+					Quantity:     1,
+					TotalPrice:   -price,
+					PricePerItem: -price,
+					IsDiscount:   true,
+				})
+			}
+			continue
+		}
+
 		// Try to match pattern with quantity
 		if matches := pricePattern.FindStringSubmatch(line); len(matches) >= 4 {
 			item.Name = strings.TrimSpace(matches[1])
@@ -105,12 +135,87 @@ func ExtractReceiptItemsFromText(ocrText string) []ReceiptItemParsed {
 	return items
 }
 
+// Patterns for the receipt-level fields ExtractReceiptFieldsFromText reads. Amounts must sit at the end of
+// their line, optionally after a rate such as "8.875%", so a tax rate or "Tip 18% = 9.00" suggestion table
+// isn't read as the amount.
+var (
+	taxLinePattern      = regexp.MustCompile(`(?im)^\s*(?:sales\s+)?(?:tax|vat|gst|hst|pst|qst)\b[^\d\n$€£]*(?:\d+(?:[.,]\d+)?\s*%[^\d\n$€£]*)?[$€£]?\s*([\d,]+\.\d{2})\s*$`)
+	tipLinePattern      = regexp.MustCompile(`(?im)^\s*tip\b[^\d\n$€£]*[$€£]?\s*([\d,]+\.\d{2})\s*$`)
+	currencyCodePattern = regexp.MustCompile(`\b(USD|EUR|GBP|CAD|AUD|NZD|JPY|CHF|MXN|INR|CNY|KRW|SGD|HKD|SEK|NOK|DKK)\b`)
+	titleSkipPattern    = regexp.MustCompile(`(?i)^(?:receipt|welcome|thank|order|check|table|server|guest|date|time)\b|\d+[.,]\d{2}`)
+)
+
+// ReceiptFields are the receipt-level fields ExtractReceiptFieldsFromText can read; nil when not found
+type ReceiptFields struct {
+	Currency *string
+	Title    *string
+	Tax      *float64 // Sum of every tax line, e.g. GST + PST
+	Tip      *float64
+}
+
+// ExtractReceiptFieldsFromText reads currency, title, tax, and tip from OCR text.
+// Like ExtractReceiptItemsFromText it is a basic parser; it only reports what is written out explicitly
+// (a currency code or €/£, a "Tax 1.50" line), so it errs toward leaving a field nil.
+func ExtractReceiptFieldsFromText(ocrText string) ReceiptFields {
+	var fields ReceiptFields
+
+	for _, matches := range taxLinePattern.FindAllStringSubmatch(ocrText, -1) {
+		if amount, err := strconv.ParseFloat(strings.ReplaceAll(matches[1], ",", ""), 64); err == nil {
+			total := amount
+			if fields.Tax != nil {
+				total += *fields.Tax
+			}
+			fields.Tax = &total
+		}
+	}
+	if matches := tipLinePattern.FindStringSubmatch(ocrText); matches != nil {
+		if amount, err := strconv.ParseFloat(strings.ReplaceAll(matches[1], ",", ""), 64); err == nil {
+			fields.Tip = &amount
+		}
+	}
+
+	var currency string
+	switch {
+	case currencyCodePattern.MatchString(ocrText):
+		currency = currencyCodePattern.FindString(ocrText)
+	case strings.Contains(ocrText, "€"):
+		currency = "EUR"
+	case strings.Contains(ocrText, "£"):
+		currency = "GBP"
+	}
+	if currency != "" {
+		fields.Currency = &currency
+	}
+
+	// The merchant name is usually printed first; only the top few lines are considered
+	checked := 0
+	for _, line := range strings.Split(ocrText, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if checked++; checked > 3 {
+			break
+		}
+		if strings.IndexFunc(line, unicode.IsLetter) >= 0 && !titleSkipPattern.MatchString(line) {
+			fields.Title = &line
+			break
+		}
+	}
+
+	return fields
+}
+
 // ReceiptItemParsed represents a parsed receipt item from OCR
 type ReceiptItemParsed struct {
 	Name         string
 	Quantity     int
-	TotalPrice   float64
+	TotalPrice   float64 // negative for discounts
 	PricePerItem float64
+	IsDiscount   bool     // coupon, promotion, or other negative line
+	Category     *string  // one of ItemCategories; only set by the Gemini parser
+	NeedsReview  bool     // implausible price or quantity; see ItemLimits
+	Confidence   *float64 // 0-1; only set by Document AI
 }
 
 // PerformOCRFromGCS performs OCR on an image/PDF stored in GCS
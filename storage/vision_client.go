@@ -10,6 +10,16 @@ import (
 	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
 )
 
+// OCRFeature selects the Vision feature used to read text from an image
+type OCRFeature string
+
+const (
+	// OCRFeatureDocumentText (DOCUMENT_TEXT_DETECTION) is tuned for dense text and is the default for receipts
+	OCRFeatureDocumentText OCRFeature = "DOCUMENT_TEXT_DETECTION"
+	// OCRFeatureText (TEXT_DETECTION) can do better on sparse receipts with little text
+	OCRFeatureText OCRFeature = "TEXT_DETECTION"
+)
+
 type VisionClient struct {
 	client *vision.ImageAnnotatorClient
 }
@@ -34,13 +44,30 @@ func (c *VisionClient) Close() error {
 	return c.client.Close()
 }
 
-func (c *VisionClient) PerformOCRFromBytes(ctx context.Context, imageData []byte) (string, error) {
+// PerformOCRFromBytes reads the text in an image using the given Vision feature.
+// languageHints (BCP-47 codes like "ja" or "ko") steer Vision toward known languages; with none, Vision auto-detects.
+func (c *VisionClient) PerformOCRFromBytes(ctx context.Context, imageData []byte, feature OCRFeature, languageHints []string) (string, error) {
 	image := &pb.Image{
 		Content: imageData,
 	}
+	var imageContext *pb.ImageContext
+	if len(languageHints) > 0 {
+		imageContext = &pb.ImageContext{LanguageHints: languageHints}
+	}
+
+	if feature == OCRFeatureText {
+		// The first annotation holds the full text; the rest are individual words
+		annotations, err := c.client.DetectTexts(ctx, image, imageContext, 1)
+		if err != nil {
+			return "", fmt.Errorf("failed to detect text: %w", err)
+		}
+		if len(annotations) == 0 || annotations[0].GetDescription() == "" {
+			return "", fmt.Errorf("no text detected in image")
+		}
+		return annotations[0].GetDescription(), nil
+	}
 
-	// Use DOCUMENT_TEXT_DETECTION for receipts
-	response, err := c.client.DetectDocumentText(ctx, image, nil)
+	response, err := c.client.DetectDocumentText(ctx, image, imageContext)
 	if err != nil {
 		return "", fmt.Errorf("failed to detect document text: %w", err)
 	}
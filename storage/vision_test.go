@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExtractReceiptItemsFromTextKeepsDiscounts(t *testing.T) {
+	text := "Burger $12.99\nCOUPON -$5.00\nMember savings 1.50-\nPromo ($2.25)\nBad line -$0.00\nTOTAL 4.24"
+
+	items := ExtractReceiptItemsFromText(text)
+
+	want := []ReceiptItemParsed{
+		{Name: "Burger", Quantity: 1, TotalPrice: 12.99, PricePerItem: 12.99},
+		{Name: "COUPON", Quantity: 1, TotalPrice: -5.00, PricePerItem: -5.00, IsDiscount: true},
+		{Name: "Member savings", Quantity: 1, TotalPrice: -1.50, PricePerItem: -1.50, IsDiscount: true},
+		{Name: "Promo", Quantity: 1, TotalPrice: -2.25, PricePerItem: -2.25, IsDiscount: true},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items %+v, want %d", len(items), items, len(want))
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item %d = %+v, want %+v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestTaxInclusiveFromText(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Schnitzel 14.00\nTOTAL EUR 20.00\nVAT included 3.19", true},
+		{"Summe 20,00\nenthaltene MwSt. 19% 3,19", true},
+		{"Total 20.00\nIncl. 19% VAT 3.19", true},
+		{"All prices include GST", true},
+		{"Total TTC 20,00\nTVA incluse", true},
+		{"合計 ¥1,100 (税込)", true},
+		{"Subtotal 20.00\nTax 1.65\nTotal 21.65", false},
+		{"Taxi receipt\nIncluded gratuity 3.00", false},
+	}
+	for _, tt := range tests {
+		if got := TaxInclusiveFromText(tt.text); got != tt.want {
+			t.Errorf("TaxInclusiveFromText(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExtractReceiptFieldsFromText(t *testing.T) {
+	text := "JOE'S DINER\n123 Main St\nBurger 12.99\nFries 4.01\nSubtotal 17.00\nSales Tax 8.875% 1.51\nTip: $3.00\nTotal USD 21.51"
+
+	fields := ExtractReceiptFieldsFromText(text)
+
+	if fields.Title == nil || *fields.Title != "JOE'S DINER" {
+		t.Errorf("title = %v, want JOE'S DINER", fields.Title)
+	}
+	if fields.Currency == nil || *fields.Currency != "USD" {
+		t.Errorf("currency = %v, want USD", fields.Currency)
+	}
+	if fields.Tax == nil || *fields.Tax != 1.51 {
+		t.Errorf("tax = %v, want 1.51", fields.Tax)
+	}
+	if fields.Tip == nil || *fields.Tip != 3.00 {
+		t.Errorf("tip = %v, want 3.00", fields.Tip)
+	}
+}
+
+func TestExtractReceiptFieldsFromTextLeavesUnwrittenFieldsNil(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "no tax or tip lines", text: "Burger $12.99\nTOTAL $12.99"},
+		{name: "suggested tips, not a tip", text: "Burger 12.99\nTOTAL 12.99\nSuggested tip 18% = 2.34"},
+		{name: "tax rate without an amount", text: "Burger 12.99\nTax rate 8.875%\nTOTAL 12.99"},
+	}
+	for _, tt := range tests {
+		fields := ExtractReceiptFieldsFromText(tt.text)
+		if fields.Title != nil || fields.Currency != nil || fields.Tax != nil || fields.Tip != nil {
+			t.Errorf("%s: fields = %+v, want all nil", tt.name, fields)
+		}
+	}
+}
+
+func TestExtractReceiptFieldsFromTextSumsTaxLines(t *testing.T) {
+	fields := ExtractReceiptFieldsFromText("Poutine 11.00\nGST 5% 0.55\nPST 7% 0.77\nTotal CAD 12.32")
+
+	if fields.Tax == nil || math.Abs(*fields.Tax-1.32) > 1e-9 {
+		t.Errorf("tax = %v, want 1.32", fields.Tax)
+	}
+	if fields.Currency == nil || *fields.Currency != "CAD" {
+		t.Errorf("currency = %v, want CAD", fields.Currency)
+	}
+}
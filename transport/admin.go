@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"splitzies/persistence"
+)
+
+// adminKeyHeader carries the admin API key for admin-only operations
+const adminKeyHeader = "X-Admin-Key"
+
+// adminAPIKeyFromEnv reads ADMIN_API_KEY; when unset, admin-only operations are disabled
+func adminAPIKeyFromEnv(log *slog.Logger) string {
+	key := os.Getenv("ADMIN_API_KEY")
+	if key == "" {
+		log.Info("ADMIN_API_KEY not set; admin operations are disabled")
+	}
+	return key
+}
+
+// isAdmin reports whether the request carries the configured admin API key
+func (t *Transport) isAdmin(r *http.Request) bool {
+	if t.adminAPIKey == "" {
+		return false
+	}
+	key := r.Header.Get(adminKeyHeader)
+	return subtle.ConstantTimeCompare([]byte(key), []byte(t.adminAPIKey)) == 1
+}
+
+// requireAdmin writes 403 and returns false unless the request carries the admin API key
+func (t *Transport) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if t.isAdmin(r) {
+		return true
+	}
+	if r.Header.Get(adminKeyHeader) != "" {
+		t.log.Warn("Admin API key rejected", "method", r.Method, "path", r.URL.Path, "client_ip", t.clientIP(r))
+	}
+	http.Error(w, "admin API key required ("+adminKeyHeader+" header)", http.StatusForbidden)
+	return false
+}
+
+// GetMigrationsHandler handles reporting the database schema version and which migrations have been applied,
+// so a deploy can be checked against the migrations it shipped with. Read-only; requires the admin API key.
+// Expects GET /admin/migrations
+func (t *Transport) GetMigrationsHandler(w http.ResponseWriter, r *http.Request) {
+	if !t.requireAdmin(w, r) {
+		return
+	}
+
+	report, err := t.persistenceClient.MigrationStatus(context.Background(), persistence.MigrationsDir)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get migration status: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := MigrationStatusResponse{
+		CurrentVersion: report.CurrentVersion,
+		Migrations:     make([]MigrationStatus, len(report.Migrations)),
+	}
+	for i, m := range report.Migrations {
+		response.Migrations[i] = MigrationStatus{Version: m.Version, Path: m.Path, State: "pending"}
+		if m.Applied {
+			response.Migrations[i].State = "applied"
+			response.Applied++
+		} else {
+			response.Pending++
+		}
+		if m.AppliedAt != nil {
+			appliedAt := m.AppliedAt.Format(time.RFC3339)
+			response.Migrations[i].AppliedAt = &appliedAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
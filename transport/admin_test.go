@@ -0,0 +1,32 @@
+package transport
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		header     string
+		want       bool
+	}{
+		{name: "matching key", configured: "s3cret", header: "s3cret", want: true},
+		{name: "wrong key", configured: "s3cret", header: "guess"},
+		{name: "missing header", configured: "s3cret"},
+		{name: "admin disabled", configured: "", header: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{adminAPIKey: tt.configured}
+			r := httptest.NewRequest("GET", "/receipts?include_deleted=true", nil)
+			if tt.header != "" {
+				r.Header.Set(adminKeyHeader, tt.header)
+			}
+			if got := tr.isAdmin(r); got != tt.want {
+				t.Errorf("isAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package transport
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ClientIP returns the IP address of the client that made r, for logging and rate limiting.
+// trustedHops is how many proxies in front of the server append to X-Forwarded-For (e.g. 1 for the load
+// balancer in front of Cloud Run). Each appends the address it received the request from, so the client is
+// trustedHops entries from the right; anything further left was sent by the client and is ignored, so a
+// spoofed header can't change the result. With no trusted hops, or a header with fewer entries than hops or
+// an unparseable entry, RemoteAddr is used.
+func ClientIP(r *http.Request, trustedHops int) string {
+	if trustedHops > 0 {
+		var forwarded []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(header, ",") {
+				forwarded = append(forwarded, strings.TrimSpace(entry))
+			}
+		}
+		if len(forwarded) >= trustedHops {
+			if ip := net.ParseIP(forwarded[len(forwarded)-trustedHops]); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP is ClientIP with the configured TRUSTED_PROXY_HOPS
+func (t *Transport) clientIP(r *http.Request) string {
+	return ClientIP(r, t.trustedProxyHops)
+}
+
+// trustedProxyHopsFromEnv reads TRUSTED_PROXY_HOPS, the number of proxies whose X-Forwarded-For entries are
+// trusted; unset or invalid trusts none and uses the connection's address
+func trustedProxyHopsFromEnv(log *slog.Logger) int {
+	value := os.Getenv("TRUSTED_PROXY_HOPS")
+	if value == "" {
+		return 0
+	}
+	hops, err := strconv.Atoi(value)
+	if err != nil || hops < 0 {
+		log.Warn("Invalid TRUSTED_PROXY_HOPS, using default", "value", value, "default", 0)
+		return 0
+	}
+	return hops
+}
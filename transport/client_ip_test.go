@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		forwarded   []string
+		trustedHops int
+		want        string
+	}{
+		{name: "direct connection", remoteAddr: "203.0.113.7:52100", want: "203.0.113.7"},
+		{name: "direct connection ignores X-Forwarded-For", remoteAddr: "203.0.113.7:52100", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "single proxy", remoteAddr: "10.0.0.2:443", forwarded: []string{"198.51.100.1"}, trustedHops: 1, want: "198.51.100.1"},
+		{name: "single proxy ignores spoofed entries", remoteAddr: "10.0.0.2:443", forwarded: []string{"1.2.3.4, 198.51.100.1"}, trustedHops: 1, want: "198.51.100.1"},
+		{name: "two proxies across headers", remoteAddr: "10.0.0.2:443", forwarded: []string{"1.2.3.4, 198.51.100.1", "10.0.0.9"}, trustedHops: 2, want: "198.51.100.1"},
+		{name: "IPv6 client", remoteAddr: "10.0.0.2:443", forwarded: []string{"2001:db8::1"}, trustedHops: 1, want: "2001:db8::1"},
+		{name: "fewer entries than hops", remoteAddr: "10.0.0.2:443", forwarded: []string{"198.51.100.1"}, trustedHops: 2, want: "10.0.0.2"},
+		{name: "proxy but no header", remoteAddr: "10.0.0.2:443", trustedHops: 1, want: "10.0.0.2"},
+		{name: "garbage entry", remoteAddr: "10.0.0.2:443", forwarded: []string{"not-an-ip"}, trustedHops: 1, want: "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/receipts", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := ClientIP(r, tt.trustedHops); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
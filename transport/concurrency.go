@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// expectedVersion returns the version a client expects to be editing, taken from the If-Match
+// header (3, "3", W/"3", or an ETag from GET /receipts/{receipt_id} such as W/"3-9f86d081884c7d65")
+// or the version field of the request body.
+// Returns nil when neither is provided, meaning the edit is applied unconditionally.
+func expectedVersion(r *http.Request, bodyVersion *int) (*int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return bodyVersion, nil
+	}
+
+	raw := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	raw, _, _ = strings.Cut(raw, "-")
+	version, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, NewValidationError("If-Match", fmt.Sprintf("invalid version %q", header))
+	}
+	if bodyVersion != nil && *bodyVersion != version {
+		return nil, NewValidationError("version", "If-Match header and body version disagree")
+	}
+	return &version, nil
+}
+
+// receiptETag returns a weak ETag for a receipt response body: the receipt's version, then a digest of the
+// body. The version alone isn't enough, since adding users and assignments doesn't change it; the digest
+// also covers query options such as formatted. The leading version lets the ETag be sent back as If-Match.
+func receiptETag(version int, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`W/"%d-%s"`, version, hex.EncodeToString(sum[:8]))
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using weak comparison: "*" or any
+// listed tag with the same opaque value, with or without W/
+func etagMatches(ifNoneMatch, etag string) bool {
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
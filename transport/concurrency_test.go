@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpectedVersion(t *testing.T) {
+	three, four := 3, 4
+	tests := []struct {
+		name        string
+		ifMatch     string
+		bodyVersion *int
+		want        *int
+		wantErr     bool
+	}{
+		{name: "neither", want: nil},
+		{name: "body only", bodyVersion: &three, want: &three},
+		{name: "bare header", ifMatch: "3", want: &three},
+		{name: "quoted header", ifMatch: `"3"`, want: &three},
+		{name: "weak header", ifMatch: `W/"3"`, want: &three},
+		{name: "receipt ETag", ifMatch: `W/"3-9f86d081884c7d65"`, want: &three},
+		{name: "header and body agree", ifMatch: "3", bodyVersion: &three, want: &three},
+		{name: "header and body conflict", ifMatch: "3", bodyVersion: &four, wantErr: true},
+		{name: "garbage header", ifMatch: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PATCH", "/receipts/01ARZ3NDEKTSV4RRFFQ69G5FAV", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			got, err := expectedVersion(r, tt.bodyVersion)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expectedVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("expectedVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiptETag(t *testing.T) {
+	etag := receiptETag(3, []byte(`{"receipt_id":"r1"}`))
+	if !strings.HasPrefix(etag, `W/"3-`) {
+		t.Errorf("etag = %s, want a weak tag starting with the version", etag)
+	}
+	if other := receiptETag(3, []byte(`{"receipt_id":"r1","users":[]}`)); other == etag {
+		t.Errorf("etag = %s for different bodies at the same version, want them to differ", etag)
+	}
+
+	for header, want := range map[string]bool{
+		etag:                           true,
+		strings.TrimPrefix(etag, "W/"): true,
+		`W/"2-abc", ` + etag:           true,
+		"*":                            true,
+		`W/"2-abc"`:                    false,
+		"":                             false,
+	} {
+		if got := etagMatches(header, etag); got != want {
+			t.Errorf("etagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowedHeaders = "Content-Type, If-Match, If-None-Match, X-OCR-Language-Hints"
+	corsExposedHeaders = "ETag"
+)
+
+// CORS returns middleware that lets browser clients on allowedOrigins call the API.
+// The request's Origin is echoed back only when it is in the allowlist (never "*"), so
+// credentialed requests can be supported later. Preflight OPTIONS requests are answered
+// with 204 without reaching the wrapped handler.
+func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			allowed[origin] = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin != "" {
+				w.Header().Add("Vary", "Origin")
+				if allowed[origin] {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+					w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+					w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+				}
+			}
+
+			if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	var reached bool
+	handler := CORS([]string{"https://app.splitzies.com", " https://staging.splitzies.com/ ", ""})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantReached bool
+	}{
+		{name: "allowed origin", method: http.MethodGet, origin: "https://app.splitzies.com", wantStatus: http.StatusOK, wantOrigin: "https://app.splitzies.com", wantReached: true},
+		{name: "allowlist entries are normalized", method: http.MethodGet, origin: "https://staging.splitzies.com", wantStatus: http.StatusOK, wantOrigin: "https://staging.splitzies.com", wantReached: true},
+		{name: "disallowed origin", method: http.MethodGet, origin: "https://evil.example", wantStatus: http.StatusOK, wantReached: true},
+		{name: "no origin", method: http.MethodGet, wantStatus: http.StatusOK, wantReached: true},
+		{name: "preflight", method: http.MethodOptions, origin: "https://app.splitzies.com", preflight: true, wantStatus: http.StatusNoContent, wantOrigin: "https://app.splitzies.com"},
+		{name: "preflight from disallowed origin", method: http.MethodOptions, origin: "https://evil.example", preflight: true, wantStatus: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			r := httptest.NewRequest(tt.method, "/receipts/01ARZ3NDEKTSV4RRFFQ69G5FAV", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if reached != tt.wantReached {
+				t.Errorf("handler reached = %v, want %v", reached, tt.wantReached)
+			}
+		})
+	}
+}
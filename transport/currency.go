@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"splitzies/money"
+)
+
+// currencyConversion is a client-requested conversion of receipt totals into another currency
+type currencyConversion struct {
+	to   string
+	rate float64 // units of to per unit of the receipt currency
+}
+
+// parseConversionQuery reads the optional convert_to and rate query parameters.
+// Returns nil if neither is set, or a ValidationError if only one is set or either is invalid.
+func parseConversionQuery(query url.Values) (*currencyConversion, error) {
+	to, rateParam := strings.TrimSpace(query.Get("convert_to")), query.Get("rate")
+	if to == "" && rateParam == "" {
+		return nil, nil
+	}
+	if to == "" || rateParam == "" {
+		return nil, NewValidationError("convert_to", "convert_to and rate must be provided together")
+	}
+	if !money.KnownCurrency(to) {
+		return nil, NewValidationError("convert_to", fmt.Sprintf("%q is not a known currency code", to))
+	}
+	rate, err := strconv.ParseFloat(rateParam, 64)
+	if err != nil || rate <= 0 {
+		return nil, NewValidationError("rate", "rate must be a positive number")
+	}
+	return &currencyConversion{to: strings.ToUpper(to), rate: rate}, nil
+}
+
+// parseFormattedQuery reads the optional formatted flag (e.g. ?formatted=true). When set, every amount in the
+// response is returned as {"value": 21.95, "formatted": "$21.95"} instead of a bare number.
+func parseFormattedQuery(query url.Values) (bool, error) {
+	param := query.Get("formatted")
+	if param == "" {
+		return false, nil
+	}
+	formatted, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, NewValidationError("formatted", "formatted must be true or false")
+	}
+	return formatted, nil
+}
+
+// convertTotals converts the grand total and each user's total in response into the requested currency.
+// Each amount is converted independently, so converted user totals may differ from the converted
+// grand total by a rounding unit.
+func convertTotals(response GetReceiptResponse, conversion currencyConversion) (*ConvertedTotals, error) {
+	grandTotal, err := money.Convert(response.GrandTotal.Value, response.Currency, conversion.to, conversion.rate)
+	if err != nil {
+		return nil, err
+	}
+	converted := &ConvertedTotals{
+		Currency:   conversion.to,
+		Rate:       conversion.rate,
+		GrandTotal: grandTotal,
+		UserTotals: make(map[string]money.Amount, len(response.Users)),
+	}
+	for _, u := range response.Users {
+		if u.UserTotal == nil {
+			continue
+		}
+		userTotal, err := money.Convert(u.UserTotal.Value, response.Currency, conversion.to, conversion.rate)
+		if err != nil {
+			return nil, err
+		}
+		converted.UserTotals[u.ID] = userTotal
+	}
+	return converted, nil
+}
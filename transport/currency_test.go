@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"net/url"
+	"testing"
+
+	"splitzies/money"
+)
+
+func TestParseConversionQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    *currencyConversion
+		wantErr bool
+	}{
+		{name: "not requested", query: ""},
+		{name: "valid", query: "convert_to=eur&rate=0.92", want: &currencyConversion{to: "EUR", rate: 0.92}},
+		{name: "missing rate", query: "convert_to=EUR", wantErr: true},
+		{name: "missing currency", query: "rate=0.92", wantErr: true},
+		{name: "unknown currency", query: "convert_to=XYZ&rate=1", wantErr: true},
+		{name: "negative rate", query: "convert_to=EUR&rate=-1", wantErr: true},
+		{name: "non-numeric rate", query: "convert_to=EUR&rate=abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := parseConversionQuery(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseConversionQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("parseConversionQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormattedQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{query: ""},
+		{query: "formatted=true", want: true},
+		{query: "formatted=1", want: true},
+		{query: "formatted=false"},
+		{query: "formatted=yes", wantErr: true},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got, err := parseFormattedQuery(query)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseFormattedQuery(%q) = %v, %v; want %v, error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestConvertTotals(t *testing.T) {
+	eur := "EUR"
+	aliceTotal := money.NewAmount(12.50, &eur)
+	response := GetReceiptResponse{
+		Currency:   eur,
+		GrandTotal: money.NewAmount(20.00, &eur),
+		Users:      []GetReceiptUserResponse{{ID: "alice", UserTotal: &aliceTotal}},
+	}
+
+	converted, err := convertTotals(response, currencyConversion{to: "JPY", rate: 163.21})
+	if err != nil {
+		t.Fatalf("convertTotals() error = %v", err)
+	}
+	if converted.GrandTotal.Value != 3264 {
+		t.Errorf("GrandTotal = %v, want 3264", converted.GrandTotal.Value)
+	}
+	if got := converted.UserTotals["alice"].Value; got != 2040 {
+		t.Errorf("alice = %v, want 2040", got)
+	}
+	if response.GrandTotal.Value != 20.00 || response.Currency != "EUR" {
+		t.Errorf("original totals changed: %v %s", response.GrandTotal.Value, response.Currency)
+	}
+}
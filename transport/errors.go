@@ -1,6 +1,12 @@
 package transport
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
 type ValidationError struct {
 	Field   string `json:"field"`
@@ -18,6 +24,69 @@ func NewValidationError(field, message string) *ValidationError {
 	}
 }
 
+// ValidationErrors collects every field problem in a request, so a client can fix them all in one round trip
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records a problem with field
+func (e *ValidationErrors) Add(field, message string) {
+	*e = append(*e, NewValidationError(field, message))
+}
+
+// Collect records err if it is a *ValidationError or ValidationErrors and reports whether it did.
+// A nil err is ignored and reported as collected.
+func (e *ValidationErrors) Collect(err error) bool {
+	if err == nil {
+		return true
+	}
+	var many ValidationErrors
+	if errors.As(err, &many) {
+		*e = append(*e, many...)
+		return true
+	}
+	var one *ValidationError
+	if errors.As(err, &one) {
+		*e = append(*e, one)
+		return true
+	}
+	return false
+}
+
+// Err returns e as an error, or nil when no problems were recorded
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ValidationErrorsResponse is the JSON body of a 400 listing every invalid field
+type ValidationErrorsResponse struct {
+	Message string             `json:"message"`
+	Errors  []*ValidationError `json:"errors"`
+}
+
+// writeValidationErrors responds 400 with every field problem as JSON
+func writeValidationErrors(w http.ResponseWriter, errs ValidationErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusBadRequest)
+	response := ValidationErrorsResponse{
+		Message: fmt.Sprintf("request has %d invalid field(s)", len(errs)),
+		Errors:  errs,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
 type InvalidMethodError struct {
 	Method string `json:"method"`
 }
@@ -31,3 +100,38 @@ func NewInvalidMethodError(method string) *InvalidMethodError {
 		Method: method,
 	}
 }
+
+// MethodNotAllowed responds 405 with the Allow header listing the methods the path supports,
+// as HTTP requires on a 405
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	setAllowHeader(w, allowed)
+	http.Error(w, NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
+}
+
+// NotFoundResponse is the JSON body of a 404 for a path no route matches
+type NotFoundResponse struct {
+	Error string `json:"error"`
+	Path  string `json:"path"`
+}
+
+// NotFound responds 404 with a JSON body for a path no route matches, so clients get JSON from every
+// unknown URL rather than net/http's plain-text "404 page not found"
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusNotFound)
+	if err := json.NewEncoder(w).Encode(NotFoundResponse{Error: "not found", Path: r.URL.Path}); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// Options answers an OPTIONS request with 204 and the Allow header listing the methods the path supports
+func Options(w http.ResponseWriter, allowed ...string) {
+	setAllowHeader(w, allowed)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setAllowHeader sets Allow to allowed plus OPTIONS, which every route answers
+func setAllowHeader(w http.ResponseWriter, allowed []string) {
+	w.Header().Set("Allow", strings.Join(append(allowed[:len(allowed):len(allowed)], http.MethodOptions), ", "))
+}
@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethodNotAllowedSetsAllowHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/receipts/id/users", nil)
+
+	MethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, POST, OPTIONS" {
+		t.Errorf("Allow = %q, want %q", got, "GET, POST, OPTIONS")
+	}
+}
+
+func TestValidationErrorsCollectsEveryProblem(t *testing.T) {
+	_, err := bulkAssignRequestToDB([]BulkAssignRequestItem{
+		{UserID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", ItemID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V"},
+		{ItemID: "not-an-id"},
+		{UserID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", UserName: "Alice", ItemID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V", Amount: func() *float64 { v := -1.0; return &v }()},
+	})
+
+	var errs ValidationErrors
+	if !errs.Collect(err) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	wantFields := []string{"assignments[1].item_id", "assignments[1]", "assignments[2]", "assignments[2].amount"}
+	if len(errs) != len(wantFields) {
+		t.Fatalf("errors = %v, want fields %v", errs, wantFields)
+	}
+	for i, field := range wantFields {
+		if errs[i].Field != field {
+			t.Errorf("errors[%d].Field = %q, want %q", i, errs[i].Field, field)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	writeValidationErrors(w, errs)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("status = %d, Content-Type = %q; want 400 application/json", w.Code, w.Header().Get("Content-Type"))
+	}
+	var body ValidationErrorsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Errors) != len(wantFields) {
+		t.Errorf("body = %s (%v), want %d errors", w.Body.String(), err, len(wantFields))
+	}
+}
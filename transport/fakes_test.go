@@ -0,0 +1,522 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"splitzies/persistence"
+	"splitzies/storage"
+)
+
+// fakeImageStore is an in-memory ImageStore
+type fakeImageStore struct {
+	mu     sync.Mutex
+	images map[string][]byte // key: receipt ID
+	err    error             // returned by every upload when set
+}
+
+func (s *fakeImageStore) UploadReceiptImageFromReader(ctx context.Context, reader io.Reader, receiptID string, contentType string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.images == nil {
+		s.images = make(map[string][]byte)
+	}
+	s.images[receiptID] = data
+	return "https://storage.example.com/receipts/" + receiptID, nil
+}
+
+// fakeOCREngine is an OCREngine that reads the same text, or fails with err, for every image, after delay
+// unless ctx is done first
+type fakeOCREngine struct {
+	text  string
+	err   error
+	delay time.Duration
+}
+
+func (e *fakeOCREngine) PerformOCRFromBytes(ctx context.Context, imageData []byte, feature storage.OCRFeature, languageHints []string) (string, error) {
+	select {
+	case <-time.After(e.delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if e.err != nil {
+		return "", e.err
+	}
+	return e.text, nil
+}
+
+// fakeReceiptStore is an in-memory ReceiptStore covering receipts, uploads, users, items, and assignments. Methods it
+// doesn't implement fall through to the nil embedded ReceiptStore and panic, so a test using one fails loudly.
+type fakeReceiptStore struct {
+	ReceiptStore
+
+	mu       sync.Mutex
+	receipts map[string]*fakeReceipt
+}
+
+// fakeReceipt is one stored receipt with its users and assignments
+type fakeReceipt struct {
+	persistence.Receipt
+	imageSHA256    string
+	finalizedAt    *time.Time
+	deleted        bool
+	itemsTruncated bool
+	taxTip         persistence.ReceiptTaxTip
+	rounding       persistence.Rounding
+	splitMode      string
+	notes          *string
+	users          []persistence.ReceiptUser
+	assignments    []persistence.ReceiptUserItem
+}
+
+// receipt returns the receipt with receiptID, treating a deleted one as missing; callers hold s.mu
+func (s *fakeReceiptStore) receipt(receiptID string) (*fakeReceipt, error) {
+	receipt, ok := s.receipts[receiptID]
+	if !ok || receipt.deleted {
+		return nil, fmt.Errorf("receipt not found")
+	}
+	return receipt, nil
+}
+
+// addReceipt stores an empty ready receipt, for tests that start past the upload
+func (s *fakeReceiptStore) addReceipt(receiptID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.receipts == nil {
+		s.receipts = make(map[string]*fakeReceipt)
+	}
+	s.receipts[receiptID] = &fakeReceipt{
+		Receipt:   persistence.Receipt{ID: receiptID, Version: 1, Status: persistence.ReceiptStatusReady, Items: []persistence.ReceiptItem{}},
+		rounding:  persistence.Rounding{Strategy: persistence.RoundingFirst},
+		splitMode: persistence.SplitModeItemized,
+	}
+}
+
+// addAssignment assigns an item to a user, with a custom amount or nil for an equal split, for tests that
+// start with a split in progress
+func (s *fakeReceiptStore) addAssignment(receiptID, userID, itemID string, amount *float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt := s.receipts[receiptID]
+	receipt.assignments = append(receipt.assignments, persistence.ReceiptUserItem{ID: ulid.Make().String(), ReceiptUserID: userID, ReceiptItemID: itemID, AmountOwed: amount})
+}
+
+func (s *fakeReceiptStore) ReceiptExists(ctx context.Context, receiptID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.receipt(receiptID)
+	return err == nil, nil
+}
+
+func (s *fakeReceiptStore) GetReceiptSnapshot(ctx context.Context, receiptID string) (*persistence.ReceiptSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return nil, err
+	}
+	return &persistence.ReceiptSnapshot{
+		ReceiptID:      receipt.ID,
+		Title:          receipt.Title,
+		ReceiptDate:    receipt.ReceiptDate,
+		Currency:       receipt.Currency,
+		TaxTip:         receipt.taxTip,
+		Rounding:       receipt.rounding,
+		SplitMode:      receipt.splitMode,
+		Notes:          receipt.notes,
+		Version:        receipt.Version,
+		Status:         receipt.Status,
+		ParseStatus:    receipt.ParseStatus,
+		ItemsTruncated: receipt.itemsTruncated,
+		FinalizedAt:    receipt.finalizedAt,
+		Users:          slices.Clone(receipt.users),
+		Items:          slices.Clone(receipt.Items),
+		Assignments:    append([]persistence.ReceiptUserItem{}, receipt.assignments...),
+	}, nil
+}
+
+func (s *fakeReceiptStore) UpdateReceipt(ctx context.Context, receiptID string, update persistence.ReceiptUpdate, expectedVersion *int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return 0, err
+	}
+	notesOnly := update.Tax == nil && update.Tip == nil && update.ServiceCharge == nil && update.RoundingStrategy == nil && update.PayerUserID == nil && update.SplitMode == nil && update.TaxInclusive == nil
+	if receipt.finalizedAt != nil && !notesOnly {
+		return 0, &persistence.FinalizedError{FinalizedAt: *receipt.finalizedAt}
+	}
+	if update.PayerUserID != nil && !slices.ContainsFunc(receipt.users, func(u persistence.ReceiptUser) bool { return u.ID == *update.PayerUserID }) {
+		return 0, fmt.Errorf("payer %s is not a user on this receipt", *update.PayerUserID)
+	}
+	if expectedVersion != nil && *expectedVersion != receipt.Version {
+		return 0, fmt.Errorf("receipt was modified by another request (version conflict)")
+	}
+	if update.Tax != nil {
+		receipt.taxTip.Tax = update.Tax
+	}
+	if update.Tip != nil {
+		receipt.taxTip.Tip = update.Tip
+	}
+	if update.ServiceCharge != nil {
+		receipt.taxTip.ServiceCharge = update.ServiceCharge
+	}
+	if update.TaxInclusive != nil {
+		receipt.taxTip.TaxInclusive = *update.TaxInclusive
+	}
+	if update.RoundingStrategy != nil {
+		receipt.rounding.Strategy = *update.RoundingStrategy
+	}
+	if update.PayerUserID != nil {
+		receipt.rounding.PayerUserID = update.PayerUserID
+	}
+	if update.SplitMode != nil {
+		receipt.splitMode = *update.SplitMode
+	}
+	if update.Notes != nil {
+		receipt.notes = update.Notes
+	}
+	receipt.Version++
+	return receipt.Version, nil
+}
+
+func (s *fakeReceiptStore) DeleteReceipt(ctx context.Context, receiptID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return err
+	}
+	receipt.deleted = true
+	return nil
+}
+
+func (s *fakeReceiptStore) GetReceiptCurrency(ctx context.Context, receiptID string) (*string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return nil, err
+	}
+	return receipt.Currency, nil
+}
+
+func (s *fakeReceiptStore) GetReceiptFinalizedAt(ctx context.Context, receiptID string) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return nil, err
+	}
+	return receipt.finalizedAt, nil
+}
+
+func (s *fakeReceiptStore) GetReceiptByImageHash(ctx context.Context, sha256 string) (*persistence.Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, receipt := range s.receipts {
+		if receipt.imageSHA256 == sha256 && receipt.Status != persistence.ReceiptStatusFailed && !receipt.deleted {
+			found := receipt.Receipt
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeReceiptStore) CreateProcessingReceipt(ctx context.Context, receiptID string, imageURL *string, image *persistence.ImageMetadata) (*persistence.Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.receipts == nil {
+		s.receipts = make(map[string]*fakeReceipt)
+	}
+	receipt := &fakeReceipt{
+		Receipt:   persistence.Receipt{ID: receiptID, CreatedAt: time.Now(), ImageURL: imageURL, Version: 1, Status: persistence.ReceiptStatusProcessing, Items: []persistence.ReceiptItem{}},
+		rounding:  persistence.Rounding{Strategy: persistence.RoundingFirst},
+		splitMode: persistence.SplitModeItemized,
+	}
+	if image != nil {
+		receipt.imageSHA256 = image.SHA256
+	}
+	s.receipts[receiptID] = receipt
+	created := receipt.Receipt
+	return &created, nil
+}
+
+func (s *fakeReceiptStore) CompleteReceiptProcessing(ctx context.Context, receiptID string, items []persistence.ReceiptItemDB, ocrText *persistence.OCRTextData, currency *string, receiptDate *time.Time, title *string, tax, tip, serviceCharge, extractedTotal *float64, taxInclusive bool, parser *persistence.ParserInfo, parseStatus string, itemsTruncated bool) ([]persistence.ReceiptItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil || receipt.Status != persistence.ReceiptStatusProcessing {
+		return nil, fmt.Errorf("receipt not found or not processing")
+	}
+	receipt.OCRText, receipt.Currency, receipt.ReceiptDate, receipt.Title = ocrText, currency, receiptDate, title
+	receipt.Status, receipt.ParseStatus, receipt.itemsTruncated = persistence.ReceiptStatusReady, &parseStatus, itemsTruncated
+	receipt.Version++
+	for _, item := range items {
+		receipt.Items = append(receipt.Items, fakeItem(receiptID, item))
+	}
+	return slices.Clone(receipt.Items), nil
+}
+
+func (s *fakeReceiptStore) FailReceiptProcessing(ctx context.Context, receiptID string, parseStatus *string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return err
+	}
+	receipt.Status = persistence.ReceiptStatusFailed
+	if parseStatus != nil {
+		receipt.ParseStatus = parseStatus
+	}
+	return nil
+}
+
+func (s *fakeReceiptStore) GetReceiptUsers(ctx context.Context, receiptID string) ([]persistence.ReceiptUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return []persistence.ReceiptUser{}, nil
+	}
+	return slices.Clone(receipt.users), nil
+}
+
+func (s *fakeReceiptStore) AddUsersToReceipt(ctx context.Context, receiptID string, names []string) ([]persistence.ReceiptUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return nil, err
+	}
+	if err := receipt.requireNewUserNames(names); err != nil {
+		return nil, err
+	}
+	users := make([]persistence.ReceiptUser, len(names))
+	for i, name := range names {
+		users[i] = persistence.ReceiptUser{ID: ulid.Make().String(), ReceiptID: receiptID, Name: name, Color: "#E53935", CreatedAt: time.Now()}
+	}
+	receipt.users = append(receipt.users, users...)
+	return users, nil
+}
+
+// requireNewUserNames fails, as the store does, when one of names matches a user on the receipt ignoring case
+func (r *fakeReceipt) requireNewUserNames(names []string) error {
+	for _, u := range r.users {
+		if slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, u.Name) }) {
+			return fmt.Errorf("receipt already has a user named %q", u.Name)
+		}
+	}
+	return nil
+}
+
+func (s *fakeReceiptStore) AddUserToReceipt(ctx context.Context, receiptID, name string, color *string) (*persistence.ReceiptUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return nil, err
+	}
+	if err := receipt.requireNewUserNames([]string{name}); err != nil {
+		return nil, err
+	}
+	user := persistence.ReceiptUser{ID: ulid.Make().String(), ReceiptID: receiptID, Name: name, Color: "#E53935", CreatedAt: time.Now()}
+	if color != nil {
+		user.Color = *color
+	}
+	receipt.users = append(receipt.users, user)
+	return &user, nil
+}
+
+func (s *fakeReceiptStore) GetReceiptItems(ctx context.Context, receiptID string) ([]persistence.ReceiptItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return []persistence.ReceiptItem{}, nil
+	}
+	return slices.Clone(receipt.Items), nil
+}
+
+func (s *fakeReceiptStore) GetReceiptItem(ctx context.Context, receiptID, itemID string) (*persistence.ReceiptItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if receipt, err := s.receipt(receiptID); err == nil {
+		for _, item := range receipt.Items {
+			if item.ID == itemID {
+				return &item, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("receipt item not found")
+}
+
+func (s *fakeReceiptStore) UpdateReceiptItem(ctx context.Context, receiptID, itemID string, update persistence.ReceiptItemUpdate, expectedVersion *int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return 0, err
+	}
+	if receipt.finalizedAt != nil {
+		return 0, &persistence.FinalizedError{FinalizedAt: *receipt.finalizedAt}
+	}
+	i := slices.IndexFunc(receipt.Items, func(item persistence.ReceiptItem) bool { return item.ID == itemID })
+	if i < 0 {
+		return 0, fmt.Errorf("receipt item not found")
+	}
+	item := &receipt.Items[i]
+	if expectedVersion != nil && *expectedVersion != item.Version {
+		return 0, fmt.Errorf("receipt item was modified by another request (version conflict)")
+	}
+	if update.Taxable != nil {
+		item.Taxable = *update.Taxable
+	}
+	if update.Shared != nil {
+		item.Shared = *update.Shared
+	}
+	item.Version++
+	return item.Version, nil
+}
+
+func (s *fakeReceiptStore) AddReceiptItem(ctx context.Context, receiptID string, item persistence.ReceiptItemDB, maxItems int) (*persistence.ReceiptItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return nil, err
+	}
+	if len(receipt.Items) >= maxItems {
+		return nil, fmt.Errorf("receipt already has the maximum of %d items", maxItems)
+	}
+	added := fakeItem(receiptID, item)
+	receipt.Items = append(receipt.Items, added)
+	return &added, nil
+}
+
+func (s *fakeReceiptStore) AssignItemsToUser(ctx context.Context, receiptUserID string, receiptItemIDs []string, updateExisting bool) ([]persistence.AssignedItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var receipt *fakeReceipt
+	for _, r := range s.receipts {
+		if !r.deleted && slices.ContainsFunc(r.users, func(u persistence.ReceiptUser) bool { return u.ID == receiptUserID }) {
+			receipt = r
+		}
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("receipt user or item not found")
+	}
+	existing := func(itemID string) int {
+		return slices.IndexFunc(receipt.assignments, func(a persistence.ReceiptUserItem) bool {
+			return a.ReceiptUserID == receiptUserID && a.ReceiptItemID == itemID
+		})
+	}
+	// Every item is checked before any is assigned, as the transaction would roll back
+	for _, itemID := range receiptItemIDs {
+		if !slices.ContainsFunc(receipt.Items, func(item persistence.ReceiptItem) bool { return item.ID == itemID }) {
+			return nil, fmt.Errorf("receipt user or item not found")
+		}
+		if i := existing(itemID); i >= 0 && receipt.assignments[i].AmountOwed != nil && !updateExisting {
+			return nil, fmt.Errorf("user is already assigned to item %s with a different amount", itemID)
+		}
+	}
+	assigned := make([]persistence.AssignedItem, 0, len(receiptItemIDs))
+	for _, itemID := range receiptItemIDs {
+		i := existing(itemID)
+		created := i < 0
+		if created {
+			receipt.assignments = append(receipt.assignments, persistence.ReceiptUserItem{ID: ulid.Make().String(), ReceiptUserID: receiptUserID, ReceiptItemID: itemID, CreatedAt: time.Now()})
+			i = len(receipt.assignments) - 1
+		}
+		receipt.assignments[i].AmountOwed = nil
+		assigned = append(assigned, persistence.AssignedItem{ReceiptUserItem: receipt.assignments[i], Created: created})
+	}
+	return assigned, nil
+}
+
+func (s *fakeReceiptStore) BulkAssign(ctx context.Context, receiptID string, assignments []persistence.ReceiptUserItemDB) ([]persistence.ReceiptUserItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return nil, err
+	}
+	var saved []persistence.ReceiptUserItem
+	for _, a := range assignments {
+		userID := a.ReceiptUserID
+		if userID == "" {
+			name := strings.TrimSpace(a.UserName)
+			i := slices.IndexFunc(receipt.users, func(u persistence.ReceiptUser) bool { return strings.EqualFold(u.Name, name) })
+			if i < 0 {
+				receipt.users = append(receipt.users, persistence.ReceiptUser{ID: ulid.Make().String(), ReceiptID: receiptID, Name: name, CreatedAt: time.Now()})
+				i = len(receipt.users) - 1
+			}
+			userID = receipt.users[i].ID
+		}
+		if !slices.ContainsFunc(receipt.users, func(u persistence.ReceiptUser) bool { return u.ID == userID }) {
+			return nil, fmt.Errorf("receipt user %s not found", userID)
+		}
+		if !slices.ContainsFunc(receipt.Items, func(item persistence.ReceiptItem) bool { return item.ID == a.ReceiptItemID }) {
+			return nil, fmt.Errorf("receipt item %s not found", a.ReceiptItemID)
+		}
+		i := slices.IndexFunc(receipt.assignments, func(existing persistence.ReceiptUserItem) bool {
+			return existing.ReceiptUserID == userID && existing.ReceiptItemID == a.ReceiptItemID
+		})
+		if i < 0 {
+			receipt.assignments = append(receipt.assignments, persistence.ReceiptUserItem{ID: ulid.Make().String(), ReceiptUserID: userID, ReceiptItemID: a.ReceiptItemID, CreatedAt: time.Now()})
+			i = len(receipt.assignments) - 1
+		}
+		receipt.assignments[i].AmountOwed = a.AmountOwed
+		saved = append(saved, receipt.assignments[i])
+	}
+	return saved, nil
+}
+
+func (s *fakeReceiptStore) DeleteReceiptItem(ctx context.Context, receiptID, itemID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, err := s.receipt(receiptID)
+	if err != nil {
+		return 0, fmt.Errorf("receipt item not found")
+	}
+	i := slices.IndexFunc(receipt.Items, func(item persistence.ReceiptItem) bool { return item.ID == itemID })
+	if i < 0 {
+		return 0, fmt.Errorf("receipt item not found")
+	}
+	receipt.Items = slices.Delete(receipt.Items, i, i+1)
+	before := len(receipt.assignments)
+	receipt.assignments = slices.DeleteFunc(receipt.assignments, func(a persistence.ReceiptUserItem) bool { return a.ReceiptItemID == itemID })
+	return int64(before - len(receipt.assignments)), nil
+}
+
+// fakeItem builds a stored item the way persistence inserts one
+func fakeItem(receiptID string, item persistence.ReceiptItemDB) persistence.ReceiptItem {
+	return persistence.ReceiptItem{
+		ID:           ulid.Make().String(),
+		ReceiptID:    receiptID,
+		Name:         item.Name,
+		Quantity:     item.Quantity,
+		TotalPrice:   item.TotalPrice,
+		PricePerItem: item.PricePerItem,
+		Version:      1,
+		Taxable:      true,
+		IsDiscount:   item.IsDiscount,
+		Category:     item.Category,
+		NeedsReview:  item.NeedsReview,
+		Confidence:   item.Confidence,
+	}
+}
@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"splitzies/storage"
+)
+
+// geminiLatencyBuckets are the upper bounds, in seconds, of the Gemini latency histogram. A receipt usually
+// parses in a few seconds; the long tail is truncated responses retried with a larger budget.
+var geminiLatencyBuckets = [...]float64{0.5, 1, 2, 5, 10, 20, 30, 60}
+
+// geminiMetrics counts Gemini parse calls, tokens, and latency for GET /metrics. The zero value is ready to use.
+type geminiMetrics struct {
+	mu              sync.Mutex
+	parsesOK        int
+	parsesFailed    int
+	promptTokens    int
+	candidateTokens int
+	latencyCounts   [len(geminiLatencyBuckets) + 1]int // per bucket, not cumulative; the last is +Inf
+	latencySum      float64
+}
+
+// record adds one receipt's Gemini usage
+func (m *geminiMetrics) record(usage storage.GeminiUsage, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ok {
+		m.parsesOK++
+	} else {
+		m.parsesFailed++
+	}
+	m.promptTokens += usage.PromptTokens
+	m.candidateTokens += usage.CandidateTokens
+
+	seconds := usage.Latency.Seconds()
+	bucket := len(geminiLatencyBuckets)
+	for i, bound := range geminiLatencyBuckets {
+		if seconds <= bound {
+			bucket = i
+			break
+		}
+	}
+	m.latencyCounts[bucket]++
+	m.latencySum += seconds
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format
+func (m *geminiMetrics) writeTo(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b.WriteString("# HELP splitzies_gemini_parses_total Receipts parsed with Gemini, by outcome.\n")
+	b.WriteString("# TYPE splitzies_gemini_parses_total counter\n")
+	fmt.Fprintf(b, "splitzies_gemini_parses_total{outcome=\"ok\"} %d\n", m.parsesOK)
+	fmt.Fprintf(b, "splitzies_gemini_parses_total{outcome=\"error\"} %d\n", m.parsesFailed)
+	b.WriteString("# HELP splitzies_gemini_prompt_tokens_total Prompt tokens sent to Gemini.\n")
+	b.WriteString("# TYPE splitzies_gemini_prompt_tokens_total counter\n")
+	fmt.Fprintf(b, "splitzies_gemini_prompt_tokens_total %d\n", m.promptTokens)
+	b.WriteString("# HELP splitzies_gemini_candidate_tokens_total Output tokens returned by Gemini.\n")
+	b.WriteString("# TYPE splitzies_gemini_candidate_tokens_total counter\n")
+	fmt.Fprintf(b, "splitzies_gemini_candidate_tokens_total %d\n", m.candidateTokens)
+
+	b.WriteString("# HELP splitzies_gemini_parse_duration_seconds Time spent waiting on Gemini per receipt, retries included.\n")
+	b.WriteString("# TYPE splitzies_gemini_parse_duration_seconds histogram\n")
+	cumulative := 0
+	for i, bound := range geminiLatencyBuckets {
+		cumulative += m.latencyCounts[i]
+		fmt.Fprintf(b, "splitzies_gemini_parse_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	cumulative += m.latencyCounts[len(geminiLatencyBuckets)]
+	fmt.Fprintf(b, "splitzies_gemini_parse_duration_seconds_bucket{le=\"+Inf\"} %d\n", cumulative)
+	fmt.Fprintf(b, "splitzies_gemini_parse_duration_seconds_sum %s\n", strconv.FormatFloat(m.latencySum, 'g', -1, 64))
+	fmt.Fprintf(b, "splitzies_gemini_parse_duration_seconds_count %d\n", cumulative)
+}
+
+// recordGeminiUsage logs what parsing a receipt with Gemini cost and adds it to the metrics.
+// Does nothing when Gemini wasn't called (OCR only, AI disabled, or it wasn't configured).
+func (t *Transport) recordGeminiUsage(receiptID string, usage storage.GeminiUsage, ok bool) {
+	if usage.Calls == 0 {
+		return
+	}
+	t.log.Info("Gemini usage", "receipt_id", receiptID, "ok", ok, "calls", usage.Calls,
+		"prompt_tokens", usage.PromptTokens, "candidate_tokens", usage.CandidateTokens, "total_tokens", usage.TotalTokens,
+		"latency_ms", usage.Latency.Milliseconds())
+	if usage.TruncatedCalls > 0 {
+		t.log.Warn("Gemini response truncated", "receipt_id", receiptID, "truncated_calls", usage.TruncatedCalls, "max_output_tokens", usage.MaxOutputTokens)
+	}
+	t.geminiMetrics.record(usage, ok)
+}
+
+// MetricsHandler serves Gemini token usage and latency for Prometheus to scrape
+// Expects GET /metrics; requires the admin API key, so the scrape config must send it
+func (t *Transport) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if !t.requireAdmin(w, r) {
+		return
+	}
+	var b strings.Builder
+	t.geminiMetrics.writeTo(&b)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		fmt.Printf("Failed to write metrics: %v\n", err)
+	}
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"splitzies/storage"
+)
+
+func TestMetricsHandlerReportsGeminiUsage(t *testing.T) {
+	transport := &Transport{log: slog.New(slog.DiscardHandler), adminAPIKey: "secret"}
+	transport.recordGeminiUsage("r1", storage.GeminiUsage{Calls: 1, PromptTokens: 800, CandidateTokens: 400, TotalTokens: 1200, Latency: 1500 * time.Millisecond}, true)
+	transport.recordGeminiUsage("r2", storage.GeminiUsage{Calls: 2, PromptTokens: 900, CandidateTokens: 3000, TotalTokens: 3900, Latency: 45 * time.Second}, false)
+	// OCR only: Gemini wasn't called, so nothing is counted
+	transport.recordGeminiUsage("r3", storage.GeminiUsage{}, false)
+
+	w := httptest.NewRecorder()
+	transport.MetricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("without the admin key: status = %d, want 403", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set(adminKeyHeader, "secret")
+	w = httptest.NewRecorder()
+	transport.MetricsHandler(w, r)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("status = %d, Content-Type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`splitzies_gemini_parses_total{outcome="ok"} 1`,
+		`splitzies_gemini_parses_total{outcome="error"} 1`,
+		"splitzies_gemini_prompt_tokens_total 1700",
+		"splitzies_gemini_candidate_tokens_total 3400",
+		`splitzies_gemini_parse_duration_seconds_bucket{le="1"} 0`,
+		`splitzies_gemini_parse_duration_seconds_bucket{le="2"} 1`,
+		`splitzies_gemini_parse_duration_seconds_bucket{le="30"} 1`,
+		`splitzies_gemini_parse_duration_seconds_bucket{le="60"} 2`,
+		`splitzies_gemini_parse_duration_seconds_bucket{le="+Inf"} 2`,
+		"splitzies_gemini_parse_duration_seconds_sum 46.5",
+		"splitzies_gemini_parse_duration_seconds_count 2",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestRecordGeminiUsageLogsTruncation(t *testing.T) {
+	var logs strings.Builder
+	transport := &Transport{log: slog.New(slog.NewTextHandler(&logs, nil))}
+	transport.recordGeminiUsage("r1", storage.GeminiUsage{Calls: 1, TotalTokens: 1200, MaxOutputTokens: 1024}, true)
+	if strings.Contains(logs.String(), "truncated") {
+		t.Errorf("logged a truncation for a response that wasn't truncated:\n%s", logs.String())
+	}
+	transport.recordGeminiUsage("r2", storage.GeminiUsage{Calls: 2, TotalTokens: 3900, TruncatedCalls: 1, MaxOutputTokens: 2048}, true)
+	if want := `level=WARN msg="Gemini response truncated" receipt_id=r2 truncated_calls=1 max_output_tokens=2048`; !strings.Contains(logs.String(), want) {
+		t.Errorf("logs missing %q:\n%s", want, logs.String())
+	}
+}
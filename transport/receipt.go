@@ -1,47 +1,133 @@
 package transport
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"splitzies/money"
 	"splitzies/persistence"
 )
 
+// Page size bounds for GET /receipts/{receipt_id}/assignments
+const (
+	defaultAssignmentsPageSize = 50
+	maxAssignmentsPageSize     = 200
+)
+
+// maxNotesLength caps a receipt's notes, in characters
+const maxNotesLength = 1000
+
+// maxUsersPerRequest caps how many users one POST /receipts/{receipt_id}/users may add
+const maxUsersPerRequest = 100
+
+// portionToleranceCents is how far dollar portions of an item may sum from its total, for rounding like
+// splitting $10 three ways as 3.33 + 3.33 + 3.33
+const portionToleranceCents = 1
+
+// userColorPattern matches a #RRGGBB hex color
+var userColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// dedupeUserNames trims names and drops repeats, keeping the first spelling. Names match case-insensitively,
+// as when assigning items by user_name, so "alice" and "Alice" would otherwise be ambiguous. A name matching a
+// user already on the receipt is left for the store to reject.
+func dedupeUserNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
+
 // AddUserToReceiptHandler handles adding a user to a receipt
 // Expects POST /receipts/{receipt_id}/users
-// Request body: {"name": "John Doe"}
+// Request body: {"name": "John Doe", "color": "#1E88E5"}, or {"names": ["John", "Jane"]} to add several users in one transaction
+// color is optional; users without one get the next color from a fixed palette
 func (t *Transport) AddUserToReceiptHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+
+	var req AddUserToReceiptRequest
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
-	receiptID, ok := parseReceiptUsersPath(r.URL.Path)
-	if !ok {
-		http.Error(w, NewValidationError("path", "invalid URL path format").Error(), http.StatusBadRequest)
-		return
+	if req.Color != nil && !userColorPattern.MatchString(*req.Color) {
+		errs.Add("color", "color must be a hex color like #1E88E5")
 	}
-
-	var req AddUserToReceiptRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, NewValidationError("body", fmt.Sprintf("failed to parse request body: %v", err)).Error(), http.StatusBadRequest)
+	if req.Names != nil {
+		if req.Name != "" {
+			errs.Add("names", "send either name or names, not both")
+		}
+		if req.Color != nil {
+			errs.Add("color", "color can only be sent with name; users added by names get palette colors")
+		}
+		if len(req.Names) == 0 {
+			errs.Add("names", "at least one name is required")
+		}
+		if len(req.Names) > maxUsersPerRequest {
+			errs.Add("names", fmt.Sprintf("at most %d users can be added at once", maxUsersPerRequest))
+		}
+		for i, name := range req.Names {
+			if strings.TrimSpace(name) == "" {
+				errs.Add(fmt.Sprintf("names[%d]", i), "name is required")
+			}
+		}
+		if len(errs) > 0 {
+			writeValidationErrors(w, errs)
+			return
+		}
+		t.addUsersToReceipt(w, receiptID, dedupeUserNames(req.Names))
 		return
 	}
+	req.Name = strings.TrimSpace(req.Name)
 	if req.Name == "" {
-		http.Error(w, NewValidationError("name", "name is required").Error(), http.StatusBadRequest)
+		errs.Add("name", "name is required")
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
 		return
 	}
 
+	if req.Color != nil {
+		color := strings.ToUpper(*req.Color)
+		req.Color = &color
+	}
+
 	ctx := context.Background()
-	user, err := t.persistenceClient.AddUserToReceipt(ctx, receiptID, req.Name)
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	user, err := t.persistenceClient.AddUserToReceipt(ctx, receiptID, req.Name, req.Color)
 	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		if strings.Contains(err.Error(), "already has a user") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to add user to receipt: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -52,94 +138,341 @@ func (t *Transport) AddUserToReceiptHandler(w http.ResponseWriter, r *http.Reque
 	response.User.ID = user.ID
 	response.User.ReceiptID = user.ReceiptID
 	response.User.Name = user.Name
+	response.User.Color = user.Color
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/receipts/%s/users/%s", user.ReceiptID, user.ID))
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		fmt.Printf("Failed to encode response: %v\n", err)
 	}
 }
 
-// PatchReceiptHandler handles updating tax and tip on a receipt (when not parsed from OCR)
-// Expects PATCH /receipts/{receipt_id}
-// Request body: {"tax": 1.50, "tip": 5.00} - both optional
-func (t *Transport) PatchReceiptHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPatch {
-		http.Error(w, NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
+// addUsersToReceipt creates users for names in one transaction and responds with them in order
+func (t *Transport) addUsersToReceipt(w http.ResponseWriter, receiptID string, names []string) {
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
 		return
 	}
-	receiptID, ok := parseReceiptIDPath(r.URL.Path)
-	if !ok {
-		http.Error(w, NewValidationError("path", "invalid URL path format").Error(), http.StatusBadRequest)
+	users, err := t.persistenceClient.AddUsersToReceipt(ctx, receiptID, names)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "already has a user") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to add users to receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := AddUsersToReceiptResponse{
+		Message: "Users added to receipt successfully",
+		Users:   make([]GetReceiptUserResponse, len(users)),
+	}
+	for i, user := range users {
+		response.Users[i] = GetReceiptUserResponse{ID: user.ID, ReceiptID: user.ReceiptID, Name: user.Name, Color: user.Color}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// validSplitMode reports whether mode is one splitAssignments knows
+func validSplitMode(mode string) bool {
+	return mode == persistence.SplitModeItemized || mode == persistence.SplitModeEqual
+}
+
+// validRoundingStrategy reports whether strategy is one ComputeBillSplit knows
+func validRoundingStrategy(strategy string) bool {
+	switch strategy {
+	case persistence.RoundingFirst, persistence.RoundingPayer, persistence.RoundingLargestShare:
+		return true
+	}
+	return false
+}
+
+// PatchReceiptHandler handles updating tax, tip, service charge, rounding strategy, split mode, tax inclusivity, and notes on a receipt
+// Expects PATCH /receipts/{receipt_id}
+// Request body: {"tax": 1.50, "tip": 5.00, "service_charge": 9.00, "rounding_strategy": "payer", "payer_user_id": "01HQ...", "split_mode": "equal", "tax_inclusive": true, "notes": "Team lunch", "version": 3} - all optional,
+// at least one of tax/tip/tip_percent/service_charge/rounding_strategy/payer_user_id/split_mode/tax_inclusive/notes required
+// split_mode equal divides the grand total evenly among users regardless of assignments; itemized switches back to them
+// tip_percent (e.g. 18) may be sent instead of tip; it is resolved against the current subtotal
+// notes is capped at maxNotesLength characters; an empty string clears it. Notes alone may be edited on a finalized receipt
+// The expected version may instead be sent as an If-Match header; a stale version returns 409
+func (t *Transport) PatchReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	var req PatchReceiptRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, NewValidationError("body", fmt.Sprintf("failed to parse request body: %v", err)).Error(), http.StatusBadRequest)
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	notesOnly := req.Tax == nil && req.Tip == nil && req.TipPercent == nil && req.ServiceCharge == nil && req.Rounding == nil && req.PayerUserID == nil && req.SplitMode == nil && req.TaxInclusive == nil
+	if notesOnly && req.Notes == nil {
+		http.Error(w, NewValidationError("body", "at least one of tax, tip, tip_percent, service_charge, rounding_strategy, payer_user_id, split_mode, tax_inclusive, or notes is required").Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Notes != nil {
+		notes := strings.TrimSpace(*req.Notes)
+		if utf8.RuneCountInString(notes) > maxNotesLength {
+			http.Error(w, NewValidationError("notes", fmt.Sprintf("notes must be at most %d characters", maxNotesLength)).Error(), http.StatusBadRequest)
+			return
+		}
+		req.Notes = &notes
+	}
+	if req.Rounding != nil && !validRoundingStrategy(*req.Rounding) {
+		http.Error(w, NewValidationError("rounding_strategy", "rounding_strategy must be first, payer, or largest-share").Error(), http.StatusBadRequest)
+		return
+	}
+	if req.PayerUserID != nil {
+		if err := validateULID("payer_user_id", *req.PayerUserID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.SplitMode != nil && !validSplitMode(*req.SplitMode) {
+		http.Error(w, NewValidationError("split_mode", "split_mode must be itemized or equal").Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ServiceCharge != nil && *req.ServiceCharge < 0 {
+		http.Error(w, NewValidationError("service_charge", "service_charge must not be negative").Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Tip != nil && req.TipPercent != nil {
+		http.Error(w, NewValidationError("tip_percent", "send either tip or tip_percent, not both").Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TipPercent != nil && (*req.TipPercent < 0 || *req.TipPercent > 100) {
+		http.Error(w, NewValidationError("tip_percent", "tip_percent must be between 0 and 100").Error(), http.StatusBadRequest)
 		return
 	}
-	if req.Tax == nil && req.Tip == nil {
-		http.Error(w, NewValidationError("body", "at least one of tax or tip is required").Error(), http.StatusBadRequest)
+	version, err := expectedVersion(r, req.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	ctx := context.Background()
-	err := t.persistenceClient.UpdateReceiptTaxTip(ctx, receiptID, req.Tax, req.Tip)
+	// A missing or deleted receipt still 404s on a notes-only edit: UpdateReceipt skips deleted receipts
+	if !notesOnly && !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	var resolvedTip *money.Amount
+	if req.TipPercent != nil {
+		items, err := t.persistenceClient.GetReceiptItems(ctx, receiptID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get receipt items: %v", err), http.StatusInternalServerError)
+			return
+		}
+		tip := tipFromPercent(items, *req.TipPercent)
+		req.Tip = &tip
+
+		currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+		if err != nil {
+			t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+			currency = &defaultUSD
+		}
+		resolvedTip = money.Ptr(&tip, currency)
+	}
+
+	update := persistence.ReceiptUpdate{Tax: req.Tax, Tip: req.Tip, ServiceCharge: req.ServiceCharge, RoundingStrategy: req.Rounding, PayerUserID: req.PayerUserID, SplitMode: req.SplitMode, TaxInclusive: req.TaxInclusive, Notes: req.Notes}
+	newVersion, err := t.persistenceClient.UpdateReceipt(ctx, receiptID, update, version)
 	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not a user on this receipt") {
+			http.Error(w, NewValidationError("payer_user_id", err.Error()).Error(), http.StatusBadRequest)
+			return
+		}
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		if strings.Contains(err.Error(), "version conflict") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to update receipt: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	response := PatchReceiptResponse{
+		Message: "Receipt updated successfully",
+		Version: newVersion,
+		Tip:     resolvedTip,
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{"message": "Receipt updated successfully"}); err != nil {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		fmt.Printf("Failed to encode response: %v\n", err)
 	}
 }
 
-// GetReceiptUsersHandler handles getting users for a receipt
-// Expects GET /receipts/{receipt_id}/users
-func (t *Transport) GetReceiptUsersHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
+// PatchReceiptItemHandler handles updating a single receipt item's taxable and shared flags
+// Expects PATCH /receipts/{receipt_id}/items/{item_id}
+// Request body: {"taxable": false, "shared": true, "version": 2} - at least one of taxable or shared; version optional
+// The expected version may instead be sent as an If-Match header; a stale version returns 409
+func (t *Transport) PatchReceiptItemHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	itemID, err := pathULID(r, "item_id")
+	errs.Collect(err)
+	if err := errs.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	receiptID, ok := parseReceiptUsersPath(r.URL.Path)
-	if !ok {
-		http.Error(w, NewValidationError("path", "invalid URL path format").Error(), http.StatusBadRequest)
+
+	var req PatchReceiptItemRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Taxable == nil && req.Shared == nil {
+		http.Error(w, NewValidationError("body", "at least one of taxable or shared is required").Error(), http.StatusBadRequest)
+		return
+	}
+	version, err := expectedVersion(r, req.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	ctx := context.Background()
-	exists, err := t.persistenceClient.ReceiptExists(ctx, receiptID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to check receipt: %v", err), http.StatusInternalServerError)
+	if !t.requireUnfinalized(ctx, w, receiptID) {
 		return
 	}
-	if !exists {
-		http.Error(w, "receipt not found", http.StatusNotFound)
+	newVersion, err := t.persistenceClient.UpdateReceiptItem(ctx, receiptID, itemID, persistence.ReceiptItemUpdate{Taxable: req.Taxable, Shared: req.Shared}, version)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "version conflict") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to update receipt item: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	users, err := t.persistenceClient.GetReceiptUsers(ctx, receiptID)
+	response := PatchReceiptItemResponse{
+		Message: "Receipt item updated successfully",
+		Version: newVersion,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// Orders for GET /receipts/{receipt_id}/users
+const (
+	userSortCreated = "created" // The order users were added (the default)
+	userSortName    = "name"    // Alphabetical, ignoring case
+	userSortTotal   = "total"   // Highest user_total first
+)
+
+// parseUserSortQuery reads the optional sort parameter of GET /receipts/{receipt_id}/users, defaulting to created
+func parseUserSortQuery(query url.Values) (string, error) {
+	switch by := query.Get("sort"); by {
+	case "":
+		return userSortCreated, nil
+	case userSortCreated, userSortName, userSortTotal:
+		return by, nil
+	default:
+		return "", NewValidationError("sort", "sort must be created, name, or total")
+	}
+}
+
+// sortReceiptUsers orders users, which are in the order they were added, by the given sort. Ties keep that order.
+// Sorting by total needs user_total set on every user.
+func sortReceiptUsers(users []GetReceiptUserResponse, by string) {
+	switch by {
+	case userSortName:
+		sort.SliceStable(users, func(i, j int) bool {
+			return strings.ToLower(users[i].Name) < strings.ToLower(users[j].Name)
+		})
+	case userSortTotal:
+		sort.SliceStable(users, func(i, j int) bool {
+			return toCents(users[i].UserTotal.Value) > toCents(users[j].UserTotal.Value)
+		})
+	}
+}
+
+// GetReceiptUsersHandler handles getting users for a receipt
+// Expects GET /receipts/{receipt_id}/users?sort=name
+// sort is created (the default), name, or total; total computes the split and includes each user_total
+func (t *Transport) GetReceiptUsersHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get receipt users: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	sortBy, err := parseUserSortQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	var responseUsers []GetReceiptUserResponse
+	if sortBy == userSortTotal {
+		snapshot, err := t.persistenceClient.GetReceiptSnapshot(ctx, receiptID)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+			return
+		}
+		responseUsers = t.receiptSplitResponse(snapshot).Users
+	} else {
+		exists, err := t.persistenceClient.ReceiptExists(ctx, receiptID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to check receipt: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "receipt not found", http.StatusNotFound)
+			return
+		}
+
+		users, err := t.persistenceClient.GetReceiptUsers(ctx, receiptID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get receipt users: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	responseUsers := make([]GetReceiptUserResponse, len(users))
-	for i, u := range users {
-		responseUsers[i] = GetReceiptUserResponse{
-			ID:        u.ID,
-			ReceiptID: u.ReceiptID,
-			Name:      u.Name,
-			UserTotal: nil,
+		responseUsers = make([]GetReceiptUserResponse, len(users))
+		for i, u := range users {
+			responseUsers[i] = GetReceiptUserResponse{
+				ID:        u.ID,
+				ReceiptID: u.ReceiptID,
+				Name:      u.Name,
+				Color:     u.Color,
+				UserTotal: nil,
+			}
 		}
 	}
+	sortReceiptUsers(responseUsers, sortBy)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(GetReceiptUsersResponse{Users: responseUsers}); err != nil {
@@ -150,13 +483,9 @@ func (t *Transport) GetReceiptUsersHandler(w http.ResponseWriter, r *http.Reques
 // GetReceiptItemsHandler handles getting items for a receipt
 // Expects GET /receipts/{receipt_id}/items
 func (t *Transport) GetReceiptItemsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
-		return
-	}
-	receiptID, ok := parseReceiptItemsPath(r.URL.Path)
-	if !ok {
-		http.Error(w, NewValidationError("path", "invalid URL path format").Error(), http.StatusBadRequest)
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -190,44 +519,72 @@ func (t *Transport) GetReceiptItemsHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-// GetReceiptHandler handles getting the full receipt with users, items, and assignments (bill split data)
-// Expects GET /receipts/{receipt_id}
-// Returns users, items, and assignments (user-item correlation) for easy frontend bill split UI
-func (t *Transport) GetReceiptHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
+// AddReceiptItemHandler handles adding an item by hand, e.g. one the parser missed or on a receipt uploaded
+// with DISABLE_AI set
+// Expects POST /receipts/{receipt_id}/items
+// Request body: {"name": "Fries", "quantity": 2, "total_price": 7.00} - price_per_item optional (defaults to
+// total_price / quantity), quantity optional (defaults to 1); is_discount marks a coupon with a negative total_price
+func (t *Transport) AddReceiptItemHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+
+	var req AddReceiptItemRequest
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
-	receiptID, ok := parseReceiptIDPath(r.URL.Path)
-	if !ok {
-		http.Error(w, NewValidationError("path", "invalid URL path format").Error(), http.StatusBadRequest)
-		return
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		errs.Add("name", "name is required")
 	}
-
-	ctx := context.Background()
-	exists, err := t.persistenceClient.ReceiptExists(ctx, receiptID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to check receipt: %v", err), http.StatusInternalServerError)
-		return
+	quantity := 1
+	if req.Quantity != nil {
+		quantity = *req.Quantity
 	}
-	if !exists {
-		http.Error(w, "receipt not found", http.StatusNotFound)
-		return
+	if quantity < 1 {
+		errs.Add("quantity", "quantity must be at least 1")
 	}
-
-	users, err := t.persistenceClient.GetReceiptUsers(ctx, receiptID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get receipt users: %v", err), http.StatusInternalServerError)
+	switch {
+	case req.TotalPrice == nil:
+		errs.Add("total_price", "total_price is required")
+	case req.IsDiscount && *req.TotalPrice >= 0:
+		errs.Add("total_price", "total_price must be negative for a discount")
+	case !req.IsDiscount && *req.TotalPrice < 0:
+		errs.Add("total_price", "total_price must not be negative; set is_discount for a coupon")
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
 		return
 	}
-	items, err := t.persistenceClient.GetReceiptItems(ctx, receiptID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get receipt items: %v", err), http.StatusInternalServerError)
+	pricePerItem := *req.TotalPrice / float64(quantity)
+	if req.PricePerItem != nil {
+		pricePerItem = *req.PricePerItem
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
 		return
 	}
-	assignments, err := t.persistenceClient.GetReceiptAssignments(ctx, receiptID)
+	item, err := t.persistenceClient.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{
+		Name:         req.Name,
+		Quantity:     quantity,
+		TotalPrice:   *req.TotalPrice,
+		PricePerItem: pricePerItem,
+		IsDiscount:   req.IsDiscount,
+	}, t.maxReceiptItems)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get receipt assignments: %v", err), http.StatusInternalServerError)
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "already has") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to add receipt item: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -236,72 +593,648 @@ func (t *Transport) GetReceiptHandler(w http.ResponseWriter, r *http.Request) {
 		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
 		currency = &defaultUSD
 	}
-
-	split := ComputeBillSplit(items, assignments)
-	response := ToGetReceiptResponse(receiptID, users, items, assignments, split, currency)
+	response := AddReceiptItemResponse{
+		Message: "Receipt item added successfully",
+		Item:    itemsToReceiptItems([]persistence.ReceiptItem{*item}, currency)[0],
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/receipts/%s/items/%s", receiptID, item.ID))
+	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		fmt.Printf("Failed to encode response: %v\n", err)
 	}
 }
 
-// AssignItemsToUserHandler handles assigning items to a user
-// Expects POST /receipts/{receipt_id}/users/{user_id}/items
-func (t *Transport) AssignItemsToUserHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, NewInvalidMethodError(r.Method).Error(), http.StatusMethodNotAllowed)
+// GetReceiptItemHandler handles getting a single item on a receipt
+// Expects GET /receipts/{receipt_id}/items/{item_id}
+// Returns 404 when the item is not on the receipt
+func (t *Transport) GetReceiptItemHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	itemID, err := pathULID(r, "item_id")
+	errs.Collect(err)
+	if err := errs.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	userID, ok := parseReceiptUserItemsPath(r.URL.Path)
-	if !ok {
-		http.Error(w, NewValidationError("path", "invalid URL path format").Error(), http.StatusBadRequest)
+
+	ctx := context.Background()
+	item, err := t.persistenceClient.GetReceiptItem(ctx, receiptID, itemID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get receipt item: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	var req AssignItemsToUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, NewValidationError("body", fmt.Sprintf("failed to parse request body: %v", err)).Error(), http.StatusBadRequest)
-		return
+	currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+	if err != nil {
+		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+		currency = &defaultUSD
 	}
-	if len(req.ItemIDs) == 0 {
-		http.Error(w, NewValidationError("item_ids", "at least one item_id is required").Error(), http.StatusBadRequest)
-		return
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(itemsToReceiptItems([]persistence.ReceiptItem{*item}, currency)[0]); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
 	}
+}
 
-	assignedItems := make([]AssignItemsToUserItem, 0, len(req.ItemIDs))
+// DeleteReceiptItemHandler handles removing an item, such as a mis-parsed line, and every assignment of it
+// Expects DELETE /receipts/{receipt_id}/items/{item_id}
+// Returns 404 when the item is not on the receipt, 409 when the receipt is finalized
+func (t *Transport) DeleteReceiptItemHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	itemID, err := pathULID(r, "item_id")
+	errs.Collect(err)
+	if err := errs.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	ctx := context.Background()
-	for _, itemID := range req.ItemIDs {
-		assignment, err := t.persistenceClient.AssignItemToUser(ctx, userID, itemID, nil)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				http.Error(w, err.Error(), http.StatusNotFound)
-				return
-			}
-			http.Error(w, fmt.Sprintf("Failed to assign item %s to user: %v", itemID, err), http.StatusInternalServerError)
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	removed, err := t.persistenceClient.DeleteReceiptItem(ctx, receiptID, itemID)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
 			return
 		}
-		assignedItems = append(assignedItems, AssignItemsToUserItem{
-			ID:            assignment.ID,
-			ReceiptUserID: assignment.ReceiptUserID,
-			ReceiptItemID: assignment.ReceiptItemID,
-		})
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to delete receipt item: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	response := AssignItemsToUserResponse{
-		Message: fmt.Sprintf("Successfully assigned %d item(s) to user", len(assignedItems)),
-		Items:   assignedItems,
+	response := DeleteReceiptItemResponse{
+		Message:            fmt.Sprintf("Item removed along with %d assignment(s)", removed),
+		ItemID:             itemID,
+		AssignmentsRemoved: removed,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		fmt.Printf("Failed to encode response: %v\n", err)
 	}
 }
 
-func itemsToReceiptItems(items []persistence.ReceiptItem, currency *string) []ReceiptItem {
+// GetReceiptHandler handles getting the full receipt with users, items, and assignments (bill split data)
+// Expects GET /receipts/{receipt_id}
+// Returns users, items, and assignments (user-item correlation) for easy frontend bill split UI
+// Optional query params convert_to=EUR&rate=0.92 add totals converted at the given rate
+// Accept: text/csv returns the per-user split as CSV instead; other Accept values without JSON get 406
+// JSON responses carry a weak ETag; a matching If-None-Match gets 304 Not Modified, for cheap polling while processing
+func (t *Transport) GetReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conversion, err := parseConversionQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	formatted, err := parseFormattedQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Vary", "Accept")
+	mediaType, ok := negotiateSplitMediaType(r.Header.Get("Accept"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("not acceptable; this resource is available as %s or %s", mediaTypeJSON, mediaTypeCSV), http.StatusNotAcceptable)
+		return
+	}
+
+	ctx := context.Background()
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(ctx, receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := t.receiptSplitResponse(snapshot)
+	if mediaType == mediaTypeCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="receipt-%s.csv"`, receiptID))
+		if err := writeSplitCSV(w, snapshot, response); err != nil {
+			fmt.Printf("Failed to write CSV response: %v\n", err)
+		}
+		return
+	}
+	response.Version = snapshot.Version
+	response.Status = snapshot.Status
+	response.ParseStatus = snapshot.ParseStatus
+	response.ItemsTruncated = snapshot.ItemsTruncated
+	response.Notes = snapshot.Notes
+	response.Rounding = snapshot.Rounding.Strategy
+	response.PayerUserID = snapshot.Rounding.PayerUserID
+	response.SplitMode = snapshot.SplitMode
+	response.NeedsReview = snapshot.NeedsReview
+	if snapshot.FinalizedAt != nil {
+		finalizedAt := snapshot.FinalizedAt.Format(time.RFC3339)
+		response.Finalized, response.FinalizedAt = true, &finalizedAt
+	}
+	if snapshot.Image != nil {
+		response.Image = &ReceiptImageInfo{Width: snapshot.Image.Width, Height: snapshot.Image.Height, SizeBytes: snapshot.Image.SizeBytes}
+	}
+	if t.debugResponses {
+		response.Debug = &ReceiptDebugInfo{}
+		if snapshot.Parser != nil {
+			response.Debug.ParserSource, response.Debug.ModelVersion = snapshot.Parser.Source, snapshot.Parser.ModelVersion
+		}
+		for _, item := range snapshot.Items {
+			if item.Confidence == nil {
+				continue
+			}
+			if response.Debug.ItemConfidence == nil {
+				response.Debug.ItemConfidence = make(map[string]float64)
+			}
+			response.Debug.ItemConfidence[item.ID] = *item.Confidence
+		}
+	}
+	if conversion != nil {
+		response.ConvertedTotal, err = convertTotals(response, *conversion)
+		if err != nil {
+			http.Error(w, NewValidationError("convert_to", err.Error()).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if formatted {
+		money.SetFormatted(&response)
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(response); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	etag := receiptETag(snapshot.Version, body.Bytes())
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body.Bytes()); err != nil {
+		fmt.Printf("Failed to write response: %v\n", err)
+	}
+}
+
+// receiptSplitResponse computes the split for a receipt snapshot, as returned by GET /receipts/{receipt_id}
+// (without version, status, or other per-request fields)
+func (t *Transport) receiptSplitResponse(snapshot *persistence.ReceiptSnapshot) GetReceiptResponse {
+	split := ComputeBillSplit(snapshot.Users, snapshot.Items, splitAssignments(snapshot), snapshot.Rounding)
+	for _, a := range split.OrphanedAssignments {
+		t.log.Warn("Assignment references an item not on the receipt", "receipt_id", snapshot.ReceiptID, "assignment_id", a.ID, "item_id", a.ReceiptItemID)
+	}
+	currency := snapshot.Currency
+	if currency == nil {
+		currency = &defaultUSD
+	}
+	response := ToGetReceiptResponse(snapshot.ReceiptID, snapshot.Users, snapshot.Items, snapshot.Assignments, split, &snapshot.TaxTip, currency)
+	response.ExtractedTotal, response.Discrepancy = extractedTotalDiscrepancy(response.GrandTotal, snapshot.ExtractedTotal, currency)
+	return response
+}
+
+// GetReceiptOCRHandler handles fetching the OCR text saved at upload time (for debugging bad parses)
+// Expects GET /receipts/{receipt_id}/ocr
+// Returns 204 No Content if the receipt has no OCR text
+func (t *Transport) GetReceiptOCRHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	ocrText, err := t.persistenceClient.GetReceiptOCRText(ctx, receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get receipt OCR text: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if ocrText == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetReceiptOCRResponse{ReceiptID: receiptID, Text: ocrText.Text, Engine: ocrText.Engine, Parser: ocrText.Parser}); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// Values of on_existing for POST /receipts/{receipt_id}/users/{user_id}/items
+const (
+	onExistingReject = "reject" // Keep an existing custom amount and answer 409
+	onExistingUpdate = "update" // Return an existing custom amount to an equal split
+)
+
+// AssignItemsToUserHandler handles assigning items to a user
+// Expects POST /receipts/{receipt_id}/users/{user_id}/items
+// Request body: {"item_ids": ["..."], "on_existing": "reject"} - on_existing optional, reject or update
+// Re-assigning an item the user already has is a no-op unless it has a custom amount, which on_existing decides.
+// Items are assigned in one transaction, so a 404 or 409 for any of them leaves none assigned.
+// Responds 201 if any assignment was created, otherwise 200.
+func (t *Transport) AssignItemsToUserHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	userID, err := pathULID(r, "user_id")
+	errs.Collect(err)
+	if err := errs.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req AssignItemsToUserRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.ItemIDs) == 0 {
+		http.Error(w, NewValidationError("item_ids", "at least one item_id is required").Error(), http.StatusBadRequest)
+		return
+	}
+	switch req.OnExisting {
+	case "":
+		req.OnExisting = onExistingReject
+	case onExistingReject, onExistingUpdate:
+	default:
+		http.Error(w, NewValidationError("on_existing", "on_existing must be reject or update").Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+
+	assigned, err := t.persistenceClient.AssignItemsToUser(ctx, userID, req.ItemIDs, req.OnExisting == onExistingUpdate)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "already assigned") {
+			http.Error(w, err.Error()+`; send "on_existing": "update" to return it to an equal split`, http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to assign items to user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	assignedItems := make([]AssignItemsToUserItem, len(assigned))
+	createdCount := 0
+	for i, a := range assigned {
+		if a.Created {
+			createdCount++
+		}
+		assignedItems[i] = AssignItemsToUserItem{
+			ID:            a.ID,
+			ReceiptUserID: a.ReceiptUserID,
+			ReceiptItemID: a.ReceiptItemID,
+			CreatedAt:     a.CreatedAt.Format(time.RFC3339),
+			Created:       &a.Created,
+		}
+	}
+
+	response := AssignItemsToUserResponse{
+		Message: fmt.Sprintf("Successfully assigned %d item(s) to user; %d already assigned", createdCount, len(assignedItems)-createdCount),
+		Items:   assignedItems,
+	}
+
+	status := http.StatusOK
+	if createdCount > 0 {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// PatchAssignmentHandler handles setting the custom amount a user owes for one item
+// Expects PATCH /receipts/{receipt_id}/users/{user_id}/items/{item_id}
+// Request body: {"amount": 4.50} - or {"amount": null} to return to an equal split
+// With the item's other custom amounts, amount must not add up to more than the item's total
+func (t *Transport) PatchAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	userID, err := pathULID(r, "user_id")
+	errs.Collect(err)
+	itemID, err := pathULID(r, "item_id")
+	errs.Collect(err)
+	if err := errs.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req PatchAssignmentRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if !req.Amount.Set {
+		http.Error(w, NewValidationError("amount", "amount is required; send null to return to an equal split").Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Amount.Value != nil && *req.Amount.Value < 0 {
+		http.Error(w, NewValidationError("amount", "amount must not be negative").Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	if req.Amount.Value != nil {
+		portion := []BulkAssignRequestItem{{UserID: userID, ItemID: itemID, Amount: req.Amount.Value}}
+		if !t.requireReconciledPortions(ctx, w, receiptID, portion, true) {
+			return
+		}
+	}
+	assignment, err := t.persistenceClient.SetAssignmentAmount(ctx, receiptID, userID, itemID, req.Amount.Value)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to update assignment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+	if err != nil {
+		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+		currency = &defaultUSD
+	}
+
+	message := "Assignment amount updated"
+	if req.Amount.Value == nil {
+		message = "Assignment returned to equal split"
+	}
+	response := PatchAssignmentResponse{
+		Message:    message,
+		Assignment: toAssignItemsToUserItems([]persistence.ReceiptUserItem{*assignment}, currency)[0],
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// ReassignItemHandler handles moving an item's assignment from one user to another ("that was Bob's, not mine")
+// Expects POST /receipts/{receipt_id}/items/{item_id}/reassign
+// Request body: {"from_user_id": "...", "to_user_id": "..."}
+func (t *Transport) ReassignItemHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	itemID, err := pathULID(r, "item_id")
+	errs.Collect(err)
+
+	var req ReassignItemRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	errs.Collect(validateULID("from_user_id", req.FromUserID))
+	errs.Collect(validateULID("to_user_id", req.ToUserID))
+	if req.FromUserID != "" && req.FromUserID == req.ToUserID {
+		errs.Add("to_user_id", "to_user_id must differ from from_user_id")
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	assignment, err := t.persistenceClient.ReassignItem(ctx, receiptID, itemID, req.FromUserID, req.ToUserID)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "already assigned") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to reassign item: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+	if err != nil {
+		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+		currency = &defaultUSD
+	}
+
+	response := ReassignItemResponse{
+		Message:    "Item reassigned",
+		Assignment: toAssignItemsToUserItems([]persistence.ReceiptUserItem{*assignment}, currency)[0],
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// ClearUserAssignmentsHandler handles removing every item assignment for a user ("start over")
+// Expects DELETE /receipts/{receipt_id}/users/{user_id}/items
+func (t *Transport) ClearUserAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	userID, err := pathULID(r, "user_id")
+	errs.Collect(err)
+	if err := errs.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	deleted, err := t.persistenceClient.ClearUserAssignments(ctx, receiptID, userID)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to clear user assignments: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := ClearUserAssignmentsResponse{
+		Message: fmt.Sprintf("Removed %d assignment(s) from user", deleted),
+		Deleted: deleted,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// GetReceiptAssignmentsHandler handles listing a receipt's assignments one page at a time
+// Expects GET /receipts/{receipt_id}/assignments?limit=50&after={assignment_id}
+// Both query parameters are optional; next_cursor in the response is the "after" value for the next page
+func (t *Transport) GetReceiptAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, after, err := parsePageQuery(r.URL.Query(), defaultAssignmentsPageSize, maxAssignmentsPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	exists, err := t.persistenceClient.ReceiptExists(ctx, receiptID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to check receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "receipt not found", http.StatusNotFound)
+		return
+	}
+
+	assignments, nextCursor, err := t.persistenceClient.GetReceiptAssignmentsPage(ctx, receiptID, limit, after)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get receipt assignments: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	responseAssignments := make([]AssignItemsToUserItem, len(assignments))
+	for i, a := range assignments {
+		responseAssignments[i] = AssignItemsToUserItem{
+			ID:            a.ID,
+			ReceiptUserID: a.ReceiptUserID,
+			ReceiptItemID: a.ReceiptItemID,
+			CreatedAt:     a.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetReceiptAssignmentsResponse{Assignments: responseAssignments, NextCursor: nextCursor}); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// BulkAssignHandler handles assigning many items to many users in one transaction
+// Expects POST /receipts/{receipt_id}/assignments
+// Request body: {"assignments": [{"user_id": "...", "item_id": "...", "amount": 4.50}]} - amount optional;
+// user_name may be given instead of user_id to assign to (or create) the receipt user with that name.
+// share (e.g. 0.5) may be given instead of amount; it's stored as that fraction of the item's total.
+// Amounts, with those already stored for the same items, must not add up to more than an item's total.
+func (t *Transport) BulkAssignHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+
+	var req BulkAssignRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.Assignments) == 0 {
+		errs.Add("assignments", "at least one assignment is required")
+	}
+	toAssign, err := bulkAssignRequestToDB(req.Assignments)
+	errs.Collect(err)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	if !t.resolveShares(ctx, w, receiptID, req.Assignments, toAssign) {
+		return
+	}
+	if !t.requireReconciledPortions(ctx, w, receiptID, req.Assignments, true) {
+		return
+	}
+	created, err := t.persistenceClient.BulkAssign(ctx, receiptID, toAssign)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "ambiguous user name") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to assign items: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+	if err != nil {
+		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+		currency = &defaultUSD
+	}
+
+	responseAssignments := toAssignItemsToUserItems(created, currency)
+
+	response := BulkAssignResponse{
+		Message:     fmt.Sprintf("Successfully created %d assignment(s)", len(responseAssignments)),
+		Assignments: responseAssignments,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+func itemsToReceiptItems(items []persistence.ReceiptItem, currency *string) []ReceiptItem {
 	result := make([]ReceiptItem, len(items))
 	for i, item := range items {
 		result[i] = ReceiptItem{
@@ -310,7 +1243,427 @@ func itemsToReceiptItems(items []persistence.ReceiptItem, currency *string) []Re
 			Quantity:     item.Quantity,
 			TotalPrice:   money.Ptr(&item.TotalPrice, currency),
 			PricePerItem: money.Ptr(&item.PricePerItem, currency),
+			Version:      item.Version,
+			Taxable:      item.Taxable,
+			IsDiscount:   item.IsDiscount,
+			Category:     item.Category,
+			NeedsReview:  item.NeedsReview,
+			Shared:       item.Shared,
 		}
 	}
 	return result
 }
+
+// ReplaceAssignmentsHandler handles replacing every assignment on a receipt with the given set
+// Expects PUT /receipts/{receipt_id}/assignments
+// Request body: {"assignments": [{"user_id": "...", "item_id": "...", "amount": 4.50}]} - an empty list clears all assignments
+// When every assignment of an item has an amount or share, they are dollar portions and must sum to the item's total
+func (t *Transport) ReplaceAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+
+	var req BulkAssignRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Assignments == nil {
+		errs.Add("assignments", "assignments is required; send an empty list to clear")
+	}
+	desired, err := bulkAssignRequestToDB(req.Assignments)
+	errs.Collect(err)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	if !t.resolveShares(ctx, w, receiptID, req.Assignments, desired) {
+		return
+	}
+	if !t.requireReconciledPortions(ctx, w, receiptID, req.Assignments, false) {
+		return
+	}
+	assigned, removed, err := t.persistenceClient.ReplaceAssignments(ctx, receiptID, desired)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "ambiguous user name") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to replace assignments: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+	if err != nil {
+		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+		currency = &defaultUSD
+	}
+
+	response := ReplaceAssignmentsResponse{
+		Message:     fmt.Sprintf("Receipt now has %d assignment(s); removed %d", len(assigned), removed),
+		Assignments: toAssignItemsToUserItems(assigned, currency),
+		Removed:     removed,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// SplitEvenlyHandler handles assigning every item on a receipt to every user on it, so each user's
+// total (including tax and tip) is an equal share. Existing assignments and custom amounts are replaced.
+// Expects POST /receipts/{receipt_id}/split-evenly
+func (t *Transport) SplitEvenlyHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	assigned, removed, err := t.persistenceClient.SplitEvenly(ctx, receiptID)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "no users") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to split receipt evenly: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+	if err != nil {
+		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+		currency = &defaultUSD
+	}
+
+	response := ReplaceAssignmentsResponse{
+		Message:     fmt.Sprintf("Receipt split evenly with %d assignment(s); removed %d", len(assigned), removed),
+		Assignments: toAssignItemsToUserItems(assigned, currency),
+		Removed:     removed,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// AssignItemToAllHandler handles splitting one item evenly among every user on a receipt except some, replacing
+// the item's existing assignments and custom amounts. A convenience over bulk assign for "everyone but Carol".
+// Expects POST /receipts/{receipt_id}/items/{item_id}/assign-all
+// Request body: {"exclude_user_ids": ["..."]} - exclude_user_ids optional; {} assigns the item to everyone
+func (t *Transport) AssignItemToAllHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	itemID, err := pathULID(r, "item_id")
+	errs.Collect(err)
+
+	var req AssignItemToAllRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	excluded := make([]string, 0, len(req.ExcludeUserIDs))
+	seen := make(map[string]bool, len(req.ExcludeUserIDs))
+	for i, userID := range req.ExcludeUserIDs {
+		if err := validateULID(fmt.Sprintf("exclude_user_ids[%d]", i), userID); err != nil {
+			errs.Collect(err)
+			continue
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			excluded = append(excluded, userID)
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	assigned, removed, err := t.persistenceClient.AssignItemToAllExcept(ctx, receiptID, itemID, excluded)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "no users") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to assign item: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	currency, err := t.persistenceClient.GetReceiptCurrency(ctx, receiptID)
+	if err != nil {
+		t.log.Error("Failed to get receipt currency, using USD", "receipt_id", receiptID, "error", err)
+		currency = &defaultUSD
+	}
+
+	response := ReplaceAssignmentsResponse{
+		Message:     fmt.Sprintf("Item split among %d user(s); removed %d assignment(s)", len(assigned), removed),
+		Assignments: toAssignItemsToUserItems(assigned, currency),
+		Removed:     removed,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// bulkAssignRequestToDB validates request assignments and converts them for persistence
+func bulkAssignRequestToDB(assignments []BulkAssignRequestItem) ([]persistence.ReceiptUserItemDB, error) {
+	var errs ValidationErrors
+	result := make([]persistence.ReceiptUserItemDB, len(assignments))
+	for i, a := range assignments {
+		field := fmt.Sprintf("assignments[%d]", i)
+		if a.ItemID == "" {
+			errs.Add(field+".item_id", "item_id is required")
+		} else {
+			errs.Collect(validateULID(field+".item_id", a.ItemID))
+		}
+		switch {
+		case a.UserID == "" && strings.TrimSpace(a.UserName) == "":
+			errs.Add(field, "one of user_id or user_name is required")
+		case a.UserID != "" && a.UserName != "":
+			errs.Add(field, "user_id and user_name are mutually exclusive")
+		case a.UserID != "":
+			errs.Collect(validateULID(field+".user_id", a.UserID))
+		}
+		if a.Amount != nil && *a.Amount < 0 {
+			errs.Add(field+".amount", "amount must not be negative")
+		}
+		switch {
+		case a.Share == nil:
+		case a.Amount != nil:
+			errs.Add(field, "amount and share are mutually exclusive")
+		case *a.Share <= 0 || *a.Share > 1:
+			errs.Add(field+".share", "share must be greater than 0 and at most 1")
+		}
+		result[i] = persistence.ReceiptUserItemDB{
+			ReceiptUserID: a.UserID,
+			UserName:      a.UserName,
+			ReceiptItemID: a.ItemID,
+			AmountOwed:    a.Amount,
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// resolveShares converts each share in requested, a fraction of its item's total, into the amount it stands
+// for, setting it on both the request and the matching assignment in toSave so the portions are checked and
+// stored as amounts. Writes a 400 for a share of a discount item, or a 500 if the items can't be loaded.
+// Reports whether the assignments may be saved. Items not on the receipt are left for the save to report.
+func (t *Transport) resolveShares(ctx context.Context, w http.ResponseWriter, receiptID string, requested []BulkAssignRequestItem, toSave []persistence.ReceiptUserItemDB) bool {
+	if !slices.ContainsFunc(requested, func(a BulkAssignRequestItem) bool { return a.Share != nil }) {
+		return true
+	}
+	items, err := t.persistenceClient.GetReceiptItems(ctx, receiptID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get receipt items: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	byID := make(map[string]persistence.ReceiptItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	var errs ValidationErrors
+	for i, a := range requested {
+		item, ok := byID[a.ItemID]
+		if a.Share == nil || !ok {
+			continue
+		}
+		if item.IsDiscount {
+			errs.Add(fmt.Sprintf("assignments[%d].share", i), "share can't be set on a discount item")
+			continue
+		}
+		amount := float64(toCents(*a.Share*item.TotalPrice)) / 100
+		requested[i].Amount = &amount
+		toSave[i].AmountOwed = &amount
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return false
+	}
+	return true
+}
+
+// requireReconciledPortions checks the amounts in assignments against the receipt's item totals (see
+// checkItemPortions), writing a 400 listing the items that don't reconcile, or a 500 if the receipt can't be
+// loaded. Reports whether the assignments may be saved.
+// When additive is set the assignments are added to the receipt's stored ones (POST /assignments, PATCH of one
+// amount), so they are checked merged with them, and only against going over an item's total: more users may
+// still be assigned the rest. Otherwise they are the complete set (PUT /assignments).
+func (t *Transport) requireReconciledPortions(ctx context.Context, w http.ResponseWriter, receiptID string, assignments []BulkAssignRequestItem, additive bool) bool {
+	var items []persistence.ReceiptItem
+	if additive {
+		snapshot, err := t.persistenceClient.GetReceiptSnapshot(ctx, receiptID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+			return false
+		}
+		items = snapshot.Items
+		assignments = mergeStoredAssignments(snapshot, assignments)
+	} else {
+		var err error
+		items, err = t.persistenceClient.GetReceiptItems(ctx, receiptID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get receipt items: %v", err), http.StatusInternalServerError)
+			return false
+		}
+	}
+	if errs := checkItemPortions(assignments, items, !additive); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return false
+	}
+	return true
+}
+
+// mergeStoredAssignments returns the receipt's stored assignments with requested added, the way saving them
+// would leave it: a request for a user and item already assigned replaces that assignment's amount. A
+// user_name is matched to the receipt's users case-insensitively; one matching none is a user yet to be made.
+func mergeStoredAssignments(snapshot *persistence.ReceiptSnapshot, requested []BulkAssignRequestItem) []BulkAssignRequestItem {
+	userIDByName := make(map[string]string, len(snapshot.Users))
+	for _, u := range snapshot.Users {
+		userIDByName[strings.ToLower(u.Name)] = u.ID
+	}
+	merged := make([]BulkAssignRequestItem, 0, len(snapshot.Assignments)+len(requested))
+	index := make(map[string]int, len(snapshot.Assignments))
+	for _, a := range snapshot.Assignments {
+		index[a.ReceiptUserID+":"+a.ReceiptItemID] = len(merged)
+		merged = append(merged, BulkAssignRequestItem{UserID: a.ReceiptUserID, ItemID: a.ReceiptItemID, Amount: a.AmountOwed})
+	}
+	for _, a := range requested {
+		userID := a.UserID
+		if userID == "" {
+			userID = userIDByName[strings.ToLower(strings.TrimSpace(a.UserName))]
+		}
+		if i, ok := index[userID+":"+a.ItemID]; ok && userID != "" {
+			merged[i].Amount = a.Amount
+			continue
+		}
+		merged = append(merged, a)
+	}
+	return merged
+}
+
+// checkItemPortions validates the dollar amounts given for each item in assignments. When only some assignments
+// of an item have an amount, the rest of the total is split equally among the others, so the amounts must not
+// add up to more than the total. When complete is set and every assignment of an item has an amount, they are
+// its portions (e.g. $6 and $4 of a $10 plate) and must sum to the item's total within portionToleranceCents.
+// Items not on the receipt are left for persistence to reject.
+func checkItemPortions(assignments []BulkAssignRequestItem, items []persistence.ReceiptItem, complete bool) ValidationErrors {
+	totals := make(map[string]float64, len(items))
+	for _, item := range items {
+		totals[item.ID] = item.TotalPrice
+	}
+	type portions struct {
+		cents     int
+		amounts   int
+		assignees int
+	}
+	byItem := make(map[string]*portions)
+	var order []string
+	for _, a := range assignments {
+		p, ok := byItem[a.ItemID]
+		if !ok {
+			p = &portions{}
+			byItem[a.ItemID] = p
+			order = append(order, a.ItemID)
+		}
+		p.assignees++
+		if a.Amount != nil {
+			p.amounts++
+			p.cents += toCents(*a.Amount)
+		}
+	}
+
+	var errs ValidationErrors
+	for _, itemID := range order {
+		p := byItem[itemID]
+		total, onReceipt := totals[itemID]
+		if !onReceipt || p.amounts == 0 {
+			continue
+		}
+		totalCents := toCents(total)
+		switch {
+		case complete && p.amounts == p.assignees && (p.cents < totalCents-portionToleranceCents || p.cents > totalCents+portionToleranceCents):
+			errs.Add("assignments", fmt.Sprintf("amounts for item %s sum to %.2f, but the item totals %.2f", itemID, float64(p.cents)/100, total))
+		case p.cents > totalCents+portionToleranceCents:
+			errs.Add("assignments", fmt.Sprintf("amounts for item %s sum to %.2f, more than the item's total of %.2f", itemID, float64(p.cents)/100, total))
+		}
+	}
+	return errs
+}
+
+// toAssignItemsToUserItems converts persisted assignments to response items in the receipt currency
+func toAssignItemsToUserItems(assignments []persistence.ReceiptUserItem, currency *string) []AssignItemsToUserItem {
+	result := make([]AssignItemsToUserItem, len(assignments))
+	for i, a := range assignments {
+		result[i] = AssignItemsToUserItem{
+			ID:            a.ID,
+			ReceiptUserID: a.ReceiptUserID,
+			ReceiptItemID: a.ReceiptItemID,
+			AmountOwed:    money.Ptr(a.AmountOwed, currency),
+			CreatedAt:     a.CreatedAt.Format(time.RFC3339),
+		}
+	}
+	return result
+}
+
+// parsePageQuery reads the optional limit and after (cursor) query parameters shared by paginated endpoints.
+// limit defaults to defaultSize and must be between 1 and maxSize; after must be a ULID when set.
+func parsePageQuery(query url.Values, defaultSize, maxSize int) (limit int, after string, err error) {
+	limit = defaultSize
+	if limitParam := query.Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxSize {
+			return 0, "", NewValidationError("limit", fmt.Sprintf("limit must be an integer between 1 and %d", maxSize))
+		}
+		limit = parsed
+	}
+	after = query.Get("after")
+	if after != "" {
+		if err := validateULID("after", after); err != nil {
+			return 0, "", err
+		}
+	}
+	return limit, after, nil
+}
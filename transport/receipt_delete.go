@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DeleteReceiptHandler handles soft-deleting a receipt. Its data is kept for audit and can be restored
+// by an admin, but it no longer appears in reads.
+// Expects DELETE /receipts/{receipt_id}
+func (t *Transport) DeleteReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := t.persistenceClient.DeleteReceipt(context.Background(), receiptID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to delete receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	t.log.Info("Receipt deleted", "receipt_id", receiptID, "client_ip", t.clientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreReceiptHandler handles undoing a soft delete. Requires the admin API key.
+// Expects POST /receipts/{receipt_id}/restore
+func (t *Transport) RestoreReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !t.requireAdmin(w, r) {
+		return
+	}
+
+	if err := t.persistenceClient.UndeleteReceipt(context.Background(), receiptID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to restore receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	t.log.Info("Receipt restored", "receipt_id", receiptID, "client_ip", t.clientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetReceiptHandler handles wiping a receipt's users and assignments to start its split over, keeping the
+// receipt and its image. With items=true the items are removed as well, e.g. before re-parsing.
+// Expects POST /receipts/{receipt_id}/reset?items=true - items optional, default false
+func (t *Transport) ResetReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	includeItems := false
+	if value := r.URL.Query().Get("items"); value != "" {
+		includeItems, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, NewValidationError("items", "items must be true or false").Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	reset, err := t.persistenceClient.ResetReceipt(ctx, receiptID, includeItems)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to reset receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	t.log.Info("Receipt reset", "receipt_id", receiptID, "client_ip", t.clientIP(r), "assignments", reset.Assignments, "users", reset.Users, "items", reset.Items)
+	response := ResetReceiptResponse{
+		Message:            fmt.Sprintf("Removed %d user(s), %d assignment(s), and %d item(s)", reset.Users, reset.Assignments, reset.Items),
+		AssignmentsRemoved: reset.Assignments,
+		UsersRemoved:       reset.Users,
+		ItemsRemoved:       reset.Items,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DuplicateReceiptHandler handles copying a receipt's title, currency, users, and items to a new receipt,
+// as a starting point for a recurring group meal. Assignments, the image, and tax/tip are not copied.
+// Finalized receipts can be duplicated; the copy is not finalized.
+// Expects POST /receipts/{receipt_id}/duplicate
+func (t *Transport) DuplicateReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	receipt, users, err := t.persistenceClient.DuplicateReceipt(context.Background(), receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to duplicate receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	t.log.Info("Receipt duplicated", "receipt_id", receipt.ID, "duplicated_from", receiptID, "users", len(users), "items", len(receipt.Items))
+	response := DuplicateReceiptResponse{
+		Message:        fmt.Sprintf("Copied %d user(s) and %d item(s) to a new receipt", len(users), len(receipt.Items)),
+		ReceiptID:      receipt.ID,
+		DuplicatedFrom: receiptID,
+		UsersCopied:    len(users),
+		ItemsCopied:    len(receipt.Items),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/receipts/"+receipt.ID)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"splitzies/persistence"
+)
+
+// requireUnfinalized writes 409 and returns false when the receipt's split is finalized, or 404/500 when its
+// state can't be read. Handlers that edit users, items, assignments, or tax/tip call it before validating
+// against stored state; the store checks again as it writes, which writeFinalizedConflict reports.
+func (t *Transport) requireUnfinalized(ctx context.Context, w http.ResponseWriter, receiptID string) bool {
+	finalizedAt, err := t.persistenceClient.GetReceiptFinalizedAt(ctx, receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return false
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	if finalizedAt != nil {
+		writeFinalized(w, receiptID, *finalizedAt)
+		return false
+	}
+	return true
+}
+
+// writeFinalizedConflict writes 409 and returns true when err is the store refusing an edit because the receipt
+// is finalized, which happens when it was finalized after requireUnfinalized checked
+func writeFinalizedConflict(w http.ResponseWriter, receiptID string, err error) bool {
+	var finalized *persistence.FinalizedError
+	if !errors.As(err, &finalized) {
+		return false
+	}
+	writeFinalized(w, receiptID, finalized.FinalizedAt)
+	return true
+}
+
+func writeFinalized(w http.ResponseWriter, receiptID string, finalizedAt time.Time) {
+	http.Error(w, fmt.Sprintf("receipt was finalized at %s; POST /receipts/%s/unfinalize to edit it", finalizedAt.Format(time.RFC3339), receiptID), http.StatusConflict)
+}
+
+// FinalizeReceiptHandler handles freezing a receipt's split once the group agrees on it
+// Expects POST /receipts/{receipt_id}/finalize, with no body
+// Idempotent. An If-Match header makes the change conditional on the version the client last read.
+func (t *Transport) FinalizeReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	t.setReceiptFinalized(w, r, true)
+}
+
+// UnfinalizeReceiptHandler handles unfreezing a finalized receipt so it can be edited again
+// Expects POST /receipts/{receipt_id}/unfinalize, with no body
+// Idempotent. An If-Match header makes the change conditional on the version the client last read.
+func (t *Transport) UnfinalizeReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	t.setReceiptFinalized(w, r, false)
+}
+
+// setReceiptFinalized finalizes or unfinalizes the receipt named in the path
+func (t *Transport) setReceiptFinalized(w http.ResponseWriter, r *http.Request, finalize bool) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, err := expectedVersion(r, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	finalizedAt, newVersion, err := t.persistenceClient.SetReceiptFinalized(context.Background(), receiptID, finalize, version)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "version conflict") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to update receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := FinalizeReceiptResponse{
+		Message:   "Receipt unfinalized; it can be edited again",
+		Finalized: finalizedAt != nil,
+		Version:   newVersion,
+	}
+	if finalizedAt != nil {
+		response.Message = "Receipt finalized; its split can no longer be edited"
+		at := finalizedAt.Format(time.RFC3339)
+		response.FinalizedAt = &at
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
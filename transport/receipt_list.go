@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitzies/persistence"
+)
+
+// Page size bounds for GET /receipts
+const (
+	defaultReceiptListPageSize = 20
+	maxReceiptListPageSize     = 100
+)
+
+// receiptDateLayout is the format of the from/to query parameters and receipt_date in summaries
+const receiptDateLayout = "2006-01-02"
+
+// parseReceiptFilter reads the title, from, and to query parameters. from and to are inclusive dates (YYYY-MM-DD).
+func parseReceiptFilter(query url.Values) (persistence.ReceiptFilter, error) {
+	filter := persistence.ReceiptFilter{Title: strings.TrimSpace(query.Get("title"))}
+	if from := query.Get("from"); from != "" {
+		date, err := time.Parse(receiptDateLayout, from)
+		if err != nil {
+			return filter, NewValidationError("from", "from must be a date like 2024-03-01")
+		}
+		filter.From = &date
+	}
+	if to := query.Get("to"); to != "" {
+		date, err := time.Parse(receiptDateLayout, to)
+		if err != nil {
+			return filter, NewValidationError("to", "to must be a date like 2024-03-31")
+		}
+		// Inclusive of the whole day
+		end := date.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, NewValidationError("from", "from must not be after to")
+	}
+	return filter, nil
+}
+
+// ListReceiptsHandler handles finding receipts by merchant title and receipt date
+// Expects GET /receipts?title=cafe&from=2024-03-01&to=2024-03-31&limit=20&after={receipt_id}
+// All parameters are optional. title is a case-insensitive substring match; from/to filter on the parsed receipt
+// date, so receipts without one are left out when either is set. next_cursor is the "after" value for the next page.
+// Deleted receipts are left out unless include_deleted=true is sent with the admin API key.
+func (t *Transport) ListReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseReceiptFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, after, err := parsePageQuery(r.URL.Query(), defaultReceiptListPageSize, maxReceiptListPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if value := r.URL.Query().Get("include_deleted"); value != "" {
+		includeDeleted, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, NewValidationError("include_deleted", "include_deleted must be true or false").Error(), http.StatusBadRequest)
+			return
+		}
+		if includeDeleted && !t.requireAdmin(w, r) {
+			return
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+
+	receipts, nextCursor, err := t.persistenceClient.ListReceipts(context.Background(), filter, limit, after)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list receipts: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]ReceiptSummary, len(receipts))
+	for i, receipt := range receipts {
+		summaries[i] = ReceiptSummary{
+			ID:        receipt.ID,
+			Title:     receipt.Title,
+			CreatedAt: receipt.CreatedAt.Format(time.RFC3339),
+			Currency:  defaultUSD,
+			Status:    receipt.Status,
+		}
+		if receipt.ReceiptDate != nil {
+			date := receipt.ReceiptDate.Format(receiptDateLayout)
+			summaries[i].ReceiptDate = &date
+		}
+		if receipt.Currency != nil && *receipt.Currency != "" {
+			summaries[i].Currency = *receipt.Currency
+		}
+		if receipt.DeletedAt != nil {
+			deletedAt := receipt.DeletedAt.Format(time.RFC3339)
+			summaries[i].DeletedAt = &deletedAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ListReceiptsResponse{Receipts: summaries, NextCursor: nextCursor}); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
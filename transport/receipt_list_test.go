@@ -0,0 +1,37 @@
+package transport
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseReceiptFilter(t *testing.T) {
+	query, _ := url.ParseQuery("title=+Joe's+&from=2024-03-01&to=2024-03-31")
+	filter, err := parseReceiptFilter(query)
+	if err != nil {
+		t.Fatalf("parseReceiptFilter: %v", err)
+	}
+	if filter.Title != "Joe's" {
+		t.Errorf("title = %q, want trimmed %q", filter.Title, "Joe's")
+	}
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); filter.From == nil || !filter.From.Equal(want) {
+		t.Errorf("from = %v, want %v", filter.From, want)
+	}
+	// to is inclusive, so the filter's exclusive bound is the next day
+	if want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); filter.To == nil || !filter.To.Equal(want) {
+		t.Errorf("to = %v, want %v", filter.To, want)
+	}
+
+	for _, bad := range []string{"from=03/01/2024", "to=yesterday", "from=2024-04-01&to=2024-03-01"} {
+		query, _ := url.ParseQuery(bad)
+		if _, err := parseReceiptFilter(query); err == nil {
+			t.Errorf("parseReceiptFilter(%q) error = nil, want a validation error", bad)
+		}
+	}
+
+	filter, err = parseReceiptFilter(url.Values{})
+	if err != nil || filter.Title != "" || filter.From != nil || filter.To != nil {
+		t.Errorf("parseReceiptFilter(empty) = %+v, %v; want no filter", filter, err)
+	}
+}
@@ -1,48 +1,23 @@
 package transport
 
-import "strings"
+import (
+	"fmt"
+	"net/http"
 
-// pathParts returns the URL path split by "/" with leading/trailing slashes trimmed
-func pathParts(path string) []string {
-	return strings.Split(strings.Trim(path, "/"), "/")
-}
-
-// parseReceiptIDPath expects path like /receipts/{receipt_id}
-// Returns receiptID and true if valid, empty string and false otherwise
-func parseReceiptIDPath(path string) (receiptID string, ok bool) {
-	parts := pathParts(path)
-	if len(parts) != 2 || parts[0] != "receipts" {
-		return "", false
-	}
-	return parts[1], true
-}
+	"github.com/oklog/ulid/v2"
+)
 
-// parseReceiptUsersPath expects path like /receipts/{receipt_id}/users
-// Returns receiptID and true if valid
-func parseReceiptUsersPath(path string) (receiptID string, ok bool) {
-	parts := pathParts(path)
-	if len(parts) != 3 || parts[0] != "receipts" || parts[2] != "users" {
-		return "", false
+// validateULID returns a ValidationError naming field if value is not a well-formed ULID
+func validateULID(field, value string) error {
+	if _, err := ulid.ParseStrict(value); err != nil {
+		return NewValidationError(field, fmt.Sprintf("%q is not a valid ID", value))
 	}
-	return parts[1], true
+	return nil
 }
 
-// parseReceiptItemsPath expects path like /receipts/{receipt_id}/items
-// Returns receiptID and true if valid
-func parseReceiptItemsPath(path string) (receiptID string, ok bool) {
-	parts := pathParts(path)
-	if len(parts) != 3 || parts[0] != "receipts" || parts[2] != "items" {
-		return "", false
-	}
-	return parts[1], true
-}
-
-// parseReceiptUserItemsPath expects path like /receipts/{receipt_id}/users/{user_id}/items
-// Returns userID and true if valid
-func parseReceiptUserItemsPath(path string) (userID string, ok bool) {
-	parts := pathParts(path)
-	if len(parts) != 5 || parts[0] != "receipts" || parts[2] != "users" || parts[4] != "items" {
-		return "", false
-	}
-	return parts[3], true
+// pathULID returns the path wildcard name (e.g. receipt_id) the router matched,
+// with a ValidationError naming it if it is not a well-formed ULID
+func pathULID(r *http.Request, name string) (string, error) {
+	value := r.PathValue(name)
+	return value, validateULID(name, value)
 }
@@ -0,0 +1,197 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+// printTemplate renders a receipt's split as a standalone page; html/template escapes every user-provided
+// string (titles, item names, user names)
+var printTemplate = template.Must(template.New("print").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40em; margin: 2em auto; color: #222; }
+table { width: 100%; border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.25em 0.5em; border-bottom: 1px solid #ddd; }
+td.amount, th.amount { text-align: right; font-variant-numeric: tabular-nums; }
+tr.total td { font-weight: bold; border-top: 2px solid #222; }
+.muted { color: #777; }
+@media print { body { margin: 0; } section { break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="muted">{{if .Date}}{{.Date}} · {{end}}All amounts in {{.Currency}}</p>
+
+<h2>Items</h2>
+<table>
+<tr><th>Item</th><th class="amount">Qty</th><th class="amount">Price</th></tr>
+{{range .Items}}<tr><td>{{.Name}}{{if .Shared}} <span class="muted">(shared)</span>{{end}}</td><td class="amount">{{.Quantity}}</td><td class="amount">{{.Price}}</td></tr>
+{{end}}<tr><td colspan="2">Subtotal</td><td class="amount">{{.Subtotal}}</td></tr>
+{{if .Tax}}<tr><td colspan="2">Tax{{if .TaxIncluded}} <span class="muted">(included)</span>{{end}}</td><td class="amount">{{.Tax}}</td></tr>
+{{end}}{{if .Tip}}<tr><td colspan="2">Tip</td><td class="amount">{{.Tip}}</td></tr>
+{{end}}{{if .ServiceCharge}}<tr><td colspan="2">Service charge</td><td class="amount">{{.ServiceCharge}}</td></tr>
+{{end}}<tr class="total"><td colspan="2">Total</td><td class="amount">{{.GrandTotal}}</td></tr>
+</table>
+
+<h2>Who owes what</h2>
+{{range .Users}}<section>
+<h3>{{.Name}}</h3>
+<table>
+{{range .Items}}<tr><td>{{.Name}}</td><td class="amount">{{.Amount}}</td></tr>
+{{end}}<tr><td>Subtotal</td><td class="amount">{{.Subtotal}}</td></tr>
+<tr><td>Tax{{if $.TaxIncluded}} <span class="muted">(included)</span>{{end}}</td><td class="amount">{{.Tax}}</td></tr>
+<tr><td>Tip</td><td class="amount">{{.Tip}}</td></tr>
+{{if .ServiceCharge}}<tr><td>Service charge</td><td class="amount">{{.ServiceCharge}}</td></tr>
+{{end}}<tr class="total"><td>Total</td><td class="amount">{{.Total}}</td></tr>
+</table>
+</section>
+{{else}}<p class="muted">Nobody has been added to this receipt yet.</p>
+{{end}}{{if .Unassigned}}<p class="muted">Not yet assigned: {{.Unassigned}}</p>
+{{end}}</body>
+</html>
+`))
+
+// printPage is the data behind printTemplate; amounts are preformatted in the receipt's currency
+type printPage struct {
+	Title         string
+	Date          string
+	Currency      string
+	Items         []printItem
+	Subtotal      string
+	Tax           string // Empty when not set
+	TaxIncluded   bool   // Tax is part of the item prices, not added to the total
+	Tip           string
+	ServiceCharge string
+	GrandTotal    string
+	Unassigned    string // Empty when every item is assigned
+	Users         []printUser
+}
+
+type printItem struct {
+	Name     string
+	Quantity int
+	Price    string
+	Shared   bool
+}
+
+type printUser struct {
+	Name          string
+	Items         []printUserItem
+	Subtotal      string
+	Tax           string
+	Tip           string
+	ServiceCharge string // Empty when zero
+	Total         string
+}
+
+type printUserItem struct {
+	Name   string
+	Amount string
+}
+
+// writeReceiptPrint renders the split of a receipt as a printable HTML page
+func writeReceiptPrint(out io.Writer, snapshot *persistence.ReceiptSnapshot, split GetReceiptResponse) error {
+	currency := &split.Currency
+	format := func(value float64) string {
+		return strconv.FormatFloat(money.Round(value, currency), 'f', money.DecimalPlaces(currency), 64)
+	}
+	optional := func(amount *money.Amount) string {
+		if amount == nil {
+			return ""
+		}
+		return format(amount.Value)
+	}
+
+	page := printPage{
+		Title:         "Receipt " + split.ReceiptID,
+		Currency:      split.Currency,
+		Items:         make([]printItem, 0, len(split.Items)),
+		Subtotal:      format(split.Subtotal.Value),
+		Tax:           optional(split.Tax),
+		TaxIncluded:   split.TaxInclusive,
+		Tip:           optional(split.Tip),
+		ServiceCharge: optional(split.ServiceCharge),
+		GrandTotal:    format(split.GrandTotal.Value),
+		Users:         make([]printUser, 0, len(split.Users)),
+	}
+	if snapshot.Title != nil && strings.TrimSpace(*snapshot.Title) != "" {
+		page.Title = *snapshot.Title
+	}
+	if snapshot.ReceiptDate != nil {
+		page.Date = snapshot.ReceiptDate.Format("January 2, 2006")
+	}
+	if split.UnassignedTotal.Value != 0 {
+		page.Unassigned = format(split.UnassignedTotal.Value)
+	}
+	for _, item := range split.Items {
+		page.Items = append(page.Items, printItem{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+			Price:    optional(item.TotalPrice),
+			Shared:   item.Shared,
+		})
+	}
+	for _, user := range split.Users {
+		breakdown, ok := userBreakdown(snapshot, user.ID)
+		if !ok {
+			continue
+		}
+		printed := printUser{
+			Name:     user.Name,
+			Items:    make([]printUserItem, 0, len(breakdown.Items)),
+			Subtotal: format(breakdown.Subtotal.Value),
+			Tax:      format(breakdown.Tax.Value),
+			Tip:      format(breakdown.Tip.Value),
+			Total:    format(breakdown.Total.Value),
+		}
+		if breakdown.ServiceCharge.Value != 0 {
+			printed.ServiceCharge = format(breakdown.ServiceCharge.Value)
+		}
+		for _, line := range breakdown.Items {
+			printed.Items = append(printed.Items, printUserItem{Name: line.Name, Amount: format(line.Amount.Value)})
+		}
+		if breakdown.Discount != nil {
+			printed.Items = append(printed.Items, printUserItem{Name: "Discount", Amount: format(breakdown.Discount.Value)})
+		}
+		page.Users = append(page.Users, printed)
+	}
+	return printTemplate.Execute(out, page)
+}
+
+// GetReceiptPrintHandler handles rendering a receipt's split as a printable HTML page
+// Expects GET /receipts/{receipt_id}/print
+func (t *Transport) GetReceiptPrintHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(ctx, receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := writeReceiptPrint(w, snapshot, t.receiptSplitResponse(snapshot)); err != nil {
+		fmt.Printf("Failed to write HTML response: %v\n", err)
+	}
+}
@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"strings"
+	"testing"
+
+	"splitzies/persistence"
+)
+
+func TestWriteReceiptPrint(t *testing.T) {
+	usd := "USD"
+	title := `Joe's <script>alert("x")</script> Diner`
+	tax := 1.20
+	snapshot := &persistence.ReceiptSnapshot{
+		ReceiptID: "r1",
+		Title:     &title,
+		Currency:  &usd,
+		TaxTip:    persistence.ReceiptTaxTip{Tax: &tax},
+		Users: []persistence.ReceiptUser{
+			{ID: "alice", ReceiptID: "r1", Name: "<b>Alice</b>"},
+			{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		},
+		Items: []persistence.ReceiptItem{
+			{ID: "pizza", ReceiptID: "r1", Name: "Pizza & Wings", Quantity: 1, TotalPrice: 20.00, PricePerItem: 20.00, Taxable: true},
+			{ID: "soda", ReceiptID: "r1", Name: "Soda", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00, Taxable: true},
+		},
+		Assignments: []persistence.ReceiptUserItem{
+			{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+			{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+		},
+	}
+
+	var out strings.Builder
+	if err := writeReceiptPrint(&out, snapshot, (&Transport{}).receiptSplitResponse(snapshot)); err != nil {
+		t.Fatalf("writeReceiptPrint: %v", err)
+	}
+	page := out.String()
+
+	for _, unescaped := range []string{"<script>", "<b>Alice</b>", "Pizza & Wings"} {
+		if strings.Contains(page, unescaped) {
+			t.Errorf("page contains unescaped %q", unescaped)
+		}
+	}
+	for _, want := range []string{
+		"&lt;script&gt;",
+		"&lt;b&gt;Alice&lt;/b&gt;",
+		"Pizza &amp; Wings",
+		`<td class="amount">25.20</td>`, // grand total
+		`<td class="amount">10.60</td>`, // each user's total
+		"Not yet assigned: 4.00",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package transport
 
 import (
 	"math"
+	"slices"
+	"time"
 
 	"splitzies/money"
 	"splitzies/persistence"
@@ -9,103 +11,539 @@ import (
 
 // BillSplitResult holds the computed amounts for a bill split
 type BillSplitResult struct {
-	AmountByUserItem map[string]float64 // key: "userID:itemID"
-	UserTotal        map[string]float64 // key: userID
+	AmountByUserItem  map[string]float64 // key: "userID:itemID"
+	UserTotal         map[string]float64 // key: userID
+	UserTaxableTotal  map[string]float64 // key: userID; only taxable items, used to weight tax
+	UserDiscount      map[string]float64 // key: userID; share of unassigned discounts, already in UserTotal
+	UnassignedItemIDs []string           // items nobody is assigned to, in item order
+	// SharedAssignments are the implicit assignments that spread shared items over users not explicitly
+	// assigned to them. They have no ID and are already reflected in every amount.
+	SharedAssignments []persistence.ReceiptUserItem
+	// OrphanedAssignments reference items that are not on the receipt (e.g. deleted without cascading).
+	// They are left out of every amount rather than treated as zero-priced items.
+	OrphanedAssignments []persistence.ReceiptUserItem
 }
 
-// ComputeBillSplit calculates equal split amounts for each user-item assignment.
-// Each user assigned to an item gets 1/n of the total, rounded to cents.
-func ComputeBillSplit(items []persistence.ReceiptItem, assignments []persistence.ReceiptUserItem) BillSplitResult {
+// ComputeBillSplit calculates split amounts for each user-item assignment.
+// Assignments with a custom amount owe exactly that; the rest of the item's total is split equally
+// among its other users, rounded to cents. rounding decides who absorbs the leftover cents of an equal
+// split (see remainderOrder); an empty strategy means persistence.RoundingFirst.
+// A discount assigned to users is split among them like any other item; an unassigned discount
+// is treated as receipt-wide and spread across users in proportion to their item totals.
+// A shared item is split among every user on the receipt; users explicitly assigned to it are counted once,
+// keeping any custom amount.
+func ComputeBillSplit(users []persistence.ReceiptUser, items []persistence.ReceiptItem, assignments []persistence.ReceiptUserItem, rounding persistence.Rounding) BillSplitResult {
+	shared := sharedItemAssignments(users, items, assignments)
+	if len(shared) > 0 {
+		assignments = append(append(make([]persistence.ReceiptUserItem, 0, len(assignments)+len(shared)), assignments...), shared...)
+	}
+
 	itemPrice := make(map[string]float64)
+	itemTaxable := make(map[string]bool)
 	for _, item := range items {
 		itemPrice[item.ID] = item.TotalPrice
+		itemTaxable[item.ID] = item.Taxable
 	}
 
 	itemUserOrder := make(map[string][]string)
+	customAmount := make(map[string]float64) // key: "userID:itemID"
+	var orphaned []persistence.ReceiptUserItem
 	for _, a := range assignments {
+		if _, ok := itemPrice[a.ReceiptItemID]; !ok {
+			orphaned = append(orphaned, a)
+			continue
+		}
 		itemUserOrder[a.ReceiptItemID] = append(itemUserOrder[a.ReceiptItemID], a.ReceiptUserID)
+		if a.AmountOwed != nil {
+			customAmount[a.ReceiptUserID+":"+a.ReceiptItemID] = *a.AmountOwed
+		}
 	}
 
 	amountByUserItem := make(map[string]float64)
 	for itemID, userIDs := range itemUserOrder {
-		totalPrice := itemPrice[itemID]
-		n := len(userIDs)
-		if n == 0 {
+		remainingCents := toCents(itemPrice[itemID])
+		var equalUserIDs []string
+		for _, userID := range userIDs {
+			key := userID + ":" + itemID
+			if amount, ok := customAmount[key]; ok {
+				amountByUserItem[key] = float64(toCents(amount)) / 100
+				remainingCents -= toCents(amount)
+				continue
+			}
+			equalUserIDs = append(equalUserIDs, userID)
+		}
+		if len(equalUserIDs) == 0 {
 			continue
 		}
-		totalCents := int(math.Round(totalPrice * 100))
-		baseCents := totalCents / n
-		remainder := totalCents - baseCents*n
-		for i, userID := range userIDs {
-			cents := baseCents
-			if i < remainder {
-				cents++
-			}
-			key := userID + ":" + itemID
-			amountByUserItem[key] = float64(cents) / 100
+		// Custom amounts that exceed the item leave nothing for everyone else, rather than a credit
+		if (remainingCents < 0) != (itemPrice[itemID] < 0) {
+			remainingCents = 0
+		}
+		equalUserIDs = remainderOrder(rounding, equalUserIDs, remainingCents < 0, itemPrice, itemUserOrder, customAmount)
+		for i, cents := range splitCentsEvenly(remainingCents, len(equalUserIDs)) {
+			amountByUserItem[equalUserIDs[i]+":"+itemID] = float64(cents) / 100
 		}
 	}
 
 	userTotal := make(map[string]float64)
+	userTaxableTotal := make(map[string]float64)
 	for _, a := range assignments {
 		key := a.ReceiptUserID + ":" + a.ReceiptItemID
+		if _, ok := amountByUserItem[key]; !ok {
+			continue
+		}
 		userTotal[a.ReceiptUserID] += amountByUserItem[key]
+		if itemTaxable[a.ReceiptItemID] {
+			userTaxableTotal[a.ReceiptUserID] += amountByUserItem[key]
+		}
+	}
+
+	// Users in order of their first assignment, so discount leftover cents land deterministically
+	var userOrder []string
+	seenUser := make(map[string]bool)
+	for _, a := range assignments {
+		if _, ok := itemPrice[a.ReceiptItemID]; ok && !seenUser[a.ReceiptUserID] {
+			seenUser[a.ReceiptUserID] = true
+			userOrder = append(userOrder, a.ReceiptUserID)
+		}
+	}
+	weights := make([]int, len(userOrder))
+	for i, userID := range userOrder {
+		weights[i] = max(toCents(userTotal[userID]), 0)
+	}
+
+	userDiscount := make(map[string]float64)
+	var unassigned []string
+	for _, item := range items {
+		if _, ok := itemUserOrder[item.ID]; ok {
+			continue
+		}
+		if item.IsDiscount && item.TotalPrice < 0 {
+			parts := allocateCents(-toCents(item.TotalPrice), weights)
+			applied := false
+			for i, cents := range parts {
+				if cents != 0 {
+					userDiscount[userOrder[i]] -= float64(cents) / 100
+					applied = true
+				}
+			}
+			if applied {
+				continue
+			}
+		}
+		unassigned = append(unassigned, item.ID)
+	}
+	for userID, discount := range userDiscount {
+		userTotal[userID] = float64(toCents(userTotal[userID])+toCents(discount)) / 100
 	}
 
 	return BillSplitResult{
-		AmountByUserItem: amountByUserItem,
-		UserTotal:        userTotal,
+		AmountByUserItem:    amountByUserItem,
+		UserTotal:           userTotal,
+		UserTaxableTotal:    userTaxableTotal,
+		UserDiscount:        userDiscount,
+		UnassignedItemIDs:   unassigned,
+		SharedAssignments:   shared,
+		OrphanedAssignments: orphaned,
 	}
 }
 
-// ToGetReceiptResponse builds GetReceiptResponse from receipt data and bill split result
+// remainderOrder orders the users splitting an item equally so that splitCentsEvenly, which gives leftover
+// cents to the earliest users, hands them to whoever absorbs the remainder under rounding:
+//   - first (the default): the users assigned to the item earliest
+//   - payer: the receipt's payer (payer_user_id), when splitting the item; the rest as with first. Others
+//     still pay a leftover cent of an item the payer isn't on, or of one that leaves more cents over than
+//     the payer's one. With no payer set it is the same as first.
+//   - largest-share: the users with the largest receipt subtotals (estimated before rounding), ties in
+//     assignment order, since a cent matters least to them
+//
+// Under payer and largest-share a leftover cent of a discount is a cent less off, so those users go last.
+func remainderOrder(rounding persistence.Rounding, userIDs []string, discount bool, itemPrice map[string]float64, itemUserOrder map[string][]string, customAmount map[string]float64) []string {
+	var absorbs func(userID string) float64 // higher absorbs first
+	switch rounding.Strategy {
+	case persistence.RoundingPayer:
+		if rounding.PayerUserID == nil {
+			return userIDs
+		}
+		payerID := *rounding.PayerUserID
+		absorbs = func(userID string) float64 {
+			if userID == payerID {
+				return 1
+			}
+			return 0
+		}
+	case persistence.RoundingLargestShare:
+		estimate := make(map[string]float64)
+		for itemID, itemUserIDs := range itemUserOrder {
+			for _, userID := range itemUserIDs {
+				if amount, ok := customAmount[userID+":"+itemID]; ok {
+					estimate[userID] += amount
+				} else {
+					estimate[userID] += itemPrice[itemID] / float64(len(itemUserIDs))
+				}
+			}
+		}
+		absorbs = func(userID string) float64 { return estimate[userID] }
+	default:
+		return userIDs
+	}
+
+	ordered := slices.Clone(userIDs)
+	slices.SortStableFunc(ordered, func(a, b string) int {
+		if discount {
+			a, b = b, a
+		}
+		switch {
+		case absorbs(a) > absorbs(b):
+			return -1
+		case absorbs(a) < absorbs(b):
+			return 1
+		}
+		return 0
+	})
+	return ordered
+}
+
+// sharedItemAssignments returns an equal-split assignment of each shared item to each user who isn't
+// already explicitly assigned to it, in item then user order
+func sharedItemAssignments(users []persistence.ReceiptUser, items []persistence.ReceiptItem, assignments []persistence.ReceiptUserItem) []persistence.ReceiptUserItem {
+	explicit := make(map[string]bool, len(assignments))
+	for _, a := range assignments {
+		explicit[a.ReceiptUserID+":"+a.ReceiptItemID] = true
+	}
+	var shared []persistence.ReceiptUserItem
+	for _, item := range items {
+		if !item.Shared {
+			continue
+		}
+		for _, u := range users {
+			if !explicit[u.ID+":"+item.ID] {
+				shared = append(shared, persistence.ReceiptUserItem{ReceiptUserID: u.ID, ReceiptItemID: item.ID})
+			}
+		}
+	}
+	return shared
+}
+
+// splitCentsEvenly splits totalCents into n parts differing by at most a cent.
+// The magnitude is split, so leftover cents of a negative total (a discount) also go to the earliest parts.
+func splitCentsEvenly(totalCents, n int) []int {
+	sign := 1
+	if totalCents < 0 {
+		sign, totalCents = -1, -totalCents
+	}
+	parts := make([]int, n)
+	baseCents := totalCents / n
+	remainder := totalCents - baseCents*n
+	for i := range parts {
+		parts[i] = baseCents
+		if i < remainder {
+			parts[i]++
+		}
+		parts[i] *= sign
+	}
+	return parts
+}
+
+// splitAssignments returns the assignments a receipt's split is computed from. In itemized split mode those are
+// its own. In equal split mode every user is assigned every item with no custom amount, so the grand total
+// (tax, tip, and discounts included) divides evenly whatever has been assigned; the stored assignments are
+// kept for switching back. Each item's users are rotated one place from the last item's, so leftover cents
+// of the equal splits land on a different user each time rather than always on the first.
+func splitAssignments(snapshot *persistence.ReceiptSnapshot) []persistence.ReceiptUserItem {
+	if snapshot.SplitMode != persistence.SplitModeEqual || len(snapshot.Users) == 0 {
+		return snapshot.Assignments
+	}
+	assignments := make([]persistence.ReceiptUserItem, 0, len(snapshot.Items)*len(snapshot.Users))
+	for i, item := range snapshot.Items {
+		for j := range snapshot.Users {
+			user := snapshot.Users[(i+j)%len(snapshot.Users)]
+			assignments = append(assignments, persistence.ReceiptUserItem{ReceiptUserID: user.ID, ReceiptItemID: item.ID})
+		}
+	}
+	return assignments
+}
+
+// Split statuses, saying how far a receipt's split has got so a partially built receipt's zero user totals
+// aren't mistaken for a finished split
+const (
+	SplitStatusNoItems    = "no_items"   // Nothing to split yet
+	SplitStatusNoUsers    = "no_users"   // Items but nobody to split them between
+	SplitStatusUnassigned = "unassigned" // Users and items, but no item assigned yet
+	SplitStatusPartial    = "partial"    // Some items are assigned
+	SplitStatusComplete   = "complete"   // Every item is assigned
+)
+
+// splitStatus returns the split status for a receipt with the given users and items, where unassigned
+// items are nobody's yet
+func splitStatus(users []persistence.ReceiptUser, items []persistence.ReceiptItem, unassigned int) string {
+	switch {
+	case len(items) == 0:
+		return SplitStatusNoItems
+	case len(users) == 0:
+		return SplitStatusNoUsers
+	case unassigned == len(items):
+		return SplitStatusUnassigned
+	case unassigned > 0:
+		return SplitStatusPartial
+	}
+	return SplitStatusComplete
+}
+
+// TaxTipAllocation holds each user's share of the receipt's tax, tip, and service charge
+type TaxTipAllocation struct {
+	UserTax           map[string]float64 // key: userID
+	UserTip           map[string]float64 // key: userID
+	UserServiceCharge map[string]float64 // key: userID
+	TaxIncluded       bool               // UserTax is already part of each user's item shares, not owed on top
+}
+
+// AllocateTaxTip distributes tax, tip, and service charge across users in proportion to their item totals from split.
+// Tax is weighted by taxable items only, so users who bought only untaxed items pay no tax;
+// tip and service charge are weighted by all items. When no assigned item is taxable, tax falls back to the
+// all-items weights rather than dropping out of the user totals. A user whose discounts exceed their items
+// weighs zero, not less. Amounts are whole cents; leftover cents from rounding go to the earliest users, so each
+// allocation sums exactly to the tax/tip whenever at least one user has assigned items.
+// On a tax-inclusive receipt tax is still allocated, to show each user the tax within their share.
+func AllocateTaxTip(users []persistence.ReceiptUser, split BillSplitResult, taxTip *persistence.ReceiptTaxTip) TaxTipAllocation {
+	weights := make([]int, len(users))
+	taxWeights := make([]int, len(users))
+	taxWeightSum := 0
+	for i, u := range users {
+		weights[i] = max(toCents(split.UserTotal[u.ID]), 0)
+		taxWeights[i] = max(toCents(split.UserTaxableTotal[u.ID]), 0)
+		taxWeightSum += taxWeights[i]
+	}
+	if taxWeightSum == 0 {
+		taxWeights = weights
+	}
+
+	allocation := TaxTipAllocation{
+		UserTax:           make(map[string]float64),
+		UserTip:           make(map[string]float64),
+		UserServiceCharge: make(map[string]float64),
+	}
+	if taxTip == nil {
+		return allocation
+	}
+	allocation.TaxIncluded = taxTip.TaxInclusive
+	if taxTip.Tax != nil {
+		for i, cents := range allocateCents(toCents(*taxTip.Tax), taxWeights) {
+			allocation.UserTax[users[i].ID] = float64(cents) / 100
+		}
+	}
+	if taxTip.Tip != nil {
+		for i, cents := range allocateCents(toCents(*taxTip.Tip), weights) {
+			allocation.UserTip[users[i].ID] = float64(cents) / 100
+		}
+	}
+	if taxTip.ServiceCharge != nil {
+		for i, cents := range allocateCents(toCents(*taxTip.ServiceCharge), weights) {
+			allocation.UserServiceCharge[users[i].ID] = float64(cents) / 100
+		}
+	}
+	return allocation
+}
+
+// tipFromPercent resolves a tip percentage (e.g. 18 for 18%) against the sum of item totals,
+// rounded to the nearest cent the same way the split rounds
+func tipFromPercent(items []persistence.ReceiptItem, percent float64) float64 {
+	subtotalCents := 0
+	for _, item := range items {
+		subtotalCents += toCents(item.TotalPrice)
+	}
+	return math.Round(float64(subtotalCents)*percent/100) / 100
+}
+
+// userGrandTotal is what a user owes in all: their item shares plus their allocated tax, tip, and service charge.
+// Tax is left out when item prices already include it.
+func userGrandTotal(userID string, split BillSplitResult, allocation TaxTipAllocation) float64 {
+	total := split.UserTotal[userID] + allocation.UserTip[userID] + allocation.UserServiceCharge[userID]
+	if !allocation.TaxIncluded {
+		total += allocation.UserTax[userID]
+	}
+	return total
+}
+
+// allocateCents splits totalCents across weights proportionally.
+// Leftover cents from rounding down go to the earliest entries with a non-zero weight. The magnitude is split,
+// so a negative total (e.g. a negative tip) also adds up exactly, with its leftover cents on the same entries.
+// Returns all zeros if every weight is zero.
+func allocateCents(totalCents int, weights []int) []int {
+	parts := make([]int, len(weights))
+	weightSum := 0
+	for _, w := range weights {
+		weightSum += w
+	}
+	if weightSum == 0 {
+		return parts
+	}
+
+	sign := 1
+	if totalCents < 0 {
+		sign, totalCents = -1, -totalCents
+	}
+	allocated := 0
+	for i, w := range weights {
+		parts[i] = totalCents * w / weightSum
+		allocated += parts[i]
+	}
+	for i := 0; allocated < totalCents; i = (i + 1) % len(weights) {
+		if weights[i] == 0 {
+			continue
+		}
+		parts[i]++
+		allocated++
+	}
+	for i := range parts {
+		parts[i] *= sign
+	}
+	return parts
+}
+
+// toCents converts a decimal amount to whole cents
+func toCents(amount float64) int {
+	return int(math.Round(amount * 100))
+}
+
+// ToGetReceiptResponse builds GetReceiptResponse from receipt data and bill split result.
+// Each user's total includes their share of tax, tip, and service charge, so user totals sum to the grand total
+// once every item is assigned. On a tax-inclusive receipt tax is already in the item totals and is not added again.
+// A partially built receipt (no users, no assignments, or nothing at all) still gets a full response: zero user
+// totals, empty lists rather than null, and the subtotal and unassigned items filled in; split_status says which.
 func ToGetReceiptResponse(
 	receiptID string,
 	users []persistence.ReceiptUser,
 	items []persistence.ReceiptItem,
 	assignments []persistence.ReceiptUserItem,
 	split BillSplitResult,
+	taxTip *persistence.ReceiptTaxTip,
 	currency *string,
 ) GetReceiptResponse {
+	allocation := AllocateTaxTip(users, split, taxTip)
+
 	responseUsers := make([]GetReceiptUserResponse, len(users))
 	for i, u := range users {
-		total := split.UserTotal[u.ID]
-		amt := money.NewAmount(total, currency)
+		amt := money.NewAmount(userGrandTotal(u.ID, split, allocation), currency)
+		var discount *money.Amount
+		if d, ok := split.UserDiscount[u.ID]; ok {
+			discount = money.Ptr(&d, currency)
+		}
 		responseUsers[i] = GetReceiptUserResponse{
 			ID:        u.ID,
 			ReceiptID: u.ReceiptID,
 			Name:      u.Name,
+			Color:     u.Color,
 			UserTotal: &amt,
+			Discount:  discount,
 		}
 	}
 
-	responseItems := make([]ReceiptItem, len(items))
-	for i, item := range items {
-		responseItems[i] = ReceiptItem{
-			ID:           item.ID,
-			Name:         item.Name,
-			Quantity:     item.Quantity,
-			TotalPrice:   money.Ptr(&item.TotalPrice, currency),
-			PricePerItem: money.Ptr(&item.PricePerItem, currency),
-		}
+	responseItems := itemsToReceiptItems(items, currency)
+
+	orphaned := make(map[string]bool, len(split.OrphanedAssignments))
+	orphanedIDs := make([]string, 0, len(split.OrphanedAssignments))
+	for _, a := range split.OrphanedAssignments {
+		orphaned[a.ID] = true
+		orphanedIDs = append(orphanedIDs, a.ID)
 	}
 
-	responseAssignments := make([]GetReceiptAssignmentResponse, len(assignments))
-	for i, a := range assignments {
+	itemIndex := make(map[string]int, len(items))
+	for i, item := range items {
+		itemIndex[item.ID] = i
+	}
+	responseAssignments := make([]GetReceiptAssignmentResponse, 0, len(assignments))
+	for _, a := range assignments {
+		if orphaned[a.ID] {
+			continue
+		}
+		if i, ok := itemIndex[a.ReceiptItemID]; ok {
+			responseItems[i].AssignedUserIDs = append(responseItems[i].AssignedUserIDs, a.ReceiptUserID)
+		}
 		key := a.ReceiptUserID + ":" + a.ReceiptItemID
 		amt := money.NewAmount(split.AmountByUserItem[key], currency)
-		responseAssignments[i] = GetReceiptAssignmentResponse{
+		responseAssignments = append(responseAssignments, GetReceiptAssignmentResponse{
 			ID:         a.ID,
 			UserID:     a.ReceiptUserID,
 			ItemID:     a.ReceiptItemID,
 			AmountOwed: amt,
+			CreatedAt:  a.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	// Users a shared item is spread over owe part of it as much as those assigned to it
+	for _, a := range split.SharedAssignments {
+		if i, ok := itemIndex[a.ReceiptItemID]; ok {
+			responseItems[i].AssignedUserIDs = append(responseItems[i].AssignedUserIDs, a.ReceiptUserID)
+		}
+	}
+
+	subtotalCents := 0
+	for _, item := range items {
+		subtotalCents += toCents(item.TotalPrice)
+	}
+
+	unassigned := make(map[string]bool, len(split.UnassignedItemIDs))
+	for _, itemID := range split.UnassignedItemIDs {
+		unassigned[itemID] = true
+	}
+	unassignedItems := make([]ReceiptItem, 0, len(split.UnassignedItemIDs))
+	unassignedCents := 0
+	for i, item := range items {
+		if unassigned[item.ID] {
+			unassignedItems = append(unassignedItems, responseItems[i])
+			unassignedCents += toCents(item.TotalPrice)
+		}
+	}
+	grandTotalCents := subtotalCents
+	var tax, tip, serviceCharge *float64
+	var taxSource, tipSource *string
+	taxInclusive := false
+	if taxTip != nil {
+		tax, tip, serviceCharge = taxTip.Tax, taxTip.Tip, taxTip.ServiceCharge
+		taxSource, tipSource = taxTip.TaxSource, taxTip.TipSource
+		taxInclusive = taxTip.TaxInclusive
+	}
+	if tax != nil && !taxInclusive {
+		grandTotalCents += toCents(*tax)
+	}
+	for _, amount := range []*float64{tip, serviceCharge} {
+		if amount != nil {
+			grandTotalCents += toCents(*amount)
 		}
 	}
 
+	currencyCode := defaultUSD
+	if currency != nil && *currency != "" {
+		currencyCode = *currency
+	}
+
 	return GetReceiptResponse{
-		ReceiptID:   receiptID,
-		Users:       responseUsers,
-		Items:       responseItems,
-		Assignments: responseAssignments,
+		ReceiptID:       receiptID,
+		Currency:        currencyCode,
+		Users:           responseUsers,
+		Items:           responseItems,
+		Assignments:     responseAssignments,
+		Subtotal:        money.NewAmount(float64(subtotalCents)/100, currency),
+		Tax:             money.Ptr(tax, currency),
+		Tip:             money.Ptr(tip, currency),
+		ServiceCharge:   money.Ptr(serviceCharge, currency),
+		TaxSource:       taxSource,
+		TipSource:       tipSource,
+		TaxInclusive:    taxInclusive,
+		GrandTotal:      money.NewAmount(float64(grandTotalCents)/100, currency),
+		Unassigned:      unassignedItems,
+		UnassignedTotal: money.NewAmount(float64(unassignedCents)/100, currency),
+		SplitStatus:     splitStatus(users, items, len(unassignedItems)),
+		Orphaned:        orphanedIDs,
+	}
+}
+
+// extractedTotalDiscrepancy returns the parser-read receipt total and how far the computed grand total
+// is from it (grand total - extracted total). Both are nil when no parser read a total.
+func extractedTotalDiscrepancy(grandTotal money.Amount, extractedTotal *float64, currency *string) (*money.Amount, *money.Amount) {
+	if extractedTotal == nil {
+		return nil, nil
 	}
+	discrepancy := money.NewAmount(float64(toCents(grandTotal.Value)-toCents(*extractedTotal))/100, currency)
+	return money.Ptr(extractedTotal, currency), &discrepancy
 }
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"splitzies/money"
+)
+
+// splitOverrides are the tax and tip a split preview uses in place of the stored ones; nil keeps the stored value
+type splitOverrides struct {
+	tax        *float64
+	tip        *float64
+	tipPercent *float64 // Resolved against the item subtotal, like tip_percent on PATCH
+}
+
+// parseSplitOverrides reads the optional tax, tip, and tip_percent query parameters of a split preview
+func parseSplitOverrides(query url.Values) (splitOverrides, error) {
+	var errs ValidationErrors
+	var overrides splitOverrides
+	parse := func(name string) *float64 {
+		param := query.Get(name)
+		if param == "" {
+			return nil
+		}
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil || value < 0 {
+			errs.Add(name, name+" must be a non-negative number")
+			return nil
+		}
+		return &value
+	}
+	overrides.tax = parse("tax")
+	overrides.tip = parse("tip")
+	overrides.tipPercent = parse("tip_percent")
+	if overrides.tipPercent != nil && *overrides.tipPercent > 100 {
+		errs.Add("tip_percent", "tip_percent must be between 0 and 100")
+	}
+	if query.Get("tip") != "" && query.Get("tip_percent") != "" {
+		errs.Add("tip_percent", "send either tip or tip_percent, not both")
+	}
+	return overrides, errs.Err()
+}
+
+// PreviewSplitHandler handles computing a receipt's split with hypothetical tax and tip, e.g. for a tip slider.
+// Nothing is written; omitted parameters use the stored values.
+// Expects GET /receipts/{receipt_id}/split?tax=2.50&tip=10 or ?tip_percent=20 - all optional
+func (t *Transport) PreviewSplitHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	overrides, err := parseSplitOverrides(r.URL.Query())
+	errs.Collect(err)
+	formatted, err := parseFormattedQuery(r.URL.Query())
+	errs.Collect(err)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(context.Background(), receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Overridden amounts weren't parsed or entered, so they have no source
+	if overrides.tax != nil {
+		snapshot.TaxTip.Tax, snapshot.TaxTip.TaxSource = overrides.tax, nil
+	}
+	if overrides.tipPercent != nil {
+		tip := tipFromPercent(snapshot.Items, *overrides.tipPercent)
+		overrides.tip = &tip
+	}
+	if overrides.tip != nil {
+		snapshot.TaxTip.Tip, snapshot.TaxTip.TipSource = overrides.tip, nil
+	}
+
+	response := t.receiptSplitResponse(snapshot)
+	response.Version = snapshot.Version
+	response.Status = snapshot.Status
+	response.Rounding = snapshot.Rounding.Strategy
+	response.PayerUserID = snapshot.Rounding.PayerUserID
+	response.SplitMode = snapshot.SplitMode
+	response.NeedsReview = snapshot.NeedsReview
+
+	if formatted {
+		money.SetFormatted(&response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
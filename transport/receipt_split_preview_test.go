@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseSplitOverrides(t *testing.T) {
+	overrides, err := parseSplitOverrides(url.Values{"tax": {"2.50"}, "tip_percent": {"20"}})
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if overrides.tax == nil || *overrides.tax != 2.50 || overrides.tip != nil || overrides.tipPercent == nil || *overrides.tipPercent != 20 {
+		t.Errorf("overrides = %+v, want tax 2.50 and tip_percent 20", overrides)
+	}
+
+	overrides, err = parseSplitOverrides(url.Values{})
+	if err != nil || overrides.tax != nil || overrides.tip != nil || overrides.tipPercent != nil {
+		t.Errorf("no parameters: got (%+v, %v), want no overrides", overrides, err)
+	}
+
+	_, err = parseSplitOverrides(url.Values{"tax": {"-1"}, "tip": {"5"}, "tip_percent": {"120"}})
+	var errs ValidationErrors
+	if !errs.Collect(err) || len(errs) != 3 {
+		t.Fatalf("err = %v, want 3 validation errors", err)
+	}
+	for i, field := range []string{"tax", "tip_percent", "tip_percent"} {
+		if errs[i].Field != field {
+			t.Errorf("error %d field = %q, want %q", i, errs[i].Field, field)
+		}
+	}
+	if !strings.Contains(errs[2].Error(), "not both") {
+		t.Errorf("error 2 = %v, want tip and tip_percent to be exclusive", errs[2])
+	}
+}
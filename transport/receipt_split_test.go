@@ -0,0 +1,849 @@
+package transport
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+func TestGetReceiptResponseUserTotalsSumToGrandTotal(t *testing.T) {
+	usd := "USD"
+	tax, tip := 1.37, 5.00
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		{ID: "carol", ReceiptID: "r1", Name: "Carol"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "burger", ReceiptID: "r1", Name: "Burger", Quantity: 1, TotalPrice: 12.99, PricePerItem: 12.99, Taxable: true},
+		{ID: "fries", ReceiptID: "r1", Name: "Fries", Quantity: 1, TotalPrice: 4.01, PricePerItem: 4.01, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "burger"},
+		{ID: "a2", ReceiptUserID: "alice", ReceiptItemID: "fries"},
+		{ID: "a3", ReceiptUserID: "bob", ReceiptItemID: "fries"},
+		{ID: "a4", ReceiptUserID: "carol", ReceiptItemID: "fries"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, &persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip}, &usd)
+
+	if got := response.Subtotal.Value; got != 17.00 {
+		t.Errorf("Subtotal = %v, want 17.00", got)
+	}
+	if got := response.GrandTotal.Value; got != 23.37 {
+		t.Errorf("GrandTotal = %v, want 23.37", got)
+	}
+	if got := response.Items[1].AssignedUserIDs; !reflect.DeepEqual(got, []string{"alice", "bob", "carol"}) {
+		t.Errorf("fries AssignedUserIDs = %v, want [alice bob carol]", got)
+	}
+
+	sumCents := 0
+	for _, u := range response.Users {
+		sumCents += int(math.Round(u.UserTotal.Value * 100))
+	}
+	if want := int(math.Round(response.GrandTotal.Value * 100)); sumCents != want {
+		t.Errorf("sum of user totals = %d cents, want grand total %d cents", sumCents, want)
+	}
+}
+
+func TestEqualSplitModeDividesGrandTotalEvenly(t *testing.T) {
+	usd := "USD"
+	tax, tip := 1.38, 5.00
+	snapshot := &persistence.ReceiptSnapshot{
+		ReceiptID: "r1",
+		Currency:  &usd,
+		TaxTip:    persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip},
+		Rounding:  persistence.Rounding{Strategy: persistence.RoundingFirst},
+		SplitMode: persistence.SplitModeEqual,
+		Users: []persistence.ReceiptUser{
+			{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+			{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+			{ID: "carol", ReceiptID: "r1", Name: "Carol"},
+		},
+		Items: []persistence.ReceiptItem{
+			{ID: "burger", ReceiptID: "r1", Name: "Burger", Quantity: 1, TotalPrice: 12.99, PricePerItem: 12.99, Taxable: true},
+			{ID: "fries", ReceiptID: "r1", Name: "Fries", Quantity: 1, TotalPrice: 4.01, PricePerItem: 4.01, Taxable: true},
+		},
+		// Ignored in equal mode
+		Assignments: []persistence.ReceiptUserItem{
+			{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "burger"},
+		},
+	}
+
+	assignments := splitAssignments(snapshot)
+	split := ComputeBillSplit(snapshot.Users, snapshot.Items, assignments, snapshot.Rounding)
+	response := ToGetReceiptResponse("r1", snapshot.Users, snapshot.Items, snapshot.Assignments, split, &snapshot.TaxTip, &usd)
+
+	if response.SplitStatus != "complete" {
+		t.Errorf("SplitStatus = %q, want complete", response.SplitStatus)
+	}
+	sumCents, minCents, maxCents := 0, math.MaxInt, 0
+	for _, u := range response.Users {
+		cents := toCents(u.UserTotal.Value)
+		sumCents += cents
+		minCents, maxCents = min(minCents, cents), max(maxCents, cents)
+	}
+	if want := toCents(response.GrandTotal.Value); sumCents != want {
+		t.Errorf("sum of user totals = %d cents, want grand total %d cents", sumCents, want)
+	}
+	if maxCents-minCents > 1 {
+		t.Errorf("user totals range from %d to %d cents, want within a cent of each other", minCents, maxCents)
+	}
+	if got := verifySplit(snapshot); !got.Reconciled {
+		t.Errorf("verifySplit discrepancies = %+v, want reconciled", got.Discrepancies)
+	}
+
+	snapshot.SplitMode = persistence.SplitModeItemized
+	if got := splitAssignments(snapshot); !reflect.DeepEqual(got, snapshot.Assignments) {
+		t.Errorf("itemized splitAssignments = %+v, want the stored assignments", got)
+	}
+}
+
+func TestGetReceiptResponseListsUnassignedItems(t *testing.T) {
+	usd := "USD"
+	users := []persistence.ReceiptUser{{ID: "alice", ReceiptID: "r1", Name: "Alice"}}
+	items := []persistence.ReceiptItem{
+		{ID: "burger", ReceiptID: "r1", Name: "Burger", Quantity: 1, TotalPrice: 12.99, PricePerItem: 12.99, Taxable: true},
+		{ID: "fries", ReceiptID: "r1", Name: "Fries", Quantity: 1, TotalPrice: 4.01, PricePerItem: 4.01, Taxable: true},
+		{ID: "soda", ReceiptID: "r1", Name: "Soda", Quantity: 2, TotalPrice: 5.00, PricePerItem: 2.50, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "burger"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, nil, &usd)
+
+	if len(response.Unassigned) != 2 || response.Unassigned[0].ID != "fries" || response.Unassigned[1].ID != "soda" {
+		t.Fatalf("Unassigned = %+v, want [fries soda]", response.Unassigned)
+	}
+	if got := response.UnassignedTotal.Value; got != 9.01 {
+		t.Errorf("UnassignedTotal = %v, want 9.01", got)
+	}
+	if response.SplitStatus != SplitStatusPartial {
+		t.Errorf("SplitStatus = %q, want %q", response.SplitStatus, SplitStatusPartial)
+	}
+}
+
+func TestGetReceiptResponseForPartiallyBuiltReceipts(t *testing.T) {
+	usd := "USD"
+	tax, tip := 1.50, 4.00
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "burger", ReceiptID: "r1", Name: "Burger", Quantity: 1, TotalPrice: 12.99, PricePerItem: 12.99, Taxable: true},
+		{ID: "coupon", ReceiptID: "r1", Name: "Coupon", Quantity: 1, TotalPrice: -2.00, PricePerItem: -2.00, IsDiscount: true},
+	}
+	tests := []struct {
+		name           string
+		users          []persistence.ReceiptUser
+		items          []persistence.ReceiptItem
+		wantSubtotal   float64
+		wantGrandTotal float64 // Tax and tip still count, though nobody owes them yet
+		wantUnassigned int
+		wantStatus     string
+	}{
+		{name: "no users, has items", items: items, wantSubtotal: 10.99, wantGrandTotal: 16.49, wantUnassigned: 2, wantStatus: SplitStatusNoUsers},
+		{name: "has users, no assignments", users: users, items: items, wantSubtotal: 10.99, wantGrandTotal: 16.49, wantUnassigned: 2, wantStatus: SplitStatusUnassigned},
+		{name: "empty everything", wantGrandTotal: 5.50, wantStatus: SplitStatusNoItems},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split := ComputeBillSplit(tt.users, tt.items, nil, persistence.Rounding{Strategy: persistence.RoundingPayer})
+			response := ToGetReceiptResponse("r1", tt.users, tt.items, nil, split, &persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip}, &usd)
+
+			if response.SplitStatus != tt.wantStatus {
+				t.Errorf("SplitStatus = %q, want %q", response.SplitStatus, tt.wantStatus)
+			}
+			if len(response.Users) != len(tt.users) {
+				t.Fatalf("Users = %+v, want %d users", response.Users, len(tt.users))
+			}
+			for _, u := range response.Users {
+				if u.UserTotal == nil || u.UserTotal.Value != 0 {
+					t.Errorf("user %s total = %v, want 0", u.ID, u.UserTotal)
+				}
+			}
+			if got := response.Subtotal.Value; got != tt.wantSubtotal {
+				t.Errorf("Subtotal = %v, want %v", got, tt.wantSubtotal)
+			}
+			if got := response.GrandTotal.Value; got != tt.wantGrandTotal {
+				t.Errorf("GrandTotal = %v, want %v", got, tt.wantGrandTotal)
+			}
+			if len(response.Unassigned) != tt.wantUnassigned || response.UnassignedTotal.Value != tt.wantSubtotal {
+				t.Errorf("Unassigned = %+v (total %v), want %d items totalling %v", response.Unassigned, response.UnassignedTotal.Value, tt.wantUnassigned, tt.wantSubtotal)
+			}
+
+			// Lists are empty, not null, so clients can iterate without checking
+			body, err := json.Marshal(response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range []string{"users", "items", "assignments", "unassigned"} {
+				if strings.Contains(string(body), `"`+field+`":null`) {
+					t.Errorf("%s is null in %s", field, body)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateTaxTipSkipsUntaxedItems(t *testing.T) {
+	tax, tip := 1.00, 3.00
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "wine", ReceiptID: "r1", Name: "Wine", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00, Taxable: true},
+		{ID: "bread", ReceiptID: "r1", Name: "Bread", Quantity: 1, TotalPrice: 5.00, PricePerItem: 5.00, Taxable: false},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "wine"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "bread"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	allocation := AllocateTaxTip(users, split, &persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip})
+
+	if got := allocation.UserTax["bob"]; got != 0 {
+		t.Errorf("bob tax = %v, want 0 (bought only untaxed items)", got)
+	}
+	if got := allocation.UserTax["alice"]; got != 1.00 {
+		t.Errorf("alice tax = %v, want 1.00", got)
+	}
+	// Tip is still shared across all items
+	if got := allocation.UserTip["bob"]; got != 1.00 {
+		t.Errorf("bob tip = %v, want 1.00", got)
+	}
+	if got := allocation.UserTip["alice"]; got != 2.00 {
+		t.Errorf("alice tip = %v, want 2.00", got)
+	}
+}
+
+func TestAllocateCentsNegativeTotal(t *testing.T) {
+	parts := allocateCents(-100, []int{1, 1, 1})
+	if want := []int{-34, -33, -33}; !slices.Equal(parts, want) {
+		t.Errorf("parts = %v, want %v", parts, want)
+	}
+}
+
+func TestNegativeTipSumsToGrandTotal(t *testing.T) {
+	usd := "USD"
+	tax, tip := -0.10, -1.00
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		{ID: "carol", ReceiptID: "r1", Name: "Carol"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 1, TotalPrice: 9.00, PricePerItem: 9.00, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+		{ID: "a3", ReceiptUserID: "carol", ReceiptItemID: "pizza"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, &persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip}, &usd)
+	sumCents := 0
+	for _, u := range response.Users {
+		sumCents += toCents(u.UserTotal.Value)
+	}
+	if want := toCents(response.GrandTotal.Value); sumCents != want {
+		t.Errorf("sum of user totals = %d cents, want grand total %d cents", sumCents, want)
+	}
+}
+
+func TestAllocateTaxTipFallsBackWithNoTaxableItems(t *testing.T) {
+	usd := "USD"
+	tax := 1.00
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "bread", ReceiptID: "r1", Name: "Bread", Quantity: 1, TotalPrice: 6.00, PricePerItem: 6.00, Taxable: false},
+		{ID: "milk", ReceiptID: "r1", Name: "Milk", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00, Taxable: false},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "bread"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "milk"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	taxTip := &persistence.ReceiptTaxTip{Tax: &tax}
+	allocation := AllocateTaxTip(users, split, taxTip)
+
+	// Tax is weighted by all items instead of vanishing
+	if allocation.UserTax["alice"] != 0.60 || allocation.UserTax["bob"] != 0.40 {
+		t.Errorf("tax = %v, want alice 0.60 and bob 0.40", allocation.UserTax)
+	}
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, taxTip, &usd)
+	sumCents := 0
+	for _, u := range response.Users {
+		sumCents += toCents(u.UserTotal.Value)
+	}
+	if want := toCents(response.GrandTotal.Value); sumCents != want {
+		t.Errorf("sum of user totals = %d cents, want grand total %d cents", sumCents, want)
+	}
+}
+
+func TestAllocateTaxTipIgnoresUserAssignedOnlyACoupon(t *testing.T) {
+	tax, tip, serviceCharge := 1.01, 3.03, 2.02
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		{ID: "carol", ReceiptID: "r1", Name: "Carol"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "burger", ReceiptID: "r1", Name: "Burger", Quantity: 1, TotalPrice: 12.00, PricePerItem: 12.00, Taxable: true},
+		{ID: "salad", ReceiptID: "r1", Name: "Salad", Quantity: 1, TotalPrice: 8.00, PricePerItem: 8.00, Taxable: true},
+		{ID: "coupon", ReceiptID: "r1", Name: "Coupon", Quantity: 1, TotalPrice: -5.00, PricePerItem: -5.00, IsDiscount: true, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "burger"},
+		{ID: "a2", ReceiptUserID: "carol", ReceiptItemID: "salad"},
+		{ID: "a3", ReceiptUserID: "bob", ReceiptItemID: "coupon"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	allocation := AllocateTaxTip(users, split, &persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip, ServiceCharge: &serviceCharge})
+
+	charges := []struct {
+		name      string
+		want      float64
+		allocated map[string]float64
+	}{
+		{"tax", tax, allocation.UserTax},
+		{"tip", tip, allocation.UserTip},
+		{"service charge", serviceCharge, allocation.UserServiceCharge},
+	}
+	for _, c := range charges {
+		sumCents := 0
+		for _, u := range users {
+			if c.allocated[u.ID] < 0 {
+				t.Errorf("%s %s = %v, want >= 0", u.ID, c.name, c.allocated[u.ID])
+			}
+			sumCents += toCents(c.allocated[u.ID])
+		}
+		if sumCents != toCents(c.want) {
+			t.Errorf("%s allocations sum to %d cents, want %d", c.name, sumCents, toCents(c.want))
+		}
+		if c.allocated["bob"] != 0 {
+			t.Errorf("bob %s = %v, want 0 (assigned only the coupon)", c.name, c.allocated["bob"])
+		}
+	}
+}
+
+func TestAllocateTaxTipWeightsSharedTaxableItems(t *testing.T) {
+	tax := 0.90
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 1, TotalPrice: 12.00, PricePerItem: 12.00, Taxable: true},
+		{ID: "milk", ReceiptID: "r1", Name: "Milk", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00, Taxable: false},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+		{ID: "a3", ReceiptUserID: "bob", ReceiptItemID: "milk"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	allocation := AllocateTaxTip(users, split, &persistence.ReceiptTaxTip{Tax: &tax})
+
+	// Both have $6 of taxable pizza; bob's milk must not increase his share
+	if allocation.UserTax["alice"] != 0.45 || allocation.UserTax["bob"] != 0.45 {
+		t.Errorf("tax = alice %v, bob %v; want 0.45 each", allocation.UserTax["alice"], allocation.UserTax["bob"])
+	}
+}
+
+func TestServiceChargeAllocatedSeparatelyFromTip(t *testing.T) {
+	usd := "USD"
+	tip, serviceCharge := 2.00, 3.00
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "steak", ReceiptID: "r1", Name: "Steak", Quantity: 1, TotalPrice: 20.00, PricePerItem: 20.00, Taxable: true},
+		{ID: "salad", ReceiptID: "r1", Name: "Salad", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00, Taxable: false},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "steak"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "salad"},
+	}
+	taxTip := &persistence.ReceiptTaxTip{Tip: &tip, ServiceCharge: &serviceCharge}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	allocation := AllocateTaxTip(users, split, taxTip)
+
+	// Weighted by all items, including bob's untaxed salad
+	if allocation.UserServiceCharge["alice"] != 2.00 || allocation.UserServiceCharge["bob"] != 1.00 {
+		t.Errorf("service charge = alice %v, bob %v; want 2.00 and 1.00", allocation.UserServiceCharge["alice"], allocation.UserServiceCharge["bob"])
+	}
+
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, taxTip, &usd)
+	if response.ServiceCharge == nil || response.ServiceCharge.Value != 3.00 {
+		t.Errorf("service_charge = %v, want 3.00", response.ServiceCharge)
+	}
+	if response.GrandTotal.Value != 35.00 {
+		t.Errorf("grand_total = %v, want 35.00", response.GrandTotal.Value)
+	}
+	sum := 0.0
+	for _, u := range response.Users {
+		sum += u.UserTotal.Value
+	}
+	if math.Abs(sum-response.GrandTotal.Value) > 0.001 {
+		t.Errorf("user totals sum to %v, want %v", sum, response.GrandTotal.Value)
+	}
+}
+
+func TestTaxInclusiveReceiptDoesNotAddTaxOnTop(t *testing.T) {
+	eur := "EUR"
+	tax, tip := 3.19, 2.00
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "schnitzel", ReceiptID: "r1", Name: "Schnitzel", Quantity: 1, TotalPrice: 14.00, PricePerItem: 14.00, Taxable: true},
+		{ID: "beer", ReceiptID: "r1", Name: "Beer", Quantity: 1, TotalPrice: 6.00, PricePerItem: 6.00, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "schnitzel"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "beer"},
+	}
+	taxTip := &persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip, TaxInclusive: true}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, taxTip, &eur)
+
+	if !response.TaxInclusive {
+		t.Error("tax_inclusive = false, want true")
+	}
+	if response.Tax == nil || response.Tax.Value != 3.19 {
+		t.Errorf("tax = %v, want 3.19", response.Tax)
+	}
+	// Subtotal 20.00 + tip 2.00; the tax is already in the item prices
+	if response.GrandTotal.Value != 22.00 {
+		t.Errorf("grand_total = %v, want 22.00", response.GrandTotal.Value)
+	}
+	if got := response.Users[0].UserTotal.Value; got != 15.40 {
+		t.Errorf("alice total = %v, want 15.40", got)
+	}
+	if got := response.Users[1].UserTotal.Value; got != 6.60 {
+		t.Errorf("bob total = %v, want 6.60", got)
+	}
+
+	// The tax within each share is still reported
+	allocation := AllocateTaxTip(users, split, taxTip)
+	if allocation.UserTax["alice"] != 2.24 || allocation.UserTax["bob"] != 0.95 {
+		t.Errorf("tax = alice %v, bob %v; want 2.24 and 0.95", allocation.UserTax["alice"], allocation.UserTax["bob"])
+	}
+}
+
+func TestGetReceiptResponseReportsTaxTipSource(t *testing.T) {
+	usd := "USD"
+	tax, tip := 1.50, 4.00
+	parsed, manual := persistence.ValueSourceParsed, persistence.ValueSourceManual
+	taxTip := &persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip, TaxSource: &parsed, TipSource: &manual}
+
+	response := ToGetReceiptResponse("r1", nil, nil, nil, ComputeBillSplit(nil, nil, nil, persistence.Rounding{Strategy: persistence.RoundingFirst}), taxTip, &usd)
+	if response.TaxSource == nil || *response.TaxSource != parsed {
+		t.Errorf("tax_source = %v, want %q", response.TaxSource, parsed)
+	}
+	if response.TipSource == nil || *response.TipSource != manual {
+		t.Errorf("tip_source = %v, want %q", response.TipSource, manual)
+	}
+}
+
+func TestExtractedTotalDiscrepancy(t *testing.T) {
+	usd := "USD"
+	grandTotal := money.NewAmount(23.37, &usd)
+
+	if total, discrepancy := extractedTotalDiscrepancy(grandTotal, nil, &usd); total != nil || discrepancy != nil {
+		t.Errorf("with no extracted total got (%v, %v), want (nil, nil)", total, discrepancy)
+	}
+
+	extracted := 25.00
+	total, discrepancy := extractedTotalDiscrepancy(grandTotal, &extracted, &usd)
+	if total == nil || total.Value != 25.00 {
+		t.Errorf("extracted total = %v, want 25.00", total)
+	}
+	if discrepancy == nil || discrepancy.Value != -1.63 {
+		t.Errorf("discrepancy = %v, want -1.63", discrepancy)
+	}
+
+	// Every view built on the split, not just GET, reports it
+	snapshot := &persistence.ReceiptSnapshot{
+		ReceiptID:      "r1",
+		Currency:       &usd,
+		ExtractedTotal: &extracted,
+		Items:          []persistence.ReceiptItem{{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 1, TotalPrice: 23.37, PricePerItem: 23.37}},
+	}
+	response := (&Transport{}).receiptSplitResponse(snapshot)
+	if response.Discrepancy == nil || response.Discrepancy.Value != -1.63 {
+		t.Errorf("split response discrepancy = %v, want -1.63", response.Discrepancy)
+	}
+}
+
+func TestComputeBillSplitReportsOrphanedAssignments(t *testing.T) {
+	usd := "USD"
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "burger", ReceiptID: "r1", Name: "Burger", Quantity: 1, TotalPrice: 12.00, PricePerItem: 12.00, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "burger"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "deleted-item"},
+		{ID: "a3", ReceiptUserID: "bob", ReceiptItemID: "burger"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+
+	if len(split.OrphanedAssignments) != 1 || split.OrphanedAssignments[0].ID != "a2" {
+		t.Fatalf("OrphanedAssignments = %+v, want [a2]", split.OrphanedAssignments)
+	}
+	// The orphan must not dilute the burger split or add a zero-priced entry
+	if split.UserTotal["alice"] != 6.00 || split.UserTotal["bob"] != 6.00 {
+		t.Errorf("UserTotal = %v, want alice 6.00 and bob 6.00", split.UserTotal)
+	}
+	if _, ok := split.AmountByUserItem["bob:deleted-item"]; ok {
+		t.Error("AmountByUserItem has an entry for the orphaned assignment")
+	}
+
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, nil, &usd)
+	if len(response.Assignments) != 2 {
+		t.Errorf("response has %d assignments, want 2 (orphan excluded)", len(response.Assignments))
+	}
+	if len(response.Orphaned) != 1 || response.Orphaned[0] != "a2" {
+		t.Errorf("response orphaned_assignments = %v, want [a2]", response.Orphaned)
+	}
+}
+
+func TestComputeBillSplitSpreadsUnassignedCouponProportionally(t *testing.T) {
+	usd := "USD"
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "steak", ReceiptID: "r1", Name: "Steak", Quantity: 1, TotalPrice: 30.00, PricePerItem: 30.00, Taxable: true},
+		{ID: "salad", ReceiptID: "r1", Name: "Salad", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00, Taxable: true},
+		{ID: "coupon", ReceiptID: "r1", Name: "COUPON", Quantity: 1, TotalPrice: -5.00, PricePerItem: -5.00, Taxable: true, IsDiscount: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "steak"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "salad"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+
+	if split.UserDiscount["alice"] != -3.75 || split.UserDiscount["bob"] != -1.25 {
+		t.Errorf("UserDiscount = %v, want alice -3.75 and bob -1.25", split.UserDiscount)
+	}
+	if split.UserTotal["alice"] != 26.25 || split.UserTotal["bob"] != 8.75 {
+		t.Errorf("UserTotal = %v, want alice 26.25 and bob 8.75", split.UserTotal)
+	}
+	if len(split.UnassignedItemIDs) != 0 {
+		t.Errorf("UnassignedItemIDs = %v, want none (coupon applies receipt-wide)", split.UnassignedItemIDs)
+	}
+
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, nil, &usd)
+	if got := response.Subtotal.Value; got != 35.00 {
+		t.Errorf("Subtotal = %v, want 35.00", got)
+	}
+	sumCents := 0
+	for _, u := range response.Users {
+		sumCents += toCents(u.UserTotal.Value)
+	}
+	if sumCents != 3500 {
+		t.Errorf("sum of user totals = %d cents, want 3500", sumCents)
+	}
+}
+
+func TestComputeBillSplitAppliesAssignedCouponToItsUsers(t *testing.T) {
+	items := []persistence.ReceiptItem{
+		{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 1, TotalPrice: 20.00, PricePerItem: 20.00, Taxable: true},
+		{ID: "coupon", ReceiptID: "r1", Name: "COUPON", Quantity: 1, TotalPrice: -5.00, PricePerItem: -5.00, Taxable: true, IsDiscount: true},
+		{ID: "soda", ReceiptID: "r1", Name: "Soda", Quantity: 1, TotalPrice: 3.00, PricePerItem: 3.00, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+		{ID: "a3", ReceiptUserID: "carol", ReceiptItemID: "pizza"},
+		{ID: "a4", ReceiptUserID: "alice", ReceiptItemID: "coupon"},
+		{ID: "a5", ReceiptUserID: "bob", ReceiptItemID: "coupon"},
+		{ID: "a6", ReceiptUserID: "carol", ReceiptItemID: "coupon"},
+		{ID: "a7", ReceiptUserID: "carol", ReceiptItemID: "soda"},
+	}
+
+	split := ComputeBillSplit(nil, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+
+	// -$5.00 three ways: the extra cent goes to the first user, like any other item
+	if split.AmountByUserItem["alice:coupon"] != -1.67 || split.AmountByUserItem["bob:coupon"] != -1.67 || split.AmountByUserItem["carol:coupon"] != -1.66 {
+		t.Errorf("coupon shares = alice %v, bob %v, carol %v; want -1.67, -1.67, -1.66",
+			split.AmountByUserItem["alice:coupon"], split.AmountByUserItem["bob:coupon"], split.AmountByUserItem["carol:coupon"])
+	}
+	if len(split.UserDiscount) != 0 {
+		t.Errorf("UserDiscount = %v, want empty (coupon was assigned)", split.UserDiscount)
+	}
+	if got := toCents(split.UserTotal["alice"]) + toCents(split.UserTotal["bob"]) + toCents(split.UserTotal["carol"]); got != 1800 {
+		t.Errorf("sum of user totals = %d cents, want 1800", got)
+	}
+}
+
+func TestComputeBillSplitEdgeCases(t *testing.T) {
+	tests := []struct {
+		name           string
+		items          []persistence.ReceiptItem
+		assignments    []persistence.ReceiptUserItem
+		wantItemShares map[string]float64 // key: "userID:itemID"
+		wantUnassigned []string
+	}{
+		{
+			name:  "one item split three ways",
+			items: []persistence.ReceiptItem{{ID: "pizza", TotalPrice: 10.00, Taxable: true}},
+			assignments: []persistence.ReceiptUserItem{
+				{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+				{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+				{ID: "a3", ReceiptUserID: "carol", ReceiptItemID: "pizza"},
+			},
+			wantItemShares: map[string]float64{"alice:pizza": 3.34, "bob:pizza": 3.33, "carol:pizza": 3.33},
+		},
+		{
+			name: "item assigned to nobody",
+			items: []persistence.ReceiptItem{
+				{ID: "burger", TotalPrice: 12.50, Taxable: true},
+				{ID: "fries", TotalPrice: 3.25, Taxable: true},
+			},
+			assignments:    []persistence.ReceiptUserItem{{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "burger"}},
+			wantItemShares: map[string]float64{"alice:burger": 12.50},
+			wantUnassigned: []string{"fries"},
+		},
+		{
+			name:  "odd cents",
+			items: []persistence.ReceiptItem{{ID: "wine", TotalPrice: 0.05, Taxable: true}},
+			assignments: []persistence.ReceiptUserItem{
+				{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "wine"},
+				{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "wine"},
+				{ID: "a3", ReceiptUserID: "carol", ReceiptItemID: "wine"},
+				{ID: "a4", ReceiptUserID: "dave", ReceiptItemID: "wine"},
+			},
+			wantItemShares: map[string]float64{"alice:wine": 0.02, "bob:wine": 0.01, "carol:wine": 0.01, "dave:wine": 0.01},
+		},
+		{
+			name:           "empty receipt",
+			wantItemShares: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split := ComputeBillSplit(nil, tt.items, tt.assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+
+			if len(split.AmountByUserItem) != len(tt.wantItemShares) {
+				t.Errorf("AmountByUserItem = %v, want %v", split.AmountByUserItem, tt.wantItemShares)
+			}
+			for key, want := range tt.wantItemShares {
+				if got := split.AmountByUserItem[key]; got != want {
+					t.Errorf("AmountByUserItem[%s] = %v, want %v", key, got, want)
+				}
+			}
+			if len(split.UnassignedItemIDs) != len(tt.wantUnassigned) {
+				t.Fatalf("UnassignedItemIDs = %v, want %v", split.UnassignedItemIDs, tt.wantUnassigned)
+			}
+			for i, id := range tt.wantUnassigned {
+				if split.UnassignedItemIDs[i] != id {
+					t.Errorf("UnassignedItemIDs = %v, want %v", split.UnassignedItemIDs, tt.wantUnassigned)
+				}
+			}
+
+			// Per-item shares sum to the item total, and user totals sum to the assigned subtotal
+			itemShareCents := make(map[string]int)
+			for _, a := range tt.assignments {
+				itemShareCents[a.ReceiptItemID] += toCents(split.AmountByUserItem[a.ReceiptUserID+":"+a.ReceiptItemID])
+			}
+			assignedCents := 0
+			for _, item := range tt.items {
+				if got, ok := itemShareCents[item.ID]; ok {
+					if got != toCents(item.TotalPrice) {
+						t.Errorf("shares of %s sum to %d cents, want %d", item.ID, got, toCents(item.TotalPrice))
+					}
+					assignedCents += toCents(item.TotalPrice)
+				}
+			}
+			userCents := 0
+			for _, total := range split.UserTotal {
+				userCents += toCents(total)
+			}
+			if userCents != assignedCents {
+				t.Errorf("UserTotal sums to %d cents, want assigned subtotal %d", userCents, assignedCents)
+			}
+		})
+	}
+}
+
+func TestTipFromPercent(t *testing.T) {
+	items := []persistence.ReceiptItem{
+		{ID: "burger", TotalPrice: 12.99},
+		{ID: "fries", TotalPrice: 4.01},
+		{ID: "soda", TotalPrice: 2.35},
+	}
+
+	// 18% of $19.35 is $3.483
+	if got := tipFromPercent(items, 18); got != 3.48 {
+		t.Errorf("18%% tip = %v, want 3.48", got)
+	}
+	// 20% of $19.35 is $3.87
+	if got := tipFromPercent(items, 20); got != 3.87 {
+		t.Errorf("20%% tip = %v, want 3.87", got)
+	}
+	if got := tipFromPercent(nil, 20); got != 0 {
+		t.Errorf("tip on empty receipt = %v, want 0", got)
+	}
+}
+
+func TestComputeBillSplitHonorsCustomAmounts(t *testing.T) {
+	custom := 7.00
+	items := []persistence.ReceiptItem{
+		{ID: "pizza", TotalPrice: 20.00, Taxable: true},
+	}
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza", AmountOwed: &custom},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+		{ID: "a3", ReceiptUserID: "carol", ReceiptItemID: "pizza"},
+	}
+
+	split := ComputeBillSplit(nil, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+
+	// Alice pays her custom $7.00; the remaining $13.00 is split equally
+	if split.AmountByUserItem["alice:pizza"] != 7.00 || split.AmountByUserItem["bob:pizza"] != 6.50 || split.AmountByUserItem["carol:pizza"] != 6.50 {
+		t.Errorf("AmountByUserItem = %v, want alice 7.00, bob 6.50, carol 6.50", split.AmountByUserItem)
+	}
+}
+
+func TestComputeBillSplitSharedItems(t *testing.T) {
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		{ID: "carol", ReceiptID: "r1", Name: "Carol"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "pasta", ReceiptID: "r1", Name: "Pasta", Quantity: 1, TotalPrice: 15.00, PricePerItem: 15.00, Taxable: true},
+		{ID: "delivery", ReceiptID: "r1", Name: "Delivery fee", Quantity: 1, TotalPrice: 6.01, PricePerItem: 6.01, Shared: true},
+	}
+	// bob is explicitly on the shared item too; he must not pay for it twice
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pasta"},
+		{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "delivery"},
+	}
+
+	split := ComputeBillSplit(users, items, assignments, persistence.Rounding{Strategy: persistence.RoundingFirst})
+
+	if len(split.UnassignedItemIDs) != 0 {
+		t.Errorf("unassigned = %v, want none", split.UnassignedItemIDs)
+	}
+	if len(split.SharedAssignments) != 2 {
+		t.Errorf("shared assignments = %+v, want alice and carol", split.SharedAssignments)
+	}
+	// The leftover cent goes to bob, whose explicit assignment comes first
+	want := map[string]float64{"alice": 17.00, "bob": 2.01, "carol": 2.00}
+	for userID, total := range want {
+		if got := split.UserTotal[userID]; got != total {
+			t.Errorf("%s total = %v, want %v", userID, got, total)
+		}
+	}
+	if got := split.UserTaxableTotal["carol"]; got != 0 {
+		t.Errorf("carol taxable total = %v, want 0 for an untaxed shared fee", got)
+	}
+	usd := "USD"
+	response := ToGetReceiptResponse("r1", users, items, assignments, split, nil, &usd)
+	if got := response.Items[1].AssignedUserIDs; !reflect.DeepEqual(got, []string{"bob", "alice", "carol"}) {
+		t.Errorf("delivery AssignedUserIDs = %v, want [bob alice carol]", got)
+	}
+
+	if split := ComputeBillSplit(nil, items, assignments[:1], persistence.Rounding{Strategy: persistence.RoundingFirst}); len(split.UnassignedItemIDs) != 1 || split.UnassignedItemIDs[0] != "delivery" {
+		t.Errorf("with no users, unassigned = %v, want the shared item", split.UnassignedItemIDs)
+	}
+}
+
+func TestComputeBillSplitRoundingStrategies(t *testing.T) {
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"}, // the payer
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		{ID: "carol", ReceiptID: "r1", Name: "Carol"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00},
+		{ID: "steak", ReceiptID: "r1", Name: "Steak", Quantity: 1, TotalPrice: 20.00, PricePerItem: 20.00},
+		{ID: "coupon", ReceiptID: "r1", Name: "Coupon", Quantity: 1, TotalPrice: -1.00, PricePerItem: -1.00, IsDiscount: true},
+	}
+	// Bob is assigned to the pizza and coupon first; Carol alone has the steak, so the largest share
+	assignments := []persistence.ReceiptUserItem{
+		{ID: "a1", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+		{ID: "a2", ReceiptUserID: "carol", ReceiptItemID: "pizza"},
+		{ID: "a3", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+		{ID: "a4", ReceiptUserID: "carol", ReceiptItemID: "steak"},
+		{ID: "a5", ReceiptUserID: "bob", ReceiptItemID: "coupon"},
+		{ID: "a6", ReceiptUserID: "carol", ReceiptItemID: "coupon"},
+		{ID: "a7", ReceiptUserID: "alice", ReceiptItemID: "coupon"},
+	}
+
+	payer := "alice"
+	tests := []struct {
+		name     string
+		rounding persistence.Rounding
+		pizza    map[string]float64
+		coupon   map[string]float64
+	}{
+		// Leftover cents go to the earliest assigned, for charges and discounts alike
+		{name: "first", rounding: persistence.Rounding{Strategy: persistence.RoundingFirst},
+			pizza:  map[string]float64{"bob": 3.34, "carol": 3.33, "alice": 3.33},
+			coupon: map[string]float64{"bob": -0.34, "carol": -0.33, "alice": -0.33}},
+		// The payer pays the extra cent and gives up the extra cent of discount
+		{name: "payer", rounding: persistence.Rounding{Strategy: persistence.RoundingPayer, PayerUserID: &payer},
+			pizza:  map[string]float64{"bob": 3.33, "carol": 3.33, "alice": 3.34},
+			coupon: map[string]float64{"bob": -0.34, "carol": -0.33, "alice": -0.33}},
+		// Without a payer set, payer rounding is first
+		{name: "payer not set", rounding: persistence.Rounding{Strategy: persistence.RoundingPayer},
+			pizza:  map[string]float64{"bob": 3.34, "carol": 3.33, "alice": 3.33},
+			coupon: map[string]float64{"bob": -0.34, "carol": -0.33, "alice": -0.33}},
+		// Carol has the largest share, so she pays the extra cent and gets the smallest discount
+		{name: "largest-share", rounding: persistence.Rounding{Strategy: persistence.RoundingLargestShare},
+			pizza:  map[string]float64{"bob": 3.33, "carol": 3.34, "alice": 3.33},
+			coupon: map[string]float64{"bob": -0.34, "carol": -0.33, "alice": -0.33}},
+		{name: "unset",
+			pizza:  map[string]float64{"bob": 3.34, "carol": 3.33, "alice": 3.33},
+			coupon: map[string]float64{"bob": -0.34, "carol": -0.33, "alice": -0.33}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split := ComputeBillSplit(users, items, assignments, tt.rounding)
+			for itemID, want := range map[string]map[string]float64{"pizza": tt.pizza, "coupon": tt.coupon} {
+				for userID, amount := range want {
+					if got := split.AmountByUserItem[userID+":"+itemID]; got != amount {
+						t.Errorf("%s owes %v for %s, want %v", userID, got, itemID, amount)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package transport
+
+import (
+	"context"
+	"time"
+
+	"splitzies/persistence"
+)
+
+// ReceiptStore is the receipt data the handlers read and write. *persistence.Client implements it against
+// Postgres; tests can substitute an in-memory store.
+type ReceiptStore interface {
+	// Receipts
+	ReceiptExists(ctx context.Context, receiptID string) (bool, error)
+	GetReceiptSnapshot(ctx context.Context, receiptID string) (*persistence.ReceiptSnapshot, error)
+	GetReceiptCurrency(ctx context.Context, receiptID string) (*string, error)
+	GetReceiptOCRText(ctx context.Context, receiptID string) (*persistence.OCRTextData, error)
+	GetReceiptFinalizedAt(ctx context.Context, receiptID string) (*time.Time, error)
+	ListReceipts(ctx context.Context, filter persistence.ReceiptFilter, limit int, after string) ([]persistence.ReceiptSummary, string, error)
+	UpdateReceipt(ctx context.Context, receiptID string, update persistence.ReceiptUpdate, expectedVersion *int) (int, error)
+	SetReceiptFinalized(ctx context.Context, receiptID string, finalized bool, expectedVersion *int) (*time.Time, int, error)
+	DeleteReceipt(ctx context.Context, receiptID string) error
+	UndeleteReceipt(ctx context.Context, receiptID string) error
+	ResetReceipt(ctx context.Context, receiptID string, includeItems bool) (*persistence.ReceiptReset, error)
+	DuplicateReceipt(ctx context.Context, receiptID string) (*persistence.Receipt, []persistence.ReceiptUser, error)
+
+	// Uploads and background processing
+	GetReceiptByImageHash(ctx context.Context, sha256 string) (*persistence.Receipt, error)
+	CreateProcessingReceipt(ctx context.Context, receiptID string, imageURL *string, image *persistence.ImageMetadata) (*persistence.Receipt, error)
+	CompleteReceiptProcessing(ctx context.Context, receiptID string, items []persistence.ReceiptItemDB, ocrText *persistence.OCRTextData, currency *string, receiptDate *time.Time, title *string, tax, tip, serviceCharge, extractedTotal *float64, taxInclusive bool, parser *persistence.ParserInfo, parseStatus string, itemsTruncated bool) ([]persistence.ReceiptItem, error)
+	FailReceiptProcessing(ctx context.Context, receiptID string, parseStatus *string) error
+
+	// Users
+	GetReceiptUsers(ctx context.Context, receiptID string) ([]persistence.ReceiptUser, error)
+	AddUserToReceipt(ctx context.Context, receiptID, name string, color *string) (*persistence.ReceiptUser, error)
+	AddUsersToReceipt(ctx context.Context, receiptID string, names []string) ([]persistence.ReceiptUser, error)
+	SearchReceiptUsersByName(ctx context.Context, name string, limit int, after string) ([]persistence.ReceiptUserMatch, string, error)
+
+	// Items
+	GetReceiptItems(ctx context.Context, receiptID string) ([]persistence.ReceiptItem, error)
+	GetReceiptItem(ctx context.Context, receiptID, itemID string) (*persistence.ReceiptItem, error)
+	AddReceiptItem(ctx context.Context, receiptID string, item persistence.ReceiptItemDB, maxItems int) (*persistence.ReceiptItem, error)
+	UpdateReceiptItem(ctx context.Context, receiptID, itemID string, update persistence.ReceiptItemUpdate, expectedVersion *int) (int, error)
+	DeleteReceiptItem(ctx context.Context, receiptID, itemID string) (int64, error)
+
+	// Assignments
+	GetReceiptAssignmentsPage(ctx context.Context, receiptID string, limit int, after string) ([]persistence.ReceiptUserItem, string, error)
+	AssignItemsToUser(ctx context.Context, receiptUserID string, receiptItemIDs []string, updateExisting bool) ([]persistence.AssignedItem, error)
+	AssignItemToAllExcept(ctx context.Context, receiptID, receiptItemID string, excludeUserIDs []string) ([]persistence.ReceiptUserItem, int64, error)
+	BulkAssign(ctx context.Context, receiptID string, assignments []persistence.ReceiptUserItemDB) ([]persistence.ReceiptUserItem, error)
+	ReplaceAssignments(ctx context.Context, receiptID string, assignments []persistence.ReceiptUserItemDB) ([]persistence.ReceiptUserItem, int64, error)
+	SplitEvenly(ctx context.Context, receiptID string) ([]persistence.ReceiptUserItem, int64, error)
+	ReassignItem(ctx context.Context, receiptID, receiptItemID, fromUserID, toUserID string) (*persistence.ReceiptUserItem, error)
+	SetAssignmentAmount(ctx context.Context, receiptID, receiptUserID, receiptItemID string, amount *float64) (*persistence.ReceiptUserItem, error)
+	ClearUserAssignments(ctx context.Context, receiptID, receiptUserID string) (int64, error)
+
+	// Admin
+	MigrationStatus(ctx context.Context, migrationsDir string) (*persistence.MigrationReport, error)
+}
+
+var _ ReceiptStore = (*persistence.Client)(nil)
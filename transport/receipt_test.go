@@ -0,0 +1,755 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+func TestDedupeUserNames(t *testing.T) {
+	got := dedupeUserNames([]string{"Alice", " Bob ", "alice", "Carol", "BOB"})
+	want := []string{"Alice", "Bob", "Carol"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeUserNames = %v, want %v", got, want)
+	}
+}
+
+func TestAddUserToReceiptValidatesEveryName(t *testing.T) {
+	body := `{"names": ["Alice", "", "  "]}`
+	r := httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T/users", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	(&Transport{}).Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var response ValidationErrorsResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(response.Errors) != 2 || response.Errors[0].Field != "names[1]" || response.Errors[1].Field != "names[2]" {
+		t.Errorf("errors = %+v, want names[1] and names[2]", response.Errors)
+	}
+}
+
+func TestAddUserToReceiptRejectsExistingName(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	tests := []struct {
+		body string
+		want int
+	}{
+		{body: `{"name": "alice"}`, want: http.StatusConflict},
+		{body: `{"name": " ALICE "}`, want: http.StatusConflict},
+		{body: `{"names": ["Bob", "Alice"]}`, want: http.StatusConflict},
+		{body: `{"names": ["Bob", "bob"]}`, want: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		store := &fakeReceiptStore{}
+		store.addReceipt(receiptID)
+		if _, err := store.AddUserToReceipt(context.Background(), receiptID, "Alice", nil); err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "/receipts/"+receiptID+"/users", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		(&Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}).Router().ServeHTTP(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.body, w.Code, tt.want, w.Body.String())
+		}
+		// A rejected batch adds none of its names
+		if users, _ := store.GetReceiptUsers(context.Background(), receiptID); tt.want == http.StatusConflict && len(users) != 1 {
+			t.Errorf("%s: users = %+v, want only Alice", tt.body, users)
+		}
+	}
+}
+
+func TestAddUserToReceiptValidatesColor(t *testing.T) {
+	for _, body := range []string{`{"name": "Alice", "color": "blue"}`, `{"name": "Alice", "color": "#12345"}`, `{"names": ["Alice"], "color": "#1E88E5"}`} {
+		r := httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T/users", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		(&Transport{}).Router().ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", body, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"color"`) {
+			t.Errorf("%s: body = %q, want a color error", body, w.Body.String())
+		}
+	}
+}
+
+func TestReassignItemRejectsSameUser(t *testing.T) {
+	body := `{"from_user_id": "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", "to_user_id": "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"}`
+	r := httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V/items/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0W/reassign", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	(&Transport{}).Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "to_user_id") {
+		t.Errorf("body = %q, want a to_user_id error", w.Body.String())
+	}
+}
+
+func TestPathULID(t *testing.T) {
+	mux := http.NewServeMux()
+	var gotID string
+	var gotErr error
+	mux.HandleFunc("/receipts/{receipt_id}", func(w http.ResponseWriter, r *http.Request) {
+		gotID, gotErr = pathULID(r, "receipt_id")
+	})
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", nil))
+	if gotErr != nil || gotID != "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T" {
+		t.Errorf("got (%q, %v), want the receipt ID", gotID, gotErr)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/receipts/not-a-ulid", nil))
+	if !strings.Contains(fmt.Sprint(gotErr), "receipt_id") {
+		t.Errorf("err = %v, want a receipt_id validation error", gotErr)
+	}
+}
+
+func TestAssignItemToAllValidatesExclusions(t *testing.T) {
+	body := `{"exclude_user_ids": ["01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", "carol"]}`
+	r := httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V/items/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0W/assign-all", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	(&Transport{}).Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "exclude_user_ids[1]") {
+		t.Errorf("body = %q, want an exclude_user_ids[1] error", w.Body.String())
+	}
+}
+
+func TestResetReceiptRejectsInvalidItemsFlag(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T/reset?items=all", nil)
+	w := httptest.NewRecorder()
+
+	(&Transport{}).Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "items must be true or false") {
+		t.Errorf("body = %q, want an items error", w.Body.String())
+	}
+}
+
+func TestSortReceiptUsers(t *testing.T) {
+	amount := func(value float64) *money.Amount {
+		a := money.NewAmount(value, &defaultUSD)
+		return &a
+	}
+	users := []GetReceiptUserResponse{
+		{ID: "1", Name: "carol", UserTotal: amount(12.50)},
+		{ID: "2", Name: "Alice", UserTotal: amount(30.01)},
+		{ID: "3", Name: "bob", UserTotal: amount(12.50)},
+	}
+	ids := func() []string {
+		result := make([]string, len(users))
+		for i, u := range users {
+			result[i] = u.ID
+		}
+		return result
+	}
+
+	sortReceiptUsers(users, userSortName)
+	if got := ids(); !reflect.DeepEqual(got, []string{"2", "3", "1"}) {
+		t.Errorf("by name = %v, want [2 3 1]", got)
+	}
+	// Ties on total keep the existing order
+	sortReceiptUsers(users, userSortTotal)
+	if got := ids(); !reflect.DeepEqual(got, []string{"2", "3", "1"}) {
+		t.Errorf("by total = %v, want [2 3 1]", got)
+	}
+
+	if _, err := parseUserSortQuery(url.Values{"sort": {"amount"}}); err == nil {
+		t.Error("sort=amount: err = nil, want a validation error")
+	}
+	if by, err := parseUserSortQuery(url.Values{}); err != nil || by != userSortCreated {
+		t.Errorf("no sort = (%q, %v), want created", by, err)
+	}
+}
+
+func TestAssignItemsToUserValidatesOnExisting(t *testing.T) {
+	body := `{"item_ids": ["01HQ3V3KAWJ8VZ1R2Q5B6Y9X0W"], "on_existing": "overwrite"}`
+	r := httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T/users/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V/items", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	(&Transport{}).Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "on_existing") {
+		t.Errorf("body = %q, want an on_existing error", w.Body.String())
+	}
+}
+
+func TestAssignItemsToUserHandler(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	ctx := context.Background()
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	alice, _ := store.AddUserToReceipt(ctx, receiptID, "Alice", nil)
+	burger, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Burger", Quantity: 1, TotalPrice: 12.00, PricePerItem: 12.00}, defaultMaxReceiptItems)
+	fries, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Fries", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00}, defaultMaxReceiptItems)
+	drink, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Drink", Quantity: 1, TotalPrice: 3.00, PricePerItem: 3.00}, defaultMaxReceiptItems)
+	store.addAssignment(receiptID, alice.ID, burger.ID, customAmount(5.00))
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+	assign := func(body string) (int, AssignItemsToUserResponse) {
+		r := httptest.NewRequest(http.MethodPost, "/receipts/"+receiptID+"/users/"+alice.ID+"/items", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		transport.Router().ServeHTTP(w, r)
+		var response AssignItemsToUserResponse
+		if w.Code < 300 {
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, response
+	}
+	assignedItems := func() map[string]*float64 {
+		snapshot, _ := store.GetReceiptSnapshot(ctx, receiptID)
+		amounts := make(map[string]*float64)
+		for _, a := range snapshot.Assignments {
+			amounts[a.ReceiptItemID] = a.AmountOwed
+		}
+		return amounts
+	}
+
+	// Burger's custom amount is kept, and fries, though fine on its own, isn't assigned either
+	code, _ := assign(fmt.Sprintf(`{"item_ids": [%q, %q]}`, fries.ID, burger.ID))
+	if code != http.StatusConflict {
+		t.Fatalf("custom amount: status = %d, want 409", code)
+	}
+	if got := assignedItems(); len(got) != 1 || got[burger.ID] == nil || *got[burger.ID] != 5.00 {
+		t.Errorf("after 409: assignments = %v, want only the burger at 5.00", got)
+	}
+
+	code, response := assign(fmt.Sprintf(`{"item_ids": [%q, %q], "on_existing": "update"}`, fries.ID, burger.ID))
+	if code != http.StatusCreated {
+		t.Fatalf("on_existing update: status = %d, want 201", code)
+	}
+	if len(response.Items) != 2 || !*response.Items[0].Created || *response.Items[1].Created {
+		t.Errorf("on_existing update: items = %+v, want fries created and the burger not", response.Items)
+	}
+	if got := assignedItems(); len(got) != 2 || got[burger.ID] != nil {
+		t.Errorf("on_existing update: assignments = %v, want the burger back to an equal split", got)
+	}
+
+	// Re-assigning what the user already has creates nothing
+	code, response = assign(fmt.Sprintf(`{"item_ids": [%q]}`, fries.ID))
+	if code != http.StatusOK || len(response.Items) != 1 || *response.Items[0].Created {
+		t.Errorf("re-assign: status = %d, items = %+v; want 200 with created false", code, response.Items)
+	}
+
+	// An item not on the receipt fails the whole request
+	code, _ = assign(fmt.Sprintf(`{"item_ids": [%q, "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0W"]}`, drink.ID))
+	if code != http.StatusNotFound {
+		t.Errorf("missing item: status = %d, want 404", code)
+	}
+	if _, ok := assignedItems()[drink.ID]; ok {
+		t.Error("missing item: drink was assigned, want nothing assigned")
+	}
+}
+
+func TestPatchReceiptCapsNotesLength(t *testing.T) {
+	body := fmt.Sprintf(`{"notes": %q}`, strings.Repeat("é", maxNotesLength+1))
+	r := httptest.NewRequest(http.MethodPatch, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	(&Transport{}).Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "notes must be at most") {
+		t.Errorf("body = %q, want a notes error", w.Body.String())
+	}
+}
+
+func TestPatchReceiptValidatesSplitMode(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", strings.NewReader(`{"split_mode": "by_weight"}`))
+	w := httptest.NewRecorder()
+
+	(&Transport{}).Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "split_mode must be itemized or equal") {
+		t.Errorf("body = %q, want a split_mode error", w.Body.String())
+	}
+}
+
+func TestPatchReceiptNotesOnDeletedReceipt(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+	patchNotes := func() int {
+		r := httptest.NewRequest(http.MethodPatch, "/receipts/"+receiptID, strings.NewReader(`{"notes": "Team lunch"}`))
+		w := httptest.NewRecorder()
+		transport.Router().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := patchNotes(); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if err := store.DeleteReceipt(context.Background(), receiptID); err != nil {
+		t.Fatal(err)
+	}
+	if code := patchNotes(); code != http.StatusNotFound {
+		t.Errorf("deleted receipt: status = %d, want 404", code)
+	}
+}
+
+func TestPatchReceiptPayer(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	store.AddUsersToReceipt(context.Background(), receiptID, []string{"Alice", "Bob"})
+	bob := store.receipts[receiptID].users[1].ID
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		transport.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/receipts/"+receiptID, strings.NewReader(body)))
+		return w
+	}
+
+	if w := patch(`{"payer_user_id": "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0Z"}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "not a user on this receipt") {
+		t.Errorf("stranger as payer: status = %d (%s), want 400", w.Code, w.Body.String())
+	}
+	if w := patch(`{"rounding_strategy": "payer", "payer_user_id": "` + bob + `"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s), want 200", w.Code, w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/receipts/"+receiptID, nil))
+	var response struct {
+		Rounding    string  `json:"rounding_strategy"`
+		PayerUserID *string `json:"payer_user_id"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Rounding != persistence.RoundingPayer || response.PayerUserID == nil || *response.PayerUserID != bob {
+		t.Errorf("rounding_strategy = %q, payer_user_id = %v; want payer, %s", response.Rounding, response.PayerUserID, bob)
+	}
+}
+
+func TestPatchRejectsStaleVersion(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	item, _ := store.AddReceiptItem(context.Background(), receiptID, persistence.ReceiptItemDB{Name: "Fries", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00}, defaultMaxReceiptItems)
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+	patchReceipt := func(body, ifMatch string) int {
+		r := httptest.NewRequest(http.MethodPatch, "/receipts/"+receiptID, strings.NewReader(body))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		transport.Router().ServeHTTP(w, r)
+		return w.Code
+	}
+	patchItem := func(body, ifMatch string) int {
+		r := httptest.NewRequest(http.MethodPatch, "/receipts/"+receiptID+"/items/"+item.ID, strings.NewReader(body))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		transport.Router().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Both start at version 1; each successful PATCH moves them on, so the second use of 1 is stale
+	tests := []struct {
+		name    string
+		patch   func(body, ifMatch string) int
+		body    string
+		ifMatch string
+		want    int
+	}{
+		{name: "receipt current version", patch: patchReceipt, body: `{"notes": "Lunch", "version": 1}`, want: http.StatusOK},
+		{name: "receipt stale body version", patch: patchReceipt, body: `{"notes": "Dinner", "version": 1}`, want: http.StatusConflict},
+		{name: "receipt stale If-Match", patch: patchReceipt, body: `{"notes": "Dinner"}`, ifMatch: `"1"`, want: http.StatusConflict},
+		{name: "item current version", patch: patchItem, body: `{"taxable": false, "version": 1}`, want: http.StatusOK},
+		{name: "item stale body version", patch: patchItem, body: `{"shared": true, "version": 1}`, want: http.StatusConflict},
+		{name: "item stale If-Match", patch: patchItem, body: `{"shared": true}`, ifMatch: `"1"`, want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := tt.patch(tt.body, tt.ifMatch); code != tt.want {
+				t.Errorf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+	if stored, _ := store.GetReceiptItem(context.Background(), receiptID, item.ID); stored.Shared {
+		t.Error("a stale item PATCH changed shared")
+	}
+}
+
+// finalizeAfterCheckStore finalizes a receipt just after GetReceiptFinalizedAt reads it, as a finalize landing
+// between requireUnfinalized and the edit would
+type finalizeAfterCheckStore struct {
+	*fakeReceiptStore
+}
+
+func (s finalizeAfterCheckStore) GetReceiptFinalizedAt(ctx context.Context, receiptID string) (*time.Time, error) {
+	finalizedAt, err := s.fakeReceiptStore.GetReceiptFinalizedAt(ctx, receiptID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if receipt, ok := s.receipts[receiptID]; ok && receipt.finalizedAt == nil {
+		now := time.Now()
+		receipt.finalizedAt = &now
+	}
+	return finalizedAt, err
+}
+
+func TestEditsToFinalizedReceiptConflict(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	tests := []struct {
+		name        string
+		store       func(*fakeReceiptStore) ReceiptStore
+		body        string
+		want        int
+		finalizedAt bool // finalized before the request
+	}{
+		{name: "finalized before", store: func(s *fakeReceiptStore) ReceiptStore { return s }, body: `{"tip": 5.00}`, want: http.StatusConflict, finalizedAt: true},
+		{name: "finalized after the check", store: func(s *fakeReceiptStore) ReceiptStore { return finalizeAfterCheckStore{s} }, body: `{"tip": 5.00}`, want: http.StatusConflict},
+		{name: "notes only", store: func(s *fakeReceiptStore) ReceiptStore { return s }, body: `{"notes": "Team lunch"}`, want: http.StatusOK, finalizedAt: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeReceiptStore{}
+			store.addReceipt(receiptID)
+			if tt.finalizedAt {
+				now := time.Now()
+				store.receipts[receiptID].finalizedAt = &now
+			}
+			transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: tt.store(store)}
+			r := httptest.NewRequest(http.MethodPatch, "/receipts/"+receiptID, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			transport.Router().ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tip := store.receipts[receiptID].taxTip.Tip; tip != nil {
+				t.Errorf("tip = %v, want it left unset", *tip)
+			}
+		})
+	}
+}
+
+func TestAddReceiptItemValidatesPrice(t *testing.T) {
+	tests := []struct {
+		body      string
+		wantField string
+	}{
+		{body: `{"name": "Fries"}`, wantField: "total_price"},
+		{body: `{"name": "Coupon", "total_price": 2.00, "is_discount": true}`, wantField: "total_price"},
+		{body: `{"name": "Fries", "total_price": -2.00}`, wantField: "total_price"},
+		{body: `{"name": " ", "total_price": 3.50}`, wantField: "name"},
+		{body: `{"name": "Fries", "quantity": 0, "total_price": 3.50}`, wantField: "quantity"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T/items", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		(&Transport{}).Router().ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.body, w.Code)
+			continue
+		}
+		if !strings.Contains(w.Body.String(), `"field":"`+tt.wantField+`"`) {
+			t.Errorf("%s: body = %q, want a %s error", tt.body, w.Body.String(), tt.wantField)
+		}
+	}
+}
+
+func TestAddReceiptItemHandlerStoresItem(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store, maxReceiptItems: 1}
+
+	r := httptest.NewRequest(http.MethodPost, "/receipts/"+receiptID+"/items", strings.NewReader(`{"name": " Fries ", "quantity": 2, "total_price": 7.00}`))
+	w := httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201 (%s)", w.Code, w.Body.String())
+	}
+	var response struct {
+		Item struct {
+			ID string `json:"id"`
+		} `json:"item"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Header().Get("Location"); response.Item.ID == "" || got != "/receipts/"+receiptID+"/items/"+response.Item.ID {
+		t.Errorf("Location = %q, want the new item", got)
+	}
+	items, _ := store.GetReceiptItems(context.Background(), receiptID)
+	if len(items) != 1 || items[0].Name != "Fries" || items[0].PricePerItem != 3.50 {
+		t.Errorf("stored items = %+v, want Fries at 3.50 each", items)
+	}
+
+	// The receipt is now at its item cap
+	r = httptest.NewRequest(http.MethodPost, "/receipts/"+receiptID+"/items", strings.NewReader(`{"name": "Soda", "total_price": 2.00}`))
+	w = httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("over the item cap: status = %d, want 409", w.Code)
+	}
+	if items, _ := store.GetReceiptItems(context.Background(), receiptID); len(items) != 1 {
+		t.Errorf("over the item cap: %d items stored, want 1", len(items))
+	}
+
+	// A receipt that doesn't exist is a 404 before anything is stored
+	r = httptest.NewRequest(http.MethodPost, "/receipts/01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V/items", strings.NewReader(`{"name": "Fries", "total_price": 3.50}`))
+	w = httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing receipt: status = %d, want 404", w.Code)
+	}
+}
+
+func TestDeleteReceiptItemHandlerRemovesAssignments(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	ctx := context.Background()
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	alice, _ := store.AddUserToReceipt(ctx, receiptID, "Alice", nil)
+	bob, _ := store.AddUserToReceipt(ctx, receiptID, "Bob", nil)
+	burger, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Burger", Quantity: 1, TotalPrice: 12.00, PricePerItem: 12.00}, defaultMaxReceiptItems)
+	fries, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Fries", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00}, defaultMaxReceiptItems)
+	store.addAssignment(receiptID, alice.ID, burger.ID, nil)
+	store.addAssignment(receiptID, bob.ID, burger.ID, nil)
+	store.addAssignment(receiptID, bob.ID, fries.ID, nil)
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+
+	r := httptest.NewRequest(http.MethodDelete, "/receipts/"+receiptID+"/items/"+burger.ID, nil)
+	w := httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	var response DeleteReceiptItemResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.ItemID != burger.ID || response.AssignmentsRemoved != 2 {
+		t.Errorf("response = %+v, want the burger with 2 assignments removed", response)
+	}
+
+	snapshot, _ := store.GetReceiptSnapshot(ctx, receiptID)
+	split := ComputeBillSplit(snapshot.Users, snapshot.Items, snapshot.Assignments, snapshot.Rounding)
+	if len(snapshot.Items) != 1 || len(split.OrphanedAssignments) != 0 {
+		t.Errorf("items = %+v, orphaned = %+v; want only the fries and no orphans", snapshot.Items, split.OrphanedAssignments)
+	}
+	if split.UserTotal[alice.ID] != 0 || split.UserTotal[bob.ID] != 4.00 {
+		t.Errorf("user totals = %v, want alice 0 and bob 4.00", split.UserTotal)
+	}
+
+	// Deleting it again, or an item on another receipt, is a 404
+	r = httptest.NewRequest(http.MethodDelete, "/receipts/"+receiptID+"/items/"+burger.ID, nil)
+	w = httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("already deleted: status = %d, want 404", w.Code)
+	}
+}
+
+// customAmount returns a pointer to an assignment's custom amount
+func customAmount(v float64) *float64 { return &v }
+
+func TestCheckItemPortions(t *testing.T) {
+	items := []persistence.ReceiptItem{
+		{ID: "plate", TotalPrice: 10.00},
+		{ID: "pizza", TotalPrice: 10.00},
+	}
+	tests := []struct {
+		name        string
+		assignments []BulkAssignRequestItem
+		additive    bool // checked as added to stored assignments rather than as the complete set
+		wantErrors  int
+	}{
+		{name: "portions reconcile", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(6)}, {UserID: "b", ItemID: "plate", Amount: customAmount(4)}}},
+		{name: "within a cent", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "pizza", Amount: customAmount(3.33)}, {UserID: "b", ItemID: "pizza", Amount: customAmount(3.33)}, {UserID: "c", ItemID: "pizza", Amount: customAmount(3.33)}}},
+		{name: "portions short", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(6)}, {UserID: "b", ItemID: "plate", Amount: customAmount(3)}}, wantErrors: 1},
+		{name: "portions over", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(6)}, {UserID: "b", ItemID: "plate", Amount: customAmount(5)}}, wantErrors: 1},
+		{name: "rest split equally", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(6)}, {UserID: "b", ItemID: "plate"}}},
+		{name: "amount over the total with others", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(12)}, {UserID: "b", ItemID: "plate"}}, wantErrors: 1},
+		{name: "equal split", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate"}, {UserID: "b", ItemID: "plate"}}},
+		{name: "item not on the receipt", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "soup", Amount: customAmount(1)}}},
+		{name: "each item checked", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(1)}, {UserID: "a", ItemID: "pizza", Amount: customAmount(1)}}, wantErrors: 2},
+		{name: "added portion short of the total", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(6)}}, additive: true},
+		{name: "added portions over the total", assignments: []BulkAssignRequestItem{{UserID: "a", ItemID: "plate", Amount: customAmount(6)}, {UserID: "b", ItemID: "plate", Amount: customAmount(5)}}, additive: true, wantErrors: 1},
+	}
+	for _, tt := range tests {
+		if errs := checkItemPortions(tt.assignments, items, !tt.additive); len(errs) != tt.wantErrors {
+			t.Errorf("%s: errors = %v, want %d", tt.name, errs, tt.wantErrors)
+		}
+	}
+}
+
+func TestBulkAssignRejectsPortionsThatDontReconcile(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	ctx := context.Background()
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	plate, err := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Plate", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00}, defaultMaxReceiptItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+
+	// PUT is the complete set, so its portions must add up to the item
+	body := fmt.Sprintf(`{"assignments": [{"user_name": "Alice", "item_id": %q, "amount": 6.00}, {"user_name": "Bob", "item_id": %q, "amount": 3.00}]}`, plate.ID, plate.ID)
+	w := httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/receipts/"+receiptID+"/assignments", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT: status = %d, want 400 (%s)", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "sum to 9.00, but the item totals 10.00") {
+		t.Errorf("PUT: body = %q, want the portions and item total", w.Body.String())
+	}
+
+	// POST adds to the stored assignments, with Carol's $5 already taking half the plate
+	carol, _ := store.AddUserToReceipt(ctx, receiptID, "Carol", nil)
+	store.addAssignment(receiptID, carol.ID, plate.ID, customAmount(5.00))
+	w = httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/receipts/"+receiptID+"/assignments", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("POST: status = %d, want 400 (%s)", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "sum to 14.00, more than the item's total of 10.00") {
+		t.Errorf("POST: body = %q, want the stored and requested portions", w.Body.String())
+	}
+}
+
+func TestBulkAssignAcceptsSinglePortion(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	ctx := context.Background()
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	plate, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Plate", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00}, defaultMaxReceiptItems)
+	alice, _ := store.AddUserToReceipt(ctx, receiptID, "Alice", nil)
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+
+	// $6 of a $10 plate; the rest is for users assigned later
+	body := fmt.Sprintf(`{"assignments": [{"user_id": %q, "item_id": %q, "amount": 6.00}]}`, alice.ID, plate.ID)
+	w := httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/receipts/"+receiptID+"/assignments", strings.NewReader(body)))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201 (%s)", w.Code, w.Body.String())
+	}
+	snapshot, _ := store.GetReceiptSnapshot(ctx, receiptID)
+	if len(snapshot.Assignments) != 1 || snapshot.Assignments[0].AmountOwed == nil || *snapshot.Assignments[0].AmountOwed != 6.00 {
+		t.Errorf("assignments = %+v, want Alice owing 6.00", snapshot.Assignments)
+	}
+}
+
+func TestBulkAssignConvertsShares(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	ctx := context.Background()
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	plate, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Plate", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00}, defaultMaxReceiptItems)
+	alice, _ := store.AddUserToReceipt(ctx, receiptID, "Alice", nil)
+	bob, _ := store.AddUserToReceipt(ctx, receiptID, "Bob", nil)
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+
+	// A quarter and three quarters of the plate make up its whole total
+	body := fmt.Sprintf(`{"assignments": [{"user_id": %q, "item_id": %q, "share": 0.25}, {"user_id": %q, "item_id": %q, "share": 0.75}]}`, alice.ID, plate.ID, bob.ID, plate.ID)
+	w := httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/receipts/"+receiptID+"/assignments", strings.NewReader(body)))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201 (%s)", w.Code, w.Body.String())
+	}
+	snapshot, _ := store.GetReceiptSnapshot(ctx, receiptID)
+	owed := make(map[string]float64)
+	for _, a := range snapshot.Assignments {
+		if a.AmountOwed == nil {
+			t.Fatalf("assignment %+v has no amount, want one from its share", a)
+		}
+		owed[a.ReceiptUserID] = *a.AmountOwed
+	}
+	if owed[alice.ID] != 2.50 || owed[bob.ID] != 7.50 {
+		t.Errorf("owed = %v, want Alice 2.50 and Bob 7.50", owed)
+	}
+}
+
+func TestBulkAssignRejectsInvalidShares(t *testing.T) {
+	share := func(v float64) *float64 { return &v }
+	_, err := bulkAssignRequestToDB([]BulkAssignRequestItem{
+		{UserID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", ItemID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V", Share: share(0.5)},
+		{UserID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", ItemID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V", Share: share(0)},
+		{UserID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", ItemID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V", Share: share(1.5)},
+		{UserID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T", ItemID: "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0V", Share: share(0.5), Amount: customAmount(5)},
+	})
+
+	var errs ValidationErrors
+	if !errs.Collect(err) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	wantFields := []string{"assignments[1].share", "assignments[2].share", "assignments[3]"}
+	if len(errs) != len(wantFields) {
+		t.Fatalf("errors = %v, want fields %v", errs, wantFields)
+	}
+	for i, field := range wantFields {
+		if errs[i].Field != field {
+			t.Errorf("errors[%d].Field = %q, want %q", i, errs[i].Field, field)
+		}
+	}
+}
+
+func TestPatchAssignmentRejectsAmountOverItemTotal(t *testing.T) {
+	const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+	ctx := context.Background()
+	store := &fakeReceiptStore{}
+	store.addReceipt(receiptID)
+	plate, _ := store.AddReceiptItem(ctx, receiptID, persistence.ReceiptItemDB{Name: "Plate", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00}, defaultMaxReceiptItems)
+	alice, _ := store.AddUserToReceipt(ctx, receiptID, "Alice", nil)
+	bob, _ := store.AddUserToReceipt(ctx, receiptID, "Bob", nil)
+	store.addAssignment(receiptID, alice.ID, plate.ID, customAmount(8.00))
+	store.addAssignment(receiptID, bob.ID, plate.ID, nil)
+	transport := &Transport{log: slog.New(slog.DiscardHandler), persistenceClient: store}
+
+	r := httptest.NewRequest(http.MethodPatch, "/receipts/"+receiptID+"/users/"+bob.ID+"/items/"+plate.ID, strings.NewReader(`{"amount": 3.00}`))
+	w := httptest.NewRecorder()
+	transport.Router().ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 (%s)", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "sum to 11.00, more than the item's total of 10.00") {
+		t.Errorf("body = %q, want the item's portions", w.Body.String())
+	}
+}
@@ -12,6 +12,30 @@ type ReceiptItem struct {
 	Quantity     int           `json:"quantity"`
 	TotalPrice   *money.Amount `json:"total_price,omitempty"`    // Optional, can be calculated
 	PricePerItem *money.Amount `json:"price_per_item,omitempty"` // Optional, can be calculated
+	Version      int           `json:"version"`                  // Pass back when editing to detect concurrent edits
+	Taxable      bool          `json:"taxable"`                  // Untaxed items don't count toward a user's share of tax
+	IsDiscount   bool          `json:"is_discount"`              // Coupon or promotion; total_price is negative
+	Category     *string       `json:"category,omitempty"`       // food, drink, alcohol, service, or other; omitted when unknown
+	NeedsReview  bool          `json:"needs_review"`             // The parser read an implausible price or quantity, or read it with low confidence
+	Shared       bool          `json:"shared"`                   // Split evenly among every user without explicit assignments
+	// Users with an assignment to the item, in assignment order, then for a shared item the other users it is
+	// spread over; only set on GET /receipts/{receipt_id} and omitted when nobody is assigned
+	AssignedUserIDs []string `json:"assigned_user_ids,omitempty"`
+}
+
+// AddReceiptItemRequest represents the request body for adding an item by hand
+type AddReceiptItemRequest struct {
+	Name         string   `json:"name"`
+	Quantity     *int     `json:"quantity,omitempty"` // Defaults to 1
+	TotalPrice   *float64 `json:"total_price"`
+	PricePerItem *float64 `json:"price_per_item,omitempty"` // Defaults to total_price / quantity
+	IsDiscount   bool     `json:"is_discount,omitempty"`    // Coupon or promotion; total_price must be negative
+}
+
+// AddReceiptItemResponse represents the response after adding an item by hand
+type AddReceiptItemResponse struct {
+	Message string      `json:"message"`
+	Item    ReceiptItem `json:"item"`
 }
 
 // AddReceiptRequest represents the request body for adding a receipt
@@ -30,15 +54,22 @@ type AddReceiptResponse struct {
 type UploadReceiptResponse struct {
 	ReceiptID string        `json:"receipt_id"`
 	ImageURL  string        `json:"image_url"`
+	Status    string        `json:"status"` // processing until background OCR/parsing finishes
 	Items     []ReceiptItem `json:"items"`
 	OCRText   *string       `json:"ocr_text,omitempty"`
 	Tax       *money.Amount `json:"tax,omitempty"`
 	Tip       *money.Amount `json:"tip,omitempty"`
+	// DuplicateOf is set when the same image was already uploaded; the response then describes that receipt
+	DuplicateOf *string `json:"duplicate_of,omitempty"`
+	ParseStatus *string `json:"parse_status,omitempty"` // ok, ocr_failed, parse_failed, no_items, or timeout once processed
 }
 
 // AddUserToReceiptRequest represents the request body for adding a user to a receipt
+// Send either name for one user or names for several
 type AddUserToReceiptRequest struct {
-	Name string `json:"name"`
+	Name  string   `json:"name,omitempty"`
+	Names []string `json:"names,omitempty"`
+	Color *string  `json:"color,omitempty"` // #RRGGBB; only with name
 }
 
 // AddUserToReceiptResponse represents the response after adding a user to a receipt
@@ -48,15 +79,24 @@ type AddUserToReceiptResponse struct {
 		ID        string `json:"id"`
 		ReceiptID string `json:"receipt_id"`
 		Name      string `json:"name"`
+		Color     string `json:"color"`
 	} `json:"user"`
 }
 
+// AddUsersToReceiptResponse represents the response after adding several users to a receipt at once
+type AddUsersToReceiptResponse struct {
+	Message string                   `json:"message"`
+	Users   []GetReceiptUserResponse `json:"users"` // In the order the names were sent, without duplicates
+}
+
 // GetReceiptUserResponse represents a user in the get receipt response
 type GetReceiptUserResponse struct {
 	ID        string        `json:"id"`
 	ReceiptID string        `json:"receipt_id"`
 	Name      string        `json:"name"`
+	Color     string        `json:"color"` // #RRGGBB, stable across sessions
 	UserTotal *money.Amount `json:"user_total,omitempty"`
+	Discount  *money.Amount `json:"discount,omitempty"` // Share of unassigned receipt-wide discounts, already included in user_total
 }
 
 // GetReceiptUsersResponse represents the response for GET receipt users
@@ -70,36 +110,424 @@ type GetReceiptAssignmentResponse struct {
 	UserID     string       `json:"user_id"`
 	ItemID     string       `json:"item_id"`
 	AmountOwed money.Amount `json:"amount_owed"`
+	CreatedAt  string       `json:"created_at"` // RFC 3339; assignments are listed in this order
 }
 
 // GetReceiptResponse represents the full get receipt response
 type GetReceiptResponse struct {
-	ReceiptID   string                         `json:"receipt_id"`
-	Users       []GetReceiptUserResponse       `json:"users"`
-	Items       []ReceiptItem                  `json:"items"`
-	Assignments []GetReceiptAssignmentResponse `json:"assignments"`
+	ReceiptID       string                         `json:"receipt_id"`
+	Currency        string                         `json:"currency"`     // ISO 4217 code all amounts are in
+	Version         int                            `json:"version"`      // Pass back on PATCH to detect concurrent edits
+	Status          string                         `json:"status"`       // processing, ready, or failed
+	NeedsReview     bool                           `json:"needs_review"` // Some item has an implausible parsed price or quantity
+	Finalized       bool                           `json:"finalized"`    // The split is frozen; edits return 409 until unfinalized
+	FinalizedAt     *string                        `json:"finalized_at,omitempty"`
+	Notes           *string                        `json:"notes,omitempty"`
+	ParseStatus     *string                        `json:"parse_status,omitempty"` // ok, ocr_failed, parse_failed, no_items, or timeout; omitted while processing
+	Users           []GetReceiptUserResponse       `json:"users"`
+	Items           []ReceiptItem                  `json:"items"`
+	Assignments     []GetReceiptAssignmentResponse `json:"assignments"`
+	Subtotal        money.Amount                   `json:"subtotal"`                       // Sum of item totals
+	Tax             *money.Amount                  `json:"tax,omitempty"`                  // Omitted when not set
+	Tip             *money.Amount                  `json:"tip,omitempty"`                  // Omitted when not set
+	ServiceCharge   *money.Amount                  `json:"service_charge,omitempty"`       // Automatic service charge; omitted when not set
+	TaxSource       *string                        `json:"tax_source,omitempty"`           // parsed or manual; omitted with tax
+	TipSource       *string                        `json:"tip_source,omitempty"`           // parsed or manual; omitted with tip
+	TaxInclusive    bool                           `json:"tax_inclusive"`                  // Item prices already include tax, so tax is not added on top
+	GrandTotal      money.Amount                   `json:"grand_total"`                    // Subtotal + tax (unless tax_inclusive) + tip + service charge
+	Unassigned      []ReceiptItem                  `json:"unassigned"`                     // Items nobody is assigned to yet
+	UnassignedTotal money.Amount                   `json:"unassigned_total"`               // Sum of unassigned item totals
+	SplitStatus     string                         `json:"split_status"`                   // no_items, no_users, unassigned, partial, or complete
+	Rounding        string                         `json:"rounding_strategy,omitempty"`    // first, payer, or largest-share: who absorbs leftover cents of equal splits
+	PayerUserID     *string                        `json:"payer_user_id,omitempty"`        // Who paid the bill; absorbs leftover cents under payer rounding
+	SplitMode       string                         `json:"split_mode,omitempty"`           // itemized (by assignment) or equal (grand total divided evenly among users)
+	Orphaned        []string                       `json:"orphaned_assignments,omitempty"` // IDs of assignments whose item no longer exists; excluded from all amounts
+	ExtractedTotal  *money.Amount                  `json:"extracted_total,omitempty"`      // Total printed on the receipt, when the parser read one
+	Discrepancy     *money.Amount                  `json:"discrepancy,omitempty"`          // grand_total - extracted_total; non-zero suggests a mis-parse
+	ItemsTruncated  bool                           `json:"items_truncated,omitempty"`      // Parsing found more than MAX_RECEIPT_ITEMS items; only the first were saved
+	ConvertedTotal  *ConvertedTotals               `json:"converted_total,omitempty"`      // Only when convert_to and rate are requested
+	Image           *ReceiptImageInfo              `json:"image,omitempty"`                // Uploaded image metadata, when recorded
+	Debug           *ReceiptDebugInfo              `json:"debug,omitempty"`                // Only when DEBUG_RESPONSES is set
+}
+
+// ReceiptImageInfo describes the uploaded receipt image
+type ReceiptImageInfo struct {
+	Width     *int  `json:"width,omitempty"` // Omitted when the image header couldn't be decoded
+	Height    *int  `json:"height,omitempty"`
+	SizeBytes int64 `json:"size_bytes"` // Size as uploaded, before any HEIC/WebP transcoding
+}
+
+// ReceiptDebugInfo is parser telemetry for a receipt, for investigating parse quality
+type ReceiptDebugInfo struct {
+	ParserSource   string             `json:"parser_source,omitempty"`   // vision_gemini, documentai, or regex; omitted when items were not parsed
+	ModelVersion   *string            `json:"model_version,omitempty"`   // Gemini model or Document AI processor
+	ItemConfidence map[string]float64 `json:"item_confidence,omitempty"` // Parser confidence (0-1) by item ID; only Document AI reports one
+}
+
+// ConvertedTotals holds a receipt's totals converted into another currency at a client-provided rate
+type ConvertedTotals struct {
+	Currency   string                  `json:"currency"`
+	Rate       float64                 `json:"rate"` // Units of currency per unit of the receipt currency
+	GrandTotal money.Amount            `json:"grand_total"`
+	UserTotals map[string]money.Amount `json:"user_totals"` // key: user ID
 }
 
 // AssignItemsToUserRequest represents the request body for assigning items to a user
 type AssignItemsToUserRequest struct {
 	ItemIDs []string `json:"item_ids"`
+	// OnExisting says what to do when the user is already assigned to an item with a custom amount: reject
+	// (the default) answers 409 and keeps it, update returns it to an equal split. Unchanged pairs are a no-op.
+	OnExisting string `json:"on_existing,omitempty"`
 }
 
 // AssignItemsToUserItem represents an assigned item in the response
 type AssignItemsToUserItem struct {
-	ID            string `json:"id"`
-	ReceiptUserID string `json:"receipt_user_id"`
-	ReceiptItemID string `json:"receipt_item_id"`
+	ID            string        `json:"id"`
+	ReceiptUserID string        `json:"receipt_user_id"`
+	ReceiptItemID string        `json:"receipt_item_id"`
+	AmountOwed    *money.Amount `json:"amount_owed,omitempty"` // Only set for custom amounts
+	CreatedAt     string        `json:"created_at"`            // RFC 3339
+	Created       *bool         `json:"created,omitempty"`     // From POST /receipts/{receipt_id}/users/{user_id}/items: false if it already existed
 }
 
 // AssignItemsToUserResponse represents the response after assigning items to a user
 type AssignItemsToUserResponse struct {
-	Message string                 `json:"message"`
+	Message string                  `json:"message"`
 	Items   []AssignItemsToUserItem `json:"items"`
 }
 
-// PatchReceiptRequest represents the request body for updating receipt tax/tip
+// PatchAssignmentRequest represents the request body for setting a single assignment's custom amount.
+// Amount is required; null returns the assignment to an equal split.
+type PatchAssignmentRequest struct {
+	Amount nullableAmount `json:"amount"`
+}
+
+// PatchAssignmentResponse represents the response after updating an assignment
+type PatchAssignmentResponse struct {
+	Message    string                `json:"message"`
+	Assignment AssignItemsToUserItem `json:"assignment"`
+}
+
+// ReassignItemRequest represents the request body for moving an item's assignment from one user to another
+type ReassignItemRequest struct {
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+}
+
+// DeleteReceiptItemResponse represents the response after removing an item from a receipt
+type DeleteReceiptItemResponse struct {
+	Message            string `json:"message"`
+	ItemID             string `json:"item_id"`
+	AssignmentsRemoved int64  `json:"assignments_removed"`
+}
+
+// ReassignItemResponse represents the response after moving an assignment; it keeps its ID and custom amount
+type ReassignItemResponse struct {
+	Message    string                `json:"message"`
+	Assignment AssignItemsToUserItem `json:"assignment"`
+}
+
+// ClearUserAssignmentsResponse represents the response after removing all of a user's assignments
+type ClearUserAssignmentsResponse struct {
+	Message string `json:"message"`
+	Deleted int64  `json:"deleted"`
+}
+
+// BulkAssignRequestItem is a single user-item pair in a bulk assignment request
+type BulkAssignRequestItem struct {
+	UserID   string   `json:"user_id,omitempty"`
+	UserName string   `json:"user_name,omitempty"` // Alternative to user_id; resolved or created on the receipt
+	ItemID   string   `json:"item_id"`
+	Amount   *float64 `json:"amount,omitempty"` // Optional custom amount; omitted means equal split
+	Share    *float64 `json:"share,omitempty"`  // Optional fraction (0-1] of the item's total, converted to an amount; exclusive with amount
+}
+
+// BulkAssignRequest represents the request body for assigning many items to many users at once
+type BulkAssignRequest struct {
+	Assignments []BulkAssignRequestItem `json:"assignments"`
+}
+
+// BulkAssignResponse represents the response after a bulk assignment
+type BulkAssignResponse struct {
+	Message     string                  `json:"message"`
+	Assignments []AssignItemsToUserItem `json:"assignments"`
+}
+
+// AssignItemToAllRequest represents the request body for assigning an item to everyone on the receipt but a few
+type AssignItemToAllRequest struct {
+	ExcludeUserIDs []string `json:"exclude_user_ids"` // Optional; receipt users who don't share the item
+}
+
+// ReplaceAssignmentsResponse represents the response after replacing a receipt's assignments
+type ReplaceAssignmentsResponse struct {
+	Message     string                  `json:"message"`
+	Assignments []AssignItemsToUserItem `json:"assignments"`
+	Removed     int64                   `json:"removed"` // Assignments deleted because they were not in the new set
+}
+
+// GetReceiptAssignmentsResponse represents a page of assignments for a receipt
+// NextCursor is passed as the "after" query parameter to fetch the next page; omitted on the last page
+type GetReceiptAssignmentsResponse struct {
+	Assignments []AssignItemsToUserItem `json:"assignments"`
+	NextCursor  string                  `json:"next_cursor,omitempty"`
+}
+
+// UserReceiptSummary is one receipt a searched-for user name appears on, with what that user owes on it
+type UserReceiptSummary struct {
+	UserID       string       `json:"user_id"`
+	Name         string       `json:"name"`
+	Color        string       `json:"color"`
+	ReceiptID    string       `json:"receipt_id"`
+	ReceiptTitle *string      `json:"receipt_title,omitempty"`
+	CreatedAt    string       `json:"created_at"` // When the receipt was created, RFC 3339
+	Currency     string       `json:"currency"`
+	UserTotal    money.Amount `json:"user_total"` // Items plus allocated tax and tip, as in GET /receipts/{receipt_id}
+}
+
+// SearchUsersResponse represents a page of receipts a user name appears on
+// NextCursor is passed as the "after" query parameter to fetch the next page; omitted on the last page
+type SearchUsersResponse struct {
+	Results    []UserReceiptSummary `json:"results"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// PatchReceiptRequest represents the request body for updating receipt tax/tip/service charge
+// Version is optional; when set (or sent as If-Match) the update fails with 409 if the receipt changed since
+// TipPercent is resolved against the current subtotal and stored as the tip; it can't be sent with Tip
 type PatchReceiptRequest struct {
-	Tax *float64 `json:"tax"`
-	Tip *float64 `json:"tip"`
+	Tax           *float64 `json:"tax"`
+	Tip           *float64 `json:"tip"`
+	TipPercent    *float64 `json:"tip_percent,omitempty"`
+	ServiceCharge *float64 `json:"service_charge,omitempty"`
+	Rounding      *string  `json:"rounding_strategy,omitempty"` // first, payer, or largest-share
+	PayerUserID   *string  `json:"payer_user_id,omitempty"`     // A user on the receipt
+	SplitMode     *string  `json:"split_mode,omitempty"`        // itemized or equal
+	TaxInclusive  *bool    `json:"tax_inclusive,omitempty"`     // Item prices already include tax
+	Notes         *string  `json:"notes,omitempty"`             // Free-form memo; "" clears it
+	Version       *int     `json:"version,omitempty"`
+}
+
+// PatchReceiptResponse represents the response after updating a receipt
+type PatchReceiptResponse struct {
+	Message string        `json:"message"`
+	Version int           `json:"version"`
+	Tip     *money.Amount `json:"tip,omitempty"` // The resolved tip, when tip_percent was sent
+}
+
+// DuplicateReceiptResponse represents the response after copying a receipt as a template
+type DuplicateReceiptResponse struct {
+	Message        string `json:"message"`
+	ReceiptID      string `json:"receipt_id"`      // The new receipt
+	DuplicatedFrom string `json:"duplicated_from"` // The receipt that was copied
+	UsersCopied    int    `json:"users_copied"`
+	ItemsCopied    int    `json:"items_copied"`
+}
+
+// ResetReceiptResponse represents the response after wiping a receipt's users and assignments
+type ResetReceiptResponse struct {
+	Message            string `json:"message"`
+	AssignmentsRemoved int64  `json:"assignments_removed"`
+	UsersRemoved       int64  `json:"users_removed"`
+	ItemsRemoved       int64  `json:"items_removed"` // Zero unless items=true
+}
+
+// FinalizeReceiptResponse represents the response after finalizing or unfinalizing a receipt
+type FinalizeReceiptResponse struct {
+	Message     string  `json:"message"`
+	Finalized   bool    `json:"finalized"`
+	FinalizedAt *string `json:"finalized_at,omitempty"` // RFC 3339; omitted once unfinalized
+	Version     int     `json:"version"`
+}
+
+// PatchReceiptItemRequest represents the request body for updating a receipt item
+// Version is optional; when set (or sent as If-Match) the update fails with 409 if the item changed since
+type PatchReceiptItemRequest struct {
+	Taxable *bool `json:"taxable,omitempty"`
+	Shared  *bool `json:"shared,omitempty"`
+	Version *int  `json:"version,omitempty"`
+}
+
+// PatchReceiptItemResponse represents the response after updating a receipt item
+type PatchReceiptItemResponse struct {
+	Message string `json:"message"`
+	Version int    `json:"version"`
+}
+
+// GetReceiptOCRResponse represents the OCR text stored for a receipt
+type GetReceiptOCRResponse struct {
+	ReceiptID string `json:"receipt_id"`
+	Text      string `json:"text"`
+	Engine    string `json:"engine,omitempty"` // Vision feature used, e.g. DOCUMENT_TEXT_DETECTION; omitted for older receipts
+	Parser    string `json:"parser,omitempty"` // gemini, documentai, regex, or none (OCR only)
+}
+
+// SettlementPayment is what one user paid toward the bill
+type SettlementPayment struct {
+	UserID string  `json:"user_id"`
+	Amount float64 `json:"amount"`
+}
+
+// SettlementRequest represents the request body for settling a receipt; payments must sum to the grand total
+type SettlementRequest struct {
+	Payments []SettlementPayment `json:"payments"`
+}
+
+// SettlementBalance is one user's share of the receipt against what they paid
+type SettlementBalance struct {
+	UserID string       `json:"user_id"`
+	Name   string       `json:"name"`
+	Share  money.Amount `json:"share"` // user_total from GET /receipts/{receipt_id}
+	Paid   money.Amount `json:"paid"`
+	Net    money.Amount `json:"net"` // share - paid; positive means the user owes, negative means they are owed
+}
+
+// SettlementTransferResponse is one payment needed to settle the receipt
+type SettlementTransferResponse struct {
+	FromUserID string       `json:"from_user_id"`
+	ToUserID   string       `json:"to_user_id"`
+	Amount     money.Amount `json:"amount"`
+}
+
+// SettlementResponse represents the balances and the fewest transfers that settle everyone to zero
+type SettlementResponse struct {
+	ReceiptID  string                       `json:"receipt_id"`
+	Currency   string                       `json:"currency"`
+	GrandTotal money.Amount                 `json:"grand_total"`
+	Balances   []SettlementBalance          `json:"balances"`
+	Transfers  []SettlementTransferResponse `json:"transfers"`
+}
+
+// TripSettlementReceipt is one receipt in a cross-receipt settlement, with what each payer fronted for it
+type TripSettlementReceipt struct {
+	ReceiptID string              `json:"receipt_id"`
+	Payments  []SettlementPayment `json:"payments"` // Must sum to the receipt's grand total
+}
+
+// TripSettlementRequest represents the request body for settling several receipts at once
+type TripSettlementRequest struct {
+	Receipts []TripSettlementReceipt `json:"receipts"`
+	People   map[string]string       `json:"people,omitempty"` // Receipt user name -> canonical person; unmapped names stand for themselves
+}
+
+// TripSettlementBalance is one person's combined share across the receipts against what they paid
+type TripSettlementBalance struct {
+	Person string       `json:"person"`
+	Share  money.Amount `json:"share"`
+	Paid   money.Amount `json:"paid"`
+	Net    money.Amount `json:"net"` // share - paid; positive means the person owes, negative means they are owed
+}
+
+// TripSettlementTransfer is one payment between people needed to settle every receipt in a currency
+type TripSettlementTransfer struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Amount money.Amount `json:"amount"`
+}
+
+// TripSettlementCurrency is the settlement of every listed receipt in one currency
+type TripSettlementCurrency struct {
+	Currency   string                   `json:"currency"`
+	ReceiptIDs []string                 `json:"receipt_ids"`
+	GrandTotal money.Amount             `json:"grand_total"`
+	Balances   []TripSettlementBalance  `json:"balances"`
+	Transfers  []TripSettlementTransfer `json:"transfers"`
+}
+
+// TripSettlementResponse represents combined balances and transfers across receipts, one breakdown per currency
+type TripSettlementResponse struct {
+	Currencies []TripSettlementCurrency `json:"currencies"`
+}
+
+// UserBreakdownLine is one item a user is assigned to, with their portion of it
+type UserBreakdownLine struct {
+	ItemID   string       `json:"item_id"`
+	Name     string       `json:"name"`
+	Quantity float64      `json:"quantity"` // The user's portion of the item's quantity, e.g. 0.5 of a shared pizza
+	Amount   money.Amount `json:"amount"`
+}
+
+// UserBreakdownResponse itemizes what one user owes on a receipt
+type UserBreakdownResponse struct {
+	ReceiptID     string              `json:"receipt_id"`
+	UserID        string              `json:"user_id"`
+	Name          string              `json:"name"`
+	Color         string              `json:"color"`
+	Currency      string              `json:"currency"`
+	Items         []UserBreakdownLine `json:"items"`
+	Discount      *money.Amount       `json:"discount,omitempty"` // Share of unassigned receipt-wide discounts, already included in subtotal
+	Subtotal      money.Amount        `json:"subtotal"`           // Item amounts plus discount
+	Tax           money.Amount        `json:"tax"`
+	Tip           money.Amount        `json:"tip"`
+	ServiceCharge money.Amount        `json:"service_charge"`
+	Total         money.Amount        `json:"total"` // Same as user_total on GET /receipts/{receipt_id}
+}
+
+// SplitDiscrepancy is one part of a receipt whose amounts owed don't add up to what the receipt says
+type SplitDiscrepancy struct {
+	Component string       `json:"component"`         // items, tax, tip, or service_charge
+	ItemID    string       `json:"item_id,omitempty"` // The item that doesn't add up, for the items component
+	Expected  money.Amount `json:"expected"`          // The amount on the receipt
+	Allocated money.Amount `json:"allocated"`         // The sum of what users owe for it
+	Reason    string       `json:"reason"`
+}
+
+// VerifyReceiptResponse reports whether a receipt's split reconciles to the cent
+type VerifyReceiptResponse struct {
+	ReceiptID     string             `json:"receipt_id"`
+	Currency      string             `json:"currency"`
+	Reconciled    bool               `json:"reconciled"` // User totals sum to the grand total and no discrepancies were found
+	GrandTotal    money.Amount       `json:"grand_total"`
+	UsersTotal    money.Amount       `json:"users_total"` // Sum of every user's user_total
+	Difference    money.Amount       `json:"difference"`  // users_total - grand_total
+	Discrepancies []SplitDiscrepancy `json:"discrepancies"`
+}
+
+// RoundUpRequest represents the request body for rounding a receipt's grand total up with the tip
+// Nearest is the multiple to round up to (1 for the next whole dollar, 5 for the next $5); it defaults to 1
+type RoundUpRequest struct {
+	Nearest *float64 `json:"nearest,omitempty"`
+	Version *int     `json:"version,omitempty"`
+}
+
+// RoundUpResponse represents the response after rounding a receipt's grand total up
+type RoundUpResponse struct {
+	Message    string       `json:"message"`
+	Version    int          `json:"version"`
+	Currency   string       `json:"currency"`
+	Tip        money.Amount `json:"tip"`         // The stored tip
+	GrandTotal money.Amount `json:"grand_total"` // The rounded total, including the new tip
+}
+
+// ReceiptSummary is one receipt in GET /receipts
+type ReceiptSummary struct {
+	ID          string  `json:"id"`
+	Title       *string `json:"title,omitempty"`
+	ReceiptDate *string `json:"receipt_date,omitempty"` // YYYY-MM-DD, when the parser read a date
+	CreatedAt   string  `json:"created_at"`
+	Currency    string  `json:"currency"`
+	Status      string  `json:"status"`
+	DeletedAt   *string `json:"deleted_at,omitempty"` // Only present for deleted receipts listed with include_deleted
+}
+
+// ListReceiptsResponse represents a page of receipts
+// NextCursor is passed as the "after" query parameter to fetch the next page; omitted on the last page
+type ListReceiptsResponse struct {
+	Receipts   []ReceiptSummary `json:"receipts"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// MigrationStatus is one migration file and whether the database has applied it
+type MigrationStatus struct {
+	Version   int64   `json:"version"`
+	Path      string  `json:"path"`
+	State     string  `json:"state"`                // "applied" or "pending"
+	AppliedAt *string `json:"applied_at,omitempty"` // RFC3339; omitted when pending
+}
+
+// MigrationStatusResponse represents the database schema version and the state of every migration
+type MigrationStatusResponse struct {
+	CurrentVersion int64             `json:"current_version"`
+	Applied        int               `json:"applied"`
+	Pending        int               `json:"pending"`
+	Migrations     []MigrationStatus `json:"migrations"`
 }
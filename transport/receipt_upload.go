@@ -3,32 +3,77 @@ package transport
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"strings"
 	"time"
 
-	"splitzies/money"
 	"splitzies/persistence"
 	"splitzies/storage"
 )
 
+// Upload request bounds. The multipart parser keeps at most multipartMemoryBytes of the form in memory and
+// spills a larger image to a temp file (removed when the request ends), so the image is only held in memory
+// once, by readImageUpload. multipartOverheadBytes allows for boundaries, part headers, and small fields.
+const (
+	multipartMemoryBytes   = 1 << 20
+	multipartOverheadBytes = 1 << 20
+)
+
+// ocrLanguageHintsHeader lets an upload name the receipt's languages for OCR, overriding OCR_LANGUAGE_HINTS
+const ocrLanguageHintsHeader = "X-OCR-Language-Hints"
+
 // ocrParseResult holds the result of parsing OCR text for a receipt
 type ocrParseResult struct {
-	items       []persistence.ReceiptItemDB
-	ocrTextData *persistence.OCRTextData
-	currency    *string
-	receiptDate *time.Time
-	title       *string
-	tax         *float64
-	tip         *float64
+	items          []persistence.ReceiptItemDB
+	ocrTextData    *persistence.OCRTextData
+	currency       *string
+	receiptDate    *time.Time
+	title          *string
+	tax            *float64
+	tip            *float64
+	serviceCharge  *float64
+	taxInclusive   bool
+	extractedTotal *float64                // total printed on the receipt; only Document AI reads one
+	parser         *persistence.ParserInfo // nil when items were not parsed (OCR only)
+	parseFailed    bool                    // Gemini failed and the fallback parsers found no items
+	geminiUsage    storage.GeminiUsage     // zero when Gemini wasn't called
+}
+
+// receiptParseStatus says how reading an uploaded receipt went, for parse_status; ocr is nil when OCR
+// failed or read no text
+func receiptParseStatus(ocr *ocrParseResult) string {
+	switch {
+	case ocr == nil:
+		return persistence.ParseStatusOCRFailed
+	case len(ocr.items) > 0:
+		return persistence.ParseStatusOK
+	case ocr.parseFailed:
+		return persistence.ParseStatusParseFailed
+	default:
+		return persistence.ParseStatusNoItems
+	}
 }
 
 // parseOCRForReceipt performs OCR on image data and parses the result using Gemini.
-// Returns nil for ocrTextData and items if OCR fails or text is empty.
-func (t *Transport) parseOCRForReceipt(ctx context.Context, fileData []byte) *ocrParseResult {
-	ocrText, err := t.visionClient.PerformOCRFromBytes(ctx, fileData)
+// languageHints are passed to Vision; nil lets it auto-detect.
+// If Gemini fails, Document AI is tried when configured, then the regex parser.
+// Currency, title, tax, or tip the parser leaves out are filled in from the OCR text when written there.
+// With OCR_ONLY set, only the OCR text is returned.
+// Without an OCR engine (DISABLE_AI), OCR and parsing are skipped and an empty result is returned, so the
+// receipt is ready for items to be added by hand.
+// Returns nil if OCR fails or the text is empty.
+func (t *Transport) parseOCRForReceipt(ctx context.Context, fileData []byte, contentType string, languageHints []string) *ocrParseResult {
+	if t.ocrEngine == nil {
+		return &ocrParseResult{}
+	}
+	ocrText, err := t.ocrEngine.PerformOCRFromBytes(ctx, fileData, t.ocrFeature, languageHints)
 	if err != nil {
 		t.log.Error("OCR failed", "error", err)
 		return nil
@@ -38,18 +83,42 @@ func (t *Transport) parseOCRForReceipt(ctx context.Context, fileData []byte) *oc
 	}
 
 	result := &ocrParseResult{
-		ocrTextData: &persistence.OCRTextData{Text: ocrText},
+		ocrTextData: &persistence.OCRTextData{Text: ocrText, Engine: string(t.ocrFeature), Parser: "gemini"},
+	}
+	if t.ocrOnly {
+		result.ocrTextData.Parser = "none"
+		return result
 	}
 
 	parseResult, parseErr := storage.ParseReceiptItemsWithGemini(ctx, ocrText)
-	if parseErr != nil {
+	result.geminiUsage = parseResult.Usage
+	if parseErr == nil {
+		result.parser = &persistence.ParserInfo{Source: persistence.ParserSourceVisionGemini, ModelVersion: &parseResult.ModelVersion}
+	} else {
 		t.log.Error("Gemini parse failed", "error", parseErr)
-		parseResult.Items = storage.ExtractReceiptItemsFromText(ocrText)
-		parseResult.Currency = nil
-		parseResult.ReceiptDate = nil
-		parseResult.Title = nil
-		parseResult.Tax = nil
-		parseResult.Tip = nil
+		parseResult = storage.GeminiReceiptParseResult{}
+		if docAI := t.parseWithDocumentAI(ctx, fileData, contentType); docAI != nil {
+			result.ocrTextData.Parser = "documentai"
+			result.parser = &persistence.ParserInfo{Source: persistence.ParserSourceDocumentAI, ModelVersion: &docAI.Processor}
+			parseResult.Items = storage.FilterLowConfidenceItems(docAI.Items, t.docAIConfidence)
+			parseResult.Tax = docAI.TaxAmount
+			parseResult.ServiceCharge = docAI.ServiceCharge
+			parseResult.TaxInclusive = storage.TaxInclusiveFromText(ocrText)
+			if docAI.MerchantName != "" {
+				parseResult.Title = &docAI.MerchantName
+			}
+			result.extractedTotal = docAI.TotalAmount
+		} else {
+			result.ocrTextData.Parser = "regex"
+			result.parser = &persistence.ParserInfo{Source: persistence.ParserSourceRegex}
+			parseResult.Items = storage.ExtractReceiptItemsFromText(ocrText)
+			parseResult.TaxInclusive = storage.TaxInclusiveFromText(ocrText)
+		}
+		result.parseFailed = len(parseResult.Items) == 0
+	}
+
+	if filled := fillMissingReceiptFields(&parseResult, ocrText); len(filled) > 0 {
+		t.log.Info("Filled receipt fields from OCR text", "parser", result.ocrTextData.Parser, "fields", filled)
 	}
 
 	result.currency = parseResult.Currency
@@ -57,6 +126,8 @@ func (t *Transport) parseOCRForReceipt(ctx context.Context, fileData []byte) *oc
 	result.title = parseResult.Title
 	result.tax = parseResult.Tax
 	result.tip = parseResult.Tip
+	result.serviceCharge = parseResult.ServiceCharge
+	result.taxInclusive = parseResult.TaxInclusive
 
 	if len(parseResult.Items) > 0 {
 		result.items = make([]persistence.ReceiptItemDB, len(parseResult.Items))
@@ -66,6 +137,10 @@ func (t *Transport) parseOCRForReceipt(ctx context.Context, fileData []byte) *oc
 				Quantity:     item.Quantity,
 				TotalPrice:   item.TotalPrice,
 				PricePerItem: item.PricePerItem,
+				IsDiscount:   item.IsDiscount,
+				Category:     item.Category,
+				NeedsReview:  item.NeedsReview,
+				Confidence:   item.Confidence,
 			}
 		}
 	}
@@ -73,120 +148,269 @@ func (t *Transport) parseOCRForReceipt(ctx context.Context, fileData []byte) *oc
 	return result
 }
 
+// fillMissingReceiptFields sets the currency, title, tax, and tip the parser left nil from a regex pass over
+// the OCR text, e.g. Gemini returning items but no tax for a receipt with a plain "Tax $1.50" line. Fields the
+// parser did read, and its items, are left alone. Returns the names of the fields it filled.
+func fillMissingReceiptFields(parseResult *storage.GeminiReceiptParseResult, ocrText string) []string {
+	fields := storage.ExtractReceiptFieldsFromText(ocrText)
+	var filled []string
+	if parseResult.Currency == nil && fields.Currency != nil {
+		parseResult.Currency = fields.Currency
+		filled = append(filled, "currency")
+	}
+	if parseResult.Title == nil && fields.Title != nil {
+		parseResult.Title = fields.Title
+		filled = append(filled, "title")
+	}
+	if parseResult.Tax == nil && fields.Tax != nil {
+		parseResult.Tax = fields.Tax
+		filled = append(filled, "tax")
+	}
+	// A "Tip" line the parser read as an included service charge isn't also a tip
+	alreadyCharged := fields.Tip != nil && parseResult.ServiceCharge != nil && toCents(*fields.Tip) == toCents(*parseResult.ServiceCharge)
+	if parseResult.Tip == nil && fields.Tip != nil && !alreadyCharged {
+		parseResult.Tip = fields.Tip
+		filled = append(filled, "tip")
+	}
+	return filled
+}
+
+// parseWithDocumentAI runs the Document AI receipt processor when it is configured.
+// Returns nil if it isn't configured, fails, or finds no items.
+func (t *Transport) parseWithDocumentAI(ctx context.Context, fileData []byte, contentType string) *storage.DocumentAIReceipt {
+	if !storage.DocumentAIConfigured() {
+		return nil
+	}
+	receipt, err := storage.ProcessReceiptWithDocumentAI(ctx, fileData, contentType)
+	if err != nil {
+		t.log.Error("Document AI parse failed", "error", err)
+		return nil
+	}
+	if len(receipt.Items) == 0 {
+		return nil
+	}
+	return receipt
+}
+
 // UploadReceiptImageHandler handles receipt image uploads
 // Expects multipart/form-data with:
-//   - "image": the receipt image file
+//   - "image": the receipt image file ("file", "receipt", and "photo" are accepted too; see IMAGE_FORM_FIELDS)
+//
+// An optional X-OCR-Language-Hints header (e.g. "ja,ko") overrides OCR_LANGUAGE_HINTS for this upload.
 //
-// Returns the uploaded image URL
+// Stores the image and returns 202 with the receipt in processing status; OCR and parsing run in the background
 func (t *Transport) UploadReceiptImageHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	receiptID := persistence.GenerateReceiptID()
 
-	file, contentType, err := t.validateReceiptImageRequest(w, r)
+	file, size, contentType, err := t.validateReceiptImageRequest(w, r)
 	if err != nil {
 		return
 	}
 	defer file.Close()
 
-	fileData, err := io.ReadAll(file)
+	languageHints := t.ocrLanguageHints
+	if header := r.Header.Get(ocrLanguageHintsHeader); header != "" {
+		languageHints, err = parseLanguageHints(header)
+		if err != nil {
+			http.Error(w, NewValidationError(ocrLanguageHintsHeader, err.Error()).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	fileData, digest, err := readImageUpload(file, size, t.maxUploadBytes)
 	if err != nil {
+		if errors.Is(err, errImageTooLarge) {
+			http.Error(w, NewValidationError("image", fmt.Sprintf("image file too large (max %d bytes)", t.maxUploadBytes)).Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to read image file: %v", err), http.StatusInternalServerError)
 		return
 	}
+	image := &persistence.ImageMetadata{SizeBytes: int64(len(fileData)), SHA256: digest}
 
-	imageURL, err := t.gcsClient.UploadReceiptImageFromReader(ctx, bytes.NewReader(fileData), receiptID, contentType)
+	// Re-uploading the same photo returns the receipt it already made rather than paying for OCR/Gemini again
+	existing, err := t.persistenceClient.GetReceiptByImageHash(ctx, image.SHA256)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to upload image: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to check for duplicate image: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		t.log.Info("Duplicate image upload", "receipt_id", existing.ID, "client_ip", t.clientIP(r))
+		t.writeDuplicateUpload(ctx, w, existing)
+		return
+	}
+
+	// HEIC/HEIF and WebP are transcoded to JPEG, and JPEGs turned upright without EXIF, before both OCR and GCS storage
+	fileData, contentType, err = storage.NormalizeReceiptImage(fileData, contentType)
+	if err != nil {
+		http.Error(w, NewValidationError("image", err.Error()).Error(), http.StatusBadRequest)
 		return
 	}
 
-	var parsedItems []persistence.ReceiptItemDB
-	var ocrTextData *persistence.OCRTextData
-	var currency, title *string
-	var receiptDate *time.Time
-	var tax, tip *float64
+	// Measured after normalization so HEIC/WebP uploads get dimensions too, and rotated photos their upright ones
+	if width, height, ok := storage.ImageDimensions(fileData); ok {
+		image.Width, image.Height = &width, &height
+	} else {
+		t.log.Warn("Could not read image dimensions", "receipt_id", receiptID, "content_type", contentType)
+	}
 
-	if ocr := t.parseOCRForReceipt(ctx, fileData); ocr != nil {
-		parsedItems = ocr.items
-		ocrTextData = ocr.ocrTextData
-		currency = ocr.currency
-		receiptDate = ocr.receiptDate
-		title = ocr.title
-		tax = ocr.tax
-		tip = ocr.tip
+	imageURL, ok := t.storeReceiptImage(r.Context(), w, receiptID, fileData, contentType)
+	if !ok {
+		return
 	}
 
-	savedReceipt, err := persistence.SaveReceipt(parsedItems, &imageURL, ocrTextData, currency, receiptDate, title, tax, tip)
+	var storedURL *string
+	if imageURL != "" {
+		storedURL = &imageURL
+	}
+	savedReceipt, err := t.persistenceClient.CreateProcessingReceipt(ctx, receiptID, storedURL, image)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to save receipt: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	response := buildUploadReceiptResponse(savedReceipt, imageURL, ocrTextData, currency, tax, tip)
+	t.log.Info("Receipt image uploaded", "receipt_id", savedReceipt.ID, "size_bytes", image.SizeBytes, "client_ip", t.clientIP(r))
+
+	// OCR and parsing take seconds; clients poll GET /receipts/{receipt_id} or use the webhook to learn when it's ready
+	t.workers.Add(1)
+	go func() {
+		defer t.workers.Done()
+		t.processReceipt(savedReceipt.ID, fileData, contentType, languageHints)
+	}()
+
+	response := UploadReceiptResponse{
+		ReceiptID: savedReceipt.ID,
+		ImageURL:  imageURL,
+		Status:    savedReceipt.Status,
+		Items:     []ReceiptItem{},
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Location", "/receipts/"+savedReceipt.ID)
+	w.WriteHeader(http.StatusAccepted)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		fmt.Printf("Failed to encode response: %v\n", err)
 	}
 }
 
-func buildUploadReceiptResponse(savedReceipt *persistence.Receipt, imageURL string, ocrTextData *persistence.OCRTextData, currency *string, tax, tip *float64) UploadReceiptResponse {
-	responseItems := make([]ReceiptItem, len(savedReceipt.Items))
-	for i, item := range savedReceipt.Items {
-		responseItems[i] = ReceiptItem{
-			ID:           item.ID,
-			Name:         item.Name,
-			Quantity:     item.Quantity,
-			TotalPrice:   money.Ptr(&item.TotalPrice, currency),
-			PricePerItem: money.Ptr(&item.PricePerItem, currency),
+// storeReceiptImage uploads an image to the image store and returns its URL. Without an image store
+// (DISABLE_AI for local development) the image isn't stored and the URL is empty.
+// On failure it writes the error response and returns false: 503 with Retry-After when storage is briefly
+// unavailable, since that is worth retrying, and 500 otherwise.
+func (t *Transport) storeReceiptImage(ctx context.Context, w http.ResponseWriter, receiptID string, fileData []byte, contentType string) (string, bool) {
+	if t.imageStore == nil {
+		return "", true
+	}
+	imageURL, err := t.imageStore.UploadReceiptImageFromReader(ctx, bytes.NewReader(fileData), receiptID, contentType)
+	if err != nil {
+		var uploadErr *storage.UploadError
+		if errors.As(err, &uploadErr) && uploadErr.Transient {
+			t.log.Warn("Image upload failed transiently", "receipt_id", receiptID, "attempts", uploadErr.Attempts, "error", err)
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, fmt.Sprintf("Image storage is temporarily unavailable, try again: %v", err), http.StatusServiceUnavailable)
+			return "", false
 		}
+		http.Error(w, fmt.Sprintf("Failed to upload image: %v", err), http.StatusInternalServerError)
+		return "", false
 	}
+	return imageURL, true
+}
 
-	response := UploadReceiptResponse{
-		ReceiptID: savedReceipt.ID,
-		ImageURL:  imageURL,
-		Items:     responseItems,
+// processReceipt runs OCR and parsing for an uploaded receipt, then stores the items and marks it ready,
+// or marks it failed if no text could be read. Sends the receipt.processed webhook either way.
+// OCR and parsing together get parseTimeout; when it runs out before any text or items were read, the parse
+// status is timeout rather than ocr_failed or parse_failed, so the receipt never stays processing.
+func (t *Transport) processReceipt(receiptID string, fileData []byte, contentType string, languageHints []string) {
+	ctx := context.Background()
+	event := ReceiptProcessedEvent{Event: "receipt.processed", ReceiptID: receiptID}
+
+	parseCtx, cancel := context.WithTimeout(ctx, t.parseTimeout)
+	ocr := t.parseOCRForReceipt(parseCtx, fileData, contentType, languageHints)
+	timedOut := errors.Is(parseCtx.Err(), context.DeadlineExceeded)
+	cancel()
+	event.ParseStatus = receiptParseStatus(ocr)
+	if timedOut && (event.ParseStatus == persistence.ParseStatusOCRFailed || event.ParseStatus == persistence.ParseStatusParseFailed) {
+		t.log.Warn("Receipt parse timed out", "receipt_id", receiptID, "timeout", t.parseTimeout)
+		event.ParseStatus = persistence.ParseStatusTimeout
+	}
+	if ocr == nil {
+		t.failReceipt(ctx, receiptID, &event.ParseStatus)
+		event.Status = persistence.ReceiptStatusFailed
+		t.webhook.notify(ctx, event)
+		return
 	}
-	if ocrTextData != nil {
-		response.OCRText = &ocrTextData.Text
+
+	t.recordGeminiUsage(receiptID, ocr.geminiUsage, ocr.parser != nil && ocr.parser.Source == persistence.ParserSourceVisionGemini)
+
+	parsedItems, truncated := t.capReceiptItems(receiptID, ocr.items)
+	items, err := t.persistenceClient.CompleteReceiptProcessing(ctx, receiptID, parsedItems, ocr.ocrTextData, ocr.currency, ocr.receiptDate, ocr.title, ocr.tax, ocr.tip, ocr.serviceCharge, ocr.extractedTotal, ocr.taxInclusive, ocr.parser, event.ParseStatus, truncated)
+	if err != nil {
+		t.log.Error("Failed to save parsed receipt", "receipt_id", receiptID, "error", err)
+		t.failReceipt(ctx, receiptID, nil)
+		event.Status = persistence.ReceiptStatusFailed
+		t.webhook.notify(ctx, event)
+		return
 	}
-	if tax != nil {
-		a := money.NewAmount(*tax, currency)
-		response.Tax = &a
+
+	t.log.Info("Receipt processed", "receipt_id", receiptID, "items", len(items), "parse_status", event.ParseStatus)
+	event.Status = persistence.ReceiptStatusReady
+	event.ItemCount = len(items)
+	event.ItemsTruncated = truncated
+	t.webhook.notify(ctx, event)
+}
+
+// capReceiptItems keeps the first maxReceiptItems parsed items, so a bad parse of a long or doctored image
+// can't store thousands of rows. Reports whether any were dropped.
+func (t *Transport) capReceiptItems(receiptID string, items []persistence.ReceiptItemDB) ([]persistence.ReceiptItemDB, bool) {
+	if len(items) <= t.maxReceiptItems {
+		return items, false
 	}
-	if tip != nil {
-		a := money.NewAmount(*tip, currency)
-		response.Tip = &a
+	t.log.Warn("Parsed too many items, keeping the first", "receipt_id", receiptID, "parsed", len(items), "max", t.maxReceiptItems)
+	return items[:t.maxReceiptItems], true
+}
+
+// failReceipt marks a receipt as failed with parseStatus, or keeping its parse status when nil, logging if even
+// that does not succeed
+func (t *Transport) failReceipt(ctx context.Context, receiptID string, parseStatus *string) {
+	if err := t.persistenceClient.FailReceiptProcessing(ctx, receiptID, parseStatus); err != nil {
+		t.log.Error("Failed to mark receipt as failed", "receipt_id", receiptID, "error", err)
 	}
-	return response
 }
 
-func (t *Transport) validateReceiptImageRequest(w http.ResponseWriter, r *http.Request) (file io.ReadCloser, contentType string, err error) {
-	if r.Method != http.MethodPost {
-		err = NewInvalidMethodError(r.Method)
-		http.Error(w, err.Error(), http.StatusMethodNotAllowed)
-		return nil, "", err
+func (t *Transport) validateReceiptImageRequest(w http.ResponseWriter, r *http.Request) (file io.ReadCloser, size int64, contentType string, err error) {
+	if err := checkMultipartContentType(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return nil, 0, "", err
 	}
 
-	err = r.ParseMultipartForm(10 << 20) // 10MB
+	// Stop reading an oversized body rather than spooling all of it to disk before the size check below
+	r.Body = http.MaxBytesReader(w, r.Body, t.maxUploadBytes+multipartOverheadBytes)
+	err = r.ParseMultipartForm(multipartMemoryBytes)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			validationErr := NewValidationError("image", fmt.Sprintf("image file too large (max %d bytes)", t.maxUploadBytes))
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return nil, 0, "", validationErr
+		}
 		validationErr := NewValidationError("form", fmt.Sprintf("failed to parse multipart form: %v", err))
 		http.Error(w, validationErr.Error(), http.StatusBadRequest)
-		return nil, "", validationErr
+		return nil, 0, "", validationErr
 	}
 
-	file, header, err := r.FormFile("image")
+	file, header, err := formFileByNames(r, t.imageFormFields)
 	if err != nil {
-		validationErr := NewValidationError("image", fmt.Sprintf("failed to get image file: %v", err))
+		validationErr := NewValidationError("image", err.Error())
 		http.Error(w, validationErr.Error(), http.StatusBadRequest)
-		return nil, "", validationErr
+		return nil, 0, "", validationErr
 	}
 
-	if header.Size > 10<<20 {
-		validationErr := NewValidationError("image", "image file too large (max 10MB)")
+	if header.Size > t.maxUploadBytes {
+		file.Close()
+		validationErr := NewValidationError("image", fmt.Sprintf("image file too large (max %d bytes)", t.maxUploadBytes))
 		http.Error(w, validationErr.Error(), http.StatusBadRequest)
-		return nil, "", validationErr
+		return nil, 0, "", validationErr
 	}
 
 	contentType = header.Header.Get("Content-Type")
@@ -197,12 +421,81 @@ func (t *Transport) validateReceiptImageRequest(w http.ResponseWriter, r *http.R
 			"image/png":  true,
 			"image/gif":  true,
 			"image/webp": true,
+			"image/heic": true,
+			"image/heif": true,
 		}
 		if !validTypes[contentType] {
 			validationErr := NewValidationError("image", fmt.Sprintf("invalid image type: %s", contentType))
 			http.Error(w, validationErr.Error(), http.StatusBadRequest)
-			return nil, "", validationErr
+			file.Close()
+			return nil, 0, "", validationErr
+		}
+	}
+	return file, header.Size, contentType, nil
+}
+
+// errImageTooLarge is returned by readImageUpload when the image is longer than allowed
+var errImageTooLarge = errors.New("image file too large")
+
+// readImageUpload reads an uploaded image, hashing it on the way in, into a buffer allocated once from the
+// part's declared size. Returns the image and its hex SHA-256; reading stops past maxBytes.
+func readImageUpload(file io.Reader, size, maxBytes int64) ([]byte, string, error) {
+	hash := sha256.New()
+	// bytes.MinRead of headroom lets ReadFrom see EOF without growing the buffer
+	buf := bytes.NewBuffer(make([]byte, 0, min(max(size, 0), maxBytes)+bytes.MinRead))
+	n, err := buf.ReadFrom(io.TeeReader(io.LimitReader(file, maxBytes+1), hash))
+	if err != nil {
+		return nil, "", err
+	}
+	if n > maxBytes {
+		return nil, "", errImageTooLarge
+	}
+	return buf.Bytes(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// formFileByNames returns the file in the first of names that the parsed multipart form has a file for.
+// When none is present the error lists every accepted name.
+func formFileByNames(r *http.Request, names []string) (multipart.File, *multipart.FileHeader, error) {
+	for _, name := range names {
+		file, header, err := r.FormFile(name)
+		if errors.Is(err, http.ErrMissingFile) {
+			continue
 		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get image file %q: %w", name, err)
+		}
+		return file, header, nil
+	}
+	return nil, nil, fmt.Errorf("no image file found; send it in one of these form fields: %s", strings.Join(names, ", "))
+}
+
+// writeDuplicateUpload responds to an upload of an image that already has a receipt with that receipt, flagged
+// with duplicate_of. Items are included once it's ready.
+func (t *Transport) writeDuplicateUpload(ctx context.Context, w http.ResponseWriter, existing *persistence.Receipt) {
+	response := UploadReceiptResponse{
+		ReceiptID:   existing.ID,
+		Status:      existing.Status,
+		ParseStatus: existing.ParseStatus,
+		Items:       []ReceiptItem{},
+		DuplicateOf: &existing.ID,
+	}
+	if existing.ImageURL != nil {
+		response.ImageURL = *existing.ImageURL
+	}
+	items, err := t.persistenceClient.GetReceiptItems(ctx, existing.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get receipt items: %v", err), http.StatusInternalServerError)
+		return
+	}
+	currency := existing.Currency
+	if currency == nil || *currency == "" {
+		currency = &defaultUSD
+	}
+	response.Items = itemsToReceiptItems(items, currency)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/receipts/"+existing.ID)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
 	}
-	return file, contentType, nil
 }
@@ -0,0 +1,464 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"splitzies/persistence"
+	"splitzies/storage"
+)
+
+func TestValidateReceiptImageRequestFieldNames(t *testing.T) {
+	tr := &Transport{maxUploadBytes: defaultMaxUploadBytes, imageFormFields: defaultImageFormFields}
+	tests := []struct {
+		field      string
+		wantStatus int
+	}{
+		{field: "image", wantStatus: http.StatusOK},
+		{field: "file", wantStatus: http.StatusOK},
+		{field: "receipt", wantStatus: http.StatusOK},
+		{field: "photo", wantStatus: http.StatusOK},
+		{field: "upload", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", `form-data; name="`+tt.field+`"; filename="receipt.png"`)
+			header.Set("Content-Type", "image/png")
+			part, err := form.CreatePart(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte("png bytes"))
+			form.Close()
+
+			r := httptest.NewRequest(http.MethodPost, "/receipts/image", &body)
+			r.Header.Set("Content-Type", form.FormDataContentType())
+			w := httptest.NewRecorder()
+			file, _, contentType, err := tr.validateReceiptImageRequest(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !strings.Contains(w.Body.String(), "image, file, receipt, photo") {
+					t.Errorf("error %q does not list the accepted field names", w.Body.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateReceiptImageRequest: %v", err)
+			}
+			defer file.Close()
+			data, _ := io.ReadAll(file)
+			if string(data) != "png bytes" || contentType != "image/png" {
+				t.Errorf("got %q as %q", data, contentType)
+			}
+		})
+	}
+}
+
+func TestReadImageUpload(t *testing.T) {
+	data, digest, err := readImageUpload(strings.NewReader("png bytes"), 9, 100)
+	if err != nil {
+		t.Fatalf("readImageUpload: %v", err)
+	}
+	// sha256("png bytes")
+	if string(data) != "png bytes" || digest != "d013614dc14a37ee20fe92005737ab7d3427e7e93580ad56ef8a42205e7f7a4e" {
+		t.Errorf("got %q with digest %s", data, digest)
+	}
+
+	// A part larger than it claimed is still cut off at the limit
+	if _, _, err := readImageUpload(strings.NewReader(strings.Repeat("x", 101)), 10, 100); !errors.Is(err, errImageTooLarge) {
+		t.Errorf("err = %v, want errImageTooLarge", err)
+	}
+}
+
+func TestReceiptParseStatus(t *testing.T) {
+	ocrText := &persistence.OCRTextData{Text: "TOTAL 12.00"}
+	tests := []struct {
+		name string
+		ocr  *ocrParseResult
+		want string
+	}{
+		{name: "OCR failed or read no text", ocr: nil, want: persistence.ParseStatusOCRFailed},
+		{name: "parsed items", ocr: &ocrParseResult{ocrTextData: ocrText, items: []persistence.ReceiptItemDB{{Name: "Burger", Quantity: 1}}}, want: persistence.ParseStatusOK},
+		{name: "every parser failed", ocr: &ocrParseResult{ocrTextData: ocrText, parseFailed: true}, want: persistence.ParseStatusParseFailed},
+		{name: "parsed but no items", ocr: &ocrParseResult{ocrTextData: ocrText, parser: &persistence.ParserInfo{Source: persistence.ParserSourceVisionGemini}}, want: persistence.ParseStatusNoItems},
+		{name: "OCR only", ocr: &ocrParseResult{ocrTextData: &persistence.OCRTextData{Text: "TOTAL 12.00", Parser: "none"}}, want: persistence.ParseStatusNoItems},
+	}
+	for _, tt := range tests {
+		if got := receiptParseStatus(tt.ocr); got != tt.want {
+			t.Errorf("%s: parse status = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseOCRForReceiptSkipsWithoutVision(t *testing.T) {
+	// With DISABLE_AI there is no Vision client; the receipt should become ready with no items, not fail
+	ocr := (&Transport{log: slog.New(slog.DiscardHandler)}).parseOCRForReceipt(context.Background(), []byte("image"), "image/jpeg", nil)
+	if ocr == nil || ocr.ocrTextData != nil || len(ocr.items) != 0 {
+		t.Fatalf("ocr = %+v, want an empty result", ocr)
+	}
+	if got := receiptParseStatus(ocr); got != persistence.ParseStatusNoItems {
+		t.Errorf("parse status = %q, want %q", got, persistence.ParseStatusNoItems)
+	}
+}
+
+func TestParseOCRForReceiptWithFakeOCR(t *testing.T) {
+	tests := []struct {
+		name       string
+		engine     *fakeOCREngine
+		wantText   string
+		wantStatus string
+	}{
+		{name: "text read", engine: &fakeOCREngine{text: "BURGER 12.99\nTOTAL 12.99"}, wantText: "BURGER 12.99\nTOTAL 12.99", wantStatus: persistence.ParseStatusNoItems},
+		{name: "OCR error", engine: &fakeOCREngine{err: errors.New("vision unavailable")}, wantStatus: persistence.ParseStatusOCRFailed},
+		{name: "no text", engine: &fakeOCREngine{}, wantStatus: persistence.ParseStatusOCRFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// OCR only, so the result doesn't depend on reaching Gemini
+			transport := &Transport{log: slog.New(slog.DiscardHandler), ocrEngine: tt.engine, ocrOnly: true}
+			ocr := transport.parseOCRForReceipt(context.Background(), []byte("image"), "image/jpeg", nil)
+
+			if got := receiptParseStatus(ocr); got != tt.wantStatus {
+				t.Errorf("parse status = %q, want %q", got, tt.wantStatus)
+			}
+			if tt.wantText == "" {
+				if ocr != nil {
+					t.Errorf("ocr = %+v, want nil", ocr)
+				}
+				return
+			}
+			if ocr == nil || ocr.ocrTextData == nil || ocr.ocrTextData.Text != tt.wantText || ocr.ocrTextData.Parser != "none" {
+				t.Errorf("ocr = %+v, want text %q with no parser", ocr, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestFillMissingReceiptFields(t *testing.T) {
+	ocrText := "JOE'S DINER\nBurger 12.99\nFries 4.01\nSubtotal 17.00\nTax $1.50\nTip: $3.00\nTotal USD 21.50"
+	title := "Joe's Diner"
+
+	// Gemini read the items and title but not the tax, tip, or currency
+	parseResult := storage.GeminiReceiptParseResult{
+		Items: []storage.ReceiptItemParsed{
+			{Name: "Burger", Quantity: 1, TotalPrice: 12.99, PricePerItem: 12.99},
+			{Name: "French Fries", Quantity: 1, TotalPrice: 4.01, PricePerItem: 4.01},
+		},
+		Title: &title,
+	}
+	filled := fillMissingReceiptFields(&parseResult, ocrText)
+
+	if !reflect.DeepEqual(filled, []string{"currency", "tax", "tip"}) {
+		t.Errorf("filled = %v, want [currency tax tip]", filled)
+	}
+	if parseResult.Tax == nil || *parseResult.Tax != 1.50 {
+		t.Errorf("tax = %v, want 1.50", parseResult.Tax)
+	}
+	if parseResult.Tip == nil || *parseResult.Tip != 3.00 {
+		t.Errorf("tip = %v, want 3.00", parseResult.Tip)
+	}
+	if parseResult.Currency == nil || *parseResult.Currency != "USD" {
+		t.Errorf("currency = %v, want USD", parseResult.Currency)
+	}
+	if *parseResult.Title != "Joe's Diner" {
+		t.Errorf("title = %q, want Gemini's", *parseResult.Title)
+	}
+	if len(parseResult.Items) != 2 || parseResult.Items[1].Name != "French Fries" {
+		t.Errorf("items = %+v, want Gemini's items unchanged", parseResult.Items)
+	}
+}
+
+func TestFillMissingReceiptFieldsKeepsParsedValues(t *testing.T) {
+	tax, serviceCharge, eur := 2.00, 3.00, "EUR"
+	parseResult := storage.GeminiReceiptParseResult{Tax: &tax, ServiceCharge: &serviceCharge, Currency: &eur}
+
+	// The parser read the tip line as an included service charge, so it isn't also a tip
+	filled := fillMissingReceiptFields(&parseResult, "Burger 12.99\nTax $1.50\nTip: $3.00\nTotal USD 17.49")
+
+	if len(filled) != 0 {
+		t.Errorf("filled = %v, want none", filled)
+	}
+	if *parseResult.Tax != 2.00 || *parseResult.Currency != "EUR" || parseResult.Tip != nil {
+		t.Errorf("tax = %v, currency = %v, tip = %v; want the parsed values and no tip", *parseResult.Tax, *parseResult.Currency, parseResult.Tip)
+	}
+}
+
+func TestStoreReceiptImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		store      *fakeImageStore
+		wantOK     bool
+		wantStatus int
+	}{
+		{name: "stored", store: &fakeImageStore{}, wantOK: true, wantStatus: http.StatusOK},
+		{name: "storage briefly unavailable", store: &fakeImageStore{err: &storage.UploadError{Err: errors.New("503"), Transient: true, Attempts: 3}}, wantStatus: http.StatusServiceUnavailable},
+		{name: "storage failed", store: &fakeImageStore{err: errors.New("permission denied")}, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			transport := &Transport{log: slog.New(slog.DiscardHandler), imageStore: tt.store}
+			imageURL, ok := transport.storeReceiptImage(context.Background(), w, "r1", []byte("image"), "image/jpeg")
+
+			if ok != tt.wantOK || w.Code != tt.wantStatus {
+				t.Fatalf("ok = %v, status = %d; want %v, %d", ok, w.Code, tt.wantOK, tt.wantStatus)
+			}
+			if tt.wantOK && (imageURL == "" || string(tt.store.images["r1"]) != "image") {
+				t.Errorf("image URL = %q, stored = %q; want the image stored", imageURL, tt.store.images["r1"])
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After not set for a transient failure")
+			}
+		})
+	}
+
+	// No image store (DISABLE_AI without GCS): nothing is stored, and the upload goes on
+	imageURL, ok := (&Transport{}).storeReceiptImage(context.Background(), httptest.NewRecorder(), "r1", []byte("image"), "image/jpeg")
+	if !ok || imageURL != "" {
+		t.Errorf("without an image store: (%q, %v), want (\"\", true)", imageURL, ok)
+	}
+}
+
+// receiptUploadRequest builds a multipart upload of a small PNG; seed varies the pixels, and so the image hash
+func receiptUploadRequest(t *testing.T, seed uint8) *http.Request {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	img.Pix[0] = seed
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="image"; filename="receipt.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(part, img); err != nil {
+		t.Fatal(err)
+	}
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/receipts/image", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+// newUploadTransport builds a Transport over the fakes with the default upload settings, skipping parsing
+func newUploadTransport(store *fakeReceiptStore, images *fakeImageStore, ocr *fakeOCREngine) *Transport {
+	return &Transport{
+		log:               slog.New(slog.DiscardHandler),
+		persistenceClient: store,
+		imageStore:        images,
+		ocrEngine:         ocr,
+		maxUploadBytes:    defaultMaxUploadBytes,
+		maxReceiptItems:   defaultMaxReceiptItems,
+		parseTimeout:      defaultParseTimeout,
+		imageFormFields:   defaultImageFormFields,
+		ocrOnly:           true,
+	}
+}
+
+// uploadReceipt posts a receipt image and waits for background processing, returning the 202 response
+func uploadReceipt(t *testing.T, transport *Transport) UploadReceiptResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	transport.UploadReceiptImageHandler(w, receiptUploadRequest(t, 1))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202 (%s)", w.Code, w.Body.String())
+	}
+	var response UploadReceiptResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != persistence.ReceiptStatusProcessing || response.ImageURL == "" {
+		t.Errorf("response = %+v, want a processing receipt with its image URL", response)
+	}
+	transport.Wait()
+	return response
+}
+
+func TestUploadReceiptImageHandler(t *testing.T) {
+	store, images := &fakeReceiptStore{}, &fakeImageStore{}
+	response := uploadReceipt(t, newUploadTransport(store, images, &fakeOCREngine{text: "BURGER 12.99\nTOTAL 12.99"}))
+
+	if len(images.images[response.ReceiptID]) == 0 {
+		t.Error("image not stored under the receipt ID")
+	}
+	receipt := store.receipts[response.ReceiptID]
+	if receipt.Status != persistence.ReceiptStatusReady || receipt.ParseStatus == nil || *receipt.ParseStatus != persistence.ParseStatusNoItems {
+		t.Errorf("after processing: status = %q, parse status = %v; want ready, %q", receipt.Status, receipt.ParseStatus, persistence.ParseStatusNoItems)
+	}
+	if receipt.OCRText == nil || !strings.Contains(receipt.OCRText.Text, "BURGER") {
+		t.Errorf("OCR text = %+v, want the text the OCR engine read", receipt.OCRText)
+	}
+}
+
+func TestUploadReceiptImageHandlerOCRFailure(t *testing.T) {
+	store, images := &fakeReceiptStore{}, &fakeImageStore{}
+	response := uploadReceipt(t, newUploadTransport(store, images, &fakeOCREngine{err: errors.New("vision unavailable")}))
+
+	// The upload itself succeeded, so the image is kept for a retry
+	if len(images.images[response.ReceiptID]) == 0 {
+		t.Error("image not stored under the receipt ID")
+	}
+	receipt := store.receipts[response.ReceiptID]
+	if receipt.Status != persistence.ReceiptStatusFailed || receipt.ParseStatus == nil || *receipt.ParseStatus != persistence.ParseStatusOCRFailed {
+		t.Errorf("after processing: status = %q, parse status = %v; want failed, %q", receipt.Status, receipt.ParseStatus, persistence.ParseStatusOCRFailed)
+	}
+	if receipt.OCRText != nil || len(receipt.Items) != 0 {
+		t.Errorf("OCR text = %+v, items = %+v; want neither after a failed read", receipt.OCRText, receipt.Items)
+	}
+}
+
+func TestProcessReceiptPersistsParseStatus(t *testing.T) {
+	// Without Gemini credentials or a Document AI processor, parsing falls through to the regex parser
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS_JSON", "")
+	t.Setenv("DOCUMENT_AI_PROCESSOR_ID", "")
+	tests := []struct {
+		name            string
+		ocr             *fakeOCREngine
+		ocrOnly         bool
+		wantStatus      string
+		wantParseStatus string
+	}{
+		{name: "every parser failed", ocr: &fakeOCREngine{text: "THANK YOU FOR VISITING"}, wantStatus: persistence.ReceiptStatusReady, wantParseStatus: persistence.ParseStatusParseFailed},
+		{name: "OCR only", ocr: &fakeOCREngine{text: "BURGER 12.99\nTOTAL 12.99"}, ocrOnly: true, wantStatus: persistence.ReceiptStatusReady, wantParseStatus: persistence.ParseStatusNoItems},
+		{name: "OCR failed", ocr: &fakeOCREngine{err: errors.New("vision unavailable")}, wantStatus: persistence.ReceiptStatusFailed, wantParseStatus: persistence.ParseStatusOCRFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const receiptID = "01HQ3V3KAWJ8VZ1R2Q5B6Y9X0T"
+			store := &fakeReceiptStore{}
+			if _, err := store.CreateProcessingReceipt(context.Background(), receiptID, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			transport := &Transport{
+				log:               slog.New(slog.DiscardHandler),
+				persistenceClient: store,
+				ocrEngine:         tt.ocr,
+				maxReceiptItems:   defaultMaxReceiptItems,
+				parseTimeout:      defaultParseTimeout,
+				ocrOnly:           tt.ocrOnly,
+			}
+
+			// A receipt that was read but not parsed is completed with CompleteReceiptProcessing; only a failed read fails it
+			transport.processReceipt(receiptID, []byte("image"), "image/jpeg", nil)
+			snapshot, err := store.GetReceiptSnapshot(context.Background(), receiptID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snapshot.Status != tt.wantStatus || snapshot.ParseStatus == nil || *snapshot.ParseStatus != tt.wantParseStatus {
+				t.Errorf("status = %q, parse status = %v; want %q, %q", snapshot.Status, snapshot.ParseStatus, tt.wantStatus, tt.wantParseStatus)
+			}
+			if len(snapshot.Items) != 0 {
+				t.Errorf("items = %+v, want none", snapshot.Items)
+			}
+		})
+	}
+}
+
+func TestUploadReceiptImageHandlerTimesOut(t *testing.T) {
+	store := &fakeReceiptStore{}
+	transport := &Transport{
+		log:               slog.New(slog.DiscardHandler),
+		persistenceClient: store,
+		ocrEngine:         &fakeOCREngine{text: "TOTAL 4.50", delay: time.Minute},
+		maxUploadBytes:    defaultMaxUploadBytes,
+		maxReceiptItems:   defaultMaxReceiptItems,
+		imageFormFields:   defaultImageFormFields,
+		parseTimeout:      20 * time.Millisecond,
+		ocrOnly:           true,
+	}
+
+	w := httptest.NewRecorder()
+	transport.UploadReceiptImageHandler(w, receiptUploadRequest(t, 1))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202 (%s)", w.Code, w.Body.String())
+	}
+	var response UploadReceiptResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	// OCR would take a minute; the receipt should be failed with a timeout well before then
+	start := time.Now()
+	transport.Wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("processing took %v, want it cut off by the 20ms parse timeout", elapsed)
+	}
+	snapshot, err := store.GetReceiptSnapshot(context.Background(), response.ReceiptID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Status != persistence.ReceiptStatusFailed || snapshot.ParseStatus == nil || *snapshot.ParseStatus != persistence.ParseStatusTimeout {
+		t.Errorf("status = %q, parse status = %v; want %q, %q", snapshot.Status, snapshot.ParseStatus, persistence.ReceiptStatusFailed, persistence.ParseStatusTimeout)
+	}
+}
+
+func TestUploadReceiptImageHandlerReturnsDuplicate(t *testing.T) {
+	transport := &Transport{
+		log:               slog.New(slog.DiscardHandler),
+		persistenceClient: &fakeReceiptStore{},
+		ocrEngine:         &fakeOCREngine{text: "TOTAL 4.50"},
+		maxUploadBytes:    defaultMaxUploadBytes,
+		maxReceiptItems:   defaultMaxReceiptItems,
+		parseTimeout:      defaultParseTimeout,
+		imageFormFields:   defaultImageFormFields,
+		ocrOnly:           true,
+	}
+	upload := func(seed uint8) UploadReceiptResponse {
+		w := httptest.NewRecorder()
+		transport.UploadReceiptImageHandler(w, receiptUploadRequest(t, seed))
+		transport.Wait()
+		var response UploadReceiptResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("decode %q: %v", w.Body.String(), err)
+		}
+		return response
+	}
+
+	first := upload(1)
+	again := upload(1)
+	if again.ReceiptID != first.ReceiptID || again.DuplicateOf == nil || *again.DuplicateOf != first.ReceiptID {
+		t.Errorf("re-upload = %+v, want receipt %s flagged as a duplicate", again, first.ReceiptID)
+	}
+	if again.Status != persistence.ReceiptStatusReady {
+		t.Errorf("re-upload status = %q, want %q", again.Status, persistence.ReceiptStatusReady)
+	}
+	if other := upload(2); other.ReceiptID == first.ReceiptID || other.DuplicateOf != nil {
+		t.Errorf("a different image = %+v, want a new receipt", other)
+	}
+}
+
+func TestCapReceiptItems(t *testing.T) {
+	transport := &Transport{log: slog.New(slog.DiscardHandler), maxReceiptItems: 2}
+	items := []persistence.ReceiptItemDB{{Name: "Burger"}, {Name: "Fries"}, {Name: "Soda"}}
+
+	got, truncated := transport.capReceiptItems("r1", items)
+	if !truncated || len(got) != 2 || got[1].Name != "Fries" {
+		t.Errorf("3 items: got %+v, truncated = %v; want the first 2, truncated", got, truncated)
+	}
+	got, truncated = transport.capReceiptItems("r1", items[:2])
+	if truncated || len(got) != 2 {
+		t.Errorf("2 items: got %+v, truncated = %v; want both, not truncated", got, truncated)
+	}
+}
@@ -0,0 +1,162 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+// verifySplit checks that a receipt's split reconciles: the user totals of GET /receipts/{receipt_id} sum to
+// its grand total to the cent. Items, tax, tip, and service charge are each checked on their own, so any gap
+// comes with the part of the receipt it is in and why.
+func verifySplit(snapshot *persistence.ReceiptSnapshot) VerifyReceiptResponse {
+	currency := snapshot.Currency
+	if currency == nil || *currency == "" {
+		currency = &defaultUSD
+	}
+	split := ComputeBillSplit(snapshot.Users, snapshot.Items, splitAssignments(snapshot), snapshot.Rounding)
+	allocation := AllocateTaxTip(snapshot.Users, split, &snapshot.TaxTip)
+	receipt := ToGetReceiptResponse(snapshot.ReceiptID, snapshot.Users, snapshot.Items, snapshot.Assignments, split, &snapshot.TaxTip, currency)
+
+	amount := func(cents int) money.Amount {
+		return money.NewAmount(float64(cents)/100, currency)
+	}
+	discrepancies := []SplitDiscrepancy{}
+	report := func(component, itemID string, expectedCents, allocatedCents int, reason string) {
+		discrepancies = append(discrepancies, SplitDiscrepancy{
+			Component: component,
+			ItemID:    itemID,
+			Expected:  amount(expectedCents),
+			Allocated: amount(allocatedCents),
+			Reason:    reason,
+		})
+	}
+
+	onReceipt := make(map[string]bool, len(snapshot.Users))
+	for _, u := range snapshot.Users {
+		onReceipt[u.ID] = true
+	}
+	itemCents := make(map[string]int)
+	assigned := make(map[string]bool)
+	strangers := make(map[string]bool) // items assigned to users who are not on the receipt
+	for key, value := range split.AmountByUserItem {
+		userID, itemID, _ := strings.Cut(key, ":")
+		assigned[itemID] = true
+		if !onReceipt[userID] {
+			strangers[itemID] = true
+			continue
+		}
+		itemCents[itemID] += toCents(value)
+	}
+
+	unassigned := make(map[string]bool, len(split.UnassignedItemIDs))
+	for _, itemID := range split.UnassignedItemIDs {
+		unassigned[itemID] = true
+	}
+	spreadCents := 0 // unassigned discounts spread across every user
+	for _, item := range snapshot.Items {
+		priceCents := toCents(item.TotalPrice)
+		switch {
+		case unassigned[item.ID]:
+			report("items", item.ID, priceCents, 0, "not assigned to anyone")
+		case !assigned[item.ID]:
+			spreadCents += priceCents
+		case strangers[item.ID]:
+			report("items", item.ID, priceCents, itemCents[item.ID], "assigned to a user who is no longer on the receipt")
+		case itemCents[item.ID] != priceCents:
+			report("items", item.ID, priceCents, itemCents[item.ID], "custom amounts don't add up to the item's price")
+		}
+	}
+	discountCents := 0
+	for userID, discount := range split.UserDiscount {
+		if onReceipt[userID] {
+			discountCents += toCents(discount)
+		}
+	}
+	if discountCents != spreadCents {
+		report("items", "", spreadCents, discountCents, "receipt-wide discounts aren't fully spread across users")
+	}
+
+	checkCharge := func(component string, charge *float64, allocated map[string]float64, unallocatedReason string) {
+		if charge == nil {
+			return
+		}
+		allocatedCents := 0
+		for _, u := range snapshot.Users {
+			allocatedCents += toCents(allocated[u.ID])
+		}
+		if expectedCents := toCents(*charge); allocatedCents != expectedCents {
+			reason := "allocations don't add up to the receipt's " + strings.ReplaceAll(component, "_", " ")
+			if allocatedCents == 0 {
+				reason = unallocatedReason
+			}
+			report(component, "", expectedCents, allocatedCents, reason)
+		}
+	}
+	// Tax on a tax-inclusive receipt is already in the item prices
+	if !snapshot.TaxTip.TaxInclusive {
+		checkCharge("tax", snapshot.TaxTip.Tax, allocation.UserTax, "no user is assigned an item")
+	}
+	checkCharge("tip", snapshot.TaxTip.Tip, allocation.UserTip, "no user is assigned an item")
+	checkCharge("service_charge", snapshot.TaxTip.ServiceCharge, allocation.UserServiceCharge, "no user is assigned an item")
+
+	usersCents := 0
+	for _, u := range receipt.Users {
+		usersCents += toCents(u.UserTotal.Value)
+	}
+	grandTotalCents := toCents(receipt.GrandTotal.Value)
+	return VerifyReceiptResponse{
+		ReceiptID:     snapshot.ReceiptID,
+		Currency:      receipt.Currency,
+		Reconciled:    usersCents == grandTotalCents && len(discrepancies) == 0,
+		GrandTotal:    receipt.GrandTotal,
+		UsersTotal:    amount(usersCents),
+		Difference:    amount(usersCents - grandTotalCents),
+		Discrepancies: discrepancies,
+	}
+}
+
+// VerifyReceiptHandler handles checking that a receipt's split reconciles to the cent
+// Expects GET /receipts/{receipt_id}/verify
+// Always 200 for an existing receipt; check reconciled and discrepancies in the body
+func (t *Transport) VerifyReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	formatted, err := parseFormattedQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(context.Background(), receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := verifySplit(snapshot)
+	if !response.Reconciled {
+		t.log.Warn("Receipt split does not reconcile", "receipt_id", receiptID, "difference", response.Difference.Value, "discrepancies", len(response.Discrepancies))
+	}
+
+	if formatted {
+		money.SetFormatted(&response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
@@ -0,0 +1,88 @@
+package transport
+
+import (
+	"testing"
+
+	"splitzies/persistence"
+)
+
+func TestVerifySplitReconcilesAwkwardCents(t *testing.T) {
+	usd := "USD"
+	tax, tip, serviceCharge := 2.03, 5.01, 3.33
+	users := []persistence.ReceiptUser{
+		{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		{ID: "carol", ReceiptID: "r1", Name: "Carol"},
+	}
+	items := []persistence.ReceiptItem{
+		{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 1, TotalPrice: 20.00, PricePerItem: 20.00, Taxable: true},
+		{ID: "wine", ReceiptID: "r1", Name: "Wine", Quantity: 1, TotalPrice: 31.99, PricePerItem: 31.99, Taxable: true},
+		{ID: "bread", ReceiptID: "r1", Name: "Bread", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00, Taxable: false},
+		{ID: "coupon", ReceiptID: "r1", Name: "Coupon", Quantity: 1, TotalPrice: -5.00, PricePerItem: -5.00, IsDiscount: true},
+	}
+	payer := "bob"
+	for _, rounding := range []string{persistence.RoundingFirst, persistence.RoundingPayer, persistence.RoundingLargestShare} {
+		snapshot := &persistence.ReceiptSnapshot{
+			ReceiptID: "r1",
+			Currency:  &usd,
+			TaxTip:    persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip, ServiceCharge: &serviceCharge},
+			Rounding:  persistence.Rounding{Strategy: rounding, PayerUserID: &payer},
+			Users:     users,
+			Items:     items,
+			Assignments: []persistence.ReceiptUserItem{
+				{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+				{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+				{ID: "a3", ReceiptUserID: "carol", ReceiptItemID: "pizza"},
+				{ID: "a4", ReceiptUserID: "bob", ReceiptItemID: "wine"},
+				{ID: "a5", ReceiptUserID: "carol", ReceiptItemID: "wine"},
+				{ID: "a6", ReceiptUserID: "alice", ReceiptItemID: "bread"},
+			},
+		}
+
+		got := verifySplit(snapshot)
+		if !got.Reconciled || len(got.Discrepancies) != 0 || got.Difference.Value != 0 {
+			t.Errorf("%s: reconciled = %v, difference = %v, discrepancies = %+v; want reconciled", rounding, got.Reconciled, got.Difference.Value, got.Discrepancies)
+		}
+		if got.GrandTotal.Value != 61.36 || got.UsersTotal.Value != 61.36 {
+			t.Errorf("%s: grand_total = %v, users_total = %v; want 61.36", rounding, got.GrandTotal.Value, got.UsersTotal.Value)
+		}
+	}
+}
+
+func TestVerifySplitListsDiscrepancies(t *testing.T) {
+	usd := "USD"
+	tax, over := 1.00, 12.00
+	snapshot := &persistence.ReceiptSnapshot{
+		ReceiptID: "r1",
+		Currency:  &usd,
+		TaxTip:    persistence.ReceiptTaxTip{Tax: &tax},
+		Users: []persistence.ReceiptUser{
+			{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+		},
+		Items: []persistence.ReceiptItem{
+			{ID: "salad", ReceiptID: "r1", Name: "Salad", Quantity: 1, TotalPrice: 10.00, PricePerItem: 10.00, Taxable: false},
+			{ID: "soup", ReceiptID: "r1", Name: "Soup", Quantity: 1, TotalPrice: 6.00, PricePerItem: 6.00, Taxable: true},
+		},
+		Assignments: []persistence.ReceiptUserItem{
+			{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "salad", AmountOwed: &over},
+		},
+	}
+
+	got := verifySplit(snapshot)
+	if got.Reconciled {
+		t.Fatal("reconciled = true, want false")
+	}
+	// Users owe 12.00 for the salad plus the tax, which falls to it with no taxable item assigned; the receipt totals 17.00
+	if got.Difference.Value != -4.00 {
+		t.Errorf("difference = %v, want -4.00", got.Difference.Value)
+	}
+	want := []struct{ component, itemID string }{{"items", "salad"}, {"items", "soup"}}
+	if len(got.Discrepancies) != len(want) {
+		t.Fatalf("discrepancies = %+v, want %v", got.Discrepancies, want)
+	}
+	for i, w := range want {
+		if d := got.Discrepancies[i]; d.Component != w.component || d.ItemID != w.itemID {
+			t.Errorf("discrepancy %d = %+v, want %s %s", i, d, w.component, w.itemID)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxJSONBodyBytes caps JSON request bodies; receipts are small, so anything larger is a mistake or abuse
+const maxJSONBodyBytes = 1 << 20 // 1MB
+
+// nullableAmount is an optional JSON number that distinguishes an explicit null (Set, nil Value)
+// from the field being absent (not Set)
+type nullableAmount struct {
+	Set   bool
+	Value *float64
+}
+
+func (n *nullableAmount) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	n.Value = &value
+	return nil
+}
+
+// requestMediaType returns the media type of the request's Content-Type header, lowercased and without
+// parameters, or "" when the header is absent
+func requestMediaType(r *http.Request) (string, error) {
+	header := r.Header.Get("Content-Type")
+	if header == "" {
+		return "", nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", NewValidationError("Content-Type", fmt.Sprintf("invalid Content-Type header %q", header))
+	}
+	return mediaType, nil
+}
+
+// checkJSONContentType rejects requests whose Content-Type is set to something other than JSON.
+// A missing Content-Type is accepted so minimal clients keep working.
+func checkJSONContentType(r *http.Request) error {
+	mediaType, err := requestMediaType(r)
+	if err != nil {
+		return err
+	}
+	if mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	msg := fmt.Sprintf("unsupported Content-Type %q; send the body as application/json", mediaType)
+	if mediaType == "multipart/form-data" || mediaType == "application/x-www-form-urlencoded" {
+		msg += " (form data is only accepted by the image upload endpoints)"
+	}
+	return NewValidationError("Content-Type", msg)
+}
+
+// checkMultipartContentType rejects upload requests that are not multipart/form-data, which is the
+// common mistake of posting JSON or raw image bytes to an upload endpoint
+func checkMultipartContentType(r *http.Request) error {
+	mediaType, err := requestMediaType(r)
+	if err != nil {
+		return err
+	}
+	if mediaType == "multipart/form-data" {
+		return nil
+	}
+	if mediaType == "" {
+		return NewValidationError("Content-Type", `missing Content-Type; send the image as multipart/form-data with an "image" file field`)
+	}
+	return NewValidationError("Content-Type", fmt.Sprintf(`unsupported Content-Type %q; send the image as multipart/form-data with an "image" file field`, mediaType))
+}
+
+// decodeJSONBody decodes the request body into dst, rejecting non-JSON content types, unknown fields and
+// bodies over maxJSONBodyBytes. On failure it writes the error response (415 for a non-JSON Content-Type,
+// 413 for oversized bodies, 400 otherwise) and returns false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := checkJSONContentType(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			msg := fmt.Sprintf("request body must be at most %d bytes", maxBytesErr.Limit)
+			http.Error(w, NewValidationError("body", msg).Error(), http.StatusRequestEntityTooLarge)
+			return false
+		}
+		http.Error(w, NewValidationError("body", fmt.Sprintf("failed to parse request body: %v", err)).Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
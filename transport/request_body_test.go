@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantOK     bool
+		wantStatus int
+	}{
+		{name: "valid body", body: `{"name": "Alice"}`, wantOK: true, wantStatus: http.StatusOK},
+		{name: "unknown field", body: `{"nmae": "Alice"}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed JSON", body: `{"name":`, wantStatus: http.StatusBadRequest},
+		{name: "oversized body", body: `{"name": "` + strings.Repeat("a", maxJSONBodyBytes) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/id/users", strings.NewReader(tt.body))
+
+			var req AddUserToReceiptRequest
+			ok := decodeJSONBody(w, r, &req)
+
+			if ok != tt.wantOK {
+				t.Fatalf("decodeJSONBody = %v, want %v (response %q)", ok, tt.wantOK, w.Body.String())
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ok && req.Name != "Alice" {
+				t.Errorf("Name = %q, want Alice", req.Name)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONBodyContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantStatus  int
+	}{
+		{contentType: "", wantStatus: http.StatusOK},
+		{contentType: "application/json", wantStatus: http.StatusOK},
+		{contentType: "application/json; charset=utf-8", wantStatus: http.StatusOK},
+		{contentType: "application/merge-patch+json", wantStatus: http.StatusOK},
+		{contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType},
+		{contentType: "multipart/form-data; boundary=x", wantStatus: http.StatusUnsupportedMediaType},
+		{contentType: ";;", wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/id/users", strings.NewReader(`{"name": "Alice"}`))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var req AddUserToReceiptRequest
+			ok := decodeJSONBody(w, r, &req)
+
+			if ok != (tt.wantStatus == http.StatusOK) || w.Code != tt.wantStatus {
+				t.Errorf("ok = %v, status = %d, want status %d (response %q)", ok, w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCheckMultipartContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantErr     bool
+	}{
+		{contentType: "multipart/form-data; boundary=abc"},
+		{contentType: "Multipart/Form-Data; boundary=abc"},
+		{contentType: "", wantErr: true},
+		{contentType: "application/json", wantErr: true},
+		{contentType: "image/jpeg", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/receipts/image", strings.NewReader("{}"))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			err := checkMultipartContentType(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkMultipartContentType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "multipart/form-data") {
+				t.Errorf("error %q does not tell the caller to use multipart/form-data", err)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONBodyNullableAmount(t *testing.T) {
+	tests := []struct {
+		body      string
+		wantSet   bool
+		wantValue *float64
+	}{
+		{body: `{"amount": 4.5}`, wantSet: true, wantValue: func() *float64 { v := 4.5; return &v }()},
+		{body: `{"amount": null}`, wantSet: true},
+		{body: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+			var req PatchAssignmentRequest
+			if !decodeJSONBody(httptest.NewRecorder(), r, &req) {
+				t.Fatal("decodeJSONBody failed")
+			}
+			if req.Amount.Set != tt.wantSet {
+				t.Errorf("Set = %v, want %v", req.Amount.Set, tt.wantSet)
+			}
+			if (req.Amount.Value == nil) != (tt.wantValue == nil) || (req.Amount.Value != nil && *req.Amount.Value != *tt.wantValue) {
+				t.Errorf("Value = %v, want %v", req.Amount.Value, tt.wantValue)
+			}
+		})
+	}
+}
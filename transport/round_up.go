@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+// RoundUpTipHandler handles setting the tip so the grand total lands on the next multiple of nearest
+// (e.g. the next whole dollar, or the next $5). Any existing tip is replaced.
+// Expects POST /receipts/{receipt_id}/round-up
+// Request body: {"nearest": 5, "version": 3} - nearest defaults to 1, version optional
+// The expected version may instead be sent as an If-Match header; a stale version returns 409
+func (t *Transport) RoundUpTipHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req RoundUpRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	nearest := 1.0
+	if req.Nearest != nil {
+		nearest = *req.Nearest
+	}
+	version, err := expectedVersion(r, req.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	if !t.requireUnfinalized(ctx, w, receiptID) {
+		return
+	}
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(ctx, receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+	receipt := t.receiptSplitResponse(snapshot)
+	currency := &receipt.Currency
+
+	// The tip being replaced is not part of what gets rounded
+	preTipCents := toCents(receipt.GrandTotal.Value)
+	if receipt.Tip != nil {
+		preTipCents -= toCents(receipt.Tip.Value)
+	}
+	preTip := float64(preTipCents) / 100
+	if preTip <= 0 {
+		http.Error(w, "receipt total must be positive to round up", http.StatusConflict)
+		return
+	}
+	target, err := money.RoundUpTo(preTip, nearest, currency)
+	if err != nil {
+		http.Error(w, NewValidationError("nearest", err.Error()).Error(), http.StatusBadRequest)
+		return
+	}
+	if target < receipt.Subtotal.Value {
+		http.Error(w, NewValidationError("nearest", "rounded total would be less than the subtotal").Error(), http.StatusBadRequest)
+		return
+	}
+	tip := money.Round(target-preTip, currency)
+
+	// Without an explicit version, guard against the receipt changing since it was read above
+	if version == nil {
+		version = &snapshot.Version
+	}
+	newVersion, err := t.persistenceClient.UpdateReceipt(ctx, receiptID, persistence.ReceiptUpdate{Tip: &tip}, version)
+	if err != nil {
+		if writeFinalizedConflict(w, receiptID, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "version conflict") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to update receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := RoundUpResponse{
+		Message:    "Tip set to round up the total",
+		Version:    newVersion,
+		Currency:   receipt.Currency,
+		Tip:        money.NewAmount(tip, currency),
+		GrandTotal: money.NewAmount(target, currency),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
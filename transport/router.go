@@ -0,0 +1,176 @@
+package transport
+
+import "net/http"
+
+// methodRoute binds one HTTP method on a path to its handler
+type methodRoute struct {
+	method  string
+	handler http.HandlerFunc
+}
+
+// pathRoute is every method a path supports, in the order they are listed in the Allow header
+type pathRoute struct {
+	pattern string // net/http ServeMux path pattern, e.g. /receipts/{receipt_id}/users
+	methods []methodRoute
+}
+
+// routes lists every API path and method pair
+func (t *Transport) routes() []pathRoute {
+	return []pathRoute{
+		// Receipts filtered by title and receipt date, newest first
+		{"/receipts", []methodRoute{
+			{http.MethodGet, t.ListReceiptsHandler},
+		}},
+		// Upload a receipt image; OCR and parsing run in the background
+		{"/receipts/image", []methodRoute{
+			{http.MethodPost, t.UploadReceiptImageHandler},
+		}},
+		// Full receipt with users, items, assignments; PATCH updates tax/tip; DELETE soft-deletes
+		{"/receipts/{receipt_id}", []methodRoute{
+			{http.MethodGet, t.GetReceiptHandler},
+			{http.MethodPatch, t.PatchReceiptHandler},
+			{http.MethodDelete, t.DeleteReceiptHandler},
+		}},
+		// Admin only: bring back a soft-deleted receipt
+		{"/receipts/{receipt_id}/restore", []methodRoute{
+			{http.MethodPost, t.RestoreReceiptHandler},
+		}},
+		{"/receipts/{receipt_id}/users", []methodRoute{
+			{http.MethodGet, t.GetReceiptUsersHandler},
+			{http.MethodPost, t.AddUserToReceiptHandler},
+		}},
+		// POST assigns items to a user; DELETE clears all of that user's assignments
+		{"/receipts/{receipt_id}/users/{user_id}/items", []methodRoute{
+			{http.MethodPost, t.AssignItemsToUserHandler},
+			{http.MethodDelete, t.ClearUserAssignmentsHandler},
+		}},
+		// One user's itemized share, for a shareable per-person summary
+		{"/receipts/{receipt_id}/users/{user_id}/breakdown", []methodRoute{
+			{http.MethodGet, t.GetUserBreakdownHandler},
+		}},
+		// PATCH sets a custom amount for one assignment, or null to return to equal split
+		{"/receipts/{receipt_id}/users/{user_id}/items/{item_id}", []methodRoute{
+			{http.MethodPatch, t.PatchAssignmentHandler},
+		}},
+		// GET lists items; POST adds one by hand
+		{"/receipts/{receipt_id}/items", []methodRoute{
+			{http.MethodGet, t.GetReceiptItemsHandler},
+			{http.MethodPost, t.AddReceiptItemHandler},
+		}},
+		// GET returns one item; PATCH toggles taxable; DELETE removes it and its assignments
+		{"/receipts/{receipt_id}/items/{item_id}", []methodRoute{
+			{http.MethodGet, t.GetReceiptItemHandler},
+			{http.MethodPatch, t.PatchReceiptItemHandler},
+			{http.MethodDelete, t.DeleteReceiptItemHandler},
+		}},
+		// Move an item's assignment from one user to another
+		{"/receipts/{receipt_id}/items/{item_id}/reassign", []methodRoute{
+			{http.MethodPost, t.ReassignItemHandler},
+		}},
+		// Split an item among every user on the receipt except an exclusion list
+		{"/receipts/{receipt_id}/items/{item_id}/assign-all", []methodRoute{
+			{http.MethodPost, t.AssignItemToAllHandler},
+		}},
+		// GET is paginated; POST bulk assigns; PUT replaces all assignments
+		{"/receipts/{receipt_id}/assignments", []methodRoute{
+			{http.MethodGet, t.GetReceiptAssignmentsHandler},
+			{http.MethodPost, t.BulkAssignHandler},
+			{http.MethodPut, t.ReplaceAssignmentsHandler},
+		}},
+		// Assign every item to every user with an equal split
+		{"/receipts/{receipt_id}/split-evenly", []methodRoute{
+			{http.MethodPost, t.SplitEvenlyHandler},
+		}},
+		// Sets the tip so the grand total lands on a round number
+		{"/receipts/{receipt_id}/round-up", []methodRoute{
+			{http.MethodPost, t.RoundUpTipHandler},
+		}},
+		// The split with hypothetical tax/tip, without saving them
+		{"/receipts/{receipt_id}/split", []methodRoute{
+			{http.MethodGet, t.PreviewSplitHandler},
+		}},
+		// Wipe users and assignments (and optionally items), keeping the receipt and image
+		{"/receipts/{receipt_id}/reset", []methodRoute{
+			{http.MethodPost, t.ResetReceiptHandler},
+		}},
+		// Copy users and items to a new receipt, for splitting a recurring meal the same way
+		{"/receipts/{receipt_id}/duplicate", []methodRoute{
+			{http.MethodPost, t.DuplicateReceiptHandler},
+		}},
+		// Freeze the agreed split so later edits return 409, and unfreeze it
+		{"/receipts/{receipt_id}/finalize", []methodRoute{
+			{http.MethodPost, t.FinalizeReceiptHandler},
+		}},
+		{"/receipts/{receipt_id}/unfinalize", []methodRoute{
+			{http.MethodPost, t.UnfinalizeReceiptHandler},
+		}},
+		// Who pays whom, given what each payer fronted
+		{"/receipts/{receipt_id}/settlement", []methodRoute{
+			{http.MethodPost, t.SettleReceiptHandler},
+		}},
+		// The split as a printable HTML page
+		{"/receipts/{receipt_id}/print", []methodRoute{
+			{http.MethodGet, t.GetReceiptPrintHandler},
+		}},
+		// Whether user totals add up to the grand total to the cent, and where they don't
+		{"/receipts/{receipt_id}/verify", []methodRoute{
+			{http.MethodGet, t.VerifyReceiptHandler},
+		}},
+		// Stored OCR text
+		{"/receipts/{receipt_id}/ocr", []methodRoute{
+			{http.MethodGet, t.GetReceiptOCRHandler},
+		}},
+		// Combined balances and transfers across several receipts, e.g. at the end of a trip
+		{"/settlements", []methodRoute{
+			{http.MethodPost, t.SettleTripHandler},
+		}},
+		// Admin only: database schema version and applied migrations
+		{"/admin/migrations", []methodRoute{
+			{http.MethodGet, t.GetMigrationsHandler},
+		}},
+		// Every receipt a user name appears on, with that user's total
+		{"/users", []methodRoute{
+			{http.MethodGet, t.SearchUsersHandler},
+		}},
+		// Admin only: Gemini token usage and latency in the Prometheus text format
+		{"/metrics", []methodRoute{
+			{http.MethodGet, t.MetricsHandler},
+		}},
+	}
+}
+
+// Router returns a handler serving every API route.
+// Each path answers OPTIONS with 204 and unsupported methods with 405, both with an Allow header
+// listing the path's methods; unknown paths get a JSON 404.
+// Handlers read IDs from the pattern's wildcards and leave method checks to the router, so they must be served through it.
+func (t *Transport) Router() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range t.routes() {
+		mux.Handle(route.pattern, methodDispatcher(route.methods))
+	}
+	// "/" matches every path the routes above don't
+	mux.HandleFunc("/", NotFound)
+	return mux
+}
+
+// methodDispatcher calls the handler registered for the request's method
+func methodDispatcher(methods []methodRoute) http.Handler {
+	allowed := make([]string, len(methods))
+	byMethod := make(map[string]http.HandlerFunc, len(methods))
+	for i, m := range methods {
+		allowed[i] = m.method
+		byMethod[m.method] = m.handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := byMethod[r.Method]; ok {
+			handler(w, r)
+			return
+		}
+		if r.Method == http.MethodOptions {
+			Options(w, allowed...)
+			return
+		}
+		MethodNotAllowed(w, r, allowed...)
+	})
+}
@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterMethodHandling(t *testing.T) {
+	router := (&Transport{}).Router()
+	receiptID := "01HQ3K4N5P6Q7R8S9T0V1W2X3Y"
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{name: "OPTIONS on receipt", method: http.MethodOptions, path: "/receipts/" + receiptID, wantStatus: http.StatusNoContent, wantAllow: "GET, PATCH, DELETE, OPTIONS"},
+		{name: "OPTIONS on assignments", method: http.MethodOptions, path: "/receipts/" + receiptID + "/assignments", wantStatus: http.StatusNoContent, wantAllow: "GET, POST, PUT, OPTIONS"},
+		{name: "OPTIONS on item", method: http.MethodOptions, path: "/receipts/" + receiptID + "/items/" + receiptID, wantStatus: http.StatusNoContent, wantAllow: "GET, PATCH, DELETE, OPTIONS"},
+		{name: "OPTIONS on user search", method: http.MethodOptions, path: "/users", wantStatus: http.StatusNoContent, wantAllow: "GET, OPTIONS"},
+		{name: "unsupported method on users", method: http.MethodDelete, path: "/receipts/" + receiptID + "/users", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, POST, OPTIONS"},
+		{name: "image upload is not a receipt ID", method: http.MethodGet, path: "/receipts/image", wantStatus: http.StatusMethodNotAllowed, wantAllow: "POST, OPTIONS"},
+		{name: "duplicate is POST only", method: http.MethodGet, path: "/receipts/" + receiptID + "/duplicate", wantStatus: http.StatusMethodNotAllowed, wantAllow: "POST, OPTIONS"},
+		{name: "unknown path", method: http.MethodGet, path: "/receipts/" + receiptID + "/nope", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRouterNotFoundIsJSON(t *testing.T) {
+	router := (&Transport{}).Router()
+	for _, path := range []string{"/", "/nope", "/receipts/01HQ3K4N5P6Q7R8S9T0V1W2X3Y/nope", "/receipts/01HQ3K4N5P6Q7R8S9T0V1W2X3Y/users/"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", path, w.Code)
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: Content-Type = %q, want application/json", path, got)
+		}
+		var body NotFoundResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error != "not found" || body.Path != path {
+			t.Errorf("%s: body = %+v (%v), want not found for the path", path, body, err)
+		}
+	}
+}
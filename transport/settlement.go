@@ -0,0 +1,185 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"splitzies/money"
+)
+
+// settlementTransfer is one payment, in cents, that moves a debtor toward zero
+type settlementTransfer struct {
+	from, to string
+	cents    int
+}
+
+// minimalTransfers settles net balances (in cents; positive means the user owes, negative means they are owed)
+// with at most n-1 transfers: the largest debtor repeatedly pays the largest creditor.
+// Ties go to the user listed first in order, so results are deterministic. Balances must sum to zero.
+func minimalTransfers(balances map[string]int, order []string) []settlementTransfer {
+	type party struct {
+		userID string
+		cents  int
+	}
+	var debtors, creditors []party
+	for _, userID := range order {
+		switch cents := balances[userID]; {
+		case cents > 0:
+			debtors = append(debtors, party{userID, cents})
+		case cents < 0:
+			creditors = append(creditors, party{userID, -cents})
+		}
+	}
+
+	largestFirst := func(parties []party) {
+		sort.SliceStable(parties, func(i, j int) bool { return parties[i].cents > parties[j].cents })
+	}
+
+	var transfers []settlementTransfer
+	for len(debtors) > 0 && len(creditors) > 0 {
+		largestFirst(debtors)
+		largestFirst(creditors)
+		cents := min(debtors[0].cents, creditors[0].cents)
+		transfers = append(transfers, settlementTransfer{from: debtors[0].userID, to: creditors[0].userID, cents: cents})
+		debtors[0].cents -= cents
+		creditors[0].cents -= cents
+		if debtors[0].cents == 0 {
+			debtors = debtors[1:]
+		}
+		if creditors[0].cents == 0 {
+			creditors = creditors[1:]
+		}
+	}
+	return transfers
+}
+
+// settleReceipt computes each user's net balance (their share minus what they paid) and the transfers that
+// settle everyone to zero. Payments must sum to the grand total, and user shares must cover it too
+// (every item assigned), otherwise nobody can be settled exactly.
+func settleReceipt(receipt GetReceiptResponse, payments []SettlementPayment) (SettlementResponse, error) {
+	currency := &receipt.Currency
+	paidCents := make(map[string]int)
+	onReceipt := make(map[string]bool, len(receipt.Users))
+	for _, u := range receipt.Users {
+		onReceipt[u.ID] = true
+	}
+	totalPaidCents := 0
+	for _, p := range payments {
+		if !onReceipt[p.UserID] {
+			return SettlementResponse{}, NewValidationError("payments", fmt.Sprintf("user %s is not on the receipt", p.UserID))
+		}
+		paidCents[p.UserID] += toCents(p.Amount)
+		totalPaidCents += toCents(p.Amount)
+	}
+
+	grandTotalCents := toCents(receipt.GrandTotal.Value)
+	if totalPaidCents != grandTotalCents {
+		return SettlementResponse{}, NewValidationError("payments", fmt.Sprintf("payments sum to %.2f but the grand total is %.2f", float64(totalPaidCents)/100, receipt.GrandTotal.Value))
+	}
+
+	order := make([]string, len(receipt.Users))
+	balances := make(map[string]int, len(receipt.Users))
+	response := SettlementResponse{
+		ReceiptID:  receipt.ReceiptID,
+		Currency:   receipt.Currency,
+		GrandTotal: receipt.GrandTotal,
+		Balances:   make([]SettlementBalance, len(receipt.Users)),
+		Transfers:  []SettlementTransferResponse{},
+	}
+	sharesCents := 0
+	for i, u := range receipt.Users {
+		shareCents := 0
+		if u.UserTotal != nil {
+			shareCents = toCents(u.UserTotal.Value)
+		}
+		sharesCents += shareCents
+		order[i] = u.ID
+		balances[u.ID] = shareCents - paidCents[u.ID]
+		response.Balances[i] = SettlementBalance{
+			UserID: u.ID,
+			Name:   u.Name,
+			Share:  money.NewAmount(float64(shareCents)/100, currency),
+			Paid:   money.NewAmount(float64(paidCents[u.ID])/100, currency),
+			Net:    money.NewAmount(float64(balances[u.ID])/100, currency),
+		}
+	}
+	if sharesCents != grandTotalCents {
+		return SettlementResponse{}, errUnsettledShares
+	}
+
+	for _, transfer := range minimalTransfers(balances, order) {
+		response.Transfers = append(response.Transfers, SettlementTransferResponse{
+			FromUserID: transfer.from,
+			ToUserID:   transfer.to,
+			Amount:     money.NewAmount(float64(transfer.cents)/100, currency),
+		})
+	}
+	return response, nil
+}
+
+// errUnsettledShares is returned when user shares don't add up to the grand total
+var errUnsettledShares = fmt.Errorf("user shares don't add up to the grand total; assign every item before settling")
+
+// SettleReceiptHandler handles computing who pays whom once one or more users have paid the bill
+// Expects POST /receipts/{receipt_id}/settlement
+// Request body: {"payments": [{"user_id": "...", "amount": 40.00}, {"user_id": "...", "amount": 23.37}]}
+func (t *Transport) SettleReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, err := pathULID(r, "receipt_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	formatted, err := parseFormattedQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req SettlementRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.Payments) == 0 {
+		http.Error(w, NewValidationError("payments", "at least one payment is required").Error(), http.StatusBadRequest)
+		return
+	}
+	for _, p := range req.Payments {
+		if p.UserID == "" || p.Amount < 0 {
+			http.Error(w, NewValidationError("payments", "each payment needs a user_id and a non-negative amount").Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(context.Background(), receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := settleReceipt(t.receiptSplitResponse(snapshot), req.Payments)
+	if err != nil {
+		if err == errUnsettledShares {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if formatted {
+		money.SetFormatted(&response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"testing"
+
+	"splitzies/money"
+)
+
+func TestMinimalTransfers(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances map[string]int
+		order    []string
+		want     []settlementTransfer
+	}{
+		{
+			name:     "single payer",
+			balances: map[string]int{"alice": -2000, "bob": 1000, "carol": 1000},
+			order:    []string{"alice", "bob", "carol"},
+			want:     []settlementTransfer{{"bob", "alice", 1000}, {"carol", "alice", 1000}},
+		},
+		{
+			name:     "two payers",
+			balances: map[string]int{"alice": -1500, "bob": -500, "carol": 2000},
+			order:    []string{"alice", "bob", "carol"},
+			want:     []settlementTransfer{{"carol", "alice", 1500}, {"carol", "bob", 500}},
+		},
+		{
+			name:     "already settled",
+			balances: map[string]int{"alice": 0, "bob": 0},
+			order:    []string{"alice", "bob"},
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := minimalTransfers(tt.balances, tt.order)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d transfers %+v, want %+v", len(got), got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("transfer %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSettleReceipt(t *testing.T) {
+	usd := "USD"
+	amount := func(v float64) *money.Amount { a := money.NewAmount(v, &usd); return &a }
+	receipt := GetReceiptResponse{
+		ReceiptID:  "r1",
+		Currency:   usd,
+		GrandTotal: money.NewAmount(60, &usd),
+		Users: []GetReceiptUserResponse{
+			{ID: "alice", Name: "Alice", UserTotal: amount(30)},
+			{ID: "bob", Name: "Bob", UserTotal: amount(20)},
+			{ID: "carol", Name: "Carol", UserTotal: amount(10)},
+		},
+	}
+
+	got, err := settleReceipt(receipt, []SettlementPayment{{"alice", 45}, {"bob", 15}})
+	if err != nil {
+		t.Fatalf("settleReceipt: %v", err)
+	}
+	// alice nets -15, bob +5, carol +10: the larger debtor pays first
+	want := []SettlementTransferResponse{
+		{FromUserID: "carol", ToUserID: "alice", Amount: money.NewAmount(10, &usd)},
+		{FromUserID: "bob", ToUserID: "alice", Amount: money.NewAmount(5, &usd)},
+	}
+	if len(got.Transfers) != len(want) {
+		t.Fatalf("transfers = %+v, want %+v", got.Transfers, want)
+	}
+	for i := range want {
+		g := got.Transfers[i]
+		if g.FromUserID != want[i].FromUserID || g.ToUserID != want[i].ToUserID || g.Amount.Value != want[i].Amount.Value {
+			t.Errorf("transfer %d = %+v, want %+v", i, g, want[i])
+		}
+	}
+	netSum := 0
+	for _, b := range got.Balances {
+		netSum += toCents(b.Net.Value)
+	}
+	if netSum != 0 {
+		t.Errorf("net balances sum to %d cents, want 0", netSum)
+	}
+
+	if _, err := settleReceipt(receipt, []SettlementPayment{{"alice", 50}}); err == nil {
+		t.Error("expected error when payments don't sum to the grand total")
+	}
+	if _, err := settleReceipt(receipt, []SettlementPayment{{"dave", 60}}); err == nil {
+		t.Error("expected error for a payer not on the receipt")
+	}
+
+	receipt.Users[2].UserTotal = nil
+	if _, err := settleReceipt(receipt, []SettlementPayment{{"alice", 60}}); err != errUnsettledShares {
+		t.Errorf("err = %v, want errUnsettledShares", err)
+	}
+}
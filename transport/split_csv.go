@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"encoding/csv"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+// Representations of GET /receipts/{receipt_id}
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeCSV  = "text/csv"
+)
+
+// negotiateSplitMediaType picks the representation of the bill split for an Accept header: the supported
+// media type with the highest q value, preferring JSON on ties. An empty header means JSON.
+// Returns false when the header accepts neither JSON nor CSV.
+func negotiateSplitMediaType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return mediaTypeJSON, true
+	}
+	quality := map[string]float64{mediaTypeJSON: -1, mediaTypeCSV: -1}
+	// Exact types outrank wildcards for the same type regardless of order
+	specificity := map[string]int{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		for _, supported := range []string{mediaTypeJSON, mediaTypeCSV} {
+			rank := mediaRangeSpecificity(mediaType, supported)
+			if rank > specificity[supported] {
+				specificity[supported] = rank
+				quality[supported] = q
+			}
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, supported := range []string{mediaTypeJSON, mediaTypeCSV} {
+		if quality[supported] > bestQ {
+			best, bestQ = supported, quality[supported]
+		}
+	}
+	return best, best != ""
+}
+
+// mediaRangeSpecificity is 3 when mediaRange names mediaType exactly, 2 for type/*, 1 for */*, and 0 otherwise
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 3
+	case mediaRange == "*/*":
+		return 1
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 2
+	}
+	return 0
+}
+
+// writeSplitCSV writes one row per user with their subtotal, tax, tip, service charge, and total,
+// followed by an unassigned row and a grand total row. Amounts use the currency's minor units.
+func writeSplitCSV(out io.Writer, snapshot *persistence.ReceiptSnapshot, split GetReceiptResponse) error {
+	currency := &split.Currency
+	format := func(value float64) string {
+		return strconv.FormatFloat(money.Round(value, currency), 'f', money.DecimalPlaces(currency), 64)
+	}
+	optional := func(amount *money.Amount) string {
+		if amount == nil {
+			return format(0)
+		}
+		return format(amount.Value)
+	}
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"user_id", "name", "subtotal", "tax", "tip", "service_charge", "total", "currency"})
+	for _, user := range split.Users {
+		breakdown, ok := userBreakdown(snapshot, user.ID)
+		if !ok {
+			continue
+		}
+		w.Write([]string{
+			user.ID,
+			user.Name,
+			format(breakdown.Subtotal.Value),
+			format(breakdown.Tax.Value),
+			format(breakdown.Tip.Value),
+			format(breakdown.ServiceCharge.Value),
+			format(breakdown.Total.Value),
+			split.Currency,
+		})
+	}
+	w.Write([]string{"", "Unassigned", format(split.UnassignedTotal.Value), "", "", "", format(split.UnassignedTotal.Value), split.Currency})
+	w.Write([]string{"", "Total", format(split.Subtotal.Value), optional(split.Tax), optional(split.Tip), optional(split.ServiceCharge), format(split.GrandTotal.Value), split.Currency})
+	w.Flush()
+	return w.Error()
+}
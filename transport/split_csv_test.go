@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"strings"
+	"testing"
+
+	"splitzies/persistence"
+)
+
+func TestNegotiateSplitMediaType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		wantOK bool
+	}{
+		{accept: "", want: mediaTypeJSON, wantOK: true},
+		{accept: "application/json", want: mediaTypeJSON, wantOK: true},
+		{accept: "text/csv", want: mediaTypeCSV, wantOK: true},
+		{accept: "*/*", want: mediaTypeJSON, wantOK: true},
+		{accept: "text/*", want: mediaTypeCSV, wantOK: true},
+		{accept: "text/csv, application/json;q=0.5", want: mediaTypeCSV, wantOK: true},
+		{accept: "text/csv;q=0.5, application/json", want: mediaTypeJSON, wantOK: true},
+		{accept: "*/*, application/json;q=0", want: mediaTypeCSV, wantOK: true},
+		{accept: "text/html, */*;q=0.1", want: mediaTypeJSON, wantOK: true},
+		{accept: "text/html"},
+		{accept: "application/xml, text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got, ok := negotiateSplitMediaType(tt.accept)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("negotiateSplitMediaType(%q) = %q, %v; want %q, %v", tt.accept, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWriteSplitCSV(t *testing.T) {
+	usd := "USD"
+	tax := 1.20
+	snapshot := &persistence.ReceiptSnapshot{
+		ReceiptID: "r1",
+		Currency:  &usd,
+		TaxTip:    persistence.ReceiptTaxTip{Tax: &tax},
+		Users: []persistence.ReceiptUser{
+			{ID: "alice", ReceiptID: "r1", Name: "Alice, Jr."},
+			{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		},
+		Items: []persistence.ReceiptItem{
+			{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 1, TotalPrice: 20.00, PricePerItem: 20.00, Taxable: true},
+			{ID: "soda", ReceiptID: "r1", Name: "Soda", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00, Taxable: true},
+		},
+		Assignments: []persistence.ReceiptUserItem{
+			{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+			{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+		},
+	}
+
+	var out strings.Builder
+	if err := writeSplitCSV(&out, snapshot, (&Transport{}).receiptSplitResponse(snapshot)); err != nil {
+		t.Fatalf("writeSplitCSV: %v", err)
+	}
+	want := `user_id,name,subtotal,tax,tip,service_charge,total,currency
+alice,"Alice, Jr.",10.00,0.60,0.00,0.00,10.60,USD
+bob,Bob,10.00,0.60,0.00,0.00,10.60,USD
+,Unassigned,4.00,,,,4.00,USD
+,Total,24.00,1.20,0.00,0.00,25.20,USD
+`
+	if out.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", out.String(), want)
+	}
+}
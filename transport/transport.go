@@ -1,24 +1,224 @@
 package transport
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"splitzies/persistence"
 	"splitzies/storage"
 )
 
+// defaultMaxUploadBytes is the upload size limit when MAX_UPLOAD_BYTES is not set
+const defaultMaxUploadBytes = 10 << 20 // 10MB
+
+// defaultMaxReceiptItems is the most items a receipt can have when MAX_RECEIPT_ITEMS is not set
+const defaultMaxReceiptItems = 500
+
+// defaultParseTimeout bounds OCR and parsing of an upload in the background when PARSE_TIMEOUT is not set.
+// It is far longer than a request would wait, since nobody is blocked on it: a truncated Gemini response
+// retried with a larger budget can take a minute.
+const defaultParseTimeout = 3 * time.Minute
+
+// defaultImageFormFields are the multipart field names checked, in order, for the uploaded image when
+// IMAGE_FORM_FIELDS is not set; HTTP client libraries and their examples disagree on the name
+var defaultImageFormFields = []string{"image", "file", "receipt", "photo"}
+
+// ImageStore stores uploaded receipt images and returns where; *storage.GCSClient in production
+type ImageStore interface {
+	UploadReceiptImageFromReader(ctx context.Context, reader io.Reader, receiptID string, contentType string) (string, error)
+}
+
+// OCREngine reads the text in a receipt image; *storage.VisionClient in production
+type OCREngine interface {
+	PerformOCRFromBytes(ctx context.Context, imageData []byte, feature storage.OCRFeature, languageHints []string) (string, error)
+}
+
 type Transport struct {
 	log               *slog.Logger
-	persistenceClient *persistence.Client
-	gcsClient         *storage.GCSClient
-	visionClient      *storage.VisionClient
+	persistenceClient ReceiptStore
+	imageStore        ImageStore // nil when images aren't stored (DISABLE_AI without GCS)
+	ocrEngine         OCREngine  // nil when AI is disabled (DISABLE_AI)
+	maxUploadBytes    int64
+	maxReceiptItems   int      // parsed items beyond this are dropped, and manual adds rejected
+	imageFormFields   []string // multipart field names accepted for the uploaded image, first present wins
+	ocrFeature        storage.OCRFeature
+	ocrLanguageHints  []string                     // default Vision language hints; nil lets Vision auto-detect
+	ocrOnly           bool                         // store OCR text only, skipping AI parsing
+	parseTimeout      time.Duration                // OCR plus parsing of one upload in the background
+	docAIConfidence   storage.ConfidenceThresholds // what to do with low-confidence Document AI items
+	debugResponses    bool                         // include parser telemetry in GET responses
+	webhook           *webhookNotifier             // nil when WEBHOOK_URL is not configured
+	adminAPIKey       string                       // required in X-Admin-Key for admin operations; empty disables them
+	trustedProxyHops  int                          // proxies whose X-Forwarded-For entries ClientIP trusts
+	workers           sync.WaitGroup               // background receipt processing
+	geminiMetrics     geminiMetrics                // served by GET /metrics
 }
 
-func NewTransport(log *slog.Logger, persistenceClient *persistence.Client, gcsClient *storage.GCSClient, visionClient *storage.VisionClient) *Transport {
+// NewTransport creates a Transport. A nil log discards log output, so handlers can always log safely.
+// imageStore and ocrEngine may be nil when AI is disabled for local development (DISABLE_AI): uploads then
+// skip OCR and parsing, and without an image store the image isn't stored. Pass an untyped nil, not a nil
+// *storage.GCSClient or *storage.VisionClient, which would make a non-nil interface.
+func NewTransport(log *slog.Logger, persistenceClient ReceiptStore, imageStore ImageStore, ocrEngine OCREngine) *Transport {
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
 	return &Transport{
 		log:               log,
 		persistenceClient: persistenceClient,
-		gcsClient:         gcsClient,
-		visionClient:      visionClient,
+		imageStore:        imageStore,
+		ocrEngine:         ocrEngine,
+		maxUploadBytes:    maxUploadBytesFromEnv(log),
+		maxReceiptItems:   maxReceiptItemsFromEnv(log),
+		parseTimeout:      parseTimeoutFromEnv(log),
+		imageFormFields:   imageFormFieldsFromEnv(log),
+		ocrFeature:        ocrFeatureFromEnv(log),
+		ocrLanguageHints:  ocrLanguageHintsFromEnv(log),
+		ocrOnly:           ocrOnlyFromEnv(log),
+		docAIConfidence:   storage.ConfidenceThresholdsFromEnv(log),
+		debugResponses:    boolFromEnv(log, "DEBUG_RESPONSES"),
+		webhook:           webhookNotifierFromEnv(log),
+		adminAPIKey:       adminAPIKeyFromEnv(log),
+		trustedProxyHops:  trustedProxyHopsFromEnv(log),
+	}
+}
+
+// Wait blocks until background receipt processing started by uploads has finished.
+// Call after the HTTP server has stopped accepting requests and before closing clients.
+func (t *Transport) Wait() {
+	t.workers.Wait()
+}
+
+// maxUploadBytesFromEnv reads MAX_UPLOAD_BYTES, falling back to 10MB when unset or invalid
+func maxUploadBytesFromEnv(log *slog.Logger) int64 {
+	value := os.Getenv("MAX_UPLOAD_BYTES")
+	if value == "" {
+		return defaultMaxUploadBytes
+	}
+	maxBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxBytes <= 0 {
+		log.Warn("Invalid MAX_UPLOAD_BYTES, using default", "value", value, "default", defaultMaxUploadBytes)
+		return defaultMaxUploadBytes
+	}
+	return maxBytes
+}
+
+// maxReceiptItemsFromEnv reads MAX_RECEIPT_ITEMS, falling back to 500 when unset or invalid
+func maxReceiptItemsFromEnv(log *slog.Logger) int {
+	value := os.Getenv("MAX_RECEIPT_ITEMS")
+	if value == "" {
+		return defaultMaxReceiptItems
+	}
+	maxItems, err := strconv.Atoi(value)
+	if err != nil || maxItems <= 0 {
+		log.Warn("Invalid MAX_RECEIPT_ITEMS, using default", "value", value, "default", defaultMaxReceiptItems)
+		return defaultMaxReceiptItems
+	}
+	return maxItems
+}
+
+// parseTimeoutFromEnv reads PARSE_TIMEOUT, a Go duration such as "90s" or "5m", falling back to 3 minutes
+// when unset or invalid
+func parseTimeoutFromEnv(log *slog.Logger) time.Duration {
+	value := os.Getenv("PARSE_TIMEOUT")
+	if value == "" {
+		return defaultParseTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Warn("Invalid PARSE_TIMEOUT, using default", "value", value, "default", defaultParseTimeout)
+		return defaultParseTimeout
+	}
+	return timeout
+}
+
+// imageFormFieldsFromEnv reads IMAGE_FORM_FIELDS (e.g. "image,file"), the multipart field names accepted for
+// the uploaded image in priority order, falling back to defaultImageFormFields when unset or empty
+func imageFormFieldsFromEnv(log *slog.Logger) []string {
+	value := os.Getenv("IMAGE_FORM_FIELDS")
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		if value != "" {
+			log.Warn("Invalid IMAGE_FORM_FIELDS, using default", "value", value, "default", defaultImageFormFields)
+		}
+		return defaultImageFormFields
+	}
+	return fields
+}
+
+// ocrFeatureFromEnv reads OCR_FEATURE (DOCUMENT_TEXT_DETECTION or TEXT_DETECTION),
+// falling back to DOCUMENT_TEXT_DETECTION when unset or invalid
+func ocrFeatureFromEnv(log *slog.Logger) storage.OCRFeature {
+	value := os.Getenv("OCR_FEATURE")
+	switch feature := storage.OCRFeature(strings.ToUpper(value)); feature {
+	case "":
+		return storage.OCRFeatureDocumentText
+	case storage.OCRFeatureDocumentText, storage.OCRFeatureText:
+		return feature
+	default:
+		log.Warn("Invalid OCR_FEATURE, using default", "value", value, "default", storage.OCRFeatureDocumentText)
+		return storage.OCRFeatureDocumentText
+	}
+}
+
+// languageTagPattern loosely matches a BCP-47 language tag such as "ja", "zh-Hant", or "pt-BR"
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// parseLanguageHints parses a comma-separated list of BCP-47 language tags (e.g. "ja,ko").
+// Returns nil for an empty list.
+func parseLanguageHints(value string) ([]string, error) {
+	var hints []string
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if !languageTagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%q is not a language tag", tag)
+		}
+		hints = append(hints, tag)
+	}
+	return hints, nil
+}
+
+// ocrLanguageHintsFromEnv reads OCR_LANGUAGE_HINTS (e.g. "ja,ko"), the Vision language hints used when an upload
+// doesn't send its own; unset or invalid leaves Vision to auto-detect
+func ocrLanguageHintsFromEnv(log *slog.Logger) []string {
+	value := os.Getenv("OCR_LANGUAGE_HINTS")
+	hints, err := parseLanguageHints(value)
+	if err != nil {
+		log.Warn("Invalid OCR_LANGUAGE_HINTS, using auto-detect", "value", value, "error", err)
+		return nil
+	}
+	return hints
+}
+
+// ocrOnlyFromEnv reads OCR_ONLY; when true, uploads store the OCR text without parsing items (no Gemini cost)
+func ocrOnlyFromEnv(log *slog.Logger) bool {
+	return boolFromEnv(log, "OCR_ONLY")
+}
+
+// boolFromEnv reads a boolean environment variable, falling back to false when unset or invalid
+func boolFromEnv(log *slog.Logger, name string) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warn("Invalid "+name+", using default", "value", value, "default", false)
+		return false
 	}
+	return enabled
 }
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestNewTransportDefaultsNilLogger(t *testing.T) {
+	t.Setenv("MAX_UPLOAD_BYTES", "not a number") // exercises a warning during construction
+
+	transport := NewTransport(nil, nil, nil, nil)
+
+	if transport.log == nil {
+		t.Fatal("log is nil, want a no-op logger")
+	}
+	transport.log.Error("OCR failed", "error", "boom") // must not panic
+	if transport.maxUploadBytes != defaultMaxUploadBytes {
+		t.Errorf("maxUploadBytes = %d, want default %d", transport.maxUploadBytes, defaultMaxUploadBytes)
+	}
+}
+
+func TestParseLanguageHints(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "ja", want: []string{"ja"}},
+		{value: " ja , ko,zh-Hant ", want: []string{"ja", "ko", "zh-Hant"}},
+		{value: "ja,,", want: []string{"ja"}},
+		{value: "japanese!", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLanguageHints(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseLanguageHints(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("parseLanguageHints(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseLanguageHints(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestOCRLanguageHintsFromEnv(t *testing.T) {
+	t.Setenv("OCR_LANGUAGE_HINTS", "ja,ko")
+	if got := NewTransport(nil, nil, nil, nil).ocrLanguageHints; len(got) != 2 || got[0] != "ja" || got[1] != "ko" {
+		t.Errorf("ocrLanguageHints = %v, want [ja ko]", got)
+	}
+
+	t.Setenv("OCR_LANGUAGE_HINTS", "not a tag")
+	if got := NewTransport(nil, nil, nil, nil).ocrLanguageHints; got != nil {
+		t.Errorf("ocrLanguageHints = %v, want nil (auto-detect) for an invalid value", got)
+	}
+}
+
+func TestParseTimeoutFromEnv(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": defaultParseTimeout, "90s": 90 * time.Second, "5m": 5 * time.Minute, "soon": defaultParseTimeout, "-1m": defaultParseTimeout} {
+		t.Setenv("PARSE_TIMEOUT", value)
+		if got := parseTimeoutFromEnv(slog.New(slog.DiscardHandler)); got != want {
+			t.Errorf("PARSE_TIMEOUT=%q: parseTimeout = %v, want %v", value, got, want)
+		}
+	}
+}
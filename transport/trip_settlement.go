@@ -0,0 +1,188 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"splitzies/money"
+)
+
+// maxTripSettlementReceipts caps how many receipts one settlement request may combine
+const maxTripSettlementReceipts = 50
+
+// tripLedger accumulates per-person cents for all receipts in one currency
+type tripLedger struct {
+	currency   string
+	receiptIDs []string
+	totalCents int
+	order      []string // person keys in first-seen order
+	names      map[string]string
+	shareCents map[string]int
+	paidCents  map[string]int
+}
+
+// settleTrip settles each receipt on its own (so every receipt's payments and shares must balance), then
+// combines users into people by name and computes the fewest transfers per currency. people maps a
+// receipt user name to the person it stands for; names match case-insensitively, and unmapped names
+// stand for themselves, so the same name on different receipts is the same person.
+func settleTrip(receipts []GetReceiptResponse, payments [][]SettlementPayment, people map[string]string) (TripSettlementResponse, error) {
+	canonical := make(map[string]string, len(people))
+	for name, person := range people {
+		canonical[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(person)
+	}
+	personFor := func(name string) string {
+		name = strings.TrimSpace(name)
+		if person, ok := canonical[strings.ToLower(name)]; ok && person != "" {
+			return person
+		}
+		return name
+	}
+
+	var ledgers []*tripLedger
+	byCurrency := make(map[string]*tripLedger)
+	for i, receipt := range receipts {
+		settled, err := settleReceipt(receipt, payments[i])
+		if err != nil {
+			return TripSettlementResponse{}, fmt.Errorf("receipt %s: %w", receipt.ReceiptID, err)
+		}
+
+		ledger, ok := byCurrency[receipt.Currency]
+		if !ok {
+			ledger = &tripLedger{
+				currency:   receipt.Currency,
+				names:      make(map[string]string),
+				shareCents: make(map[string]int),
+				paidCents:  make(map[string]int),
+			}
+			byCurrency[receipt.Currency] = ledger
+			ledgers = append(ledgers, ledger)
+		}
+		ledger.receiptIDs = append(ledger.receiptIDs, receipt.ReceiptID)
+		ledger.totalCents += toCents(settled.GrandTotal.Value)
+		for _, balance := range settled.Balances {
+			person := personFor(balance.Name)
+			key := strings.ToLower(person)
+			if _, seen := ledger.names[key]; !seen {
+				ledger.names[key] = person
+				ledger.order = append(ledger.order, key)
+			}
+			ledger.shareCents[key] += toCents(balance.Share.Value)
+			ledger.paidCents[key] += toCents(balance.Paid.Value)
+		}
+	}
+
+	response := TripSettlementResponse{Currencies: make([]TripSettlementCurrency, 0, len(ledgers))}
+	for _, ledger := range ledgers {
+		currency := &ledger.currency
+		settlement := TripSettlementCurrency{
+			Currency:   ledger.currency,
+			ReceiptIDs: ledger.receiptIDs,
+			GrandTotal: money.NewAmount(float64(ledger.totalCents)/100, currency),
+			Balances:   make([]TripSettlementBalance, len(ledger.order)),
+			Transfers:  []TripSettlementTransfer{},
+		}
+		balances := make(map[string]int, len(ledger.order))
+		for i, key := range ledger.order {
+			balances[key] = ledger.shareCents[key] - ledger.paidCents[key]
+			settlement.Balances[i] = TripSettlementBalance{
+				Person: ledger.names[key],
+				Share:  money.NewAmount(float64(ledger.shareCents[key])/100, currency),
+				Paid:   money.NewAmount(float64(ledger.paidCents[key])/100, currency),
+				Net:    money.NewAmount(float64(balances[key])/100, currency),
+			}
+		}
+		for _, transfer := range minimalTransfers(balances, ledger.order) {
+			settlement.Transfers = append(settlement.Transfers, TripSettlementTransfer{
+				From:   ledger.names[transfer.from],
+				To:     ledger.names[transfer.to],
+				Amount: money.NewAmount(float64(transfer.cents)/100, currency),
+			})
+		}
+		response.Currencies = append(response.Currencies, settlement)
+	}
+	return response, nil
+}
+
+// SettleTripHandler handles settling several receipts at once, e.g. at the end of a trip
+// Expects POST /settlements
+// Request body: {"receipts": [{"receipt_id": "...", "payments": [{"user_id": "...", "amount": 63.37}]}], "people": {"Al": "Alice"}}
+func (t *Transport) SettleTripHandler(w http.ResponseWriter, r *http.Request) {
+	formatted, err := parseFormattedQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req TripSettlementRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	var errs ValidationErrors
+	if len(req.Receipts) == 0 {
+		errs.Add("receipts", "at least one receipt is required")
+	}
+	if len(req.Receipts) > maxTripSettlementReceipts {
+		errs.Add("receipts", fmt.Sprintf("at most %d receipts can be settled together", maxTripSettlementReceipts))
+	}
+	seen := make(map[string]bool, len(req.Receipts))
+	for i, receipt := range req.Receipts {
+		field := fmt.Sprintf("receipts[%d]", i)
+		errs.Collect(validateULID(field+".receipt_id", receipt.ReceiptID))
+		if seen[receipt.ReceiptID] {
+			errs.Add(field+".receipt_id", "receipt is listed more than once")
+		}
+		seen[receipt.ReceiptID] = true
+		if len(receipt.Payments) == 0 {
+			errs.Add(field+".payments", "at least one payment is required")
+		}
+		for j, p := range receipt.Payments {
+			if p.UserID == "" || p.Amount < 0 {
+				errs.Add(fmt.Sprintf("%s.payments[%d]", field, j), "each payment needs a user_id and a non-negative amount")
+			}
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx := context.Background()
+	receipts := make([]GetReceiptResponse, len(req.Receipts))
+	payments := make([][]SettlementPayment, len(req.Receipts))
+	for i, receipt := range req.Receipts {
+		snapshot, err := t.persistenceClient.GetReceiptSnapshot(ctx, receipt.ReceiptID)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, fmt.Sprintf("receipt %s: %v", receipt.ReceiptID, err), http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to load receipt %s: %v", receipt.ReceiptID, err), http.StatusInternalServerError)
+			return
+		}
+		receipts[i] = t.receiptSplitResponse(snapshot)
+		payments[i] = receipt.Payments
+	}
+
+	response, err := settleTrip(receipts, payments, req.People)
+	if err != nil {
+		if errors.Is(err, errUnsettledShares) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if formatted {
+		money.SetFormatted(&response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
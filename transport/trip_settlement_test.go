@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"errors"
+	"testing"
+
+	"splitzies/money"
+)
+
+func TestSettleTrip(t *testing.T) {
+	usd, eur := "USD", "EUR"
+	amount := func(v float64, currency *string) *money.Amount { a := money.NewAmount(v, currency); return &a }
+	dinner := GetReceiptResponse{
+		ReceiptID:  "dinner",
+		Currency:   usd,
+		GrandTotal: money.NewAmount(60, &usd),
+		Users: []GetReceiptUserResponse{
+			{ID: "d1", Name: "Alice", UserTotal: amount(30, &usd)},
+			{ID: "d2", Name: "Bob", UserTotal: amount(30, &usd)},
+		},
+	}
+	taxi := GetReceiptResponse{
+		ReceiptID:  "taxi",
+		Currency:   usd,
+		GrandTotal: money.NewAmount(20, &usd),
+		Users: []GetReceiptUserResponse{
+			{ID: "t1", Name: "al", UserTotal: amount(10, &usd)},
+			{ID: "t2", Name: "bob", UserTotal: amount(5, &usd)},
+			{ID: "t3", Name: "Carol", UserTotal: amount(5, &usd)},
+		},
+	}
+	museum := GetReceiptResponse{
+		ReceiptID:  "museum",
+		Currency:   eur,
+		GrandTotal: money.NewAmount(10, &eur),
+		Users: []GetReceiptUserResponse{
+			{ID: "m1", Name: "Alice", UserTotal: amount(10, &eur)},
+		},
+	}
+
+	got, err := settleTrip(
+		[]GetReceiptResponse{dinner, taxi, museum},
+		[][]SettlementPayment{{{"d1", 60}}, {{"t3", 20}}, {{"m1", 10}}},
+		map[string]string{"Al": "Alice"},
+	)
+	if err != nil {
+		t.Fatalf("settleTrip: %v", err)
+	}
+	if len(got.Currencies) != 2 || got.Currencies[0].Currency != usd || got.Currencies[1].Currency != eur {
+		t.Fatalf("currencies = %+v, want USD then EUR", got.Currencies)
+	}
+
+	// Alice: share 40, paid 60; Bob (both spellings): share 35; Carol: share 5, paid 20
+	usdSettlement := got.Currencies[0]
+	if usdSettlement.GrandTotal.Value != 80 {
+		t.Errorf("USD grand total = %v, want 80", usdSettlement.GrandTotal.Value)
+	}
+	wantNet := map[string]float64{"Alice": -20, "Bob": 35, "Carol": -15}
+	if len(usdSettlement.Balances) != len(wantNet) {
+		t.Fatalf("balances = %+v, want one per person %v", usdSettlement.Balances, wantNet)
+	}
+	for _, b := range usdSettlement.Balances {
+		if b.Net.Value != wantNet[b.Person] {
+			t.Errorf("%s net = %v, want %v", b.Person, b.Net.Value, wantNet[b.Person])
+		}
+	}
+	wantTransfers := []TripSettlementTransfer{
+		{From: "Bob", To: "Alice", Amount: money.NewAmount(20, &usd)},
+		{From: "Bob", To: "Carol", Amount: money.NewAmount(15, &usd)},
+	}
+	if len(usdSettlement.Transfers) != len(wantTransfers) {
+		t.Fatalf("transfers = %+v, want %+v", usdSettlement.Transfers, wantTransfers)
+	}
+	for i, want := range wantTransfers {
+		g := usdSettlement.Transfers[i]
+		if g.From != want.From || g.To != want.To || g.Amount.Value != want.Amount.Value {
+			t.Errorf("transfer %d = %+v, want %+v", i, g, want)
+		}
+	}
+	if len(got.Currencies[1].Transfers) != 0 {
+		t.Errorf("EUR transfers = %+v, want none", got.Currencies[1].Transfers)
+	}
+
+	museum.Users[0].UserTotal = nil
+	_, err = settleTrip([]GetReceiptResponse{museum}, [][]SettlementPayment{{{"m1", 10}}}, nil)
+	if !errors.Is(err, errUnsettledShares) {
+		t.Errorf("err = %v, want errUnsettledShares", err)
+	}
+}
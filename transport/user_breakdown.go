@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+// userBreakdown itemizes what userID owes on the receipt: their share of each assigned item, their share of
+// unassigned discounts, and their allocated tax, tip, and service charge. The second result is false when the
+// user is not on the receipt.
+func userBreakdown(snapshot *persistence.ReceiptSnapshot, userID string) (UserBreakdownResponse, bool) {
+	var user *persistence.ReceiptUser
+	for i := range snapshot.Users {
+		if snapshot.Users[i].ID == userID {
+			user = &snapshot.Users[i]
+			break
+		}
+	}
+	if user == nil {
+		return UserBreakdownResponse{}, false
+	}
+
+	currency := snapshot.Currency
+	if currency == nil || *currency == "" {
+		currency = &defaultUSD
+	}
+	assignments := splitAssignments(snapshot)
+	split := ComputeBillSplit(snapshot.Users, snapshot.Items, assignments, snapshot.Rounding)
+	allocation := AllocateTaxTip(snapshot.Users, split, &snapshot.TaxTip)
+
+	usersPerItem := make(map[string]int)
+	for _, a := range append(assignments, split.SharedAssignments...) {
+		usersPerItem[a.ReceiptItemID]++
+	}
+
+	lines := make([]UserBreakdownLine, 0)
+	for _, item := range snapshot.Items {
+		amount, ok := split.AmountByUserItem[userID+":"+item.ID]
+		if !ok {
+			continue
+		}
+		// The user's portion of the quantity follows their portion of the price; equal when the price is zero
+		portion := 1 / float64(usersPerItem[item.ID])
+		if item.TotalPrice != 0 {
+			portion = amount / item.TotalPrice
+		}
+		lines = append(lines, UserBreakdownLine{
+			ItemID:   item.ID,
+			Name:     item.Name,
+			Quantity: math.Round(float64(item.Quantity)*portion*100) / 100,
+			Amount:   money.NewAmount(amount, currency),
+		})
+	}
+
+	var discount *money.Amount
+	if d, ok := split.UserDiscount[userID]; ok {
+		discount = money.Ptr(&d, currency)
+	}
+
+	return UserBreakdownResponse{
+		ReceiptID:     snapshot.ReceiptID,
+		UserID:        user.ID,
+		Name:          user.Name,
+		Color:         user.Color,
+		Currency:      *currency,
+		Items:         lines,
+		Discount:      discount,
+		Subtotal:      money.NewAmount(split.UserTotal[userID], currency),
+		Tax:           money.NewAmount(allocation.UserTax[userID], currency),
+		Tip:           money.NewAmount(allocation.UserTip[userID], currency),
+		ServiceCharge: money.NewAmount(allocation.UserServiceCharge[userID], currency),
+		Total:         money.NewAmount(userGrandTotal(userID, split, allocation), currency),
+	}, true
+}
+
+// GetUserBreakdownHandler handles itemizing one user's share of a receipt, for a shareable per-person summary
+// Expects GET /receipts/{receipt_id}/users/{user_id}/breakdown
+func (t *Transport) GetUserBreakdownHandler(w http.ResponseWriter, r *http.Request) {
+	var errs ValidationErrors
+	receiptID, err := pathULID(r, "receipt_id")
+	errs.Collect(err)
+	userID, err := pathULID(r, "user_id")
+	errs.Collect(err)
+	if err := errs.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	formatted, err := parseFormattedQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(context.Background(), receiptID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to load receipt: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response, ok := userBreakdown(snapshot, userID)
+	if !ok {
+		http.Error(w, "receipt user not found", http.StatusNotFound)
+		return
+	}
+
+	if formatted {
+		money.SetFormatted(&response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"testing"
+
+	"splitzies/persistence"
+)
+
+func TestUserBreakdown(t *testing.T) {
+	usd := "USD"
+	tax, tip := 1.20, 3.00
+	snapshot := &persistence.ReceiptSnapshot{
+		ReceiptID: "r1",
+		Currency:  &usd,
+		TaxTip:    persistence.ReceiptTaxTip{Tax: &tax, Tip: &tip},
+		Users: []persistence.ReceiptUser{
+			{ID: "alice", ReceiptID: "r1", Name: "Alice"},
+			{ID: "bob", ReceiptID: "r1", Name: "Bob"},
+		},
+		Items: []persistence.ReceiptItem{
+			{ID: "pizza", ReceiptID: "r1", Name: "Pizza", Quantity: 2, TotalPrice: 20.00, PricePerItem: 10.00, Taxable: true},
+			{ID: "soda", ReceiptID: "r1", Name: "Soda", Quantity: 1, TotalPrice: 4.00, PricePerItem: 4.00, Taxable: true},
+		},
+		Assignments: []persistence.ReceiptUserItem{
+			{ID: "a1", ReceiptUserID: "alice", ReceiptItemID: "pizza"},
+			{ID: "a2", ReceiptUserID: "bob", ReceiptItemID: "pizza"},
+			{ID: "a3", ReceiptUserID: "bob", ReceiptItemID: "soda"},
+		},
+	}
+
+	got, ok := userBreakdown(snapshot, "bob")
+	if !ok {
+		t.Fatal("bob not found")
+	}
+	if len(got.Items) != 2 {
+		t.Fatalf("items = %+v, want pizza and soda", got.Items)
+	}
+	if got.Items[0].Name != "Pizza" || got.Items[0].Quantity != 1 || got.Items[0].Amount.Value != 10.00 {
+		t.Errorf("pizza line = %+v, want quantity 1 for 10.00", got.Items[0])
+	}
+	if got.Subtotal.Value != 14.00 || got.Tax.Value != 0.70 || got.Tip.Value != 1.75 || got.Total.Value != 16.45 {
+		t.Errorf("subtotal/tax/tip/total = %v/%v/%v/%v, want 14.00/0.70/1.75/16.45",
+			got.Subtotal.Value, got.Tax.Value, got.Tip.Value, got.Total.Value)
+	}
+
+	if _, ok := userBreakdown(snapshot, "carol"); ok {
+		t.Error("expected unknown user to be reported as not found")
+	}
+}
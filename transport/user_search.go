@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"splitzies/money"
+	"splitzies/persistence"
+)
+
+// Page size bounds for GET /users
+const (
+	defaultUserSearchPageSize = 20
+	maxUserSearchPageSize     = 100
+)
+
+// SearchUsersHandler handles listing every receipt a user name appears on, with that user's total on each
+// Expects GET /users?name=Dave&limit=20&after={user_id}
+// name is matched case-insensitively; next_cursor in the response is the "after" value for the next page
+func (t *Transport) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		http.Error(w, NewValidationError("name", "name is required").Error(), http.StatusBadRequest)
+		return
+	}
+	limit, after, err := parsePageQuery(r.URL.Query(), defaultUserSearchPageSize, maxUserSearchPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	matches, nextCursor, err := t.persistenceClient.SearchReceiptUsersByName(ctx, name, limit, after)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to search users: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]UserReceiptSummary, len(matches))
+	for i, m := range matches {
+		summary, err := t.userReceiptSummary(ctx, m)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to compute total for receipt %s: %v", m.User.ReceiptID, err), http.StatusInternalServerError)
+			return
+		}
+		results[i] = summary
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(SearchUsersResponse{Results: results, NextCursor: nextCursor}); err != nil {
+		fmt.Printf("Failed to encode response: %v\n", err)
+	}
+}
+
+// userReceiptSummary computes what the matched user owes on their receipt, the same way GET /receipts/{receipt_id} does
+func (t *Transport) userReceiptSummary(ctx context.Context, match persistence.ReceiptUserMatch) (UserReceiptSummary, error) {
+	receiptID := match.User.ReceiptID
+	snapshot, err := t.persistenceClient.GetReceiptSnapshot(ctx, receiptID)
+	if err != nil {
+		return UserReceiptSummary{}, err
+	}
+	currency := snapshot.Currency
+	if currency == nil || *currency == "" {
+		currency = &defaultUSD
+	}
+
+	split := ComputeBillSplit(snapshot.Users, snapshot.Items, splitAssignments(snapshot), snapshot.Rounding)
+	allocation := AllocateTaxTip(snapshot.Users, split, &snapshot.TaxTip)
+
+	return UserReceiptSummary{
+		UserID:       match.User.ID,
+		Name:         match.User.Name,
+		Color:        match.User.Color,
+		ReceiptID:    receiptID,
+		ReceiptTitle: match.ReceiptTitle,
+		CreatedAt:    match.ReceiptCreatedAt.Format(time.RFC3339),
+		Currency:     *currency,
+		UserTotal:    money.NewAmount(userGrandTotal(match.User.ID, split, allocation), currency),
+	}, nil
+}
@@ -0,0 +1,129 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// webhookSignatureHeader carries "sha256=<hex HMAC of the body>" keyed by WEBHOOK_SECRET
+	webhookSignatureHeader = "X-Splitzies-Signature"
+	webhookMaxAttempts     = 3
+	webhookTimeout         = 10 * time.Second
+)
+
+// ReceiptProcessedEvent is the webhook payload sent once background processing of an uploaded receipt finishes
+type ReceiptProcessedEvent struct {
+	Event     string `json:"event"`
+	ReceiptID string `json:"receipt_id"`
+	Status    string `json:"status"` // ready or failed
+	ItemCount int    `json:"item_count"`
+	// ParseStatus is ok, ocr_failed, parse_failed, no_items, or timeout; omitted when saving the parsed receipt failed
+	ParseStatus string `json:"parse_status,omitempty"`
+	// ItemsTruncated is set when parsing found more than MAX_RECEIPT_ITEMS items and only the first were saved
+	ItemsTruncated bool `json:"items_truncated,omitempty"`
+}
+
+// webhookNotifier POSTs signed events to a configured URL
+type webhookNotifier struct {
+	log     *slog.Logger
+	url     string
+	secret  []byte
+	client  *http.Client
+	backoff time.Duration // delay before the first retry; doubled for each later retry
+}
+
+// webhookNotifierFromEnv reads WEBHOOK_URL and WEBHOOK_SECRET.
+// Returns nil (webhooks disabled) when WEBHOOK_URL is unset, or when WEBHOOK_SECRET is missing
+// since receivers would have no way to verify the payload.
+func webhookNotifierFromEnv(log *slog.Logger) *webhookNotifier {
+	url := os.Getenv("WEBHOOK_URL")
+	if url == "" {
+		return nil
+	}
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		log.Warn("WEBHOOK_URL is set but WEBHOOK_SECRET is not, webhooks disabled")
+		return nil
+	}
+	return &webhookNotifier{
+		log:     log,
+		url:     url,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: webhookTimeout},
+		backoff: time.Second,
+	}
+}
+
+// notify sends event, logging rather than returning delivery failures.
+// Called from background receipt processing, so retries don't delay any response. A nil notifier does nothing.
+func (n *webhookNotifier) notify(ctx context.Context, event ReceiptProcessedEvent) {
+	if n == nil {
+		return
+	}
+	if err := n.send(ctx, event); err != nil {
+		n.log.Error("Failed to deliver webhook", "event", event.Event, "receipt_id", event.ReceiptID, "error", err)
+	}
+}
+
+// send POSTs event, retrying on network errors and non-2xx responses
+func (n *webhookNotifier) send(ctx context.Context, event ReceiptProcessedEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	signature := signWebhookPayload(n.secret, body)
+
+	delay := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, body, signature)
+		if err == nil {
+			return nil
+		}
+		if attempt == webhookMaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		n.log.Warn("Webhook delivery failed, retrying", "receipt_id", event.ReceiptID, "attempt", attempt, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+func (n *webhookNotifier) post(ctx context.Context, body []byte, signature string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, signature)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhookPayload returns the signature header value for body: "sha256=" followed by the hex HMAC-SHA256
+func signWebhookPayload(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookNotifierSignsAndRetries(t *testing.T) {
+	secret := []byte("shh")
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(webhookSignatureHeader), signWebhookPayload(secret, body); !hmac.Equal([]byte(got), []byte(want)) {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		var event ReceiptProcessedEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.ReceiptID != "r1" || event.ItemCount != 3 {
+			t.Errorf("payload = %s (err %v), want receipt r1 with 3 items", body, err)
+		}
+		if attempts < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := &webhookNotifier{log: slog.New(slog.NewTextHandler(io.Discard, nil)), url: server.URL, secret: secret, client: server.Client()}
+	if err := n.send(context.Background(), ReceiptProcessedEvent{Event: "receipt.processed", ReceiptID: "r1", ItemCount: 3}); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestWebhookNotifierGivesUp(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := &webhookNotifier{log: slog.New(slog.NewTextHandler(io.Discard, nil)), url: server.URL, secret: []byte("shh"), client: server.Client()}
+	if err := n.send(context.Background(), ReceiptProcessedEvent{ReceiptID: "r1"}); err == nil {
+		t.Fatal("send() error = nil, want error after exhausting retries")
+	}
+	if attempts != webhookMaxAttempts {
+		t.Errorf("attempts = %d, want %d", attempts, webhookMaxAttempts)
+	}
+}